@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/auth"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/sku"
+	"github.com/sirupsen/logrus"
+)
+
+//config settings shared by the subcommands, read from the environment
+type config struct {
+	port     string
+	grpcPort string
+	dbURI    string
+	//skuRules path of the JSON file defining the SKU rules. The default FAL- rule applies when it is empty
+	skuRules string
+	//userTokens path of the JSON file with the digests of the tokens of the users. Users are identified by the
+	//user header when it is empty
+	userTokens string
+}
+
+func loadConfig() config {
+	cfg := config{
+		port:       os.Getenv("PORT"),
+		grpcPort:   os.Getenv("GRPC_PORT"),
+		dbURI:      os.Getenv("DATABASE_URI"),
+		skuRules:   os.Getenv("SKU_RULES_FILE"),
+		userTokens: os.Getenv("USER_TOKENS_FILE"),
+	}
+
+	if cfg.port == "" {
+		cfg.port = "8080"
+	}
+
+	if cfg.grpcPort == "" {
+		cfg.grpcPort = "9090"
+	}
+
+	return cfg
+}
+
+//connects to the database. Only the subcommands using the database require DATABASE_URI
+func (c config) database() (*db.PostgreSQLDB, error) {
+	if c.dbURI == "" {
+		return nil, errors.New("DATABASE_URI environment variable not defined")
+	}
+
+	return db.NewPostgreSQLDB(c.dbURI)
+}
+
+//loads the SKU rules of the configuration file, or the default ones when there is no file
+func (c config) rules() (*sku.Rules, error) {
+	if c.skuRules == "" {
+		return sku.DefaultRules(), nil
+	}
+
+	return sku.Load(c.skuRules)
+}
+
+//loads the tokens of the users of the configuration file. Returns nil when the users are not authenticated
+func (c config) tokens() (*auth.Tokens, error) {
+	if c.userTokens == "" {
+		return nil, nil
+	}
+
+	return auth.Load(c.userTokens)
+}
+
+//reads a duration environment variable, returning def when it is not defined
+func durationEnv(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		logrus.Panicf("invalid %s: %v", name, err)
+	}
+
+	return d
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+//checks the health endpoint of the server running in this host. Used by the HEALTHCHECK of the docker image,
+//which has no shell nor curl
+func runHealthcheck(cfg config, _ []string) error {
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	resp, err := client.Get(fmt.Sprintf("http://localhost:%s/health", cfg.port))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unhealthy server: %s", resp.Status)
+	}
+
+	return nil
+}
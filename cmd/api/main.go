@@ -1,56 +1,66 @@
 package main
 
 import (
-	"context"
+	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
+	"sort"
 
 	_ "github.com/garciacer87/product-api/docs"
-
-	"github.com/garciacer87/product-api/internal/api"
-	"github.com/garciacer87/product-api/internal/db"
-	"github.com/sirupsen/logrus"
 )
 
+//command subcommand of the binary
+type command struct {
+	usage string
+	run   func(cfg config, args []string) error
+}
+
+var commands = map[string]command{
+	"serve":       {usage: "serve", run: runServe},
+	"migrate":     {usage: "migrate up | down [N|all] | status | force VERSION", run: runMigrate},
+	"import":      {usage: "import [-user USER] [-rule RULE | -seller SELLER] FILE", run: runImport},
+	"export":      {usage: "export", run: runExport},
+	"get":         {usage: "get SKU", run: runGet},
+	"delete":      {usage: "delete [-cascade] [-user USER] SKU", run: runDelete},
+	"validate":    {usage: "validate [-rule RULE | -seller SELLER] FILE", run: runValidate},
+	"healthcheck": {usage: "healthcheck", run: runHealthcheck},
+}
+
 // @title Product-API
 // @version 1.0.0
 // @description Basic API to manage CRUD operations on products
 // @contact.url https://github.com/garciacer87/product-api
 // @host http://localhost:8080
 // @BasePath /
+// @accept json
+// @produce json,application/xml,application/msgpack
 func main() {
-	port, ok := os.LookupEnv("PORT")
-	if !ok || port == "" {
-		port = "8080"
-		logrus.Println("env variable PORT not defined. Using default port 8080")
+	//the server is started when no subcommand is given
+	name, args := "serve", []string{}
+	if len(os.Args) > 1 {
+		name, args = os.Args[1], os.Args[2:]
 	}
 
-	dbURI := os.Getenv("DATABASE_URI")
-	if dbURI == "" {
-		logrus.Panicf("DATABASE_URI environment variable not defined")
+	cmd, ok := commands[name]
+	if !ok {
+		usage()
+		os.Exit(2)
 	}
 
-	db, err := db.NewPostgreSQLDB(dbURI)
-	if err != nil {
-		logrus.Panicf("could not initialize database: %v", err)
+	if err := cmd.run(loadConfig(), args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(1)
 	}
+}
 
-	srv := api.NewServer(port, db)
-
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGABRT, syscall.SIGTERM)
-
-	go func() {
-		if err := srv.ListenAndServe(); err != nil {
-			logrus.Error(err)
-		}
-	}()
-
-	s := <-signalChan
-	logrus.Infof("Signal triggered: %v", s)
-
-	srv.Shutdown(context.Background())
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	os.Exit(0)
+	fmt.Fprintln(os.Stderr, "usage: product-api <command> [arguments]\n\ncommands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/sirupsen/logrus"
+)
+
+const migrateUsage = "usage: product-api migrate up | down [N|all] | status | force VERSION"
+
+//runs the migrate subcommand with the embedded migrations
+func runMigrate(cfg config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if cfg.dbURI == "" {
+		return errors.New("DATABASE_URI environment variable not defined")
+	}
+
+	mg, err := newMigrator(cfg.dbURI)
+	if err != nil {
+		return err
+	}
+	defer mg.Close()
+
+	switch args[0] {
+	case "up":
+		return mg.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 && args[1] == "all" {
+			steps = 0
+		} else if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations to revert: %s", args[1])
+			}
+		}
+		return mg.Down(steps)
+	case "status":
+		status, err := mg.Status()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version: %d\nlatest: %d\ndirty: %t\n", status.Version, status.Latest, status.Dirty)
+		return nil
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		//-1 removes the version, as if no migration was applied
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < -1 {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+		return mg.Force(version)
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+//applies the pending migrations when MIGRATE_ON_START is true, and checks that the schema of the
+//database is the one expected by this version of the application
+func checkSchema(dbURI string) error {
+	mg, err := newMigrator(dbURI)
+	if err != nil {
+		return err
+	}
+	defer mg.Close()
+
+	if migrateOnStart, _ := strconv.ParseBool(os.Getenv("MIGRATE_ON_START")); migrateOnStart {
+		logrus.Info("Applying pending migrations")
+		if err := mg.Up(); err != nil {
+			return err
+		}
+	}
+
+	return mg.CheckVersion()
+}
+
+func newMigrator(dbURI string) (*db.Migrator, error) {
+	return db.NewMigrator(dbURI, durationEnv("MIGRATE_LOCK_TIMEOUT", time.Minute))
+}
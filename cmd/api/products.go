@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/validation"
+)
+
+//products read per query by the export
+const exportPageSize = 1000
+
+//creates or replaces the products of a JSON file, after validating all of them. Their status changes like in the API
+func runImport(cfg config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	user := flags.String("user", os.Getenv("USER"), "author of the changes")
+	rule := skuRuleFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: product-api import [-user USER] [-rule RULE | -seller SELLER] FILE")
+	}
+
+	prds, err := readProducts(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	v, ctx, err := rule(cfg)
+	if err != nil {
+		return err
+	}
+
+	store, err := cfg.database()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	stored, err := storedProducts(store, prds)
+	if err != nil {
+		return err
+	}
+
+	//products are approved by a second user through the API
+	approval, _ := strconv.ParseBool(os.Getenv("REQUIRE_APPROVAL"))
+
+	//the status of the products tells how they are validated, so it is settled first
+	var invalid int
+	for i := range prds {
+		if err := importStatus(&prds[i], stored[prds[i].SKU], approval); err != nil {
+			invalid++
+			fmt.Fprintf(os.Stderr, "product #%d (%s): %v\n", i+1, prds[i].SKU, err)
+		}
+	}
+
+	n, err := validateProducts(ctx, v, store, prds, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	//nothing is imported unless every product is valid
+	if invalid += n; invalid > 0 {
+		return fmt.Errorf("%d invalid product(s)", invalid)
+	}
+
+	var created, updated int
+	for _, prd := range prds {
+		prd.UpdatedBy = *user
+
+		existing := stored[prd.SKU]
+		if existing == nil {
+			_, err = store.Create(prd)
+			created++
+		} else {
+			_, err = store.UpdateFunc(prd.SKU, func(current *contract.Product) error {
+				//the product was validated with the status it had, which may have changed since then
+				if current.Status != existing.Status {
+					return fmt.Errorf("the status changed from %s to %s during the import", existing.Status, current.Status)
+				}
+
+				*current = prd
+				return nil
+			})
+			updated++
+		}
+
+		if err != nil {
+			return fmt.Errorf("product %s: %v", prd.SKU, err)
+		}
+	}
+
+	fmt.Printf("%d product(s) created, %d product(s) updated\n", created, updated)
+
+	return nil
+}
+
+//writes every product to the standard output as a JSON array, which can be imported again
+func runExport(cfg config, _ []string) error {
+	store, err := cfg.database()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	//the products are read in pages, so the whole catalog is never held in memory
+	filter := contract.ProductFilter{Limit: exportPageSize}
+	sep := "[\n"
+	for {
+		prds, err := store.List(filter)
+		if err != nil {
+			return err
+		}
+
+		for _, prd := range prds {
+			body, _ := json.Marshal(prd)
+			fmt.Fprintf(w, "%s  %s", sep, body)
+			sep = ",\n"
+		}
+
+		if len(prds) < exportPageSize {
+			break
+		}
+		filter.AfterSKU = prds[len(prds)-1].SKU
+	}
+
+	if sep == "[\n" {
+		fmt.Fprint(w, sep)
+	}
+	fmt.Fprint(w, "\n]\n")
+
+	return nil
+}
+
+//prints a product along with its availability
+func runGet(cfg config, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: product-api get SKU")
+	}
+
+	store, err := cfg.database()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	prd, err := store.Get(args[0])
+	if err != nil {
+		return err
+	}
+
+	if prd == nil {
+		return fmt.Errorf("product %s not found", args[0])
+	}
+
+	stocks, err := store.Stock(prd.SKU)
+	if err != nil {
+		return err
+	}
+	prd.Availability = contract.NewAvailability(stocks)
+
+	body, _ := json.MarshalIndent(prd, "", "  ")
+	fmt.Println(string(body))
+
+	return nil
+}
+
+//deletes a product. Products with stock are only deleted with -cascade, like in the API
+func runDelete(cfg config, args []string) error {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	cascade := flags.Bool("cascade", false, "deletes the stock and reservations of the product too")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: product-api delete [-cascade] SKU")
+	}
+	sku := flags.Arg(0)
+
+	store, err := cfg.database()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	prd, err := store.Get(sku)
+	if err != nil {
+		return err
+	}
+
+	if prd == nil {
+		return fmt.Errorf("product %s not found", sku)
+	}
+
+	if err := store.Delete(sku, *cascade); err != nil {
+		if errors.Is(err, db.ErrInStock) {
+			return errors.New("product has stock. Use -cascade to delete it along with its stock")
+		}
+		return err
+	}
+
+	fmt.Printf("product %s deleted\n", sku)
+
+	return nil
+}
+
+//validates the products of a JSON file with the rules of the API, without using the database
+func runValidate(cfg config, args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	rule := skuRuleFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: product-api validate [-rule RULE | -seller SELLER] FILE")
+	}
+
+	prds, err := readProducts(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	v, ctx, err := rule(cfg)
+	if err != nil {
+		return err
+	}
+
+	invalid, err := validateProducts(ctx, v, nil, prds, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d product(s) are invalid", invalid, len(prds))
+	}
+
+	fmt.Printf("%d product(s) are valid\n", len(prds))
+
+	return nil
+}
+
+//reads a JSON array of products from a file, or from the standard input when path is -
+func readProducts(path string) ([]contract.Product, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	prds := []contract.Product{}
+	if err := json.NewDecoder(r).Decode(&prds); err != nil {
+		return nil, fmt.Errorf("could not decode the products of %s: %v", path, err)
+	}
+
+	return prds, nil
+}
+
+//defines the flags selecting the SKU rule of the products. The returned function creates the validator and
+//the context selecting the rule once the flags are parsed
+func skuRuleFlags(flags *flag.FlagSet) func(cfg config) (*validation.Validator, context.Context, error) {
+	name := flags.String("rule", "", "name of the rule validating the SKUs")
+	seller := flags.String("seller", "", "seller whose rule validates the SKUs")
+
+	return func(cfg config) (*validation.Validator, context.Context, error) {
+		rules, err := cfg.rules()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rule, ok := rules.Select(*name, *seller)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown sku rule %s", *name)
+		}
+
+		return validation.NewWithRules(rules), validation.WithSKURule(context.Background(), rule), nil
+	}
+}
+
+//retrieves the stored products of the SKUs of the products, by SKU
+func storedProducts(store db.Database, prds []contract.Product) (map[string]*contract.Product, error) {
+	skus := make([]string, 0, len(prds))
+	for _, prd := range prds {
+		skus = append(skus, prd.SKU)
+	}
+
+	found, _, err := store.GetMany(skus)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make(map[string]*contract.Product, len(found))
+	for i := range found {
+		stored[found[i].SKU] = &found[i]
+	}
+
+	return stored, nil
+}
+
+//checks the status of an imported product can follow the one of the stored product, which is nil for new products,
+//and sets the status the product will have. Products without status keep the stored one, or are created as drafts
+func importStatus(prd *contract.Product, stored *contract.Product, approval bool) error {
+	var from string
+	if stored != nil {
+		from = stored.Status
+	}
+
+	to := prd.Status
+	if to == "" {
+		to = from
+	}
+	if to == "" {
+		to = contract.StatusDraft
+	}
+
+	if to != from && !contract.CanTransition(from, to) {
+		if from == "" {
+			return fmt.Errorf("products cannot be created with the status %s", to)
+		}
+		return fmt.Errorf("the status cannot change from %s to %s", from, to)
+	}
+
+	if approval && to == contract.StatusActive && from != contract.StatusActive {
+		return errors.New("products are not activated by the import when approval is required")
+	}
+
+	prd.Status = to
+	return nil
+}
+
+//writes the validation errors of the products, returning how many are invalid. Drafts can be incomplete, and the
+//attributes are validated with the schemas of their categories unless there are no schemas
+func validateProducts(ctx context.Context, v *validation.Validator, schemas db.CategorySchemas, prds []contract.Product,
+	w io.Writer) (int, error) {
+	var (
+		invalid    int
+		seen       = make(map[string]int, len(prds))
+		categories = make(map[string]*contract.CategorySchema)
+	)
+
+	for i, prd := range prds {
+		schema, ok := categories[prd.Category]
+		if !ok && schemas != nil && prd.Category != "" {
+			var err error
+			if schema, err = schemas.CategorySchema(prd.Category); err != nil && !errors.Is(err, db.ErrNotFound) {
+				return 0, err
+			}
+			categories[prd.Category] = schema
+		}
+
+		//without the schemas, the attributes cannot be told apart from unknown ones
+		if schemas == nil {
+			prd.Attributes = nil
+		}
+
+		errs := v.Product(ctx, prd, schema)
+
+		if j, ok := seen[prd.SKU]; ok && prd.SKU != "" {
+			errs = append(errs, fmt.Sprintf("sku is repeated from product #%d", j+1))
+		} else {
+			seen[prd.SKU] = i
+		}
+
+		if len(errs) > 0 {
+			invalid++
+			fmt.Fprintf(w, "product #%d (%s): %s\n", i+1, prd.SKU, strings.Join(errs, " | "))
+		}
+	}
+
+	return invalid, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/validation"
+)
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		content         string
+		errorExpected   bool
+		invalidExpected int
+	}{
+		"#1: valid products": {
+			content: `[{"sku":"FAL-1000000","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a"},
+				{"sku":"FAL-1000001","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a"}]`,
+		},
+		"#2: invalid product": {
+			content:         `[{"sku":"FAL-1","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a"}]`,
+			errorExpected:   true,
+			invalidExpected: 1,
+		},
+		"#3: repeated sku": {
+			content: `[{"sku":"FAL-1000000","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a"},
+				{"sku":"FAL-1000000","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a"}]`,
+			errorExpected:   true,
+			invalidExpected: 1,
+		},
+		"#4: invalid file": {
+			content:       `{"sku":"FAL-1000000"}`,
+			errorExpected: true,
+		},
+		"#5: incomplete draft": {
+			content: `[{"sku":"FAL-1000000","name":"name","status":"draft"}]`,
+		},
+		"#6: incomplete product": {
+			content:         `[{"sku":"FAL-1000000","name":"name","status":"active"}]`,
+			errorExpected:   true,
+			invalidExpected: 1,
+		},
+	}
+
+	for desc, tc := range tests {
+		path := filepath.Join(t.TempDir(), "products.json")
+		if err := os.WriteFile(path, []byte(tc.content), 0600); err != nil {
+			t.Fatalf("could not write the products file: %v", err)
+		}
+
+		err := runValidate(config{}, []string{path})
+		if (err != nil) != tc.errorExpected {
+			t.Errorf("%s:\n error got: %v", desc, err)
+		}
+
+		prds, err := readProducts(path)
+		if err != nil {
+			continue
+		}
+
+		var out bytes.Buffer
+		if invalid, _ := validateProducts(context.Background(), validation.New(), nil, prds, &out); invalid != tc.invalidExpected {
+			t.Errorf("%s:\n invalid products got: %d\n expected: %d\n output: %s", desc, invalid, tc.invalidExpected, out.String())
+		}
+	}
+}
+
+func TestImportStatus(t *testing.T) {
+	active := &contract.Product{Status: contract.StatusActive}
+	review := &contract.Product{Status: contract.StatusPendingReview}
+
+	tests := map[string]struct {
+		status         string
+		stored         *contract.Product
+		approval       bool
+		statusExpected string
+		errorExpected  bool
+	}{
+		"#1: created without status":    {statusExpected: contract.StatusDraft},
+		"#2: created as active":         {status: contract.StatusActive, statusExpected: contract.StatusActive},
+		"#3: created as archived":       {status: contract.StatusArchived, errorExpected: true},
+		"#4: updated without status":    {stored: active, statusExpected: contract.StatusActive},
+		"#5: updated with same status":  {status: contract.StatusActive, stored: active, statusExpected: contract.StatusActive},
+		"#6: valid transition":          {status: contract.StatusArchived, stored: active, statusExpected: contract.StatusArchived},
+		"#7: invalid transition":        {status: contract.StatusDraft, stored: active, errorExpected: true},
+		"#8: activated with approval":   {status: contract.StatusActive, stored: review, approval: true, errorExpected: true},
+		"#9: kept active with approval": {status: contract.StatusActive, stored: active, approval: true, statusExpected: contract.StatusActive},
+	}
+
+	for desc, tc := range tests {
+		prd := contract.Product{Status: tc.status}
+
+		err := importStatus(&prd, tc.stored, tc.approval)
+		if (err != nil) != tc.errorExpected {
+			t.Errorf("%s:\n error got: %v", desc, err)
+			continue
+		}
+
+		if err == nil && prd.Status != tc.statusExpected {
+			t.Errorf("%s:\n status got: %s\n expected: %s", desc, prd.Status, tc.statusExpected)
+		}
+	}
+}
+
+func TestGetArguments(t *testing.T) {
+	if err := runGet(config{}, nil); err == nil {
+		t.Errorf("usage error expected without sku")
+	}
+
+	if err := runGet(config{}, []string{"FAL-1000000"}); err == nil {
+		t.Errorf("error expected without DATABASE_URI")
+	}
+}
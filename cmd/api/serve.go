@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/api"
+	"github.com/garciacer87/product-api/internal/cache"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/egress"
+	"github.com/garciacer87/product-api/internal/i18n"
+	"github.com/garciacer87/product-api/internal/imagecheck"
+	"github.com/garciacer87/product-api/internal/media"
+	"github.com/garciacer87/product-api/internal/outbox"
+	"github.com/garciacer87/product-api/internal/rpc"
+	"github.com/garciacer87/product-api/internal/storage"
+	"github.com/garciacer87/product-api/internal/webhook"
+	"github.com/sirupsen/logrus"
+)
+
+//starts the http and gRPC servers and the background workers until a termination signal is received
+func runServe(cfg config, _ []string) error {
+	if cfg.dbURI == "" {
+		return fmt.Errorf("DATABASE_URI environment variable not defined")
+	}
+
+	if err := checkSchema(cfg.dbURI); err != nil {
+		return fmt.Errorf("could not start with the current database schema: %v", err)
+	}
+
+	db, err := cfg.database()
+	if err != nil {
+		return fmt.Errorf("could not initialize database: %v", err)
+	}
+
+	rules, err := cfg.rules()
+	if err != nil {
+		return err
+	}
+
+	sweepInterval := durationEnv("RESERVATION_SWEEP_INTERVAL", time.Minute)
+
+	opts := []api.Option{
+		api.WithInventory(db, sweepInterval),
+		api.WithWebhooks(db),
+		api.WithCategorySchemas(db),
+		api.WithRevisions(db),
+		api.WithSKURules(rules),
+		api.WithSKUGeneration(db),
+		api.WithIdempotency(db, durationEnv("IDEMPOTENCY_TTL", 24*time.Hour)),
+		api.WithEventLog(db, durationEnv("EVENTS_POLL_INTERVAL", time.Second)),
+	}
+
+	objects, imagesURL, err := imageStorage(cfg.port)
+	if err != nil {
+		return fmt.Errorf("could not initialize the image storage: %v", err)
+	}
+
+	images, err := imagesOption(db, objects, imagesURL)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, images)
+
+	//the image checker is optional, since it sends requests to the servers of the images
+	checkInterval := durationEnv("IMAGE_CHECK_INTERVAL", 0)
+	if checkInterval > 0 {
+		opts = append(opts, api.WithImageReport(db))
+	}
+
+	if v := os.Getenv("EVENTS_REPLAY_SIZE"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 1 {
+			logrus.Panicf("invalid EVENTS_REPLAY_SIZE: %s", v)
+		}
+		opts = append(opts, api.WithEventReplay(size))
+	}
+
+	if v := os.Getenv("DEFAULT_LANGUAGE"); v != "" {
+		lang, err := i18n.Canonical(v)
+		if err != nil {
+			logrus.Panicf("invalid DEFAULT_LANGUAGE: %s", v)
+		}
+		opts = append(opts, api.WithDefaultLanguage(lang))
+	}
+
+	tokens, err := cfg.tokens()
+	if err != nil {
+		return err
+	}
+
+	grpcOpts := []rpc.Option{rpc.WithSKURules(rules), rpc.WithCategorySchemas(db)}
+	if tokens != nil {
+		opts = append(opts, api.WithAuthentication(tokens))
+		grpcOpts = append(grpcOpts, rpc.WithAuthentication(tokens))
+	}
+
+	if approval, _ := strconv.ParseBool(os.Getenv("REQUIRE_APPROVAL")); approval {
+		//the approvals are only meaningful when the reviewers cannot pretend to be someone else
+		if tokens == nil {
+			return errors.New("REQUIRE_APPROVAL requires the users to be authenticated with USER_TOKENS_FILE")
+		}
+		opts = append(opts, api.WithApproval())
+	}
+
+	//an empty policy disables the Cache-Control header of the route
+	if v, ok := os.LookupEnv("CACHE_CONTROL_PRODUCT"); ok {
+		opts = append(opts, api.WithCacheControl(api.RouteProduct, v))
+	}
+	if v, ok := os.LookupEnv("CACHE_CONTROL_PRODUCTS"); ok {
+		opts = append(opts, api.WithCacheControl(api.RouteProducts, v))
+	}
+
+	products, brands, cacheOpts := newProductCache(db)
+	opts = append(opts, api.WithBrands(brands))
+	opts = append(opts, cacheOpts...)
+
+	srv := api.NewServer(cfg.port, products, opts...)
+	grpcSrv := rpc.NewServer(cfg.grpcPort, products, grpcOpts...)
+
+	//background workers using the database are stopped before the server closes it
+	var (
+		workers     sync.WaitGroup
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+
+	relay := newOutboxRelay(db)
+	dispatcher := webhook.NewDispatcher(db, egress.NewClient(10*time.Second), durationEnv("WEBHOOK_POLL_INTERVAL", 5*time.Second))
+
+	runs := []func(context.Context){relay.Run, dispatcher.Run}
+	if checkInterval > 0 {
+		runs = append(runs, newImageChecker(db, objects, imagesURL, checkInterval).Run)
+	}
+
+	for _, run := range runs {
+		workers.Add(1)
+		go func(run func(context.Context)) {
+			defer workers.Done()
+			run(ctx)
+		}(run)
+	}
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGABRT, syscall.SIGTERM)
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	go func() {
+		if err := grpcSrv.ListenAndServe(); err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	s := <-signalChan
+	logrus.Infof("Signal triggered: %v", s)
+
+	cancel()
+	workers.Wait()
+
+	//the http server closes the database, so it is the last one to shut down
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 30*time.Second)
+	grpcSrv.Shutdown(shutdownCtx)
+	cancelShutdown()
+
+	return srv.Shutdown(context.Background())
+}
+
+//creates the relay of product events for the webhook subscriptions and the sinks listed in OUTBOX_SINKS
+func newOutboxRelay(store *db.PostgreSQLDB) *outbox.Relay {
+	sinks := []outbox.Sink{webhook.NewSink(store)}
+
+	for _, name := range strings.Split(os.Getenv("OUTBOX_SINKS"), ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "stdout":
+			sinks = append(sinks, outbox.NewStdoutSink(os.Stdout))
+		case "webhook":
+			url := os.Getenv("OUTBOX_WEBHOOK_URL")
+			if url == "" {
+				logrus.Panicf("OUTBOX_WEBHOOK_URL environment variable not defined")
+			}
+			sinks = append(sinks, outbox.NewWebhookSink(url, &http.Client{Timeout: 10 * time.Second}))
+		default:
+			logrus.Panicf("unknown outbox sink: %s", name)
+		}
+	}
+
+	return outbox.NewRelay(store, durationEnv("OUTBOX_POLL_INTERVAL", time.Second), sinks...)
+}
+
+//creates the storage of the product images, the IMAGE_DIR directory or the bucket of an S3-compatible service
+//depending on IMAGE_STORAGE, and the base URL they are served from
+func imageStorage(port string) (storage.Storage, string, error) {
+	var (
+		objects storage.Storage
+		err     error
+	)
+
+	switch v := os.Getenv("IMAGE_STORAGE"); v {
+	case "", "local":
+		dir := os.Getenv("IMAGE_DIR")
+		if dir == "" {
+			dir = "images"
+		}
+		objects, err = storage.NewLocal(dir)
+	case "s3":
+		objects, err = storage.NewS3(storage.S3Config{
+			Endpoint:  os.Getenv("IMAGE_S3_ENDPOINT"),
+			Region:    os.Getenv("IMAGE_S3_REGION"),
+			Bucket:    os.Getenv("IMAGE_S3_BUCKET"),
+			AccessKey: os.Getenv("IMAGE_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("IMAGE_S3_SECRET_KEY"),
+		}, &http.Client{Timeout: 30 * time.Second})
+	default:
+		return nil, "", fmt.Errorf("unknown IMAGE_STORAGE: %s", v)
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	baseURL := os.Getenv("IMAGE_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port
+	}
+
+	return objects, baseURL, nil
+}
+
+//enables the product images, stored in objects and served from baseURL
+func imagesOption(store *db.PostgreSQLDB, objects storage.Storage, baseURL string) (api.Option, error) {
+	var err error
+
+	limits := media.DefaultLimits
+	if v := os.Getenv("IMAGE_MAX_SIZE"); v != "" {
+		if limits.MaxSize, err = strconv.ParseInt(v, 10, 64); err != nil || limits.MaxSize < 1 {
+			return nil, fmt.Errorf("invalid IMAGE_MAX_SIZE: %s", v)
+		}
+	}
+
+	return api.WithImages(store, objects, baseURL, limits), nil
+}
+
+//creates the checker of the image URLs configured by the IMAGE_CHECK_* environment variables. The images uploaded
+//to the API are checked against their storage
+func newImageChecker(store *db.PostgreSQLDB, objects storage.Storage, imagesURL string, interval time.Duration) *imagecheck.Checker {
+	checker := imagecheck.NewChecker(store, egress.NewClient(30*time.Second), interval)
+	checker.Uploaded(imagesURL, objects)
+	checker.RecheckAfter = durationEnv("IMAGE_CHECK_RECHECK_AFTER", checker.RecheckAfter)
+
+	if v := os.Getenv("IMAGE_CHECK_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			logrus.Panicf("invalid IMAGE_CHECK_CONCURRENCY: %s", v)
+		}
+		checker.Concurrency = n
+	}
+
+	if v := os.Getenv("IMAGE_CHECK_RATE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 {
+			logrus.Panicf("invalid IMAGE_CHECK_RATE: %s", v)
+		}
+		checker.Rate = rate
+	}
+
+	return checker
+}
+
+//wraps the database with the product cache unless CACHE_SIZE is 0. The brand registry is wrapped too, since
+//renaming a brand renames its products
+func newProductCache(store *db.PostgreSQLDB) (db.Database, db.Brands, []api.Option) {
+	size := 10000
+	if v := os.Getenv("CACHE_SIZE"); v != "" {
+		var err error
+		if size, err = strconv.Atoi(v); err != nil || size < 0 {
+			logrus.Panicf("invalid CACHE_SIZE: %s", v)
+		}
+	}
+
+	if size == 0 {
+		return store, store, nil
+	}
+
+	c := cache.New(store, size, durationEnv("CACHE_TTL", time.Minute), durationEnv("CACHE_NEGATIVE_TTL", 10*time.Second))
+
+	return c, c.WrapBrands(store), []api.Option{api.WithCacheStats(c)}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/sirupsen/logrus"
+)
+
+// getBrands godoc
+// @Summary Retrieves the brands
+// @Description Retrieves the brands of the registry ordered by name
+// @Tags brand
+// @Success 200 {array} contract.Brand
+// @Failure 500 {object} contract.Response{status=int,message=object}
+// @Router /brands [get]
+func (s *server) getBrands(w http.ResponseWriter, _ *http.Request) {
+	brands, err := s.brands.Brands()
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not get brands")
+		return
+	}
+
+	body, _ := json.Marshal(brands)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// getBrand godoc
+// @Summary Get a brand
+// @Description Get a brand of the registry by its id
+// @Tags brand
+// @Success 200 {object} contract.Brand
+// @Failure 400,404,500 {object} contract.Response{status=int,message=object}
+// @Param id path int true "brand id"
+// @Router /brands/{id} [get]
+func (s *server) getBrand(w http.ResponseWriter, req *http.Request) {
+	id, ok := pathID(w, req)
+	if !ok {
+		return
+	}
+
+	brand, err := s.brands.Brand(id)
+	if err != nil {
+		writeBrandError(w, err, "could not get brand")
+		return
+	}
+
+	body, _ := json.Marshal(brand)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// createBrand godoc
+// @Summary Registers a brand
+// @Description Registers a brand with its canonical name and the aliases resolving it. The slug is generated from
+// @Description the name when it is not given
+// @Tags brand
+// @Accept json
+// @Success 201 {object} contract.Brand
+// @Header 201 {string} Location "path of the created brand"
+// @Failure 400,409,500 {object} contract.Response{status=int,message=object}
+// @Param brand body contract.Brand true "brand"
+// @Param X-User header string false "user registering the brand"
+// @Router /brands [post]
+func (s *server) createBrand(w http.ResponseWriter, req *http.Request) {
+	brand := contract.Brand{}
+	if !s.decodeAndValidate(w, req, &brand) {
+		return
+	}
+	brand.UpdatedBy = actor(req)
+
+	created, err := s.brands.CreateBrand(brand)
+	if err != nil {
+		writeBrandError(w, err, "could not create brand")
+		return
+	}
+
+	logrus.Infof("Brand %d created", created.ID)
+	body, _ := json.Marshal(created)
+	w.Header().Set("Location", brandPath(created.ID))
+	writeJSONResponse(w, http.StatusCreated, body)
+}
+
+// replaceBrand godoc
+// @Summary Replaces a brand
+// @Description Replaces the name, slug and aliases of a brand. The products of a renamed brand take the new name,
+// @Description and the former name is kept as an alias. The slug is kept when it is not given
+// @Tags brand
+// @Accept json
+// @Success 200 {object} contract.Brand
+// @Failure 400,404,409,500 {object} contract.Response{status=int,message=object}
+// @Param id path int true "brand id"
+// @Param brand body contract.Brand true "brand. The id is optional, but must be the one of the path when given"
+// @Param X-User header string false "user replacing the brand"
+// @Router /brands/{id} [put]
+func (s *server) replaceBrand(w http.ResponseWriter, req *http.Request) {
+	id, ok := pathID(w, req)
+	if !ok {
+		return
+	}
+
+	brand := contract.Brand{}
+	if !s.decodeAndValidate(w, req, &brand) {
+		return
+	}
+
+	if brand.ID != 0 && brand.ID != id {
+		writeResponse(w, http.StatusBadRequest, "the id of the body does not match the id of the path")
+		return
+	}
+	brand.ID, brand.UpdatedBy = id, actor(req)
+
+	updated, err := s.brands.UpdateBrand(brand)
+	if err != nil {
+		writeBrandError(w, err, "could not update brand")
+		return
+	}
+
+	logrus.Infof("Brand %d updated", id)
+	body, _ := json.Marshal(updated)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// deleteBrand godoc
+// @Summary Deletes a brand
+// @Description Deletes a brand of the registry. Brands cannot be deleted while products reference them
+// @Tags brand
+// @Success 200 {object} contract.Response{status=int,message=object}
+// @Failure 400,404,409,500 {object} contract.Response{status=int,message=object}
+// @Param id path int true "brand id"
+// @Router /brands/{id} [delete]
+func (s *server) deleteBrand(w http.ResponseWriter, req *http.Request) {
+	id, ok := pathID(w, req)
+	if !ok {
+		return
+	}
+
+	if err := s.brands.DeleteBrand(id); err != nil {
+		writeBrandError(w, err, "could not delete brand")
+		return
+	}
+
+	logrus.Infof("Brand %d deleted", id)
+	writeResponse(w, http.StatusOK, "brand successfully deleted")
+}
+
+// getBrandFacets godoc
+// @Summary Counts the products of each brand
+// @Description Retrieves the brands with the number of their products, ordered by number of products. The brands
+// @Description without products are left out. Requests without user header only count the active products
+// @Tags brand
+// @Success 200 {array} contract.BrandFacet
+// @Failure 400,500 {object} contract.Response{status=int,message=object}
+// @Param category query string false "category of the products counted"
+// @Param status query string false "status of the products counted. Ignored without user header"
+// @Param X-User header string false "user reading the facets"
+// @Router /brands/facets [get]
+func (s *server) getBrandFacets(w http.ResponseWriter, req *http.Request) {
+	filter := contract.ProductFilter{Category: req.URL.Query().Get("category")}
+
+	switch status := req.URL.Query().Get("status"); {
+	case actor(req) == "":
+		//anonymous requests only count the active products
+		filter.Status = contract.StatusActive
+	case status == "" || contract.IsStatus(status):
+		filter.Status = status
+	default:
+		writeResponse(w, http.StatusBadRequest, fmt.Sprintf("unknown status %s", status))
+		return
+	}
+
+	facets, err := s.brands.BrandFacets(filter)
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not get brand facets")
+		return
+	}
+
+	w.Header().Add("Vary", userHeader)
+	body, _ := json.Marshal(facets)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+//writes the error of a brand operation
+func writeBrandError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		writeResponse(w, http.StatusNotFound, "brand not found")
+	case errors.Is(err, db.ErrAlreadyExists):
+		writeResponse(w, http.StatusConflict, "the name, an alias or the slug belongs to another brand")
+	case errors.Is(err, db.ErrInUse):
+		writeResponse(w, http.StatusConflict, "products reference the brand")
+	default:
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, msg)
+	}
+}
+
+//path of a brand
+func brandPath(id int64) string {
+	return fmt.Sprintf("/brands/%d", id)
+}
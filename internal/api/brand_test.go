@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestBrands(t *testing.T) {
+	store := &mockBrands{inUse: map[int64]bool{1: true}}
+	srv := NewServer("8081", &mockDB{prdCount: 1}, WithBrands(store))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	//the steps share the store, so they run in order
+	tests := []struct {
+		desc            string
+		method          string
+		path            string
+		body            string
+		throwError      bool
+		statusExpected  int
+		messageExpected string
+	}{
+		{desc: "#1: created", method: http.MethodPost, path: "/brands", body: `{"name":"Nike","aliases":["Nike Inc"]}`,
+			statusExpected: http.StatusCreated, messageExpected: `"slug":"nike"`},
+		{desc: "#2: alias taken", method: http.MethodPost, path: "/brands", body: `{"name":"NIKE INC"}`, statusExpected: http.StatusConflict},
+		{desc: "#3: invalid name", method: http.MethodPost, path: "/brands", body: `{"name":"N"}`, statusExpected: http.StatusBadRequest,
+			messageExpected: "Name must be at least 3 characters in length"},
+		{desc: "#4: invalid slug", method: http.MethodPost, path: "/brands", body: `{"name":"Adidas","slug":"Adidas"}`, statusExpected: http.StatusBadRequest},
+		{desc: "#5: created with slug", method: http.MethodPost, path: "/brands", body: `{"name":"Adidas","slug":"adidas-originals"}`,
+			statusExpected: http.StatusCreated, messageExpected: `"slug":"adidas-originals"`},
+		{desc: "#6: read", method: http.MethodGet, path: "/brands/1", statusExpected: http.StatusOK, messageExpected: `"aliases":["Nike Inc"]`},
+		{desc: "#7: listed", method: http.MethodGet, path: "/brands", statusExpected: http.StatusOK, messageExpected: `"name":"Adidas"`},
+		{desc: "#8: renamed", method: http.MethodPut, path: "/brands/1", body: `{"name":"Nike Sportswear"}`, statusExpected: http.StatusOK,
+			messageExpected: `"slug":"nike"`},
+		{desc: "#9: id mismatch", method: http.MethodPut, path: "/brands/1", body: `{"id":2,"name":"Nike"}`, statusExpected: http.StatusBadRequest},
+		{desc: "#10: renamed as another brand", method: http.MethodPut, path: "/brands/1", body: `{"name":"adidas"}`, statusExpected: http.StatusConflict},
+		{desc: "#11: unknown brand", method: http.MethodPut, path: "/brands/9", body: `{"name":"Puma"}`, statusExpected: http.StatusNotFound},
+		{desc: "#12: facets", method: http.MethodGet, path: "/brands/facets", statusExpected: http.StatusOK,
+			messageExpected: `[{"id":1,"name":"Nike Sportswear","slug":"nike","products":1}]`},
+		{desc: "#13: brand in use", method: http.MethodDelete, path: "/brands/1", statusExpected: http.StatusConflict},
+		{desc: "#14: deleted", method: http.MethodDelete, path: "/brands/2", statusExpected: http.StatusOK},
+		{desc: "#15: deleted brand", method: http.MethodGet, path: "/brands/2", statusExpected: http.StatusNotFound},
+		{desc: "#16: database error", method: http.MethodGet, path: "/brands", throwError: true, statusExpected: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		store.throwError = tc.throwError
+
+		req, _ := http.NewRequest(tc.method, "http://localhost:8081"+tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userHeader, "merchandiser")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected || !strings.Contains(string(body), tc.messageExpected) {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v %s", tc.desc, resp.StatusCode, body, tc.statusExpected, tc.messageExpected)
+		}
+
+		if tc.statusExpected == http.StatusCreated && !strings.HasPrefix(resp.Header.Get("Location"), "/brands/") {
+			t.Errorf("%s: Location got: %q", tc.desc, resp.Header.Get("Location"))
+		}
+	}
+}
+
+func TestBrandFacets(t *testing.T) {
+	store := &mockBrands{}
+	srv := NewServer("8081", &mockDB{prdCount: 1}, WithBrands(store))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	tests := map[string]struct {
+		query          string
+		user           string
+		statusExpected int
+		filterExpected contract.ProductFilter
+	}{
+		"#1: anonymous":             {query: "?status=draft", statusExpected: http.StatusOK, filterExpected: contract.ProductFilter{Status: contract.StatusActive}},
+		"#2: user":                  {user: "editor", statusExpected: http.StatusOK},
+		"#3: user by status":        {query: "?status=draft&category=shoes", user: "editor", statusExpected: http.StatusOK, filterExpected: contract.ProductFilter{Status: contract.StatusDraft, Category: "shoes"}},
+		"#4: unknown status":        {query: "?status=sold", user: "editor", statusExpected: http.StatusBadRequest},
+		"#5: anonymous by category": {query: "?category=shoes", statusExpected: http.StatusOK, filterExpected: contract.ProductFilter{Status: contract.StatusActive, Category: "shoes"}},
+	}
+
+	for desc, tc := range tests {
+		store.mu.Lock()
+		store.filter = contract.ProductFilter{}
+		store.mu.Unlock()
+
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost:8081/brands/facets"+tc.query, nil)
+		if tc.user != "" {
+			req.Header.Set(userHeader, tc.user)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Got: %v\n Expected: %v", desc, resp.StatusCode, tc.statusExpected)
+			continue
+		}
+
+		store.mu.Lock()
+		filter := store.filter
+		store.mu.Unlock()
+
+		if filter.Status != tc.filterExpected.Status || filter.Category != tc.filterExpected.Category {
+			t.Errorf("%s:\n Filter got: %+v\n Expected: %+v", desc, filter, tc.filterExpected)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	//attributeParam prefix of the query parameters filtering the products by their custom attributes
+	attributeParam = "attr."
+	//maxSchemaConflicts maximum number of products reported when a schema does not fit the stored products
+	maxSchemaConflicts = 10
+)
+
+// getCategorySchemas godoc
+// @Summary Retrieves the category schemas
+// @Description Retrieves the schemas of the custom attributes of every category
+// @Tags category
+// @Success 200 {array} contract.CategorySchema
+// @Failure 500 {object} contract.Response{status=int,message=object}
+// @Router /categories [get]
+func (s *server) getCategorySchemas(w http.ResponseWriter, _ *http.Request) {
+	schemas, err := s.categories.CategorySchemas()
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not get category schemas")
+		return
+	}
+
+	body, _ := json.Marshal(schemas)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// getCategorySchema godoc
+// @Summary Get a category schema
+// @Description Get the schema of the custom attributes of a category
+// @Tags category
+// @Success 200 {object} contract.CategorySchema
+// @Failure 404,500 {object} contract.Response{status=int,message=object}
+// @Param category path string true "category slug"
+// @Router /categories/{category} [get]
+func (s *server) getCategorySchema(w http.ResponseWriter, req *http.Request) {
+	category := mux.Vars(req)["category"]
+
+	schema, err := s.categories.CategorySchema(category)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeResponse(w, http.StatusNotFound, "category schema not found")
+			return
+		}
+
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not get category schema")
+		return
+	}
+
+	body, _ := json.Marshal(schema)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// putCategorySchema godoc
+// @Summary Creates or replaces a category schema
+// @Description Defines the custom attributes allowed for the products of a category. A schema cannot be replaced
+// @Description while stored products of the category do not fit it, which are listed in the conflict response
+// @Tags category
+// @Accept json
+// @Success 200 {object} contract.CategorySchema
+// @Success 201 {object} contract.CategorySchema
+// @Failure 400,409,500 {object} contract.Response{status=int,message=object}
+// @Param category path string true "category slug"
+// @Param schema body contract.CategorySchema true "schema. The category is optional, but must be the one of the path when given"
+// @Router /categories/{category} [put]
+func (s *server) putCategorySchema(w http.ResponseWriter, req *http.Request) {
+	var (
+		category = mux.Vars(req)["category"]
+		schema   = contract.CategorySchema{}
+	)
+
+	if !decodeBody(w, req, &schema) {
+		return
+	}
+
+	if schema.Category != "" && schema.Category != category {
+		writeResponse(w, http.StatusBadRequest, "the category of the body does not match the category of the path")
+		return
+	}
+	schema.Category = category
+	schema.UpdatedBy = actor(req)
+
+	if errs := s.validator.Schema(req.Context(), schema); len(errs) > 0 {
+		logrus.Printf("Validation error(s):\n%s", strings.Join(errs, " | "))
+		writeResponse(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	//the stored products must keep being valid with the new schema
+	prds, err := s.db.List(contract.ProductFilter{Category: category})
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not check the products of the category")
+		return
+	}
+
+	var conflicts []string
+	for _, prd := range prds {
+		errs := s.validator.Attributes(req.Context(), category, &schema, prd.Attributes, prd.Status != contract.StatusDraft)
+		if len(errs) == 0 {
+			continue
+		}
+
+		conflicts = append(conflicts, fmt.Sprintf("%s: %s", prd.SKU, strings.Join(errs, " | ")))
+		if len(conflicts) == maxSchemaConflicts {
+			break
+		}
+	}
+
+	if len(conflicts) > 0 {
+		writeResponse(w, http.StatusConflict, conflicts)
+		return
+	}
+
+	stored, created, err := s.categories.PutCategorySchema(schema)
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not store category schema")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	logrus.Infof("Schema of the category %s stored", category)
+	body, _ := json.Marshal(stored)
+	writeJSONResponse(w, status, body)
+}
+
+// deleteCategorySchema godoc
+// @Summary Deletes a category schema
+// @Description Deletes the schema of a category. Schemas cannot be deleted while products of the category have attributes
+// @Tags category
+// @Success 200 {object} contract.Response{status=int,message=object}
+// @Failure 404,409,500 {object} contract.Response{status=int,message=object}
+// @Param category path string true "category slug"
+// @Router /categories/{category} [delete]
+func (s *server) deleteCategorySchema(w http.ResponseWriter, req *http.Request) {
+	category := mux.Vars(req)["category"]
+
+	if err := s.categories.DeleteCategorySchema(category); err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			writeResponse(w, http.StatusNotFound, "category schema not found")
+		case errors.Is(err, db.ErrInUse):
+			writeResponse(w, http.StatusConflict, "products of the category have attributes")
+		default:
+			logrus.Errorf("db error: %v", err)
+			writeResponse(w, http.StatusInternalServerError, "could not delete category schema")
+		}
+		return
+	}
+
+	logrus.Infof("Schema of the category %s deleted", category)
+	writeResponse(w, http.StatusOK, "category schema successfully deleted")
+}
+
+//retrieves the schema of the category, or nil when the category has none
+func (s *server) categorySchema(category string) (*contract.CategorySchema, error) {
+	if category == "" || s.categories == nil {
+		return nil, nil
+	}
+
+	schema, err := s.categories.CategorySchema(category)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+
+	return schema, err
+}
+
+//reports if the query filters the products by their custom attributes
+func hasAttributeFilters(query url.Values) bool {
+	for param := range query {
+		if strings.HasPrefix(param, attributeParam) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//parses the attribute filters of the query, attr.<key>=value, attr.<key>.min=n and attr.<key>.max=n, with the types
+//of the schema of the category. Returns the message of the first invalid filter
+func parseAttributeFilters(query url.Values, category string, schema *contract.CategorySchema) ([]contract.AttributeFilter, string) {
+	if category == "" {
+		return nil, "the attribute filters require a category"
+	}
+
+	params := make([]string, 0, len(query))
+	for param := range query {
+		if strings.HasPrefix(param, attributeParam) {
+			params = append(params, param)
+		}
+	}
+	sort.Strings(params)
+
+	var (
+		filters []contract.AttributeFilter
+		byKey   = make(map[string]int)
+	)
+
+	for _, param := range params {
+		key := strings.TrimPrefix(param, attributeParam)
+		bound := ""
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			key, bound = key[:i], key[i+1:]
+		}
+
+		var def *contract.AttributeDefinition
+		if schema != nil {
+			def, _ = schema.Attribute(key)
+		}
+		if def == nil {
+			return nil, fmt.Sprintf("%s is not an attribute of the category %s", key, category)
+		}
+
+		i, ok := byKey[key]
+		if !ok {
+			i = len(filters)
+			byKey[key] = i
+			filters = append(filters, contract.AttributeFilter{Key: key})
+		}
+
+		value := query.Get(param)
+
+		switch bound {
+		case "":
+			v, ok := parseAttribute(def.Type, value)
+			if !ok {
+				return nil, fmt.Sprintf("%s must be of type %s", param, def.Type)
+			}
+			filters[i].Equals = v
+		case "min", "max":
+			if def.Type != contract.AttributeNumber && def.Type != contract.AttributeInteger {
+				return nil, fmt.Sprintf("%s is only allowed for numeric attributes", param)
+			}
+
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Sprintf("%s must be a number", param)
+			}
+
+			if bound == "min" {
+				filters[i].Min = &n
+			} else {
+				filters[i].Max = &n
+			}
+		default:
+			return nil, fmt.Sprintf("unknown attribute filter %s", param)
+		}
+	}
+
+	return filters, ""
+}
+
+//parses the value of an attribute of the given type, with the representation it has once decoded from JSON
+func parseAttribute(typ, value string) (interface{}, bool) {
+	switch typ {
+	case contract.AttributeString:
+		return value, true
+	case contract.AttributeNumber, contract.AttributeInteger:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || (typ == contract.AttributeInteger && n != math.Trunc(n)) {
+			return nil, false
+		}
+		return n, true
+	case contract.AttributeBoolean:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, false
+		}
+		return b, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestPutCategorySchema(t *testing.T) {
+	var (
+		mdb   = &mockDB{prdCount: 1}
+		store = &mockCategories{}
+	)
+	srv := NewServer("8081", mdb, WithCategorySchemas(store))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	const schema = `{"attributes":[{"key":"color","type":"string","values":["black","white"]},{"key":"size","type":"integer","required":true,"min":20,"max":50}]}`
+
+	//the steps share the store, so they run in order
+	tests := []struct {
+		desc            string
+		category        string
+		body            string
+		attributes      map[string]interface{}
+		throwError      bool
+		statusExpected  int
+		messageExpected string
+	}{
+		{desc: "#1: created", category: "shoes", body: schema, attributes: map[string]interface{}{"size": 42.0}, statusExpected: http.StatusCreated},
+		{desc: "#2: replaced", category: "shoes", body: schema, attributes: map[string]interface{}{"size": 42.0}, statusExpected: http.StatusOK},
+		{desc: "#3: products not fitting the schema", category: "shoes", body: schema, attributes: map[string]interface{}{"size": 60.0},
+			statusExpected: http.StatusConflict, messageExpected: "FAL-1000000: Attributes[size] must be 50 or less"},
+		{desc: "#4: category mismatch", category: "shoes", body: `{"category":"boots"}`, statusExpected: http.StatusBadRequest},
+		{desc: "#5: invalid category", category: "Shoes", body: schema, statusExpected: http.StatusBadRequest, messageExpected: "Category must only have lowercase letters, digits and hyphens"},
+		{desc: "#6: invalid type", category: "shoes", body: `{"attributes":[{"key":"color","type":"text"}]}`, statusExpected: http.StatusBadRequest},
+		{desc: "#7: duplicated attribute", category: "shoes", body: `{"attributes":[{"key":"color","type":"string"},{"key":"color","type":"string"}]}`,
+			statusExpected: http.StatusBadRequest, messageExpected: "Attributes[color] is defined more than once"},
+		{desc: "#8: option of another type", category: "shoes", body: `{"attributes":[{"key":"size","type":"integer","pattern":"[0-9]+"}]}`,
+			statusExpected: http.StatusBadRequest, messageExpected: "Attributes[size] cannot have pattern with the type integer"},
+		{desc: "#9: database error", category: "boots", body: schema, attributes: map[string]interface{}{"size": 42.0}, throwError: true, statusExpected: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		mdb.attributes, store.throwError = tc.attributes, tc.throwError
+
+		req, _ := http.NewRequest(http.MethodPut, "http://localhost:8081/categories/"+tc.category, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userHeader, "merchandiser")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected || !strings.Contains(string(body), tc.messageExpected) {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v %s", tc.desc, resp.StatusCode, body, tc.statusExpected, tc.messageExpected)
+		}
+	}
+
+	store.throwError = false
+	stored, err := store.CategorySchema("shoes")
+	if err != nil || stored.UpdatedBy != "merchandiser" || len(stored.Attributes) != 2 {
+		t.Errorf("the schema was not stored: %+v %v", stored, err)
+	}
+}
+
+func TestDeleteCategorySchema(t *testing.T) {
+	store := &mockCategories{
+		schemas: map[string]contract.CategorySchema{"shoes": mockShoes(), "boots": {Category: "boots"}},
+		inUse:   map[string]bool{"shoes": true},
+	}
+	srv := NewServer("8081", &mockDB{}, WithCategorySchemas(store))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	tests := map[string]struct {
+		category       string
+		statusExpected int
+	}{
+		"#1: category in use":  {category: "shoes", statusExpected: http.StatusConflict},
+		"#2: unknown category": {category: "hats", statusExpected: http.StatusNotFound},
+		"#3: valid case":       {category: "boots", statusExpected: http.StatusOK},
+	}
+
+	for desc, tc := range tests {
+		req, _ := http.NewRequest(http.MethodDelete, "http://localhost:8081/categories/"+tc.category, nil)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Status code got: %v\n Status code expected: %v", desc, resp.StatusCode, tc.statusExpected)
+		}
+	}
+}
+
+func TestProductAttributes(t *testing.T) {
+	store := &mockCategories{schemas: map[string]contract.CategorySchema{"shoes": mockShoes()}}
+	srv := NewServer("8081", &mockDB{prdCount: 1}, WithCategorySchemas(store))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	const prd = `{"sku":"FAL-1000000","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a","status":"active",`
+
+	tests := map[string]struct {
+		method          string
+		body            string
+		acceptLanguage  string
+		throwError      bool
+		statusExpected  int
+		messageExpected string
+	}{
+		"#1: valid attributes":    {method: http.MethodPost, body: prd + `"category":"shoes","attributes":{"size":42,"color":"black","weight":0.8}}`, statusExpected: http.StatusCreated},
+		"#2: without attributes":  {method: http.MethodPost, body: prd + `"category":"hats"}`, statusExpected: http.StatusCreated},
+		"#3: without category":    {method: http.MethodPost, body: prd + `"attributes":{"size":42}}`, statusExpected: http.StatusBadRequest, messageExpected: "Attributes require a category"},
+		"#4: unknown category":    {method: http.MethodPost, body: prd + `"category":"hats","attributes":{"size":42}}`, statusExpected: http.StatusBadRequest, messageExpected: "Attributes[size] is not an attribute of the category hats"},
+		"#5: required attribute":  {method: http.MethodPost, body: prd + `"category":"shoes","attributes":{"color":"black"}}`, statusExpected: http.StatusBadRequest, messageExpected: "Attributes[size] is required by the category shoes"},
+		"#6: invalid type":        {method: http.MethodPost, body: prd + `"category":"shoes","attributes":{"size":42.5}}`, statusExpected: http.StatusBadRequest, messageExpected: "Attributes[size] must be of type integer"},
+		"#7: value not allowed":   {method: http.MethodPost, body: prd + `"category":"shoes","attributes":{"size":42,"color":"red"}}`, statusExpected: http.StatusBadRequest, messageExpected: "Attributes[color] must be one of [black white]"},
+		"#8: translated message":  {method: http.MethodPost, body: prd + `"category":"shoes","attributes":{"size":10}}`, acceptLanguage: "es", statusExpected: http.StatusBadRequest, messageExpected: "Attributes[size] debe ser 20 o más"},
+		"#9: replaced product":    {method: http.MethodPut, body: prd + `"category":"shoes","attributes":{"size":42,"waterproof":"yes"}}`, statusExpected: http.StatusBadRequest, messageExpected: "Attributes[waterproof] must be of type boolean"},
+		"#10: patched category":   {method: http.MethodPatch, body: `{"category":"shoes"}`, statusExpected: http.StatusBadRequest, messageExpected: "Attributes[size] is required by the category shoes"},
+		"#11: patched attributes": {method: http.MethodPatch, body: `{"category":"shoes","attributes":{"size":38}}`, statusExpected: http.StatusOK},
+		"#12: schema error":       {method: http.MethodPost, body: prd + `"category":"shoes","attributes":{"size":42}}`, throwError: true, statusExpected: http.StatusInternalServerError},
+	}
+
+	for desc, tc := range tests {
+		store.throwError = tc.throwError
+
+		path := "/product"
+		if tc.method != http.MethodPost {
+			path += "/FAL-1000000"
+		}
+
+		req, _ := http.NewRequest(tc.method, "http://localhost:8081"+path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected || !strings.Contains(string(body), tc.messageExpected) {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v %s", desc, resp.StatusCode, body, tc.statusExpected, tc.messageExpected)
+		}
+	}
+}
+
+func TestAttributeFilters(t *testing.T) {
+	var (
+		mdb   = &mockDB{prdCount: 1}
+		store = &mockCategories{schemas: map[string]contract.CategorySchema{"shoes": mockShoes()}}
+	)
+	srv := NewServer("8081", mdb, WithCategorySchemas(store))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	min, max := 0.5, 1.5
+
+	tests := map[string]struct {
+		query           string
+		statusExpected  int
+		filterExpected  contract.ProductFilter
+		messageExpected string
+	}{
+		"#1: category": {query: "category=shoes", statusExpected: http.StatusOK, filterExpected: contract.ProductFilter{Category: "shoes", Status: contract.StatusActive}},
+		"#2: attribute values": {query: "category=shoes&attr.color=black&attr.size=42&attr.waterproof=true", statusExpected: http.StatusOK,
+			filterExpected: contract.ProductFilter{Category: "shoes", Status: contract.StatusActive, Attributes: []contract.AttributeFilter{
+				{Key: "color", Equals: "black"}, {Key: "size", Equals: 42.0}, {Key: "waterproof", Equals: true},
+			}}},
+		"#3: numeric range": {query: "category=shoes&attr.weight.min=0.5&attr.weight.max=1.5", statusExpected: http.StatusOK,
+			filterExpected: contract.ProductFilter{Category: "shoes", Status: contract.StatusActive, Attributes: []contract.AttributeFilter{{Key: "weight", Min: &min, Max: &max}}}},
+		"#4: without category":        {query: "attr.color=black", statusExpected: http.StatusBadRequest, messageExpected: "the attribute filters require a category"},
+		"#5: unknown attribute":       {query: "category=shoes&attr.heel=high", statusExpected: http.StatusBadRequest, messageExpected: "heel is not an attribute of the category shoes"},
+		"#6: invalid value":           {query: "category=shoes&attr.size=big", statusExpected: http.StatusBadRequest, messageExpected: "attr.size must be of type integer"},
+		"#7: range of a string":       {query: "category=shoes&attr.color.min=1", statusExpected: http.StatusBadRequest, messageExpected: "attr.color.min is only allowed for numeric attributes"},
+		"#8: unknown filter":          {query: "category=shoes&attr.size.avg=1", statusExpected: http.StatusBadRequest, messageExpected: "unknown attribute filter attr.size.avg"},
+		"#9: category without schema": {query: "category=hats&attr.color=black", statusExpected: http.StatusBadRequest, messageExpected: "color is not an attribute of the category hats"},
+	}
+
+	for desc, tc := range tests {
+		mdb.filter.Store(contract.ProductFilter{})
+
+		resp, err := http.Get("http://localhost:8081/product?" + tc.query)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected || !strings.Contains(string(body), tc.messageExpected) {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v %s", desc, resp.StatusCode, body, tc.statusExpected, tc.messageExpected)
+			continue
+		}
+
+		if filter := mdb.filter.Load().(contract.ProductFilter); tc.statusExpected == http.StatusOK && !reflect.DeepEqual(filter, tc.filterExpected) {
+			t.Errorf("%s:\n Filter got: %+v\n Filter expected: %+v", desc, filter, tc.filterExpected)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//Routes with a configurable Cache-Control policy
+const (
+	//RouteProduct GET /product/{sku}
+	RouteProduct = "product"
+	//RouteProducts GET /product
+	RouteProducts = "products"
+)
+
+//default Cache-Control policies of the routes
+var defaultCacheControl = map[string]string{
+	RouteProduct:  "public, max-age=60",
+	RouteProducts: "public, max-age=30",
+}
+
+//WithCacheControl sets the Cache-Control policy of a route. An empty policy omits the header
+func WithCacheControl(route, policy string) Option {
+	return func(s *server) {
+		s.cacheControl[route] = policy
+	}
+}
+
+//writes the body along with its validators and the Cache-Control policy of the route, or a
+//304 response when the preconditions of the request show the client already has it
+func (s *server) writeCacheable(w http.ResponseWriter, req *http.Request, route string, lastModified time.Time, body []byte) {
+	etag := weakETag(body)
+
+	s.setCacheHeaders(w, route, lastModified)
+	w.Header().Set("ETag", etag)
+
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		if etagMatches(inm, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else if notModifiedSince(req, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+//writes a 304 response before building the body when the request only has an If-Modified-Since
+//precondition and the resource did not change since then
+func (s *server) writeNotModified(w http.ResponseWriter, req *http.Request, route string, lastModified time.Time) bool {
+	if req.Header.Get("If-None-Match") != "" || !notModifiedSince(req, lastModified) {
+		return false
+	}
+
+	s.setCacheHeaders(w, route, lastModified)
+	w.WriteHeader(http.StatusNotModified)
+
+	return true
+}
+
+func (s *server) setCacheHeaders(w http.ResponseWriter, route string, lastModified time.Time) {
+	//the products are localized with the Accept-Language header, and only the users read the inactive ones
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Add("Vary", userHeader)
+
+	if policy := s.cacheControl[route]; policy != "" {
+		w.Header().Set("Cache-Control", policy)
+	}
+
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+//reports if the resource was not modified after the If-Modified-Since date of the request
+func notModifiedSince(req *http.Request, lastModified time.Time) bool {
+	ims := req.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+
+	//http dates have a precision of seconds
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+//weak entity tag of the body. Weak, since equivalent bodies may differ in encoding details
+func weakETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+//reports if any of the entity tags of the If-None-Match header matches the etag, using the weak comparison
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestConditionalGet(t *testing.T) {
+	srv := NewServer("8081", &mockDB{prdCount: 2}, WithCacheControl(RouteProducts, "no-cache"))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	get := func(url string, headers map[string]string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+		resp.Body.Close()
+
+		return resp
+	}
+
+	lastModified := mockUpdatedAt.Format(http.TimeFormat)
+
+	for _, route := range []struct {
+		url          string
+		cacheControl string
+	}{
+		{url: "http://localhost:8081/product/FAL-1000000", cacheControl: defaultCacheControl[RouteProduct]},
+		{url: "http://localhost:8081/product", cacheControl: "no-cache"},
+	} {
+		resp := get(route.url, nil)
+		etag := resp.Header.Get("ETag")
+
+		if resp.StatusCode != http.StatusOK || etag == "" || resp.Header.Get("Last-Modified") != lastModified || resp.Header.Get("Cache-Control") != route.cacheControl {
+			t.Fatalf("%s: unexpected response %d: %v", route.url, resp.StatusCode, resp.Header)
+		}
+
+		tests := map[string]struct {
+			headers        map[string]string
+			statusExpected int
+		}{
+			"#1: matching etag": {
+				headers:        map[string]string{"If-None-Match": etag},
+				statusExpected: http.StatusNotModified,
+			},
+			"#2: matching etag in a list": {
+				headers:        map[string]string{"If-None-Match": `W/"other", ` + etag},
+				statusExpected: http.StatusNotModified,
+			},
+			"#3: any etag": {
+				headers:        map[string]string{"If-None-Match": "*"},
+				statusExpected: http.StatusNotModified,
+			},
+			"#4: stale etag": {
+				headers:        map[string]string{"If-None-Match": `W/"other"`},
+				statusExpected: http.StatusOK,
+			},
+			"#5: stale etag takes precedence over the date": {
+				headers:        map[string]string{"If-None-Match": `W/"other"`, "If-Modified-Since": lastModified},
+				statusExpected: http.StatusOK,
+			},
+			"#6: not modified since": {
+				headers:        map[string]string{"If-Modified-Since": lastModified},
+				statusExpected: http.StatusNotModified,
+			},
+			"#7: modified since": {
+				headers:        map[string]string{"If-Modified-Since": mockUpdatedAt.Add(-time.Second).Format(http.TimeFormat)},
+				statusExpected: http.StatusOK,
+			},
+			"#8: invalid date": {
+				headers:        map[string]string{"If-Modified-Since": "yesterday"},
+				statusExpected: http.StatusOK,
+			},
+		}
+
+		for desc, tc := range tests {
+			resp := get(route.url, tc.headers)
+
+			if resp.StatusCode != tc.statusExpected {
+				t.Errorf("%s %s: status code expected %d, got %d", route.url, desc, tc.statusExpected, resp.StatusCode)
+			}
+
+			if resp.Header.Get("Cache-Control") != route.cacheControl {
+				t.Errorf("%s %s: Cache-Control expected %q, got %q", route.url, desc, route.cacheControl, resp.Header.Get("Cache-Control"))
+			}
+		}
+	}
+}
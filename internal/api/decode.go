@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+//decodeError holds the message of a body that could not be decoded and the status it is answered with
+type decodeError struct {
+	status int
+	msg    string
+}
+
+func (e *decodeError) Error() string {
+	return e.msg
+}
+
+//decodes the JSON body into v. The body must be sent as application/json and must be a single JSON value whose
+//fields are all known. Writes the error response when it fails
+func decodeBody(w http.ResponseWriter, req *http.Request, v interface{}) bool {
+	if err := decodeJSON(req, v); err != nil {
+		var dErr *decodeError
+		if !errors.As(err, &dErr) {
+			dErr = &decodeError{http.StatusBadRequest, "could not decode the body"}
+		}
+
+		logrus.Errorf("could not decode the body %v", err)
+		writeResponse(w, dErr.status, []string{dErr.msg})
+		return false
+	}
+
+	return true
+}
+
+//decodes the JSON body into v, returning a decodeError telling why it is not valid
+func decodeJSON(req *http.Request, v interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != mimeJSON {
+		return &decodeError{http.StatusUnsupportedMediaType, fmt.Sprintf("Content-Type must be %s", mimeJSON)}
+	}
+
+	dec := json.NewDecoder(req.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return &decodeError{http.StatusBadRequest, jsonErrorMessage(err)}
+	}
+
+	//a body with more than one value is ambiguous
+	if _, err := dec.Token(); err != io.EOF {
+		return &decodeError{http.StatusBadRequest, "the body has data after the JSON value"}
+	}
+
+	return nil
+}
+
+//translates the errors of the JSON decoder into readable messages telling the field that failed
+func jsonErrorMessage(err error) string {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("the body is not valid JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Sprintf("the body must be a JSON %s", jsonType(typeErr.Type))
+		}
+		return fmt.Sprintf("%s must be a JSON %s", typeErr.Field, jsonType(typeErr.Type))
+	case errors.Is(err, io.EOF):
+		return "the body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "the body is not valid JSON: unexpected end"
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		//the decoder does not have a typed error for unknown fields
+		return fmt.Sprintf("%s is an unknown field", strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`))
+	}
+
+	return "could not decode the body"
+}
+
+//name of the JSON type a Go type is decoded from
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+
+	return "object"
+}
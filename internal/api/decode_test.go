@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBody(t *testing.T) {
+	srv := NewServer("8081", &mockDB{})
+	serve(t, srv)
+
+	defer func() {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}()
+
+	prd := `{"sku":"FAL-1000000","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a"}`
+
+	tests := map[string]struct {
+		body            string
+		contentType     string
+		statusExpected  int
+		messageExpected string
+	}{
+		"#1: valid body":          {body: prd, contentType: "application/json; charset=utf-8", statusExpected: http.StatusCreated},
+		"#2: unknown field":       {body: `{"sku":"FAL-1000000","colour":"red"}`, contentType: "application/json", statusExpected: http.StatusBadRequest, messageExpected: "colour is an unknown field"},
+		"#3: trailing data":       {body: prd + `{}`, contentType: "application/json", statusExpected: http.StatusBadRequest, messageExpected: "the body has data after the JSON value"},
+		"#4: wrong type":          {body: `{"sku":"FAL-1000000","size":"10"}`, contentType: "application/json", statusExpected: http.StatusBadRequest, messageExpected: "size must be a JSON integer"},
+		"#5: invalid json":        {body: `{"sku":}`, contentType: "application/json", statusExpected: http.StatusBadRequest, messageExpected: "the body is not valid JSON at offset 8"},
+		"#6: empty body":          {contentType: "application/json", statusExpected: http.StatusBadRequest, messageExpected: "the body is empty"},
+		"#7: no content type":     {body: prd, statusExpected: http.StatusUnsupportedMediaType, messageExpected: "Content-Type must be application/json"},
+		"#8: unsupported content": {body: prd, contentType: "text/plain", statusExpected: http.StatusUnsupportedMediaType, messageExpected: "Content-Type must be application/json"},
+	}
+
+	for desc, tc := range tests {
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost:8081/product", strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected || !strings.Contains(string(respBody), tc.messageExpected) {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v %s", desc, resp.StatusCode, respBody, tc.statusExpected, tc.messageExpected)
+		}
+	}
+}
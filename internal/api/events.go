@@ -0,0 +1,348 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultReplaySize  = 1000
+	subscriberBuffer   = 64
+	heartbeatInterval  = 15 * time.Second
+	reconnectionMillis = 3000
+
+	defaultEventPollInterval = time.Second
+	//eventBatchSize how many events are read from the event log at once
+	eventBatchSize = 500
+	//eventGapTimeout how long a missing id holds back the following events. Ids are assigned before the events
+	//are committed, so a missing id is an event not committed yet, or a rolled back one once it lasts longer
+	eventGapTimeout = 10 * time.Second
+
+	//eventStreamReset sent to the streams resuming from an event that is no longer buffered. The events since
+	//then may be missed, so the clients must reload the products they follow
+	eventStreamReset = "stream.reset"
+)
+
+//eventBroker fans out the product events read from the event log to the open streams, keeping the last events in
+//a bounded buffer so clients can resume after a reconnection. The events come from every writer of the database,
+//so every replica streams the same events with the same ids
+type eventBroker struct {
+	mu     sync.Mutex
+	buffer []contract.ProductEvent
+	size   int
+	//every event after floor is in the buffer. Streams resuming from an older event may have missed events
+	floor int64
+	//id of the last published event
+	cursor      int64
+	subscribers map[chan contract.ProductEvent]struct{}
+	closed      bool
+
+	gapTimeout time.Duration
+}
+
+//subscription of a stream to the broker
+type subscription struct {
+	events chan contract.ProductEvent
+	//buffered events after the event the stream resumes from
+	replay []contract.ProductEvent
+	//the stream resumes from an event older than the buffer
+	reset bool
+	//id of the last event published before the subscription
+	cursor int64
+}
+
+func newEventBroker(size int) *eventBroker {
+	return &eventBroker{
+		size:        size,
+		buffer:      make([]contract.ProductEvent, 0, size),
+		subscribers: make(map[chan contract.ProductEvent]struct{}),
+		gapTimeout:  eventGapTimeout,
+	}
+}
+
+//follow publishes the events of the log in id order until the context is done. It starts with the last buffered
+//events, so the streams resume across restarts
+func (b *eventBroker) follow(ctx context.Context, log db.EventLog, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		started bool
+		cursor  int64
+		//when the missing id after the cursor was first seen
+		gapSince time.Time
+	)
+
+	for {
+		if !started {
+			last, err := log.LastEventID()
+			if err != nil {
+				logrus.Errorf("could not get the last product event: %v", err)
+			} else {
+				cursor, started = last-int64(b.size), true
+				if cursor < 0 {
+					cursor = 0
+				}
+				b.start(cursor)
+			}
+		}
+
+		for started && ctx.Err() == nil {
+			events, err := log.EventsAfter(cursor, eventBatchSize)
+			if err != nil {
+				logrus.Errorf("could not get the product events: %v", err)
+				break
+			}
+
+			for _, event := range events {
+				if event.ID != cursor+1 {
+					if gapSince.IsZero() {
+						gapSince = time.Now()
+					}
+					if time.Since(gapSince) < b.gapTimeout {
+						break
+					}
+				}
+
+				gapSince = time.Time{}
+				cursor = event.ID
+				b.publish(event)
+			}
+
+			//the events are read back to back until the log is caught up or a gap holds them back
+			if len(events) < eventBatchSize || cursor != events[len(events)-1].ID {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//start sets the id the events are published after
+func (b *eventBroker) start(cursor int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.floor, b.cursor = cursor, cursor
+}
+
+//publish sends the event to every subscriber. Subscribers not keeping up are disconnected, they can resume from the buffer
+func (b *eventBroker) publish(event contract.ProductEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	if len(b.buffer) == b.size {
+		b.floor = b.buffer[0].ID
+		b.buffer = append(b.buffer[:0], b.buffer[1:]...)
+	}
+	b.buffer = append(b.buffer, event)
+	b.cursor = event.ID
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+//subscribe registers a new subscriber, returning the buffered events after lastID. Without lastID, the stream
+//starts with the next event. It returns false when the broker is closed
+func (b *eventBroker) subscribe(lastID int64, resume bool) (*subscription, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, false
+	}
+
+	sub := &subscription{
+		events: make(chan contract.ProductEvent, subscriberBuffer),
+		reset:  resume && lastID < b.floor,
+		cursor: b.cursor,
+	}
+
+	if resume && !sub.reset {
+		for _, event := range b.buffer {
+			if event.ID > lastID {
+				sub.replay = append(sub.replay, event)
+			}
+		}
+	}
+
+	b.subscribers[sub.events] = struct{}{}
+
+	return sub, true
+}
+
+func (b *eventBroker) unsubscribe(ch chan contract.ProductEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+//close ends every open stream and rejects new subscribers
+func (b *eventBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+//filters of a stream. Empty filters match every event the user can read
+type eventFilter struct {
+	user   string
+	skus   []string
+	brands []string
+}
+
+func (f eventFilter) matches(event contract.ProductEvent) bool {
+	if !visible(f.user, event.Payload) {
+		return false
+	}
+
+	if len(f.skus) > 0 && !contains(f.skus, event.SKU) {
+		return false
+	}
+
+	if len(f.brands) > 0 {
+		for _, brand := range f.brands {
+			if strings.EqualFold(brand, event.Payload.Brand) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// streamEvents godoc
+// @Summary Streams live product changes
+// @Description Server-Sent Events stream of the products created, updated and deleted through any API, replica or the command line.
+// @Description Send the Last-Event-ID header to resume a stream from the last received event. Streams resuming from an event that is no longer buffered get a stream.reset event first, since they may have missed events.
+// @Description Anonymous streams, without user header, only receive the events of active products
+// @Tags product events
+// @Produce text/event-stream
+// @Success 200 {object} contract.ProductEvent
+// @Failure 500,503 {object} contract.Response{status=int,message=object}
+// @Param sku query []string false "product skus" collectionFormat(multi)
+// @Param brand query []string false "product brands" collectionFormat(multi)
+// @Param Last-Event-ID header int false "id of the last received event"
+// @Param X-User header string false "user reading the stream. Required to receive the events of products that are not active"
+// @Router /product/events [get]
+func (s *server) streamEvents(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeResponse(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	var lastID int64
+	v := req.Header.Get("Last-Event-ID")
+	if v != "" {
+		lastID, _ = strconv.ParseInt(v, 10, 64)
+	}
+
+	filter := eventFilter{user: actor(req), skus: req.URL.Query()["sku"], brands: req.URL.Query()["brand"]}
+
+	sub, ok := s.events.subscribe(lastID, v != "")
+	if !ok {
+		writeResponse(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
+	defer s.events.unsubscribe(sub.events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", reconnectionMillis)
+
+	//the stream goes on from the last published event, which the client resumes from when it reconnects
+	if sub.reset {
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: {\"lastEventId\":%d}\n\n", sub.cursor, eventStreamReset, sub.cursor)
+		lastID = sub.cursor
+	}
+
+	for _, event := range sub.replay {
+		if filter.matches(event) {
+			writeEvent(w, event)
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case event, ok := <-sub.events:
+			if !ok {
+				return
+			}
+
+			//a client resuming from an event this replica has not read yet already has the events up to it
+			if event.ID > lastID && filter.matches(event) {
+				writeEvent(w, event)
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event contract.ProductEvent) {
+	data, err := json.Marshal(&event)
+	if err != nil {
+		logrus.Errorf("could not encode event %d: %v", event.ID, err)
+		return
+	}
+
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
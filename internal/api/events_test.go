@@ -0,0 +1,265 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestEventBroker(t *testing.T) {
+	b := newEventBroker(3)
+	b.start(10)
+
+	for id := int64(11); id <= 15; id++ {
+		b.publish(productEvent(id, contract.EventProductCreated))
+	}
+
+	tests := []struct {
+		desc           string
+		lastID         int64
+		resume         bool
+		replayExpected []int64
+		resetExpected  bool
+	}{
+		{desc: "#1: new stream"},
+		{desc: "#2: resumed stream", lastID: 13, resume: true, replayExpected: []int64{14, 15}},
+		{desc: "#3: oldest buffered event", lastID: 12, resume: true, replayExpected: []int64{13, 14, 15}},
+		{desc: "#4: event no longer buffered", lastID: 11, resume: true, resetExpected: true},
+		{desc: "#5: up to date stream", lastID: 15, resume: true},
+		{desc: "#6: event not read yet", lastID: 20, resume: true},
+	}
+
+	for _, tc := range tests {
+		sub, ok := b.subscribe(tc.lastID, tc.resume)
+		if !ok {
+			t.Fatalf("%s: subscription expected", tc.desc)
+		}
+		b.unsubscribe(sub.events)
+
+		var replay []int64
+		for _, event := range sub.replay {
+			replay = append(replay, event.ID)
+		}
+
+		if fmt.Sprint(replay) != fmt.Sprint(tc.replayExpected) || sub.reset != tc.resetExpected || sub.cursor != 15 {
+			t.Errorf("%s:\n Got: %v reset: %v cursor: %d\n Expected: %v reset: %v", tc.desc, replay, sub.reset, sub.cursor, tc.replayExpected, tc.resetExpected)
+		}
+	}
+
+	//a subscriber not reading its events is disconnected
+	slow, _ := b.subscribe(0, false)
+	for id := int64(16); id <= 16+subscriberBuffer; id++ {
+		b.publish(productEvent(id, contract.EventProductUpdated))
+	}
+
+	for range slow.events {
+	}
+
+	b.close()
+	if _, ok := b.subscribe(0, false); ok {
+		t.Errorf("#7: closed broker must reject subscriptions")
+	}
+}
+
+func TestFollowEvents(t *testing.T) {
+	log := &mockEventLog{}
+	log.append(productEvent(1, contract.EventProductCreated), productEvent(2, contract.EventProductUpdated))
+
+	//only the last event is buffered when following starts
+	b := newEventBroker(1)
+	b.gapTimeout = 200 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub, _ := b.subscribe(0, false)
+	go b.follow(ctx, log, 10*time.Millisecond)
+
+	next := func() int64 {
+		select {
+		case event := <-sub.events:
+			return event.ID
+		case <-time.After(time.Second):
+			return 0
+		}
+	}
+
+	if id := next(); id != 2 {
+		t.Fatalf("#1: last event of the log expected. Got: %d", id)
+	}
+
+	//the event 3 is not committed yet, so the event 4 waits for it
+	log.append(productEvent(4, contract.EventProductUpdated))
+	select {
+	case event := <-sub.events:
+		t.Fatalf("#2: event %d must wait for the missing event", event.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	log.append(productEvent(3, contract.EventProductUpdated))
+	if first, second := next(), next(); first != 3 || second != 4 {
+		t.Errorf("#3: events in id order expected. Got: %d %d", first, second)
+	}
+
+	//an id missing for longer than the gap timeout was rolled back
+	log.append(productEvent(6, contract.EventProductUpdated))
+	start := time.Now()
+	if id := next(); id != 6 || time.Since(start) < b.gapTimeout {
+		t.Errorf("#4: event after a rolled back event expected after the gap timeout. Got: %d after %v", id, time.Since(start))
+	}
+}
+
+func TestEventFilter(t *testing.T) {
+	active := contract.Product{SKU: "FAL-1000000", Brand: "brand", Status: contract.StatusActive}
+	draft := contract.Product{SKU: "FAL-1000001", Brand: "brand", Status: contract.StatusDraft}
+
+	tests := map[string]struct {
+		filter   eventFilter
+		prd      contract.Product
+		expected bool
+	}{
+		"#1: active product":         {filter: eventFilter{}, prd: active, expected: true},
+		"#2: draft without user":     {filter: eventFilter{}, prd: draft, expected: false},
+		"#3: draft read by a user":   {filter: eventFilter{user: "alice"}, prd: draft, expected: true},
+		"#4: filtered by other sku":  {filter: eventFilter{skus: []string{"FAL-2000000"}}, prd: active, expected: false},
+		"#5: filtered by same brand": {filter: eventFilter{brands: []string{"BRAND"}}, prd: active, expected: true},
+	}
+
+	for desc, tc := range tests {
+		prd := tc.prd
+		event := contract.ProductEvent{SKU: prd.SKU, Payload: &prd}
+		if got := tc.filter.matches(event); got != tc.expected {
+			t.Errorf("%s:\n got: %v\n expected: %v", desc, got, tc.expected)
+		}
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	log := &mockEventLog{}
+
+	srv := NewServer("8081", &mockDB{prdCount: 1}, WithEventLog(log, 10*time.Millisecond), WithEventReplay(2))
+	serve(t, srv)
+
+	stream := func(lastEventID string, query string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost:8081/product/events"+query, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("event stream expected. Got: %v", ct)
+		}
+
+		return resp
+	}
+
+	filtered := stream("", "?brand=BRAND")
+	defer filtered.Body.Close()
+	events := bufio.NewReader(filtered.Body)
+
+	other := stream("", "?sku=FAL-2000000")
+	defer other.Body.Close()
+
+	//events written by any replica or the command line are streamed
+	log.append(productEvent(1, contract.EventProductCreated))
+
+	id, name, event := readEvent(t, events)
+	if id != "1" || name != contract.EventProductCreated || event.SKU != "FAL-1000000" {
+		t.Errorf("#1: created event expected. Got: %s %s %+v", id, name, event)
+	}
+
+	//a client resuming a stream gets the events it missed
+	resumed := stream("0", "")
+	defer resumed.Body.Close()
+
+	if id, _, _ := readEvent(t, bufio.NewReader(resumed.Body)); id != "1" {
+		t.Errorf("#2: replayed event expected. Got: %s", id)
+	}
+
+	log.append(productEvent(2, contract.EventProductUpdated), productEvent(3, contract.EventProductUpdated))
+	for _, expected := range []string{"2", "3"} {
+		if id, _, _ := readEvent(t, events); id != expected {
+			t.Errorf("#3: event %s expected. Got: %s", expected, id)
+		}
+	}
+
+	//the event 1 is no longer buffered, so a client resuming from it may have missed events
+	stale := stream("0", "")
+	defer stale.Body.Close()
+
+	if id, name, _ := readEvent(t, bufio.NewReader(stale.Body)); id != "3" || name != eventStreamReset {
+		t.Errorf("#4: reset event expected. Got: %s %s", id, name)
+	}
+
+	partial := stream("1", "")
+	defer partial.Body.Close()
+
+	if id, _, _ := readEvent(t, bufio.NewReader(partial.Body)); id != "2" {
+		t.Errorf("#5: buffered events after the last event expected. Got: %s", id)
+	}
+
+	//shutdown ends the open streams instead of waiting for them
+	done := make(chan error)
+	go func() {
+		done <- srv.Shutdown(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("#6: could not shutdown the test server: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("#6: shutdown blocked by the open streams")
+	}
+
+	//the stream filtered by another sku ends without events
+	rest, _ := io.ReadAll(other.Body)
+	if strings.Contains(string(rest), "data:") {
+		t.Errorf("#7: filtered stream must not receive events: %s", rest)
+	}
+}
+
+//reads lines until a complete event is received, returning its id and name
+func readEvent(t *testing.T, r *bufio.Reader) (string, string, contract.ProductEvent) {
+	t.Helper()
+
+	var (
+		id    string
+		name  string
+		event contract.ProductEvent
+	)
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("could not read event: %v", err)
+		}
+
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("could not decode event: %v", err)
+			}
+			return id, name, event
+		}
+	}
+}
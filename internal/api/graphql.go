@@ -0,0 +1,577 @@
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/i18n"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed schema.graphql
+var graphqlSchema string
+
+const (
+	maxGraphQLPageSize  = 500
+	maxGraphQLBatchSize = 100
+	maxGraphQLDepth     = 10
+)
+
+//GraphQL error codes, returned in the extensions of the errors
+const (
+	codeBadUserInput = "BAD_USER_INPUT"
+	codeNotFound     = "NOT_FOUND"
+	codeConflict     = "CONFLICT"
+	codeInternal     = "INTERNAL"
+)
+
+type loadersKey struct{}
+
+//loaders batch the database lookups of a GraphQL request, so resolving a list of products
+//does not query the database once per product
+type loaders struct {
+	products *loader
+	//nil when the inventory is disabled
+	stocks *loader
+}
+
+// graphql godoc
+// @Summary GraphQL endpoint
+// @Description Product queries and mutations. The schema is defined in internal/api/schema.graphql
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Success 200 {object} object
+// @Failure 400 {string} string
+// @Router /graphql [post]
+func (s *server) graphql() http.HandlerFunc {
+	schema := graphql.MustParseSchema(graphqlSchema, &graphqlResolver{s}, graphql.MaxDepth(maxGraphQLDepth))
+	handler := &relay.Handler{Schema: schema}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx, ok := s.withSKURule(req)
+		if !ok {
+			writeResponse(w, http.StatusBadRequest, fmt.Sprintf("unknown sku rule %s", req.Header.Get(skuRuleHeader)))
+			return
+		}
+
+		ctx = context.WithValue(ctx, loadersKey{}, s.newLoaders())
+		ctx = context.WithValue(ctx, actorKey, actor(req))
+		handler.ServeHTTP(w, req.WithContext(ctx))
+	}
+}
+
+func (s *server) newLoaders() *loaders {
+	l := &loaders{
+		products: newLoader(defaultLoaderWait, func(skus []string) (map[string]interface{}, error) {
+			prds, _, err := s.db.GetMany(skus)
+			if err != nil {
+				return nil, err
+			}
+
+			values := make(map[string]interface{}, len(prds))
+			for i := range prds {
+				values[prds[i].SKU] = &prds[i]
+			}
+
+			return values, nil
+		}),
+	}
+
+	if s.inventory != nil {
+		l.stocks = newLoader(defaultLoaderWait, func(skus []string) (map[string]interface{}, error) {
+			stocks, err := s.inventory.Stock(skus...)
+			if err != nil {
+				return nil, err
+			}
+
+			bySKU := make(map[string][]contract.Stock)
+			for _, stock := range stocks {
+				bySKU[stock.SKU] = append(bySKU[stock.SKU], stock)
+			}
+
+			values := make(map[string]interface{}, len(skus))
+			for _, sku := range skus {
+				values[sku] = contract.NewAvailability(bySKU[sku])
+			}
+
+			return values, nil
+		})
+	}
+
+	return l
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+//graphqlError error exposing its code and details in the extensions of the GraphQL error
+type graphqlError struct {
+	code    string
+	message string
+	details []string
+}
+
+func (e *graphqlError) Error() string {
+	return e.message
+}
+
+//Extensions implements the graphql-go resolver error interface
+func (e *graphqlError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"code": e.code}
+	if len(e.details) > 0 {
+		ext["details"] = e.details
+	}
+
+	return ext
+}
+
+//logs the error and hides it behind a generic message
+func internalError(message string, err error) error {
+	logrus.Errorf("db error: %v", err)
+	return &graphqlError{code: codeInternal, message: message}
+}
+
+type graphqlResolver struct {
+	s *server
+}
+
+//Product resolves a product by its SKU
+func (r *graphqlResolver) Product(ctx context.Context, args struct{ SKU string }) (*productResolver, error) {
+	l := loadersFrom(ctx)
+
+	v, err := l.products.load(args.SKU)
+	if err != nil {
+		return nil, internalError("could not retrieve product", err)
+	}
+
+	if v == nil || !visible(actorFrom(ctx), v.(*contract.Product)) {
+		return nil, nil
+	}
+
+	return &productResolver{prd: v.(*contract.Product), stocks: l.stocks}, nil
+}
+
+type productFilterInput struct {
+	Brand        *string
+	MinPrice     *float64
+	MaxPrice     *float64
+	UpdatedSince *graphql.Time
+}
+
+//Products resolves a page of products. The cursor of the page is its last SKU
+func (r *graphqlResolver) Products(ctx context.Context, args struct {
+	Filter *productFilterInput
+	First  int32
+	After  *string
+}) (*productConnectionResolver, error) {
+	if args.First < 1 {
+		return nil, &graphqlError{code: codeBadUserInput, message: "first must be greater than 0"}
+	}
+
+	filter := contract.ProductFilter{Limit: int(args.First)}
+	if filter.Limit > maxGraphQLPageSize {
+		filter.Limit = maxGraphQLPageSize
+	}
+
+	if actorFrom(ctx) == "" {
+		filter.Status = contract.StatusActive
+	}
+
+	if args.After != nil {
+		sku, err := base64.RawURLEncoding.DecodeString(*args.After)
+		if err != nil {
+			return nil, &graphqlError{code: codeBadUserInput, message: "invalid cursor"}
+		}
+		filter.AfterSKU = string(sku)
+	}
+
+	if f := args.Filter; f != nil {
+		if f.Brand != nil {
+			filter.Brand = *f.Brand
+		}
+		if f.MinPrice != nil {
+			filter.MinPrice = *f.MinPrice
+		}
+		if f.MaxPrice != nil {
+			filter.MaxPrice = *f.MaxPrice
+		}
+		if f.UpdatedSince != nil {
+			filter.UpdatedSince = f.UpdatedSince.Time
+		}
+	}
+
+	pageSize := filter.Limit
+
+	//one more product than the page size tells if there is a next page
+	filter.Limit++
+	prds, err := r.s.db.List(filter)
+	if err != nil {
+		return nil, internalError("could not get the list of products", err)
+	}
+
+	conn := &productConnectionResolver{}
+	if len(prds) > pageSize {
+		prds = prds[:pageSize]
+		conn.hasNextPage = true
+	}
+
+	conn.nodes = r.resolvers(ctx, prds)
+
+	return conn, nil
+}
+
+//ProductsBySku resolves the products of the given SKUs with a single query
+func (r *graphqlResolver) ProductsBySku(ctx context.Context, args struct{ SKUs []string }) ([]*productResolver, error) {
+	if len(args.SKUs) > maxGraphQLBatchSize {
+		return nil, &graphqlError{code: codeBadUserInput, message: fmt.Sprintf("up to %d skus can be requested", maxGraphQLBatchSize)}
+	}
+
+	loaders := loadersFrom(ctx)
+	loaders.products.prime(args.SKUs...)
+
+	prds := make([]contract.Product, 0, len(args.SKUs))
+	found := make(map[string]bool, len(args.SKUs))
+	for _, sku := range args.SKUs {
+		v, err := loaders.products.load(sku)
+		if err != nil {
+			return nil, internalError("could not get the products", err)
+		}
+
+		if v != nil && visible(actorFrom(ctx), v.(*contract.Product)) {
+			prds = append(prds, *v.(*contract.Product))
+			found[sku] = true
+		}
+	}
+
+	resolved := r.resolvers(ctx, prds)
+
+	//missing products are resolved as null keeping the order of the skus
+	result := make([]*productResolver, len(args.SKUs))
+	for i, sku := range args.SKUs {
+		if found[sku] {
+			result[i] = resolved[0]
+			resolved = resolved[1:]
+		}
+	}
+
+	return result, nil
+}
+
+//creates the resolvers of a list of products, scheduling the lookup of their availability in a single batch
+func (r *graphqlResolver) resolvers(ctx context.Context, prds []contract.Product) []*productResolver {
+	stocks := loadersFrom(ctx).stocks
+
+	result := make([]*productResolver, len(prds))
+	skus := make([]string, len(prds))
+	for i := range prds {
+		result[i] = &productResolver{prd: &prds[i], stocks: stocks}
+		skus[i] = prds[i].SKU
+	}
+
+	if stocks != nil {
+		stocks.prime(skus...)
+	}
+
+	return result
+}
+
+type productInput struct {
+	SKU         string
+	Name        string
+	Description *string
+	Brand       string
+	Size        int32
+	Price       float64
+	ImageURL    string
+	AltImages   *[]string
+}
+
+//CreateProduct creates a new product
+func (r *graphqlResolver) CreateProduct(ctx context.Context, args struct{ Input productInput }) (*productResolver, error) {
+	in := args.Input
+	prd := contract.Product{
+		SKU:      in.SKU,
+		Name:     in.Name,
+		Brand:    in.Brand,
+		Size:     int(in.Size),
+		Price:    in.Price,
+		ImageURL: in.ImageURL,
+
+		UpdatedBy: actorFrom(ctx),
+	}
+
+	if in.Description != nil {
+		prd.Description = *in.Description
+	}
+	if in.AltImages != nil {
+		prd.AltImages = *in.AltImages
+	}
+
+	if err := r.validate(ctx, prd); err != nil {
+		return nil, err
+	}
+
+	existing, err := r.s.db.Get(prd.SKU)
+	if err != nil {
+		return nil, internalError("could not create new product", err)
+	}
+
+	if existing != nil {
+		return nil, &graphqlError{code: codeConflict, message: fmt.Sprintf("product %s already exists", prd.SKU)}
+	}
+
+	created, err := r.s.db.Create(prd)
+	if err != nil {
+		//the product may have been created after checking its existence
+		if errors.Is(err, db.ErrAlreadyExists) {
+			return nil, &graphqlError{code: codeConflict, message: fmt.Sprintf("product %s already exists", prd.SKU)}
+		}
+		return nil, internalError("could not create new product", err)
+	}
+
+	logrus.Infof("Product %s created", prd.SKU)
+
+	return &productResolver{prd: created, stocks: loadersFrom(ctx).stocks}, nil
+}
+
+type productPatch struct {
+	Name        *string
+	Description *string
+	Brand       *string
+	Size        *int32
+	Price       *float64
+	ImageURL    *string
+	AltImages   *[]string
+}
+
+//UpdateProduct sets the fields present in the patch while the product is locked
+func (r *graphqlResolver) UpdateProduct(ctx context.Context, args struct {
+	SKU   string
+	Patch productPatch
+}) (*productResolver, error) {
+	patch := args.Patch
+
+	prd, err := r.s.db.UpdateFunc(args.SKU, func(prd *contract.Product) error {
+		prd.UpdatedBy = actorFrom(ctx)
+
+		if patch.Name != nil {
+			prd.Name = *patch.Name
+		}
+		if patch.Description != nil {
+			prd.Description = *patch.Description
+		}
+		if patch.Brand != nil {
+			prd.Brand = *patch.Brand
+		}
+		if patch.Size != nil {
+			prd.Size = int(*patch.Size)
+		}
+		if patch.Price != nil {
+			prd.Price = *patch.Price
+		}
+		if patch.ImageURL != nil {
+			prd.ImageURL = *patch.ImageURL
+		}
+		if patch.AltImages != nil {
+			prd.AltImages = *patch.AltImages
+		}
+
+		return r.validate(ctx, *prd)
+	})
+	if err != nil {
+		var gqlErr *graphqlError
+
+		switch {
+		case errors.As(err, &gqlErr):
+			return nil, gqlErr
+		case errors.Is(err, db.ErrNotFound):
+			return nil, &graphqlError{code: codeNotFound, message: fmt.Sprintf("product %s not found", args.SKU)}
+		default:
+			return nil, internalError("could not update product", err)
+		}
+	}
+
+	logrus.Infof("Product %s updated", prd.SKU)
+
+	return &productResolver{prd: prd, stocks: loadersFrom(ctx).stocks}, nil
+}
+
+//DeleteProduct deletes a product. Products with stock are only deleted with cascade
+func (r *graphqlResolver) DeleteProduct(args struct {
+	SKU     string
+	Cascade bool
+}) (*productResolver, error) {
+	prd, err := r.get(args.SKU)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.s.db.Delete(args.SKU, args.Cascade); err != nil {
+		if errors.Is(err, db.ErrInStock) {
+			return nil, &graphqlError{code: codeConflict, message: "product has stock. Use cascade to delete it along with its stock"}
+		}
+		return nil, internalError("could not delete product", err)
+	}
+
+	logrus.Infof("Product %s deleted", prd.SKU)
+
+	//the stock of a deleted product is gone, so its availability is not resolved
+	return &productResolver{prd: prd}, nil
+}
+
+//retrieves a product translating its absence into a not found error
+func (r *graphqlResolver) get(sku string) (*contract.Product, error) {
+	prd, err := r.s.db.Get(sku)
+	if err != nil {
+		return nil, internalError("could not retrieve product", err)
+	}
+
+	if prd == nil {
+		return nil, &graphqlError{code: codeNotFound, message: fmt.Sprintf("product %s not found", sku)}
+	}
+
+	return prd, nil
+}
+
+//validates the product with the same rules of the REST endpoints, which are relaxed for drafts
+func (r *graphqlResolver) validate(ctx context.Context, prd contract.Product) error {
+	err := r.s.validate(ctx, prd)
+
+	var vErr *validationError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &vErr):
+		return &graphqlError{code: codeBadUserInput, message: "invalid product", details: vErr.errs}
+	default:
+		return internalError("could not validate product", err)
+	}
+}
+
+type productResolver struct {
+	prd    *contract.Product
+	stocks *loader
+}
+
+func (r *productResolver) SKU() string      { return r.prd.SKU }
+func (r *productResolver) Brand() string    { return r.prd.Brand }
+func (r *productResolver) Size() int32      { return int32(r.prd.Size) }
+func (r *productResolver) Price() float64   { return r.prd.Price }
+func (r *productResolver) ImageURL() string { return r.prd.ImageURL }
+func (r *productResolver) Status() string   { return r.prd.Status }
+
+func (r *productResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.prd.CreatedAt} }
+func (r *productResolver) UpdatedAt() graphql.Time { return graphql.Time{Time: r.prd.UpdatedAt} }
+func (r *productResolver) CreatedBy() *string      { return optional(r.prd.CreatedBy) }
+func (r *productResolver) UpdatedBy() *string      { return optional(r.prd.UpdatedBy) }
+
+//Name retrieves the name in the languages of the request
+func (r *productResolver) Name(ctx context.Context) string {
+	return r.localized(ctx).Name
+}
+
+//Description retrieves the description in the languages of the request
+func (r *productResolver) Description(ctx context.Context) *string {
+	return optional(r.localized(ctx).Description)
+}
+
+//copy of the product localized in the languages of the request
+func (r *productResolver) localized(ctx context.Context) contract.Product {
+	prd := *r.prd
+	prd.Localize(i18n.Languages(ctx))
+
+	return prd
+}
+
+func (r *productResolver) AltImages() []string {
+	if r.prd.AltImages == nil {
+		return []string{}
+	}
+
+	return r.prd.AltImages
+}
+
+//Availability is resolved in batches along with the rest of the products of the request
+func (r *productResolver) Availability() (*availabilityResolver, error) {
+	if r.stocks == nil {
+		return nil, nil
+	}
+
+	v, err := r.stocks.load(r.prd.SKU)
+	if err != nil {
+		return nil, internalError("could not get the availability of the product", err)
+	}
+
+	return &availabilityResolver{v.(*contract.Availability)}, nil
+}
+
+type availabilityResolver struct {
+	av *contract.Availability
+}
+
+func (r *availabilityResolver) OnHand() int32    { return int32(r.av.OnHand) }
+func (r *availabilityResolver) Reserved() int32  { return int32(r.av.Reserved) }
+func (r *availabilityResolver) Available() int32 { return int32(r.av.Available) }
+
+func (r *availabilityResolver) Warehouses() []*stockResolver {
+	result := make([]*stockResolver, len(r.av.Warehouses))
+	for i := range r.av.Warehouses {
+		result[i] = &stockResolver{&r.av.Warehouses[i]}
+	}
+
+	return result
+}
+
+type stockResolver struct {
+	stock *contract.Stock
+}
+
+func (r *stockResolver) Warehouse() string { return r.stock.Warehouse }
+func (r *stockResolver) OnHand() int32     { return int32(r.stock.OnHand) }
+func (r *stockResolver) Reserved() int32   { return int32(r.stock.Reserved) }
+func (r *stockResolver) Available() int32  { return int32(r.stock.Available) }
+
+type productConnectionResolver struct {
+	nodes       []*productResolver
+	hasNextPage bool
+}
+
+func (r *productConnectionResolver) Nodes() []*productResolver {
+	return r.nodes
+}
+
+func (r *productConnectionResolver) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNextPage: r.hasNextPage}
+	if len(r.nodes) > 0 {
+		cursor := base64.RawURLEncoding.EncodeToString([]byte(r.nodes[len(r.nodes)-1].prd.SKU))
+		info.endCursor = &cursor
+	}
+
+	return info
+}
+
+type pageInfoResolver struct {
+	hasNextPage bool
+	endCursor   *string
+}
+
+func (r *pageInfoResolver) HasNextPage() bool  { return r.hasNextPage }
+func (r *pageInfoResolver) EndCursor() *string { return r.endCursor }
+
+//nil when the value is empty
+func optional(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+//postGraphQL sends the query to the test server
+func postGraphQL(t *testing.T, query string) graphqlResponse {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]string{"query": query})
+	resp, err := http.Post("http://localhost:8081/graphql", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+	defer resp.Body.Close()
+
+	gqlResp := graphqlResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&gqlResp); err != nil {
+		t.Fatalf("could not decode response body: %v", err)
+	}
+
+	return gqlResp
+}
+
+func TestGraphQLQueries(t *testing.T) {
+	tests := map[string]struct {
+		prdCount     int
+		query        string
+		dataExpected string
+		codeExpected string
+	}{
+		"#1: product":                {prdCount: 1, query: `{product(sku:"FAL-1000000"){sku availability{available}}}`, dataExpected: `{"product":{"sku":"FAL-1000000","availability":{"available":7}}}`},
+		"#2: product not found":      {prdCount: 0, query: `{product(sku:"FAL-1000000"){sku}}`, dataExpected: `{"product":null}`},
+		"#3: first page":             {prdCount: 3, query: `{products(first:2){nodes{sku} pageInfo{hasNextPage endCursor}}}`, dataExpected: `"hasNextPage":true,"endCursor":"RkFMLTEwMDAwMDA"`},
+		"#4: last page":              {prdCount: 2, query: `{products(filter:{brand:"brand"}){pageInfo{hasNextPage}}}`, dataExpected: `{"products":{"pageInfo":{"hasNextPage":false}}}`},
+		"#5: invalid page size":      {prdCount: 1, query: `{products(first:0){nodes{sku}}}`, codeExpected: codeBadUserInput},
+		"#6: batch with missing sku": {prdCount: 1, query: `{productsBySku(skus:["FAL-1000001","FAL-2000000"]){sku}}`, dataExpected: `{"productsBySku":[{"sku":"FAL-1000001"},null]}`},
+		"#7: audit fields":           {prdCount: 1, query: `{product(sku:"FAL-1000000"){updatedAt updatedBy}}`, dataExpected: `{"product":{"updatedAt":"2022-01-10T12:30:00Z","updatedBy":null}}`},
+		"#8: updated since":          {prdCount: 2, query: `{products(filter:{updatedSince:"2022-01-10T12:30:01Z"}){nodes{sku}}}`, dataExpected: `{"products":{"nodes":[]}}`},
+	}
+
+	for desc, tc := range tests {
+		srv := NewServer("8081", &mockDB{prdCount: tc.prdCount}, WithInventory(&mockInventory{onHand: 7}, 0))
+		serve(t, srv)
+
+		resp := postGraphQL(t, tc.query)
+
+		if tc.codeExpected != "" {
+			if len(resp.Errors) == 0 || resp.Errors[0].Extensions["code"] != tc.codeExpected {
+				t.Errorf("%s:\n errors got: %+v\n code expected: %s", desc, resp.Errors, tc.codeExpected)
+			}
+		} else if len(resp.Errors) > 0 || !strings.Contains(string(resp.Data), tc.dataExpected) {
+			t.Errorf("%s:\n data got: %s\n errors got: %+v\n data expected: %s", desc, resp.Data, resp.Errors, tc.dataExpected)
+		}
+
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}
+}
+
+func TestGraphQLBatching(t *testing.T) {
+	var (
+		mdb = &mockDB{prdCount: 3}
+		inv = &mockInventory{onHand: 7}
+		srv = NewServer("8081", mdb, WithInventory(inv, 0))
+	)
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	resp := postGraphQL(t, `{
+		a: product(sku:"FAL-1000000"){sku availability{available}}
+		b: product(sku:"FAL-1000001"){sku availability{available}}
+		products{nodes{sku availability{available warehouses{warehouse}}}}
+	}`)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("errors not expected: %+v", resp.Errors)
+	}
+
+	if mdb.getManyCalls != 1 {
+		t.Errorf("#1: products by sku must be fetched in a single query. Queries: %d", mdb.getManyCalls)
+	}
+
+	if inv.stockCalls > 2 {
+		t.Errorf("#2: the availability of the products must be fetched in batches. Queries: %d", inv.stockCalls)
+	}
+}
+
+func TestGraphQLMutations(t *testing.T) {
+	tests := map[string]struct {
+		prdCount     int
+		inStock      bool
+		edit         func(prd *contract.Product)
+		query        string
+		codeExpected string
+	}{
+		"#1: create invalid product":  {prdCount: 0, query: `mutation{createProduct(input:{sku:"FAL-1",name:"name",brand:"brand",size:1,price:100,imageURL:"http://a"}){sku}}`, codeExpected: codeBadUserInput},
+		"#2: create existing product": {prdCount: 1, query: `mutation{createProduct(input:{sku:"FAL-1000000",name:"name",brand:"brand",size:1,price:100,imageURL:"http://a"}){sku}}`, codeExpected: codeConflict},
+		"#3: create valid case":       {prdCount: 0, query: `mutation{createProduct(input:{sku:"FAL-1000000",name:"name",brand:"brand",size:1,price:100,imageURL:"http://a"}){sku}}`},
+		"#4: update not found":        {prdCount: 0, query: `mutation{updateProduct(sku:"FAL-1000000",patch:{name:"new name"}){name}}`, codeExpected: codeNotFound},
+		"#5: update invalid value":    {prdCount: 1, query: `mutation{updateProduct(sku:"FAL-1000000",patch:{name:""}){name}}`, codeExpected: codeBadUserInput},
+		"#6: update valid case":       {prdCount: 1, query: `mutation{updateProduct(sku:"FAL-1000000",patch:{name:"new name"}){name}}`},
+		"#7: delete with stock":       {prdCount: 1, inStock: true, query: `mutation{deleteProduct(sku:"FAL-1000000"){sku}}`, codeExpected: codeConflict},
+		"#8: delete cascade":          {prdCount: 1, inStock: true, query: `mutation{deleteProduct(sku:"FAL-1000000",cascade:true){sku}}`},
+		"#9: update incomplete draft": {prdCount: 1, edit: func(prd *contract.Product) { draft(prd); prd.ImageURL = "" },
+			query: `mutation{updateProduct(sku:"FAL-1000000",patch:{name:"new name"}){name}}`},
+		"#10: update incomplete product": {prdCount: 1, edit: func(prd *contract.Product) { prd.ImageURL = "" },
+			query: `mutation{updateProduct(sku:"FAL-1000000",patch:{name:"new name"}){name}}`, codeExpected: codeBadUserInput},
+	}
+
+	for desc, tc := range tests {
+		srv := NewServer("8081", &mockDB{prdCount: tc.prdCount, edit: tc.edit, inStock: tc.inStock}, WithInventory(&mockInventory{}, 0))
+		serve(t, srv)
+
+		resp := postGraphQL(t, tc.query)
+
+		var codeGot string
+		if len(resp.Errors) > 0 {
+			codeGot, _ = resp.Errors[0].Extensions["code"].(string)
+		}
+
+		if codeGot != tc.codeExpected {
+			t.Errorf("%s:\n code got: %q\n code expected: %q\n errors: %+v", desc, codeGot, tc.codeExpected, resp.Errors)
+		}
+
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}
+}
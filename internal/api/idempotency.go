@@ -0,0 +1,180 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	//idempotencyKeyHeader identifies a request, so its retries replay the original response instead of applying it again
+	idempotencyKeyHeader = "Idempotency-Key"
+	//idempotentReplayedHeader is set in the responses replayed for a retried request
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+
+	//defaultIdempotencyTTL how long the responses are replayed by default
+	defaultIdempotencyTTL = 24 * time.Hour
+	//idempotencySweepInterval how often the expired responses are deleted
+	idempotencySweepInterval = time.Hour
+)
+
+//headers of the responses replayed along with their status and body
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+//responseRecorder writes the response through, keeping its status and body
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+//replays the response of the previous request of the same user with the same Idempotency-Key header. The first
+//request stores its response, unless it fails with a server error so it can be retried. Reusing a key with a
+//different request fails
+func (s *server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get(idempotencyKeyHeader)
+		if key == "" || s.idempotency == nil {
+			next(w, req)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			writeResponse(w, http.StatusBadRequest, fmt.Sprintf("%s is longer than %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength))
+			return
+		}
+
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			logrus.Errorf("could not read the body %v", err)
+			writeResponse(w, http.StatusBadRequest, "could not read the body")
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		key = scopedIdempotencyKey(req, key)
+		fingerprint := requestFingerprint(req, body)
+
+		stored, err := s.idempotency.ClaimIdempotencyKey(key, fingerprint, s.idempotencyTTL)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			writeResponse(w, http.StatusInternalServerError, "could not check the idempotency key")
+			return
+		}
+
+		switch {
+		case stored == nil:
+			s.recordResponse(w, req, key, next)
+		case stored.Fingerprint != fingerprint:
+			writeResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("%s was already used with a different request", idempotencyKeyHeader))
+		case stored.Status == 0:
+			writeResponse(w, http.StatusConflict, fmt.Sprintf("a request with the same %s is in progress", idempotencyKeyHeader))
+		default:
+			for name, value := range stored.Header {
+				w.Header().Set(name, value)
+			}
+			w.Header().Set(idempotentReplayedHeader, "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+		}
+	})
+}
+
+//serves the request that claimed the key and stores its response
+func (s *server) recordResponse(w http.ResponseWriter, req *http.Request, key string, next http.HandlerFunc) {
+	rec := &responseRecorder{ResponseWriter: w}
+	next(rec, req)
+
+	if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+		if err := s.idempotency.ReleaseIdempotencyKey(key); err != nil {
+			logrus.Errorf("db error: %v", err)
+		}
+		return
+	}
+
+	resp := contract.IdempotentResponse{
+		Key:    key,
+		Status: rec.status,
+		Header: make(map[string]string, len(replayedHeaders)),
+		Body:   rec.body.Bytes(),
+	}
+
+	for _, name := range replayedHeaders {
+		if value := w.Header().Get(name); value != "" {
+			resp.Header[name] = value
+		}
+	}
+
+	if err := s.idempotency.StoreIdempotentResponse(resp); err != nil {
+		logrus.Errorf("db error: %v", err)
+	}
+}
+
+//scopes the key by the user making the request, so the keys chosen by different users never replay the responses
+//of each other
+func scopedIdempotencyKey(req *http.Request, key string) string {
+	sum := sha256.Sum256([]byte(actor(req) + "\n" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+//identifies a request by its method, path, user, the headers changing its outcome or its format and its body
+func requestFingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "%s %s\n", req.Method, req.URL.RequestURI())
+	fmt.Fprintf(h, "%s: %s\n", userHeader, actor(req))
+	for _, name := range []string{skuRuleHeader, sellerHeader, "Accept", "Accept-Language", "Content-Type"} {
+		fmt.Fprintf(h, "%s: %s\n", name, req.Header.Get(name))
+	}
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+//deletes the expired responses periodically until the context is cancelled
+func (s *server) sweepIdempotencyKeys(ctx context.Context) {
+	ticker := time.NewTicker(idempotencySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.idempotency.DeleteExpiredIdempotencyKeys()
+			if err != nil {
+				logrus.Errorf("could not delete expired idempotency keys: %v", err)
+				continue
+			}
+
+			if n > 0 {
+				logrus.Infof("%d expired idempotency key(s) deleted", n)
+			}
+		}
+	}
+}
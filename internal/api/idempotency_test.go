@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestIdempotent(t *testing.T) {
+	const (
+		prd      = `{"sku":"FAL-1000000","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a"}`
+		otherPrd = `{"sku":"FAL-1000001","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a"}`
+	)
+
+	var (
+		mdb   = &mockDB{}
+		store = &mockIdempotency{resps: map[string]contract.IdempotentResponse{}}
+		srv   = NewServer("8081", mdb, WithIdempotency(store, 0))
+	)
+
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	//a request with the same key is in progress
+	busy, _ := http.NewRequest(http.MethodPost, "/product", nil)
+	busy.Header.Set("Content-Type", "application/json")
+	busyKey := scopedIdempotencyKey(busy, "busy")
+	store.resps[busyKey] = contract.IdempotentResponse{Key: busyKey, Fingerprint: requestFingerprint(busy, []byte(prd))}
+
+	//the steps depend on the previous ones
+	tests := []struct {
+		desc             string
+		key              string
+		user             string
+		accept           string
+		body             string
+		conflicts        int32
+		throwError       bool
+		statusExpected   int
+		replayedExpected bool
+	}{
+		{desc: "#1: first request", key: "a", body: prd, statusExpected: http.StatusCreated},
+		{desc: "#2: retried request", key: "a", body: prd, conflicts: 1, statusExpected: http.StatusCreated, replayedExpected: true},
+		{desc: "#3: key reused", key: "a", body: otherPrd, statusExpected: http.StatusUnprocessableEntity},
+		{desc: "#4: without key", body: prd, conflicts: 1, statusExpected: http.StatusConflict},
+		{desc: "#5: failed request", key: "b", body: prd, throwError: true, statusExpected: http.StatusInternalServerError},
+		{desc: "#6: failed request retried", key: "b", body: prd, statusExpected: http.StatusCreated},
+		{desc: "#7: request in progress", key: "busy", body: prd, statusExpected: http.StatusConflict},
+		{desc: "#8: key too long", key: strings.Repeat("k", maxIdempotencyKeyLength+1), body: prd, statusExpected: http.StatusBadRequest},
+		{desc: "#9: key of another user", key: "a", user: "bob", body: prd, conflicts: 1, statusExpected: http.StatusConflict},
+		{desc: "#10: key reused with another format", key: "a", accept: "application/xml", body: prd, statusExpected: http.StatusUnprocessableEntity},
+	}
+
+	var first string
+	for _, tc := range tests {
+		mdb.conflicts, mdb.throwError = tc.conflicts, tc.throwError
+
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost:8081/product", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.key != "" {
+			req.Header.Set(idempotencyKeyHeader, tc.key)
+		}
+		if tc.user != "" {
+			req.Header.Set(userHeader, tc.user)
+		}
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		replayed := resp.Header.Get(idempotentReplayedHeader) == "true"
+		if resp.StatusCode != tc.statusExpected || replayed != tc.replayedExpected {
+			t.Errorf("%s:\n Got: %v replayed: %v %s\n Expected: %v replayed: %v", tc.desc, resp.StatusCode, replayed, body, tc.statusExpected, tc.replayedExpected)
+		}
+
+		if tc.key == "a" && tc.user == "" && resp.StatusCode == http.StatusCreated {
+			if first == "" {
+				first = string(body)
+			} else if string(body) != first || resp.Header.Get("Location") != "/product/FAL-1000000" {
+				t.Errorf("%s:\n the original response expected. Got: %s %s", tc.desc, resp.Header.Get("Location"), body)
+			}
+		}
+	}
+}
@@ -0,0 +1,495 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/media"
+	"github.com/garciacer87/product-api/internal/storage"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	//imageField form field of the uploaded image file
+	imageField = "image"
+	//imageRoleMain sets the uploaded image as the main image of the product, while imageRoleAlt adds it to the
+	//alternative images
+	imageRoleMain = "main"
+	imageRoleAlt  = "alt"
+
+	//maxFormOverhead room for the multipart headers on top of the maximum image size
+	maxFormOverhead = 1 << 20
+	//imageSweepInterval how often the images of the deleted products are removed
+	imageSweepInterval = time.Hour
+	//orphanBatchSize how many images of deleted products are removed at once
+	orphanBatchSize = 100
+	//imageCacheControl the stored images never change, since every upload gets a new key
+	imageCacheControl = "public, max-age=31536000, immutable"
+)
+
+//errMainImage returned when deleting the image that is the main image of its product
+var errMainImage = errors.New("the image is the main image of the product")
+
+// uploadImage godoc
+// @Summary Uploads an image of a product
+// @Description Stores a JPEG, PNG or GIF image along with its thumbnail and references it from the product, as its main image or as an alternative one. The type is detected from the content, and the size and dimensions must be within the configured limits
+// @Tags product image
+// @Accept mpfd
+// @Success 201 {object} contract.ProductImage
+// @Failure 400,404,413,415,500 {object} contract.Response{status=int,message=object}
+// @Param sku path string true "sku product"
+// @Param image formData file true "image file"
+// @Param role query string false "main replaces the main image, removing the replaced uploaded image, alt adds an alternative image. Default: alt" Enums(main, alt)
+// @Router /product/{sku}/images [post]
+func (s *server) uploadImage(w http.ResponseWriter, req *http.Request) {
+	sku := mux.Vars(req)["sku"]
+
+	role := req.URL.Query().Get("role")
+	if role == "" {
+		role = imageRoleAlt
+	}
+
+	if role != imageRoleMain && role != imageRoleAlt {
+		writeResponse(w, http.StatusBadRequest, fmt.Sprintf("role must be %s or %s", imageRoleMain, imageRoleAlt))
+		return
+	}
+
+	ctx, ok := s.withSKURule(req)
+	if !ok {
+		writeResponse(w, http.StatusBadRequest, fmt.Sprintf("unknown sku rule %s", req.Header.Get(skuRuleHeader)))
+		return
+	}
+
+	data, status, err := s.readImage(w, req)
+	if err != nil {
+		writeResponse(w, status, err.Error())
+		return
+	}
+
+	img, err := media.Process(data, s.imageLimits)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrTooLarge):
+			writeResponse(w, http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, media.ErrUnsupportedType):
+			writeResponse(w, http.StatusUnsupportedMediaType, err.Error())
+		case errors.Is(err, media.ErrInvalidImage):
+			writeResponse(w, http.StatusBadRequest, err.Error())
+		default:
+			logrus.Errorf("could not process the image: %v", err)
+			writeResponse(w, http.StatusInternalServerError, "could not process the image")
+		}
+		return
+	}
+
+	created, err := s.storeImage(sku, img)
+	if err != nil {
+		logrus.Errorf("could not store the image: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not store the image")
+		return
+	}
+
+	//main image replaced by the uploaded one
+	var replaced string
+
+	prd, err := s.db.UpdateFunc(sku, func(prd *contract.Product) error {
+		if role == imageRoleMain {
+			replaced = prd.ImageURL
+			prd.ImageURL = created.URL
+		} else {
+			prd.AltImages = append(prd.AltImages, created.URL)
+		}
+		prd.UpdatedBy = actor(req)
+
+		if err := s.validate(ctx, *prd); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		//the image is not referenced by the product, so it is removed right away
+		if rmErr := s.removeImage(*created); rmErr != nil {
+			logrus.Errorf("could not remove the image %d: %v", created.ID, rmErr)
+		}
+
+		var vErr *validationError
+
+		switch {
+		case errors.As(err, &vErr):
+			writeResponse(w, http.StatusBadRequest, vErr.errs)
+		case errors.Is(err, db.ErrNotFound):
+			writeResponse(w, http.StatusNotFound, "product not found")
+		default:
+			logrus.Errorf("error updating product: %s", err)
+			writeResponse(w, http.StatusInternalServerError, "could not update product")
+		}
+		return
+	}
+
+	logrus.Infof("Image %d of product %s uploaded", created.ID, sku)
+
+	if replaced != "" {
+		s.removeReplacedImage(*prd, replaced)
+	}
+
+	body, _ := json.Marshal(created)
+	w.Header().Set("Location", imagePath(sku, created.ID))
+	writeJSONResponse(w, http.StatusCreated, body)
+}
+
+// getImages godoc
+// @Summary Retrieves the images of a product
+// @Description Retrieves the images uploaded for a product in upload order
+// @Tags product image
+// @Success 200 {array} contract.ProductImage
+// @Failure 404,500 {object} contract.Response{status=int,message=object}
+// @Param sku path string true "sku product"
+// @Router /product/{sku}/images [get]
+func (s *server) getImages(w http.ResponseWriter, req *http.Request) {
+	imgs, err := s.images.Images(mux.Vars(req)["sku"])
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not get images")
+		return
+	}
+
+	for i := range imgs {
+		s.setImageURLs(&imgs[i])
+	}
+
+	body, _ := json.Marshal(imgs)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// deleteImage godoc
+// @Summary Deletes an image of a product
+// @Description Removes the image from the alternative images of the product and deletes it along with its thumbnail. The main image cannot be deleted until it is replaced
+// @Tags product image
+// @Success 204 {string} string "image deleted"
+// @Failure 400,404,409,500 {object} contract.Response{status=int,message=object}
+// @Param sku path string true "sku product"
+// @Param id path int true "image id"
+// @Router /product/{sku}/images/{id} [delete]
+func (s *server) deleteImage(w http.ResponseWriter, req *http.Request) {
+	sku := mux.Vars(req)["sku"]
+
+	id, ok := pathID(w, req)
+	if !ok {
+		return
+	}
+
+	img, err := s.images.Image(id)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not delete image")
+		return
+	}
+
+	if img == nil || img.SKU != sku {
+		writeResponse(w, http.StatusNotFound, "image not found")
+		return
+	}
+	s.setImageURLs(img)
+
+	_, err = s.db.UpdateFunc(sku, func(prd *contract.Product) error {
+		if prd.ImageURL == img.URL {
+			return errMainImage
+		}
+
+		alt := prd.AltImages[:0]
+		for _, u := range prd.AltImages {
+			if u != img.URL {
+				alt = append(alt, u)
+			}
+		}
+		prd.AltImages = alt
+		prd.UpdatedBy = actor(req)
+
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errMainImage):
+			writeResponse(w, http.StatusConflict, "the image is the main image of the product. Replace it before deleting the image")
+		case errors.Is(err, db.ErrNotFound):
+			writeResponse(w, http.StatusNotFound, "product not found")
+		default:
+			logrus.Errorf("error updating product: %s", err)
+			writeResponse(w, http.StatusInternalServerError, "could not delete image")
+		}
+		return
+	}
+
+	if err := s.removeImage(*img); err != nil {
+		logrus.Errorf("could not remove the image %d: %v", img.ID, err)
+		writeResponse(w, http.StatusInternalServerError, "could not delete image")
+		return
+	}
+
+	logrus.Infof("Image %d of product %s deleted", img.ID, sku)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveImage godoc
+// @Summary Serves an uploaded image
+// @Description Serves an uploaded image or thumbnail from the storage. The images never change, so they can be cached forever
+// @Tags product image
+// @Produce image/jpeg,image/png,image/gif
+// @Success 200 {file} file
+// @Failure 404,500 {object} contract.Response{status=int,message=object}
+// @Param key path string true "key of the image"
+// @Router /images/{key} [get]
+// @Router /images/{key} [head]
+func (s *server) serveImage(w http.ResponseWriter, req *http.Request) {
+	key := mux.Vars(req)["key"]
+	if !storage.ValidKey(key) {
+		writeResponse(w, http.StatusNotFound, "image not found")
+		return
+	}
+
+	obj, err := s.storage.Get(req.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeResponse(w, http.StatusNotFound, "image not found")
+			return
+		}
+		logrus.Errorf("could not get the image %s: %v", key, err)
+		writeResponse(w, http.StatusInternalServerError, "could not get the image")
+		return
+	}
+	defer obj.Body.Close()
+
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("Cache-Control", imageCacheControl)
+	if obj.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	}
+
+	if _, err := io.Copy(w, obj.Body); err != nil {
+		logrus.Errorf("could not serve the image %s: %v", key, err)
+	}
+}
+
+//reads the image file of the multipart form, limiting the size of the body. Returns the status of the error
+func (s *server) readImage(w http.ResponseWriter, req *http.Request) ([]byte, int, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return nil, http.StatusUnsupportedMediaType, errors.New("Content-Type must be multipart/form-data")
+	}
+
+	req.Body = http.MaxBytesReader(w, req.Body, s.imageLimits.MaxSize+maxFormOverhead)
+
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("could not read the form: %v", err)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, http.StatusBadRequest, fmt.Errorf("the %s file is required", imageField)
+		}
+		if err != nil {
+			return nil, formErrorStatus(err), fmt.Errorf("could not read the form: %v", err)
+		}
+
+		if part.FormName() != imageField {
+			part.Close()
+			continue
+		}
+
+		//one more byte than the maximum size tells that the image is too large
+		data, err := ioutil.ReadAll(io.LimitReader(part, s.imageLimits.MaxSize+1))
+		part.Close()
+		if err != nil {
+			return nil, formErrorStatus(err), fmt.Errorf("could not read the %s file: %v", imageField, err)
+		}
+
+		return data, 0, nil
+	}
+}
+
+//status of an error reading the form, which fails when the body exceeds the limit of http.MaxBytesReader
+func formErrorStatus(err error) int {
+	if strings.Contains(err.Error(), "request body too large") {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return http.StatusBadRequest
+}
+
+//stores the image and its thumbnail with random keys, so they can be cached forever, and registers them
+func (s *server) storeImage(sku string, img *media.Image) (*contract.ProductImage, error) {
+	name, err := randomName()
+	if err != nil {
+		return nil, err
+	}
+
+	stored := contract.ProductImage{
+		SKU:          sku,
+		ContentType:  img.ContentType,
+		Width:        img.Width,
+		Height:       img.Height,
+		Size:         int64(len(img.Data)),
+		Key:          "products/" + name + media.Formats[img.ContentType],
+		ThumbnailKey: "products/" + name + "_thumb" + media.Formats[img.ThumbnailContentType],
+	}
+
+	ctx := context.Background()
+
+	if err := s.storage.Put(ctx, stored.Key, img.Data, img.ContentType); err != nil {
+		return nil, err
+	}
+
+	if err := s.storage.Put(ctx, stored.ThumbnailKey, img.Thumbnail, img.ThumbnailContentType); err != nil {
+		s.deleteObjects(stored.Key)
+		return nil, err
+	}
+
+	created, err := s.images.CreateImage(stored)
+	if err != nil {
+		s.deleteObjects(stored.Key, stored.ThumbnailKey)
+		return nil, err
+	}
+
+	s.setImageURLs(created)
+	return created, nil
+}
+
+//deletes the files of the image from the storage, and then the image
+func (s *server) removeImage(img contract.ProductImage) error {
+	ctx := context.Background()
+
+	for _, key := range []string{img.Key, img.ThumbnailKey} {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	if err := s.images.DeleteImage(img.ID); err != nil && !errors.Is(err, db.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+//removes the uploaded image that was the main image of the product, unless the product still references it as an
+//alternative image. The images not uploaded to the API are left alone
+func (s *server) removeReplacedImage(prd contract.Product, url string) {
+	if contains(prd.AltImages, url) {
+		return
+	}
+
+	imgs, err := s.images.Images(prd.SKU)
+	if err != nil {
+		logrus.Errorf("could not get the images of product %s: %v", prd.SKU, err)
+		return
+	}
+
+	for _, img := range imgs {
+		s.setImageURLs(&img)
+		if img.URL != url {
+			continue
+		}
+
+		if err := s.removeImage(img); err != nil {
+			logrus.Errorf("could not remove the image %d: %v", img.ID, err)
+			return
+		}
+
+		logrus.Infof("Image %d of product %s replaced", img.ID, prd.SKU)
+		return
+	}
+}
+
+//deletes the objects of an image that could not be registered
+func (s *server) deleteObjects(keys ...string) {
+	for _, key := range keys {
+		if err := s.storage.Delete(context.Background(), key); err != nil {
+			logrus.Errorf("could not delete the object %s: %v", key, err)
+		}
+	}
+}
+
+//removes the images of a deleted product. The images that cannot be removed are retried by sweepImages
+func (s *server) removeProductImages(sku string) {
+	imgs, err := s.images.Images(sku)
+	if err != nil {
+		logrus.Errorf("could not get the images of product %s: %v", sku, err)
+		return
+	}
+
+	for _, img := range imgs {
+		if err := s.removeImage(img); err != nil {
+			logrus.Errorf("could not remove the image %d: %v", img.ID, err)
+		}
+	}
+}
+
+//removes the images of the products deleted by other means than the API, like the gRPC API or the command line,
+//and the ones that could not be removed when the product was deleted
+func (s *server) sweepImages(ctx context.Context) {
+	ticker := time.NewTicker(imageSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			imgs, err := s.images.OrphanImages(orphanBatchSize)
+			if err != nil {
+				logrus.Errorf("could not get the orphan images: %v", err)
+				continue
+			}
+
+			removed := 0
+			for _, img := range imgs {
+				if err := s.removeImage(img); err != nil {
+					logrus.Errorf("could not remove the image %d: %v", img.ID, err)
+					continue
+				}
+				removed++
+			}
+
+			if removed > 0 {
+				logrus.Infof("%d orphan image(s) removed", removed)
+			}
+		}
+	}
+}
+
+//sets the URLs the image and its thumbnail are served from
+func (s *server) setImageURLs(img *contract.ProductImage) {
+	img.URL = s.imagesURL + "/images/" + img.Key
+	img.ThumbnailURL = s.imagesURL + "/images/" + img.ThumbnailKey
+}
+
+//path of the image resource
+func imagePath(sku string, id int64) string {
+	return fmt.Sprintf("%s/images/%d", productPath(sku), id)
+}
+
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate the name of the image: %v", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/media"
+	"github.com/garciacer87/product-api/internal/storage"
+)
+
+func encodeTestPNG(w, h int) []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, w, h)))
+	return buf.Bytes()
+}
+
+//builds a multipart form with the file in the given field
+func imageForm(field string, data []byte) (string, *bytes.Buffer) {
+	var body bytes.Buffer
+
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile(field, "image.png")
+	part.Write(data)
+	form.Close()
+
+	return form.FormDataContentType(), &body
+}
+
+func TestUploadImage(t *testing.T) {
+	objects, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create the storage: %v", err)
+	}
+
+	limits := media.Limits{MaxSize: 64 << 10, MinWidth: 100, MinHeight: 100, MaxWidth: 1000, MaxHeight: 1000, ThumbnailSize: 64}
+
+	mdb := &mockDB{prdCount: 1}
+	images := &mockImages{}
+
+	srv := NewServer("8081", mdb, WithImages(images, objects, "http://cdn.local/", limits))
+	serve(t, srv)
+
+	defer func() {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}()
+
+	tests := map[string]struct {
+		url             string
+		field           string
+		data            []byte
+		contentType     string
+		statusExpected  int
+		messageExpected string
+		mainExpected    bool
+	}{
+		"#1: alternative image": {url: "/product/FAL-1000000/images", field: "image", data: encodeTestPNG(400, 200), statusExpected: http.StatusCreated},
+		"#2: main image":        {url: "/product/FAL-1000000/images?role=main", field: "image", data: encodeTestPNG(200, 200), statusExpected: http.StatusCreated, mainExpected: true},
+		"#3: other product":     {url: "/product/FAL-2000000/images", field: "image", data: encodeTestPNG(200, 200), statusExpected: http.StatusCreated},
+		"#4: invalid role":      {url: "/product/FAL-1000000/images?role=other", field: "image", data: encodeTestPNG(200, 200), statusExpected: http.StatusBadRequest, messageExpected: "role must be main or alt"},
+		"#5: too small":         {url: "/product/FAL-1000000/images", field: "image", data: encodeTestPNG(50, 200), statusExpected: http.StatusBadRequest, messageExpected: "must be between 100x100 and 1000x1000"},
+		"#6: too large":         {url: "/product/FAL-1000000/images", field: "image", data: bytes.Repeat([]byte("a"), 65<<10), statusExpected: http.StatusRequestEntityTooLarge},
+		"#7: unsupported type":  {url: "/product/FAL-1000000/images", field: "image", data: []byte("GIF87 is not a gif"), statusExpected: http.StatusUnsupportedMediaType},
+		"#8: missing file":      {url: "/product/FAL-1000000/images", field: "file", data: encodeTestPNG(200, 200), statusExpected: http.StatusBadRequest, messageExpected: "the image file is required"},
+		"#9: not a form":        {url: "/product/FAL-1000000/images", data: encodeTestPNG(200, 200), contentType: "image/png", statusExpected: http.StatusUnsupportedMediaType},
+	}
+
+	for desc, tc := range tests {
+		contentType, body := imageForm(tc.field, tc.data)
+		if tc.contentType != "" {
+			contentType, body = tc.contentType, bytes.NewBuffer(tc.data)
+		}
+
+		resp, err := http.Post("http://localhost:8081"+tc.url, contentType, body)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected || !strings.Contains(string(respBody), tc.messageExpected) {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v %s", desc, resp.StatusCode, respBody, tc.statusExpected, tc.messageExpected)
+			continue
+		}
+
+		if resp.StatusCode != http.StatusCreated {
+			continue
+		}
+
+		img := contract.ProductImage{}
+		json.Unmarshal(respBody, &img)
+
+		if !strings.HasPrefix(img.URL, "http://cdn.local/images/products/") || img.ContentType != "image/png" || resp.Header.Get("Location") == "" {
+			t.Errorf("%s:\n image got: %+v\n location: %s", desc, img, resp.Header.Get("Location"))
+		}
+
+		prd := mdb.saved.Load().(contract.Product)
+		if tc.mainExpected != (prd.ImageURL == img.URL) || tc.mainExpected == contains(prd.AltImages, img.URL) {
+			t.Errorf("%s:\n product images got: %s %v", desc, prd.ImageURL, prd.AltImages)
+		}
+
+		//the image and its thumbnail are served from the storage
+		for _, u := range []string{img.URL, img.ThumbnailURL} {
+			resp, err := http.Get(strings.Replace(u, "http://cdn.local", "http://localhost:8081", 1))
+			if err != nil {
+				t.Fatalf("error not expected")
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" || resp.Header.Get("Cache-Control") != imageCacheControl {
+				t.Errorf("%s:\n %s got: %v %s", desc, u, resp.StatusCode, resp.Header.Get("Content-Type"))
+			}
+		}
+	}
+}
+
+func TestReplaceMainImage(t *testing.T) {
+	objects, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create the storage: %v", err)
+	}
+
+	mdb := &mockDB{prdCount: 1}
+	images := &mockImages{}
+
+	srv := NewServer("8081", mdb, WithImages(images, objects, "http://localhost:8081", media.DefaultLimits))
+	serve(t, srv)
+
+	defer func() {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}()
+
+	upload := func(url string) contract.ProductImage {
+		contentType, body := imageForm("image", encodeTestPNG(200, 200))
+		resp, err := http.Post("http://localhost:8081"+url, contentType, body)
+		if err != nil || resp.StatusCode != http.StatusCreated {
+			t.Fatalf("could not upload the image: %v", err)
+		}
+		defer resp.Body.Close()
+
+		img := contract.ProductImage{}
+		json.NewDecoder(resp.Body).Decode(&img)
+		return img
+	}
+
+	tests := []struct {
+		desc            string
+		altImage        bool
+		removedExpected bool
+	}{
+		{desc: "#1: uploaded main image", removedExpected: true},
+		{desc: "#2: main image also an alternative image", altImage: true},
+	}
+
+	for _, tc := range tests {
+		old := upload("/product/FAL-1000000/images")
+		mdb.edit = func(prd *contract.Product) {
+			prd.ImageURL, prd.AltImages = old.URL, nil
+			if tc.altImage {
+				prd.AltImages = []string{old.URL}
+			}
+		}
+
+		upload("/product/FAL-1000000/images?role=main")
+
+		_, err := images.Image(old.ID)
+		removed := errors.Is(err, db.ErrNotFound)
+
+		resp, err := http.Get(old.URL)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+		resp.Body.Close()
+
+		if removed != tc.removedExpected || (resp.StatusCode == http.StatusNotFound) != tc.removedExpected {
+			t.Errorf("%s:\n Got: removed %v, served %v\n Expected: removed %v", tc.desc, removed, resp.StatusCode, tc.removedExpected)
+		}
+	}
+
+	//the images not uploaded to the API are left alone
+	mdb.edit = nil
+	count := len(images.images)
+	upload("/product/FAL-1000000/images?role=main")
+
+	if len(images.images) != count+1 {
+		t.Errorf("#3: external main image:\n Got: %d images\n Expected: %d images", len(images.images), count+1)
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	objects, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create the storage: %v", err)
+	}
+
+	images := &mockImages{}
+
+	srv := NewServer("8081", &mockDB{prdCount: 1}, WithImages(images, objects, "http://localhost:8081", media.DefaultLimits))
+	serve(t, srv)
+
+	defer func() {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}()
+
+	for _, sku := range []string{"FAL-1000000", "FAL-1000000", "FAL-2000000"} {
+		contentType, body := imageForm("image", encodeTestPNG(200, 200))
+		resp, err := http.Post("http://localhost:8081/product/"+sku+"/images", contentType, body)
+		if err != nil || resp.StatusCode != http.StatusCreated {
+			t.Fatalf("could not upload the image: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	//the steps depend on the previous ones
+	tests := []struct {
+		desc           string
+		url            string
+		statusExpected int
+		imagesExpected int
+	}{
+		{desc: "#1: image", url: "/product/FAL-1000000/images/1", statusExpected: http.StatusNoContent, imagesExpected: 2},
+		{desc: "#2: deleted image", url: "/product/FAL-1000000/images/1", statusExpected: http.StatusNotFound, imagesExpected: 2},
+		{desc: "#3: image of other product", url: "/product/FAL-1000000/images/3", statusExpected: http.StatusNotFound, imagesExpected: 2},
+		{desc: "#4: invalid id", url: "/product/FAL-1000000/images/a", statusExpected: http.StatusNotFound, imagesExpected: 2},
+		{desc: "#5: product", url: "/product/FAL-1000000", statusExpected: http.StatusNoContent, imagesExpected: 1},
+		{desc: "#6: image of remaining product", url: "/product/FAL-2000000/images/3", statusExpected: http.StatusNoContent, imagesExpected: 0},
+	}
+
+	for _, tc := range tests {
+		req, _ := http.NewRequest(http.MethodDelete, "http://localhost:8081"+tc.url, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected || len(images.images) != tc.imagesExpected {
+			t.Errorf("%s:\n Got: %v %d images\n Expected: %v %d images", tc.desc, resp.StatusCode, len(images.images), tc.statusExpected, tc.imagesExpected)
+		}
+	}
+
+	resp, err := http.Get("http://localhost:8081/images/products/missing.png")
+	if err != nil {
+		t.Fatalf("error not expected")
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("a missing image got: %v", resp.StatusCode)
+	}
+}
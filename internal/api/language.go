@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/i18n"
+)
+
+//defaultLanguage language of the names and descriptions of the products unless configured with WithDefaultLanguage
+const defaultLanguage = "en"
+
+//passes the languages of the Accept-Language header, followed by their fallbacks, through the request context.
+//They select the translations of the products and the language of the validation messages
+func (s *server) languages(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tags := i18n.ParseAcceptLanguage(req.Header.Get("Accept-Language"))
+		if len(tags) == 0 {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		next.ServeHTTP(w, req.WithContext(i18n.WithLanguages(req.Context(), i18n.Fallbacks(tags))))
+	})
+}
+
+//localizes the names and descriptions of the products in the languages of the request, reporting the languages
+//of the response in the Content-Language header. Products are not localized without an Accept-Language header,
+//so they keep their translations
+func (s *server) localize(w http.ResponseWriter, req *http.Request, prds ...*contract.Product) {
+	if req.Header.Get("Accept-Language") == "" {
+		return
+	}
+
+	var (
+		langs = i18n.Languages(req.Context())
+		used  []string
+		seen  = make(map[string]bool)
+	)
+
+	for _, prd := range prds {
+		lang := prd.Localize(langs)
+		if lang == "" {
+			lang = s.defaultLanguage
+		}
+
+		if !seen[lang] {
+			seen[lang] = true
+			used = append(used, lang)
+		}
+	}
+
+	if len(used) > 0 {
+		w.Header().Set("Content-Language", strings.Join(used, ", "))
+	}
+}
+
+//keys the translations by the canonical form of their language tags, which is the one looked up. The invalid
+//tags are kept, so the validation rejects them
+func canonicalTranslations(translations map[string]contract.ProductTranslation) map[string]contract.ProductTranslation {
+	if translations == nil {
+		return nil
+	}
+
+	canonical := make(map[string]contract.ProductTranslation, len(translations))
+	for tag, tr := range translations {
+		if c, err := i18n.Canonical(tag); err == nil {
+			tag = c
+		}
+		canonical[tag] = tr
+	}
+
+	return canonical
+}
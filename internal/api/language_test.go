@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestLocalize(t *testing.T) {
+	srv := NewServer("8081", &mockDB{prdCount: 1}, WithDefaultLanguage("en-US"))
+
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	tests := map[string]struct {
+		path                string
+		acceptLanguage      string
+		nameExpected        string
+		descriptionExpected string
+		languageExpected    string
+		translatedExpected  bool
+	}{
+		"#1: without languages":      {path: "/product/FAL-1000000", nameExpected: "name", translatedExpected: true},
+		"#2: translated language":    {path: "/product/FAL-1000000", acceptLanguage: "es", nameExpected: "nombre", descriptionExpected: "descripción", languageExpected: "es"},
+		"#3: regional language":      {path: "/product/FAL-1000000", acceptLanguage: "pt-BR", nameExpected: "nome", languageExpected: "pt"},
+		"#4: preferred language":     {path: "/product/FAL-1000000", acceptLanguage: "es;q=0.5, pt", nameExpected: "nome", descriptionExpected: "descripción", languageExpected: "pt"},
+		"#5: untranslated language":  {path: "/product/FAL-1000000", acceptLanguage: "fr", nameExpected: "name", languageExpected: "en-US"},
+		"#6: wildcard":               {path: "/product/FAL-1000000", acceptLanguage: "*", nameExpected: "name", languageExpected: "en-US"},
+		"#7: list without languages": {path: "/product", nameExpected: "name", translatedExpected: true},
+		"#8: list translated":        {path: "/product", acceptLanguage: "es-AR", nameExpected: "nombre", descriptionExpected: "descripción", languageExpected: "es"},
+	}
+
+	for desc, tc := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost:8081"+tc.path, nil)
+		if tc.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v", desc, resp.StatusCode, body, http.StatusOK)
+			continue
+		}
+
+		prd := contract.Product{}
+		if strings.HasPrefix(tc.path, "/product/") {
+			err = json.Unmarshal(body, &prd)
+		} else {
+			prds := []contract.Product{}
+			if err = json.Unmarshal(body, &prds); err == nil && len(prds) > 0 {
+				prd = prds[0]
+			}
+		}
+		if err != nil {
+			t.Errorf("%s:\n could not decode the response: %v", desc, err)
+			continue
+		}
+
+		if prd.Name != tc.nameExpected || prd.Description != tc.descriptionExpected || (prd.Translations != nil) != tc.translatedExpected {
+			t.Errorf("%s:\n Got: %q %q %v\n Expected: %q %q translations: %v", desc, prd.Name, prd.Description, prd.Translations,
+				tc.nameExpected, tc.descriptionExpected, tc.translatedExpected)
+		}
+
+		if lang := resp.Header.Get("Content-Language"); lang != tc.languageExpected {
+			t.Errorf("%s:\n Content-Language got: %q\n Expected: %q", desc, lang, tc.languageExpected)
+		}
+
+		if !strings.Contains(strings.Join(resp.Header.Values("Vary"), ","), "Accept-Language") {
+			t.Errorf("%s: the response should vary by Accept-Language", desc)
+		}
+	}
+}
+
+func TestValidationLanguage(t *testing.T) {
+	srv := NewServer("8081", &mockDB{})
+
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	const (
+		prd             = `{"sku":"FAL-1000000","name":"name","brand":"brand","size":10,"price":100,"status":"active"}`
+		invalidLanguage = `{"sku":"FAL-1000000","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a","translations":{"x-!!":{"name":"nombre"}}}`
+	)
+
+	tests := map[string]struct {
+		body            string
+		acceptLanguage  string
+		messageExpected string
+	}{
+		"#1: default language":     {body: prd, messageExpected: "ImageURL must have a value"},
+		"#2: spanish":              {body: prd, acceptLanguage: "es-ES", messageExpected: "ImageURL debe tener un valor"},
+		"#3: portuguese":           {body: prd, acceptLanguage: "pt-BR, es;q=0.5", messageExpected: "ImageURL deve ter um valor"},
+		"#4: unsupported language": {body: prd, acceptLanguage: "fr", messageExpected: "ImageURL must have a value"},
+		"#5: invalid language tag": {body: invalidLanguage, acceptLanguage: "es", messageExpected: "Translations[x-!!] no es una etiqueta de idioma válida"},
+		"#6: default translations": {body: `{"skus":[]}`, acceptLanguage: "es", messageExpected: "SKUs debe contener al menos 1 elemento"},
+		"#7: invalid translation":  {body: strings.Replace(invalidLanguage, `"x-!!":{"name":"nombre"}`, `"es":{"name":" "}`, 1), acceptLanguage: "pt", messageExpected: "Name está em branco"},
+		"#8: empty translation":    {body: strings.Replace(invalidLanguage, `"x-!!":{"name":"nombre"}`, `"es":{}`, 1), acceptLanguage: "es", messageExpected: "Name debe tener un valor"},
+	}
+
+	for desc, tc := range tests {
+		path := "/product"
+		if strings.Contains(tc.body, "skus") {
+			path = "/product/batch"
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost:8081"+path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), tc.messageExpected) {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v %s", desc, resp.StatusCode, body, http.StatusBadRequest, tc.messageExpected)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+//defaultLoaderWait how long a loader collects keys before fetching them
+const defaultLoaderWait = 2 * time.Millisecond
+
+//loader batches the lookups of a request into a single fetch. The keys requested during the wait
+//window are fetched together and every key is fetched once per request
+type loader struct {
+	wait  time.Duration
+	fetch func(keys []string) (map[string]interface{}, error)
+
+	mu      sync.Mutex
+	pending *loaderBatch
+	batches map[string]*loaderBatch
+}
+
+type loaderBatch struct {
+	keys   []string
+	done   chan struct{}
+	values map[string]interface{}
+	err    error
+}
+
+func newLoader(wait time.Duration, fetch func(keys []string) (map[string]interface{}, error)) *loader {
+	return &loader{
+		wait:    wait,
+		fetch:   fetch,
+		batches: make(map[string]*loaderBatch),
+	}
+}
+
+//load retrieves the value of the key, which is nil when it does not exist
+func (l *loader) load(key string) (interface{}, error) {
+	l.mu.Lock()
+	b := l.schedule(key)
+	l.mu.Unlock()
+
+	<-b.done
+
+	return b.values[key], b.err
+}
+
+//prime schedules the keys without waiting for them, so the keys loaded later by the resolvers of
+//a list are fetched in the same batch
+func (l *loader) prime(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		l.schedule(key)
+	}
+}
+
+//adds the key to the pending batch unless it was already scheduled. Must be called holding the lock
+func (l *loader) schedule(key string) *loaderBatch {
+	if b, ok := l.batches[key]; ok {
+		return b
+	}
+
+	if l.pending == nil {
+		b := &loaderBatch{done: make(chan struct{})}
+		l.pending = b
+		time.AfterFunc(l.wait, func() { l.dispatch(b) })
+	}
+
+	l.pending.keys = append(l.pending.keys, key)
+	l.batches[key] = l.pending
+
+	return l.pending
+}
+
+func (l *loader) dispatch(b *loaderBatch) {
+	l.mu.Lock()
+	if l.pending == b {
+		l.pending = nil
+	}
+	l.mu.Unlock()
+
+	b.values, b.err = l.fetch(b.keys)
+	close(b.done)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoader(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fetches [][]string
+	)
+
+	l := newLoader(10*time.Millisecond, func(keys []string) (map[string]interface{}, error) {
+		mu.Lock()
+		fetches = append(fetches, keys)
+		mu.Unlock()
+
+		values := make(map[string]interface{})
+		for _, key := range keys {
+			if key != "missing" {
+				values[key] = "value of " + key
+			}
+		}
+		return values, nil
+	})
+
+	l.prime("a", "b")
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "b", "c", "c", "missing"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+
+			v, err := l.load(key)
+			if err != nil {
+				t.Errorf("error not expected: %v", err)
+			}
+
+			if key == "missing" && v != nil {
+				t.Errorf("missing key must be nil. Got: %v", v)
+			}
+
+			if key != "missing" && v != "value of "+key {
+				t.Errorf("value of %s different than expected: %v", key, v)
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	if len(fetches) != 1 || len(fetches[0]) != 4 {
+		t.Errorf("#1: keys must be fetched once in a single batch. Got: %v", fetches)
+	}
+
+	//keys already fetched are not fetched again
+	if _, err := l.load("a"); err != nil || len(fetches) != 1 {
+		t.Errorf("#2: loaded keys must be cached. Fetches: %v", fetches)
+	}
+
+	l = newLoader(0, func(keys []string) (map[string]interface{}, error) {
+		return nil, fmt.Errorf("mocked error")
+	})
+
+	if _, err := l.load("a"); err == nil {
+		t.Errorf("#3: error expected")
+	}
+}
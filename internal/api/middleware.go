@@ -1,57 +1,136 @@
 package api
 
 import (
-	"bytes"
-	"encoding/json"
-	"io/ioutil"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/garciacer87/product-api/internal/auth"
 	"github.com/garciacer87/product-api/internal/contract"
-	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/validation"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
-//validates product fields
-func validateProduct(next http.HandlerFunc) http.HandlerFunc {
-	prodValidator := newValidator()
+type contextKey int
+
+const (
+	//product loaded from the database by validateExistence
+	productKey contextKey = iota
+	//product decoded from the request body by decodeProduct
+	bodyKey
+	//user making the request
+	actorKey
+	//set when the SKU of the decoded product was generated by generateSKU
+	generatedKey
+	//user authenticated by authenticate, empty for anonymous requests
+	identityKey
+)
+
+const (
+	//userHeader identifies the user making the request, who is tracked as the author of the changes. It is
+	//ignored when the users are authenticated
+	userHeader = "X-User"
+	//skuRuleHeader selects the rule validating the SKUs of the request by name
+	skuRuleHeader = "X-SKU-Rule"
+	//sellerHeader selects the rule validating the SKUs of the request by seller
+	sellerHeader = "X-Seller"
+)
+
+//validationError holds the translated messages of a failed validation
+type validationError struct {
+	errs []string
+}
+
+func (e *validationError) Error() string {
+	return strings.Join(e.errs, " | ")
+}
 
+//identifies the users by their bearer tokens when the authentication is enabled, instead of the user header.
+//Requests without token are anonymous and requests with an unknown token are rejected
+func (s *server) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		bodyBytes, err := ioutil.ReadAll(req.Body)
-		if err != nil {
-			logrus.Errorf("could not decode the body %v", err)
-			writeResponse(w, http.StatusBadRequest, "could not decode the body")
+		if s.tokens == nil {
+			next.ServeHTTP(w, req)
 			return
 		}
 
-		body := ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
+		var user string
+		if header := req.Header.Get("Authorization"); header != "" {
+			token, ok := auth.Bearer(header)
+			if ok {
+				user, ok = s.tokens.User(token)
+			}
+
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="product-api"`)
+				writeResponse(w, http.StatusUnauthorized, "invalid bearer token")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), identityKey, user)))
+	})
+}
 
+//decodes the product of the body once and passes it through the request context
+func (s *server) decodeProduct(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		prd := contract.Product{}
+		if !decodeBody(w, req, &prd) {
+			return
+		}
 
-		err = json.NewDecoder(body).Decode(&prd)
-		if err != nil {
-			logrus.Errorf("could not decode the body %v", err)
-			writeResponse(w, http.StatusBadRequest, "could not decode the body")
+		//the authors of the changes come from the user header, never from the body
+		prd.CreatedBy, prd.UpdatedBy = "", actor(req)
+		prd.Translations = canonicalTranslations(prd.Translations)
+
+		ctx, ok := s.withSKURule(req)
+		if !ok {
+			writeResponse(w, http.StatusBadRequest, fmt.Sprintf("unknown sku rule %s", req.Header.Get(skuRuleHeader)))
 			return
 		}
 
-		//validates product fields from decoded body
-		err = prodValidator.Struct(prd)
-		if err != nil {
-			errs := prodValidator.translate(err)
-			logrus.Printf("Validation error(s):\n%s", strings.Join(errs, " | "))
-			writeResponse(w, http.StatusBadRequest, errs)
+		next(w, req.WithContext(context.WithValue(ctx, bodyKey, prd)))
+	})
+}
+
+//allocates the SKU of the products decoded without one when the SKUs are generated
+func (s *server) generateSKU(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		prd := bodyFrom(req)
+		if prd.SKU != "" || s.skus == nil {
+			next(w, req)
+			return
+		}
+
+		skus, ok := s.nextSKUs(w, req, 1)
+		if !ok {
+			return
+		}
+		prd.SKU = skus[0]
+
+		ctx := context.WithValue(req.Context(), generatedKey, true)
+		next(w, req.WithContext(context.WithValue(ctx, bodyKey, prd)))
+	})
+}
+
+//validates the fields of the product decoded by decodeProduct
+func (s *server) validateProduct(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := s.validate(req.Context(), bodyFrom(req)); err != nil {
+			writeValidationError(w, err)
 			return
 		}
 
-		req.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
 		next(w, req)
 	})
 }
 
-//validates product fields
-func validateExistence(db db.Database, next http.HandlerFunc) http.HandlerFunc {
+//validates the existence of the product, which is passed through the request context
+func (s *server) validateExistence(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		sku, ok := mux.Vars(req)["sku"]
 		if !ok || sku == "" {
@@ -60,7 +139,7 @@ func validateExistence(db db.Database, next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		prd, err := db.Get(sku)
+		prd, err := s.db.Get(sku)
 		if err != nil {
 			logrus.Errorf("error retrieving product: %s", err)
 			writeResponse(w, http.StatusInternalServerError, "could not retrieve product")
@@ -72,47 +151,77 @@ func validateExistence(db db.Database, next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		next(w, req)
+		next(w, req.WithContext(context.WithValue(req.Context(), productKey, prd)))
 	})
 }
 
-//validates product fields from patch method
-func validatePatchFields(db db.Database, next http.HandlerFunc) http.HandlerFunc {
-	prodValidator := newValidator()
+//validates the product with the validation rules, the SKU rule of the context and the schema of its category,
+//which are relaxed for drafts. Returns a *validationError with the translated errors when the product is not valid
+func (s *server) validate(ctx context.Context, prd contract.Product) error {
+	schema, err := s.categorySchema(prd.Category)
+	if err != nil {
+		return err
+	}
 
-	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		bodyBytes, err := ioutil.ReadAll(req.Body)
-		if err != nil {
-			logrus.Errorf("could not decode the body %v", err)
-			writeResponse(w, http.StatusBadRequest, "could not decode the body")
-			return
-		}
-		body := ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
+	if errs := s.validator.Product(ctx, prd, schema); len(errs) > 0 {
+		logrus.Printf("Validation error(s):\n%s", strings.Join(errs, " | "))
+		return &validationError{errs}
+	}
 
-		sku := mux.Vars(req)["sku"]
-		prd, _ := db.Get(sku)
+	return nil
+}
 
-		patch := contract.Product{}
-		err = json.NewDecoder(body).Decode(&patch)
-		if err != nil {
-			logrus.Errorf("could not decode the body %v", err)
-			writeResponse(w, http.StatusBadRequest, "could not decode the body")
-			return
-		}
+//writes the errors of a failed validation, or an internal error when the product could not be validated
+func writeValidationError(w http.ResponseWriter, err error) {
+	var vErr *validationError
+	if errors.As(err, &vErr) {
+		writeResponse(w, http.StatusBadRequest, vErr.errs)
+		return
+	}
 
-		prd.Patch(patch)
+	logrus.Errorf("could not validate product: %v", err)
+	writeResponse(w, http.StatusInternalServerError, "could not validate product")
+}
 
-		//validates product fields from decoded body
-		err = prodValidator.Struct(prd)
-		if err != nil {
-			errs := prodValidator.translate(err)
-			logrus.Printf("Validation error(s):\n%s", strings.Join(errs, " | "))
-			writeResponse(w, http.StatusBadRequest, errs)
-			return
-		}
+//retrieves the product loaded by validateExistence
+func productFrom(req *http.Request) *contract.Product {
+	prd, _ := req.Context().Value(productKey).(*contract.Product)
+	return prd
+}
 
-		req.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
+//retrieves the product decoded from the body by decodeProduct
+func bodyFrom(req *http.Request) contract.Product {
+	prd, _ := req.Context().Value(bodyKey).(contract.Product)
+	return prd
+}
 
-		next(w, req)
-	})
+//reports if the SKU of the decoded product was generated
+func generated(req *http.Request) bool {
+	ok, _ := req.Context().Value(generatedKey).(bool)
+	return ok
+}
+
+//retrieves the user making the request, authenticated by authenticate or taken from the user header
+func actor(req *http.Request) string {
+	if user, ok := req.Context().Value(identityKey).(string); ok {
+		return user
+	}
+
+	return strings.TrimSpace(req.Header.Get(userHeader))
+}
+
+//retrieves the user making the request passed through the context
+func actorFrom(ctx context.Context) string {
+	user, _ := ctx.Value(actorKey).(string)
+	return user
+}
+
+//selects the rule validating the SKUs of the request by name or by seller. Returns false when the named rule does not exist
+func (s *server) withSKURule(req *http.Request) (context.Context, bool) {
+	rule, ok := s.validator.Rules().Select(req.Header.Get(skuRuleHeader), req.Header.Get(sellerHeader))
+	if !ok {
+		return nil, false
+	}
+
+	return validation.WithSKURule(req.Context(), rule), true
 }
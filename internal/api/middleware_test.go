@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/sku"
 )
 
 func TestValidatePatch(t *testing.T) {
@@ -21,7 +26,7 @@ func TestValidatePatch(t *testing.T) {
 	for desc, tc := range tests {
 		srv := NewServer("8081", &mockDB{prdCount: 1})
 
-		go srv.ListenAndServe()
+		serve(t, srv)
 
 		url := fmt.Sprintf("http://localhost:8081/product/%s", tc.sku)
 		req, _ := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(tc.patch))
@@ -55,7 +60,7 @@ func TestValidateProduct(t *testing.T) {
 	for desc, tc := range tests {
 		srv := NewServer("8081", &mockDB{prdCount: 1})
 
-		go srv.ListenAndServe()
+		serve(t, srv)
 
 		resp, err := http.Post("http://localhost:8081/product", "application/json", bytes.NewBuffer(tc.prd))
 		if err != nil {
@@ -71,3 +76,170 @@ func TestValidateProduct(t *testing.T) {
 		}
 	}
 }
+
+func TestSingleRead(t *testing.T) {
+	tests := map[string]struct {
+		method           string
+		body             []byte
+		statusExpected   int
+		getCallsExpected int32
+	}{
+		"#1: get":           {method: http.MethodGet, statusExpected: http.StatusOK, getCallsExpected: 1},
+		"#2: patch":         {method: http.MethodPatch, body: []byte(`{"name":"new name"}`), statusExpected: http.StatusOK, getCallsExpected: 0},
+		"#3: invalid patch": {method: http.MethodPatch, body: []byte(`{"name":"a"}`), statusExpected: http.StatusBadRequest, getCallsExpected: 0},
+		"#4: delete":        {method: http.MethodDelete, statusExpected: http.StatusNoContent, getCallsExpected: 1},
+	}
+
+	for desc, tc := range tests {
+		mdb := &mockDB{prdCount: 1}
+		srv := NewServer("8081", mdb)
+		serve(t, srv)
+
+		req, _ := http.NewRequest(tc.method, "http://localhost:8081/product/FAL-1000000", bytes.NewBuffer(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Got: %v\n Expected: %v", desc, resp.StatusCode, tc.statusExpected)
+		}
+
+		if mdb.getCalls != tc.getCallsExpected {
+			t.Errorf("%s:\n db reads got: %v\n db reads expected: %v", desc, mdb.getCalls, tc.getCallsExpected)
+		}
+
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}
+}
+
+func TestActor(t *testing.T) {
+	tests := map[string]struct {
+		method         string
+		url            string
+		body           []byte
+		user           string
+		statusExpected int
+	}{
+		"#1: create": {
+			method:         http.MethodPost,
+			url:            "http://localhost:8081/product",
+			body:           []byte(`{"sku":"FAL-1000000","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a","createdBy":"mallory","updatedBy":"mallory"}`),
+			user:           "alice",
+			statusExpected: http.StatusCreated,
+		},
+		"#2: update": {
+			method:         http.MethodPatch,
+			url:            "http://localhost:8081/product/FAL-1000000",
+			body:           []byte(`{"name":"new name","updatedBy":"mallory"}`),
+			user:           "bob",
+			statusExpected: http.StatusOK,
+		},
+		"#3: anonymous update": {
+			method:         http.MethodPatch,
+			url:            "http://localhost:8081/product/FAL-1000000",
+			body:           []byte(`{"name":"new name","updatedBy":"mallory"}`),
+			statusExpected: http.StatusOK,
+		},
+		"#4: user too long": {
+			method:         http.MethodPatch,
+			url:            "http://localhost:8081/product/FAL-1000000",
+			body:           []byte(`{"name":"new name"}`),
+			user:           strings.Repeat("a", 101),
+			statusExpected: http.StatusBadRequest,
+		},
+	}
+
+	for desc, tc := range tests {
+		mdb := &mockDB{prdCount: 1}
+		srv := NewServer("8081", mdb)
+		serve(t, srv)
+
+		req, _ := http.NewRequest(tc.method, tc.url, bytes.NewBuffer(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.user != "" {
+			req.Header.Set(userHeader, tc.user)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Got: %v\n Expected: %v", desc, resp.StatusCode, tc.statusExpected)
+		}
+
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+			prd := mdb.saved.Load().(contract.Product)
+			if prd.UpdatedBy != tc.user || prd.CreatedBy != "" {
+				t.Errorf("%s:\n author got: %q/%q\n expected: %q", desc, prd.CreatedBy, prd.UpdatedBy, tc.user)
+			}
+		}
+
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}
+}
+
+func TestSKURule(t *testing.T) {
+	rules, err := sku.New(sku.Config{
+		Rules:   []sku.Rule{{Name: "fal", Prefix: "FAL-"}, {Name: "gtin", CheckDigit: sku.GTIN}},
+		Sellers: map[string]string{"acme": "gtin"},
+	})
+	if err != nil {
+		t.Fatalf("could not create the sku rules: %v", err)
+	}
+
+	srv := NewServer("8081", &mockDB{}, WithSKURules(rules))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	tests := map[string]struct {
+		sku             string
+		headers         map[string]string
+		statusExpected  int
+		messageExpected string
+	}{
+		"#1: default rule":         {sku: "FAL-1", statusExpected: http.StatusCreated},
+		"#2: default rule failed":  {sku: "4006381333931", statusExpected: http.StatusBadRequest, messageExpected: "SKU does not satisfy the SKU rule fal: it does not start with FAL-"},
+		"#3: selected rule":        {sku: "4006381333931", headers: map[string]string{skuRuleHeader: "gtin"}, statusExpected: http.StatusCreated},
+		"#4: selected rule failed": {sku: "4006381333932", headers: map[string]string{skuRuleHeader: "gtin"}, statusExpected: http.StatusBadRequest, messageExpected: "SKU does not satisfy the SKU rule gtin: it is not a valid GTIN"},
+		"#5: rule of the seller":   {sku: "4006381333931", headers: map[string]string{sellerHeader: "acme"}, statusExpected: http.StatusCreated},
+		"#6: unknown rule":         {sku: "FAL-1", headers: map[string]string{skuRuleHeader: "other"}, statusExpected: http.StatusBadRequest, messageExpected: "unknown sku rule other"},
+	}
+
+	for desc, tc := range tests {
+		body := fmt.Sprintf(`{"sku":"%s","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a"}`, tc.sku)
+
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost:8081/product", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected || !strings.Contains(string(respBody), tc.messageExpected) {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v %s", desc, resp.StatusCode, respBody, tc.statusExpected, tc.messageExpected)
+		}
+	}
+}
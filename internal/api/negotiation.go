@@ -0,0 +1,388 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	mimeJSON    = "application/json"
+	mimeXML     = "application/xml"
+	mimeMsgpack = "application/msgpack"
+)
+
+//format representation of the responses. The handlers write JSON, which is transcoded to the other formats
+type format struct {
+	//media types of the format, the first one is the Content-Type of the responses
+	mediaTypes []string
+	//transcodes a JSON response body, nil for JSON
+	transcode func(body []byte) ([]byte, error)
+}
+
+//formats of the responses in order of preference
+var formats = []format{
+	{mediaTypes: []string{mimeJSON}},
+	{mediaTypes: []string{mimeXML, "text/xml"}, transcode: jsonToXML},
+	{mediaTypes: []string{mimeMsgpack, "application/x-msgpack", "application/vnd.msgpack"}, transcode: jsonToMsgpack},
+}
+
+//bufferedWriter keeps the response to transcode it once the handler finishes
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+//negotiates the format of the response with the Accept header, answering 406 when none of the formats is
+//acceptable. JSON responses are transcoded to the negotiated format
+func (s *server) negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		f, mediaType, ok := negotiateFormat(req.Header.Get("Accept"))
+		if !ok {
+			writeResponse(w, http.StatusNotAcceptable, fmt.Sprintf("the responses are available as %s", strings.Join(acceptedTypes(), ", ")))
+			return
+		}
+
+		if f.transcode == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		buf := &bufferedWriter{ResponseWriter: w}
+		next.ServeHTTP(buf, req)
+
+		body := buf.body.Bytes()
+		if ct, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type")); ct == mimeJSON && len(body) > 0 {
+			transcoded, err := f.transcode(body)
+			if err != nil {
+				logrus.Errorf("could not transcode the response to %s: %v", mediaType, err)
+				writeResponse(w, http.StatusInternalServerError, "could not encode the response")
+				return
+			}
+
+			body = transcoded
+			w.Header().Set("Content-Type", mediaType)
+		}
+
+		if buf.status != 0 {
+			w.WriteHeader(buf.status)
+		}
+		w.Write(body)
+	})
+}
+
+//selects the format with the greatest quality in the Accept header, preferring the first formats on ties.
+//Returns the media type of the response too. JSON is selected when there is no Accept header
+func negotiateFormat(accept string) (*format, string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return &formats[0], formats[0].mediaTypes[0], true
+	}
+
+	ranges := parseAccept(accept)
+
+	var (
+		best        *format
+		bestType    string
+		bestQuality float64
+	)
+
+	for i := range formats {
+		for _, mediaType := range formats[i].mediaTypes {
+			if q := quality(ranges, mediaType); q > bestQuality {
+				best, bestType, bestQuality = &formats[i], mediaType, q
+			}
+		}
+	}
+
+	return best, bestType, best != nil
+}
+
+//mediaRange media range of the Accept header, e.g. application/* with its quality
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+//parses the media ranges of the Accept header, ignoring the invalid ones
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType, q})
+	}
+
+	return ranges
+}
+
+//quality of the media type given by the most specific media range matching it
+func quality(ranges []mediaRange, mediaType string) float64 {
+	var (
+		q           float64
+		specificity = -1
+		group       = strings.SplitN(mediaType, "/", 2)[0] + "/*"
+	)
+
+	for _, r := range ranges {
+		s := -1
+		switch r.mediaType {
+		case mediaType:
+			s = 2
+		case group:
+			s = 1
+		case "*/*":
+			s = 0
+		}
+
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+
+	return q
+}
+
+//media types of all the formats
+func acceptedTypes() []string {
+	var types []string
+	for _, f := range formats {
+		types = append(types, f.mediaTypes[0])
+	}
+
+	return types
+}
+
+//jsonField member of a JSON object. Objects are decoded as slices of fields to keep their order
+type jsonField struct {
+	key   string
+	value interface{}
+}
+
+//decodes a JSON value keeping the order of the fields of its objects and the precision of its numbers
+func decodeOrdered(body []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	return readValue(dec)
+}
+
+func readValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		obj := []jsonField{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := readValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			obj = append(obj, jsonField{key.(string), value})
+		}
+
+		_, err = dec.Token()
+		return obj, err
+	case '[':
+		arr := []interface{}{}
+		for dec.More() {
+			value, err := readValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+
+		_, err = dec.Token()
+		return arr, err
+	}
+
+	return nil, fmt.Errorf("unexpected delimiter %v", delim)
+}
+
+//transcodes a JSON body to XML. The value is the response element, objects are elements with an element per field
+//and arrays are elements with an item element per value. Fields that are not valid XML names are entry elements
+//with a key attribute
+func jsonToXML(body []byte) ([]byte, error) {
+	value, err := decodeOrdered(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&buf)
+	if err := encodeXML(enc, "response", value); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encodeXML(enc *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !validXMLName(name) {
+		start = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}}}
+	}
+
+	switch v := value.(type) {
+	case []jsonField:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, field := range v {
+			if err := encodeXML(enc, field.key, field.value); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case []interface{}:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := encodeXML(enc, "item", item); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case nil:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		return enc.EncodeToken(start.End())
+	}
+
+	return enc.EncodeElement(fmt.Sprint(value), start)
+}
+
+//reports if the name is a valid XML element name. Names starting with xml are reserved
+func validXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+
+	for i, c := range name {
+		switch {
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		case i > 0 && (c == '-' || c == '.' || (c >= '0' && c <= '9')):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+//transcodes a JSON body to MessagePack, keeping the order of the fields and encoding the integers as such
+func jsonToMsgpack(body []byte) ([]byte, error) {
+	value, err := decodeOrdered(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := encodeMsgpack(msgpack.NewEncoder(&buf), value); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encodeMsgpack(enc *msgpack.Encoder, value interface{}) error {
+	switch v := value.(type) {
+	case []jsonField:
+		if err := enc.EncodeMapLen(len(v)); err != nil {
+			return err
+		}
+		for _, field := range v {
+			if err := enc.EncodeString(field.key); err != nil {
+				return err
+			}
+			if err := encodeMsgpack(enc, field.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		if err := enc.EncodeArrayLen(len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := encodeMsgpack(enc, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return enc.EncodeInt(n)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		return enc.EncodeFloat64(f)
+	case string:
+		return enc.EncodeString(v)
+	case bool:
+		return enc.EncodeBool(v)
+	case nil:
+		return enc.EncodeNil()
+	}
+
+	return fmt.Errorf("unexpected JSON value %T", value)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := map[string]struct {
+		accept       string
+		typeExpected string
+		okExpected   bool
+	}{
+		"#1: no header":           {typeExpected: mimeJSON, okExpected: true},
+		"#2: any":                 {accept: "*/*", typeExpected: mimeJSON, okExpected: true},
+		"#3: xml":                 {accept: "application/xml", typeExpected: mimeXML, okExpected: true},
+		"#4: text xml":            {accept: "text/xml", typeExpected: "text/xml", okExpected: true},
+		"#5: msgpack alias":       {accept: "application/x-msgpack", typeExpected: "application/x-msgpack", okExpected: true},
+		"#6: quality":             {accept: "application/json;q=0.5, application/msgpack", typeExpected: mimeMsgpack, okExpected: true},
+		"#7: tie keeps the order": {accept: "application/xml, application/json", typeExpected: mimeJSON, okExpected: true},
+		"#8: excluded json":       {accept: "application/*, application/json;q=0", typeExpected: mimeXML, okExpected: true},
+		"#9: not acceptable":      {accept: "text/html"},
+		"#10: invalid quality":    {accept: "application/xml;q=2"},
+	}
+
+	for desc, tc := range tests {
+		_, mediaType, ok := negotiateFormat(tc.accept)
+		if ok != tc.okExpected || mediaType != tc.typeExpected {
+			t.Errorf("%s:\n Got: %q, %v\n Expected: %q, %v", desc, mediaType, ok, tc.typeExpected, tc.okExpected)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	srv := NewServer("8081", &mockDB{prdCount: 1})
+	serve(t, srv)
+
+	defer func() {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}()
+
+	tests := map[string]struct {
+		accept         string
+		statusExpected int
+		typeExpected   string
+		decode         func(body []byte) (string, error)
+	}{
+		"#1: json": {
+			accept:         "application/json",
+			statusExpected: http.StatusOK,
+			typeExpected:   mimeJSON,
+			decode: func(body []byte) (string, error) {
+				var prd map[string]interface{}
+				err := json.Unmarshal(body, &prd)
+				sku, _ := prd["sku"].(string)
+				return sku, err
+			},
+		},
+		"#2: xml": {
+			accept:         "application/xml",
+			statusExpected: http.StatusOK,
+			typeExpected:   mimeXML,
+			decode: func(body []byte) (string, error) {
+				if !strings.Contains(string(body), "<response><sku>FAL-1000000</sku><name>name</name>") ||
+					!strings.Contains(string(body), "<altImages><item>http://bbbb</item><item>http://cccc</item></altImages>") {
+					return string(body), nil
+				}
+				return "FAL-1000000", nil
+			},
+		},
+		"#3: msgpack": {
+			accept:         "application/msgpack",
+			statusExpected: http.StatusOK,
+			typeExpected:   mimeMsgpack,
+			decode: func(body []byte) (string, error) {
+				var prd struct {
+					SKU  string `msgpack:"sku"`
+					Size int64  `msgpack:"size"`
+				}
+				err := msgpack.Unmarshal(body, &prd)
+				if prd.Size != 10 {
+					return "", err
+				}
+				return prd.SKU, err
+			},
+		},
+		"#4: not acceptable": {
+			accept:         "text/html",
+			statusExpected: http.StatusNotAcceptable,
+		},
+	}
+
+	for desc, tc := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost:8081/product/FAL-1000000", nil)
+		req.Header.Set("Accept", tc.accept)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v", desc, resp.StatusCode, body, tc.statusExpected)
+			continue
+		}
+
+		if resp.Header.Get("Vary") != "Accept" {
+			t.Errorf("%s: the response should vary by Accept", desc)
+		}
+
+		if tc.decode == nil {
+			continue
+		}
+
+		if ct := resp.Header.Get("Content-Type"); ct != tc.typeExpected {
+			t.Errorf("%s:\n Content-Type got: %s\n expected: %s", desc, ct, tc.typeExpected)
+		}
+
+		if sku, err := tc.decode(body); err != nil || sku != "FAL-1000000" {
+			t.Errorf("%s:\n could not decode the response: %v %s", desc, err, body)
+		}
+	}
+}
@@ -2,86 +2,347 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
 // create godoc
 // @Summary Creates a new product
-// @Description Creates a new product
+// @Description Creates a new product. When the SKUs are generated, products without SKU get the next one of
+// @Description the sequence of their SKU rule
 // @Tags product create
 // @Accept json
-// @Success 200 {object} contract.Response{status=int,message=object}
-// @Failure 400,500 {object} contract.Response{status=int,message=object}
+// @Produce json
+// @Success 201 {object} contract.Product
+// @Header 201 {string} Location "path of the created product"
+// @Failure 400,409,422,500 {object} contract.Response{status=int,message=object}
 // @Param product body contract.Product true "product"
+// @Param X-SKU-Rule header string false "name of the SKU rule"
+// @Param X-Seller header string false "seller selecting the SKU rule"
+// @Param Idempotency-Key header string false "key of the request. Retries with the same key replay the original response"
 // @Router /product [post]
 func (s *server) create(w http.ResponseWriter, req *http.Request) {
-	prd := contract.Product{}
-	json.NewDecoder(req.Body).Decode(&prd)
+	prd := bodyFrom(req)
 
 	//inserts the new product into database
-	err := s.db.Create(prd)
+	created, err := s.db.Create(prd)
+
+	//a generated SKU may have been taken by hand after the sequence was created, so the next ones are tried
+	for attempt := 1; errors.Is(err, db.ErrAlreadyExists) && generated(req) && attempt < maxSKUAttempts; attempt++ {
+		logrus.Warnf("generated sku %s already exists: %v", prd.SKU, err)
+
+		skus, ok := s.nextSKUs(w, req, 1)
+		if !ok {
+			return
+		}
+		prd.SKU = skus[0]
+
+		created, err = s.db.Create(prd)
+	}
+
+	if err != nil {
+		if errors.Is(err, db.ErrAlreadyExists) {
+			writeResponse(w, http.StatusConflict, fmt.Sprintf("product %s already exists", prd.SKU))
+			return
+		}
+
+		logrus.Errorf("db error: %s", err)
+		writeResponse(w, http.StatusInternalServerError, "could not create new product")
+		return
+	}
+
+	logrus.Infof("Product %s created", created.SKU)
+
+	writeCreated(w, created)
+}
+
+// replace godoc
+// @Summary Creates or replaces a product
+// @Description Replaces all the fields of the product, or creates it when it does not exist
+// @Tags product put
+// @Accept json
+// @Produce json
+// @Success 200 {object} contract.Product
+// @Success 201 {object} contract.Product
+// @Header 201 {string} Location "path of the created product"
+// @Failure 400,409,500 {object} contract.Response{status=int,message=object}
+// @Param sku path string true "product sku"
+// @Param product body contract.Product true "product. The sku is optional, but must be the one of the path when given"
+// @Param X-SKU-Rule header string false "name of the SKU rule"
+// @Param X-Seller header string false "seller selecting the SKU rule"
+// @Router /product/{sku} [put]
+func (s *server) replace(w http.ResponseWriter, req *http.Request) {
+	var (
+		sku string           = mux.Vars(req)["sku"]
+		prd contract.Product = bodyFrom(req)
+	)
+
+	if prd.SKU != "" && prd.SKU != sku {
+		writeResponse(w, http.StatusBadRequest, "the sku of the body does not match the sku of the path")
+		return
+	}
+	prd.SKU = sku
+
+	replaced, err := s.db.UpdateFunc(sku, func(stored *contract.Product) error {
+		if prd.Status != "" && prd.Status != stored.Status {
+			return errStatusChange
+		}
+
+		status := stored.Status
+		*stored = prd
+		stored.Status = status
+
+		return s.validate(req.Context(), *stored)
+	})
+	if err == nil {
+		logrus.Infof("Product %s replaced", sku)
+
+		body, _ := json.Marshal(replaced)
+		writeJSONResponse(w, http.StatusOK, body)
+		return
+	}
+
+	if !errors.Is(err, db.ErrNotFound) {
+		var vErr *validationError
+		if errors.As(err, &vErr) {
+			writeResponse(w, http.StatusBadRequest, vErr.errs)
+			return
+		}
+
+		logrus.Errorf("error replacing product: %s", err)
+		writeResponse(w, http.StatusInternalServerError, "could not replace product")
+		return
+	}
+
+	if err := s.startStatus(&prd, actor(req)); err != nil {
+		writeTransitionError(w, err)
+		return
+	}
+
+	if err := s.validate(req.Context(), prd); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	created, err := s.db.Create(prd)
 	if err != nil {
+		//the product was created by another request after it was not found
+		if errors.Is(err, db.ErrAlreadyExists) {
+			writeResponse(w, http.StatusConflict, fmt.Sprintf("product %s was created concurrently", sku))
+			return
+		}
+
 		logrus.Errorf("db error: %s", err)
 		writeResponse(w, http.StatusInternalServerError, "could not create new product")
 		return
 	}
 
-	logrus.Infof("Product %s created", prd.SKU)
-	writeResponse(w, http.StatusOK, "product successfully created")
+	logrus.Infof("Product %s created", sku)
+
+	writeCreated(w, created)
 }
 
 // getAll godoc
 // @Summary Retrieves all the products stored in the database
-// @Description Retrieves all the products stored in the database
+// @Description Retrieves all the products stored in the database, or the ones updated since a given time for incremental syncs.
+// @Description The products can be filtered by category and by the custom attributes of the category
 // @Tags product list
 // @Success 200 {array} contract.Product
-// @Failure 404,500 {object} contract.Response{status=int,message=object}
+// @Success 304 {string} string "not modified since the If-None-Match or If-Modified-Since preconditions"
+// @Failure 400,404,500 {object} contract.Response{status=int,message=object}
+// @Param updatedSince query string false "only the products updated at or after this RFC 3339 date and time"
+// @Param status query string false "only the products of this status. Requests without X-User header only list the active products" Enums(draft, pending_review, active, discontinued, archived)
+// @Param category query string false "only the products of this category"
+// @Param attr.{key} query string false "only the products with this value of the custom attribute key of the category. Use attr.{key}.min and attr.{key}.max for numeric ranges"
+// @Param Accept-Language header string false "languages of the names and descriptions. Localized products omit their translations"
+// @Param If-None-Match header string false "entity tag of the cached response"
+// @Param If-Modified-Since header string false "Last-Modified date of the cached response"
+// @Param X-User header string false "user reading the product. Requests without it only read the active products"
 // @Router /product [get]
-func (s *server) getAll(w http.ResponseWriter, _ *http.Request) {
-	prds, err := s.db.GetAll()
+func (s *server) getAll(w http.ResponseWriter, req *http.Request) {
+	filter := contract.ProductFilter{}
+
+	if v := req.URL.Query().Get("updatedSince"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "updatedSince must be an RFC 3339 date and time")
+			return
+		}
+		filter.UpdatedSince = since
+	}
+
+	switch status := req.URL.Query().Get("status"); {
+	case actor(req) == "":
+		//anonymous requests only list the active products
+		filter.Status = contract.StatusActive
+	case status == "" || contract.IsStatus(status):
+		filter.Status = status
+	default:
+		writeResponse(w, http.StatusBadRequest, fmt.Sprintf("unknown status %s", status))
+		return
+	}
+
+	filter.Category = req.URL.Query().Get("category")
+	if hasAttributeFilters(req.URL.Query()) {
+		schema, err := s.categorySchema(filter.Category)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			writeResponse(w, http.StatusInternalServerError, "could not get the list of products")
+			return
+		}
+
+		var msg string
+		if filter.Attributes, msg = parseAttributeFilters(req.URL.Query(), filter.Category, schema); msg != "" {
+			writeResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+	}
+
+	lastModified, err := s.db.LastModified()
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not get the list of products")
+		return
+	}
+
+	if s.writeNotModified(w, req, RouteProducts, lastModified) {
+		return
+	}
+
+	prds, err := s.db.List(filter)
 	if err != nil {
 		logrus.Errorf("db error: %v", err)
 		writeResponse(w, http.StatusInternalServerError, "could not get the list of products")
 		return
 	}
 
-	if len(prds) > 0 {
+	//an incremental sync without changes or a filter without matches is not an error
+	if len(prds) > 0 || !filter.UpdatedSince.IsZero() || filter.Category != "" {
+		refs := make([]*contract.Product, len(prds))
+		for i := range prds {
+			refs[i] = &prds[i]
+		}
+
+		if err := s.withAvailability(refs...); err != nil {
+			logrus.Errorf("db error: %v", err)
+			writeResponse(w, http.StatusInternalServerError, "could not get the availability of the products")
+			return
+		}
+		s.localize(w, req, refs...)
+
 		body, _ := json.Marshal(&prds)
-		writeJSONResponse(w, http.StatusOK, body)
+		s.writeCacheable(w, req, RouteProducts, lastModified, body)
 	} else {
 		writeResponse(w, http.StatusNotFound, "No products found in database")
 	}
 }
 
+// batchGet godoc
+// @Summary Get several products by their SKUs
+// @Description Get up to 100 products with a single query. SKUs that do not exist are listed as missing
+// @Tags product get
+// @Accept json
+// @Success 200 {object} contract.BatchResponse
+// @Failure 400,409,422,500 {object} contract.Response{status=int,message=object}
+// @Param batch body contract.BatchRequest true "skus"
+// @Param Accept-Language header string false "languages of the names and descriptions. Localized products omit their translations"
+// @Param Idempotency-Key header string false "key of the request. Retries with the same key replay the original response"
+// @Param X-User header string false "user reading the product. Requests without it only read the active products"
+// @Router /product/batch [post]
+func (s *server) batchGet(w http.ResponseWriter, req *http.Request) {
+	batch := contract.BatchRequest{}
+	if !s.decodeAndValidate(w, req, &batch) {
+		return
+	}
+
+	prds, missing, err := s.db.GetMany(batch.SKUs)
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not get the products")
+		return
+	}
+
+	//the products the request cannot read are missing for it
+	user, found := actor(req), prds[:0]
+	for i := range prds {
+		if visible(user, &prds[i]) {
+			found = append(found, prds[i])
+		} else {
+			missing = append(missing, prds[i].SKU)
+		}
+	}
+	prds = found
+
+	refs := make([]*contract.Product, len(prds))
+	for i := range prds {
+		refs[i] = &prds[i]
+	}
+
+	if err := s.withAvailability(refs...); err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not get the availability of the products")
+		return
+	}
+	s.localize(w, req, refs...)
+
+	body, _ := json.Marshal(contract.BatchResponse{Products: prds, Missing: missing})
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
 // get godoc
 // @Summary Get a product by its SKU
 // @Description Get a product by its SKU
 // @Tags product get
 // @Accept json
 // @Success 200 {object} contract.Product
+// @Success 304 {string} string "not modified since the If-None-Match or If-Modified-Since preconditions"
 // @Failure 400,404,500 {object} contract.Response{status=int,message=object}
 // @Param sku path string true "product sku"
+// @Param Accept-Language header string false "languages of the names and descriptions. Localized products omit their translations"
+// @Param If-None-Match header string false "entity tag of the cached response"
+// @Param If-Modified-Since header string false "Last-Modified date of the cached response"
+// @Param X-User header string false "user reading the product. Requests without it only read the active products"
+// @Param asOf query string false "RFC 3339 date reading the version of the product at that time. Requires the revisions"
 // @Router /product/{sku} [get]
 func (s *server) get(w http.ResponseWriter, req *http.Request) {
-	sku := mux.Vars(req)["sku"]
+	prd := productFrom(req)
+	if !visible(actor(req), prd) {
+		writeResponse(w, http.StatusNotFound, "product not found")
+		return
+	}
 
-	prd, _ := s.db.Get(sku)
+	if err := s.withAvailability(prd); err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not get the availability of the product")
+		return
+	}
 
+	lastModified := prd.UpdatedAt
+	if prd.Availability != nil && prd.Availability.LastModified().After(lastModified) {
+		lastModified = prd.Availability.LastModified()
+	}
+
+	s.localize(w, req, prd)
 	body, _ := json.Marshal(&prd)
 
-	writeJSONResponse(w, http.StatusOK, body)
+	s.writeCacheable(w, req, RouteProduct, lastModified, body)
 }
 
 // update godoc
 // @Summary Updates an existing product
-// @Description Updates a existing product
+// @Description Updates the given fields of an existing product
 // @Tags product patch
 // @Accept json
-// @Success 200 {object} contract.Response{status=int,message=object}
+// @Produce json
+// @Success 200 {object} contract.Product
 // @Failure 400,404,500 {object} contract.Response{status=int,message=object}
 // @Param sku path string true "product sku"
 // @Param patch body contract.Product true "product patch"
@@ -89,43 +350,83 @@ func (s *server) get(w http.ResponseWriter, req *http.Request) {
 func (s *server) update(w http.ResponseWriter, req *http.Request) {
 	var (
 		sku   string           = mux.Vars(req)["sku"]
-		patch contract.Product = contract.Product{}
+		patch contract.Product = bodyFrom(req)
 	)
 
-	prd, _ := s.db.Get(sku)
+	//the product is patched and validated while it is locked, so concurrent patches cannot overwrite each other
+	prd, err := s.db.UpdateFunc(sku, func(prd *contract.Product) error {
+		if patch.Status != "" && patch.Status != prd.Status {
+			return errStatusChange
+		}
 
-	json.NewDecoder(req.Body).Decode(&patch)
+		prd.Patch(patch)
+		prd.UpdatedBy = patch.UpdatedBy
 
-	prd.Patch(patch)
+		if err := s.validate(req.Context(), *prd); err != nil {
+			return err
+		}
 
-	err := s.db.Update(*prd)
+		return nil
+	})
 	if err != nil {
-		logrus.Errorf("error updating product: %s", err)
-		writeResponse(w, http.StatusInternalServerError, "could not update product")
+		var vErr *validationError
+
+		switch {
+		case errors.As(err, &vErr):
+			writeResponse(w, http.StatusBadRequest, vErr.errs)
+		case errors.Is(err, db.ErrNotFound):
+			writeResponse(w, http.StatusNotFound, "product not found")
+		default:
+			logrus.Errorf("error updating product: %s", err)
+			writeResponse(w, http.StatusInternalServerError, "could not update product")
+		}
 		return
 	}
 
-	writeResponse(w, http.StatusOK, "product successfully updated")
+	body, _ := json.Marshal(prd)
+	writeJSONResponse(w, http.StatusOK, body)
 }
 
 // delete godoc
 // @Summary Deletes an existing product
-// @Description Deletes a existing product
+// @Description Deletes a existing product along with its images. Products with stock can only be deleted along with their stock using cascade=true
 // @Tags product delete
-// @Success 200 {object} contract.Response{status=int,message=object}
-// @Failure 400,404,500 {object} contract.Response{status=int,message=object}
+// @Success 204 {string} string "product deleted"
+// @Failure 400,404,409,500 {object} contract.Response{status=int,message=object}
 // @Param sku path string true "sku product"
-// @Param patch body contract.Product true "product patch"
+// @Param cascade query bool false "deletes the stock and reservations of the product too"
 // @Router /product/{sku} [delete]
 func (s *server) delete(w http.ResponseWriter, req *http.Request) {
 	sku := mux.Vars(req)["sku"]
 
-	err := s.db.Delete(sku)
+	err := s.db.Delete(sku, req.URL.Query().Get("cascade") == "true")
 	if err != nil {
+		if errors.Is(err, db.ErrInStock) {
+			writeResponse(w, http.StatusConflict, "product has stock. Use cascade=true to delete it along with its stock")
+			return
+		}
+
 		logrus.Errorf("error deleting product: %s", err)
 		writeResponse(w, http.StatusInternalServerError, "could not delete product")
 		return
 	}
 
-	writeResponse(w, http.StatusOK, "product successfully deleted")
+	if s.images != nil {
+		s.removeProductImages(sku)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//path of the product resource
+func productPath(sku string) string {
+	return "/product/" + url.PathEscape(sku)
+}
+
+//writes the created product along with its location
+func writeCreated(w http.ResponseWriter, prd *contract.Product) {
+	body, _ := json.Marshal(prd)
+
+	w.Header().Set("Location", productPath(prd.SKU))
+	writeJSONResponse(w, http.StatusCreated, body)
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/garciacer87/product-api/internal/contract"
@@ -22,7 +23,7 @@ func TestCreate(t *testing.T) {
 		}
 	}(srv)
 
-	go srv.ListenAndServe()
+	serve(t, srv)
 
 	tests := map[string]struct {
 		prd            contract.Product
@@ -41,7 +42,7 @@ func TestCreate(t *testing.T) {
 			statusExpected: http.StatusBadRequest,
 		},
 		"#4: invalid name": {
-			prd:            contract.Product{SKU: "FAL-1000000", Name: "", Brand: "brand", Size: 10, Price: 100.00, ImageURL: "http://a", AltImages: []string{"http://b", "http://c"}},
+			prd:            contract.Product{SKU: "FAL-1000000", Name: "", Brand: "brand", Size: 10, Price: 100.00, ImageURL: "http://a", AltImages: []string{"http://b", "http://c"}, Status: contract.StatusActive},
 			statusExpected: http.StatusBadRequest,
 		},
 		"#5: blank name": {
@@ -66,7 +67,15 @@ func TestCreate(t *testing.T) {
 		},
 		"#10: valid case": {
 			prd:            contract.Product{SKU: "FAL-1000000", Name: "name", Brand: "brand", Size: 10, Price: 100.00, ImageURL: "http://a", AltImages: []string{"http://b", "http://c"}},
-			statusExpected: http.StatusOK,
+			statusExpected: http.StatusCreated,
+		},
+		"#11: incomplete draft": {
+			prd:            contract.Product{SKU: "FAL-1000000", Name: "", Brand: "brand"},
+			statusExpected: http.StatusCreated,
+		},
+		"#12: draft without sku": {
+			prd:            contract.Product{Name: "name"},
+			statusExpected: http.StatusBadRequest,
 		},
 	}
 
@@ -83,23 +92,37 @@ func TestCreate(t *testing.T) {
 		if resp.StatusCode != tc.statusExpected {
 			t.Errorf("%s Status expected: %v Status got: %v", desc, tc.statusExpected, resp.StatusCode)
 		}
+
+		if resp.StatusCode == http.StatusCreated {
+			prd := contract.Product{}
+			json.NewDecoder(resp.Body).Decode(&prd)
+
+			if loc := resp.Header.Get("Location"); loc != "/product/FAL-1000000" || prd.SKU != "FAL-1000000" || prd.CreatedAt.IsZero() {
+				t.Errorf("%s:\n location got: %s\n product got: %+v", desc, loc, prd)
+			}
+		}
+		resp.Body.Close()
 	}
 }
 
 func TestGetAll(t *testing.T) {
 	tests := map[string]struct {
 		srv            Server
+		query          string
 		statusExpected int
 		prdsExpected   int
 	}{
 		"#1: valid case":            {srv: NewServer("8081", &mockDB{prdCount: 2}), statusExpected: http.StatusOK, prdsExpected: 2},
 		"#2: internal server error": {srv: NewServer("8081", &mockDB{throwError: true}), statusExpected: http.StatusInternalServerError},
 		"#3: empty list":            {srv: NewServer("8081", &mockDB{}), statusExpected: http.StatusNotFound},
+		"#4: updated since":         {srv: NewServer("8081", &mockDB{prdCount: 2}), query: "?updatedSince=2022-01-10T12:30:00Z", statusExpected: http.StatusOK, prdsExpected: 2},
+		"#5: not updated since":     {srv: NewServer("8081", &mockDB{prdCount: 2}), query: "?updatedSince=2022-01-10T12:30:01Z", statusExpected: http.StatusOK, prdsExpected: 0},
+		"#6: invalid updatedSince":  {srv: NewServer("8081", &mockDB{prdCount: 2}), query: "?updatedSince=yesterday", statusExpected: http.StatusBadRequest},
 	}
 
 	for desc, tc := range tests {
-		go tc.srv.ListenAndServe()
-		resp, err := http.Get("http://localhost:8081/product")
+		serve(t, tc.srv)
+		resp, err := http.Get("http://localhost:8081/product" + tc.query)
 		if err != nil {
 			t.Errorf("Error not expected: %v", err)
 		}
@@ -146,7 +169,7 @@ func TestGet(t *testing.T) {
 
 	for desc, tc := range tests {
 		srv := NewServer("8081", tc.db)
-		go srv.ListenAndServe()
+		serve(t, srv)
 
 		url := fmt.Sprintf("http://localhost:8081/product/%s", tc.sku)
 		resp, err := http.Get(url)
@@ -165,6 +188,49 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestBatchGet(t *testing.T) {
+	tests := map[string]struct {
+		db              *mockDB
+		body            string
+		statusExpected  int
+		missingExpected []string
+	}{
+		"#1: invalid body":          {db: &mockDB{}, body: `{"skus":"FAL-1000000"}`, statusExpected: http.StatusBadRequest},
+		"#2: no skus":               {db: &mockDB{}, body: `{"skus":[]}`, statusExpected: http.StatusBadRequest},
+		"#3: internal server error": {db: &mockDB{throwError: true}, body: `{"skus":["FAL-1000000"]}`, statusExpected: http.StatusInternalServerError},
+		"#4: valid case":            {db: &mockDB{prdCount: 1}, body: `{"skus":["FAL-1000000","FAL-1000001"]}`, statusExpected: http.StatusOK, missingExpected: []string{"FAL-1000001"}},
+	}
+
+	for desc, tc := range tests {
+		srv := NewServer("8081", tc.db)
+		serve(t, srv)
+
+		resp, err := http.Post("http://localhost:8081/product/batch", "application/json", bytes.NewBufferString(tc.body))
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Status code got: %v\n Status code expected: %v", desc, resp.StatusCode, tc.statusExpected)
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			batch := contract.BatchResponse{}
+			if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+				t.Fatalf("could not decode response body: %v", err)
+			}
+
+			if len(batch.Products) != 1 || fmt.Sprint(batch.Missing) != fmt.Sprint(tc.missingExpected) {
+				t.Errorf("%s:\n batch got: %+v", desc, batch)
+			}
+		}
+
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}
+}
+
 func TestUpdate(t *testing.T) {
 	tests := map[string]struct {
 		db             *mockDB
@@ -180,7 +246,7 @@ func TestUpdate(t *testing.T) {
 	for desc, tc := range tests {
 		srv := NewServer("8081", tc.db)
 
-		go srv.ListenAndServe()
+		serve(t, srv)
 
 		body, _ := json.Marshal(&tc.patch)
 		url := fmt.Sprintf("http://localhost:8081/product/%s", tc.sku)
@@ -197,6 +263,63 @@ func TestUpdate(t *testing.T) {
 			t.Errorf("%s:\n Status code got: %v\n Status code expected: %v", desc, resp.StatusCode, tc.statusExpected)
 		}
 
+		if resp.StatusCode == http.StatusOK {
+			prd := contract.Product{}
+			if err := json.NewDecoder(resp.Body).Decode(&prd); err != nil || prd.Name != tc.patch.Name {
+				t.Errorf("%s:\n updated product got: %+v, %v", desc, prd, err)
+			}
+		}
+		resp.Body.Close()
+
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	const prd = `"name":"new name","brand":"brand","size":10,"price":100,"imageURL":"http://a"`
+
+	tests := map[string]struct {
+		db               *mockDB
+		sku              string
+		body             string
+		statusExpected   int
+		locationExpected string
+	}{
+		"#1: internal server error": {db: &mockDB{throwError: true, prdCount: 1}, sku: "FAL-1000001", body: `{` + prd + `}`, statusExpected: http.StatusInternalServerError},
+		"#2: invalid product":       {db: &mockDB{prdCount: 1}, sku: "FAL-1000001", body: `{"name":"new name"}`, statusExpected: http.StatusBadRequest},
+		"#3: different sku":         {db: &mockDB{prdCount: 1}, sku: "FAL-1000001", body: `{"sku":"FAL-1000002",` + prd + `}`, statusExpected: http.StatusBadRequest},
+		"#4: replaced":              {db: &mockDB{prdCount: 1}, sku: "FAL-1000001", body: `{` + prd + `}`, statusExpected: http.StatusOK},
+		"#5: created":               {db: &mockDB{prdCount: 0}, sku: "FAL-1000001", body: `{"sku":"FAL-1000001",` + prd + `}`, statusExpected: http.StatusCreated, locationExpected: "/product/FAL-1000001"},
+		"#6: created concurrently":  {db: &mockDB{prdCount: 0, conflicts: 1}, sku: "FAL-1000001", body: `{` + prd + `}`, statusExpected: http.StatusConflict},
+	}
+
+	for desc, tc := range tests {
+		srv := NewServer("8081", tc.db)
+		serve(t, srv)
+
+		url := fmt.Sprintf("http://localhost:8081/product/%s", tc.sku)
+		req, _ := http.NewRequest(http.MethodPut, url, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+
+		if resp.StatusCode != tc.statusExpected || resp.Header.Get("Location") != tc.locationExpected {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v %s", desc, resp.StatusCode, resp.Header.Get("Location"), tc.statusExpected, tc.locationExpected)
+		}
+
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+			saved := tc.db.saved.Load().(contract.Product)
+			if saved.SKU != tc.sku || saved.Name != "new name" {
+				t.Errorf("%s:\n saved product got: %+v", desc, saved)
+			}
+		}
+		resp.Body.Close()
+
 		if err := srv.Shutdown(context.Background()); err != nil {
 			t.Fatalf("could not shutdown the test server")
 		}
@@ -211,12 +334,12 @@ func TestDelete(t *testing.T) {
 	}{
 		"#1: internal server error": {db: &mockDB{throwError: true, prdCount: 1}, sku: "FAL-1000001", statusExpected: http.StatusInternalServerError},
 		"#2: product not found":     {db: &mockDB{prdCount: 0}, sku: "FAL-1000002", statusExpected: http.StatusNotFound},
-		"#3: valid case":            {db: &mockDB{prdCount: 1}, sku: "FAL-1000003", statusExpected: http.StatusOK},
+		"#3: valid case":            {db: &mockDB{prdCount: 1}, sku: "FAL-1000003", statusExpected: http.StatusNoContent},
 	}
 
 	for desc, tc := range tests {
 		srv := NewServer("8081", tc.db)
-		go srv.ListenAndServe()
+		serve(t, srv)
 
 		url := fmt.Sprintf("http://localhost:8081/product/%s", tc.sku)
 		req, _ := http.NewRequest(http.MethodDelete, url, nil)
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// brokenImages godoc
+// @Summary Report of the broken product images
+// @Description Counts the image URLs checked by the image checker and lists the products whose images failed their last check, with the status or error of each one
+// @Tags report
+// @Success 200 {object} contract.ImageReport
+// @Failure 500 {object} contract.Response{status=int,message=object}
+// @Router /reports/broken-images [get]
+func (s *server) brokenImages(w http.ResponseWriter, _ *http.Request) {
+	report, err := s.imageChecks.ImageReport()
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not get the image report")
+		return
+	}
+
+	body, _ := json.Marshal(report)
+	writeJSONResponse(w, http.StatusOK, body)
+}
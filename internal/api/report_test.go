@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestBrokenImages(t *testing.T) {
+	tests := map[string]struct {
+		throwError     bool
+		statusExpected int
+	}{
+		"#1: report":   {statusExpected: http.StatusOK},
+		"#2: db error": {throwError: true, statusExpected: http.StatusInternalServerError},
+	}
+
+	for desc, tc := range tests {
+		srv := NewServer("8081", &mockDB{}, WithImageReport(&mockImageChecks{throwError: tc.throwError}))
+		serve(t, srv)
+
+		resp, err := http.Get("http://localhost:8081/reports/broken-images")
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+
+		report := contract.ImageReport{}
+		json.NewDecoder(resp.Body).Decode(&report)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Got: %v\n Expected: %v", desc, resp.StatusCode, tc.statusExpected)
+		}
+
+		if resp.StatusCode == http.StatusOK && (report.BrokenURLs != 1 || len(report.Products) != 1 || report.Products[0].Images[0].Status != http.StatusNotFound) {
+			t.Errorf("%s:\n report got: %+v", desc, report)
+		}
+
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}
+}
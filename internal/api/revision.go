@@ -0,0 +1,248 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+//asOfParam query parameter reading the version of a product at a point in time
+const asOfParam = "asOf"
+
+// getRevisions godoc
+// @Summary Retrieves the revisions of a product
+// @Description Retrieves the immutable revisions stored on every write of the product, newest first. The deletions
+// @Description are revisions too, with the last version of the deleted product
+// @Tags product revision
+// @Success 200 {array} contract.ProductRevision
+// @Failure 403,404,500 {object} contract.Response{status=int,message=object}
+// @Param sku path string true "product sku"
+// @Param X-User header string true "user reading the revisions"
+// @Router /product/{sku}/revisions [get]
+func (s *server) getRevisions(w http.ResponseWriter, req *http.Request) {
+	sku := mux.Vars(req)["sku"]
+
+	revs, err := s.revisions.Revisions(sku)
+	if err != nil {
+		writeRevisionError(w, err)
+		return
+	}
+
+	body, _ := json.Marshal(revs)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// getRevision godoc
+// @Summary Get a revision of a product
+// @Description Get a revision of a product by its number
+// @Tags product revision
+// @Success 200 {object} contract.ProductRevision
+// @Failure 403,404,500 {object} contract.Response{status=int,message=object}
+// @Param sku path string true "product sku"
+// @Param revision path int true "revision number"
+// @Param X-User header string true "user reading the revision"
+// @Router /product/{sku}/revisions/{revision} [get]
+func (s *server) getRevision(w http.ResponseWriter, req *http.Request) {
+	rev, ok := s.revisionFrom(w, req)
+	if !ok {
+		return
+	}
+
+	body, _ := json.Marshal(rev)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// diffRevisions godoc
+// @Summary Compares two revisions of a product
+// @Description Retrieves the fields changed from one revision of a product to another, sorted by their JSON path.
+// @Description The attributes and translations are compared key by key, and the audit fields are left out
+// @Tags product revision
+// @Success 200 {object} contract.RevisionDiff
+// @Failure 400,403,404,500 {object} contract.Response{status=int,message=object}
+// @Param sku path string true "product sku"
+// @Param from query int true "revision compared"
+// @Param to query int true "revision compared with"
+// @Param X-User header string true "user comparing the revisions"
+// @Router /product/{sku}/revisions/diff [get]
+func (s *server) diffRevisions(w http.ResponseWriter, req *http.Request) {
+	var (
+		sku   = mux.Vars(req)["sku"]
+		query = req.URL.Query()
+	)
+
+	from, err := strconv.Atoi(query.Get("from"))
+	if err != nil || from < 1 {
+		writeResponse(w, http.StatusBadRequest, "from must be a revision number")
+		return
+	}
+
+	to, err := strconv.Atoi(query.Get("to"))
+	if err != nil || to < 1 {
+		writeResponse(w, http.StatusBadRequest, "to must be a revision number")
+		return
+	}
+
+	before, err := s.revisions.Revision(sku, from)
+	if err != nil {
+		writeRevisionError(w, err)
+		return
+	}
+
+	after, err := s.revisions.Revision(sku, to)
+	if err != nil {
+		writeRevisionError(w, err)
+		return
+	}
+
+	diff := contract.RevisionDiff{SKU: sku, From: from, To: to, Changes: contract.Diff(before.Product, after.Product)}
+
+	body, _ := json.Marshal(diff)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// rollback godoc
+// @Summary Rolls a product back to a revision
+// @Description Restores the fields of an older revision of the product, storing them as a new revision. The status
+// @Description is kept, since it has its own transitions, and the restored product is validated again
+// @Tags product revision
+// @Produce json
+// @Success 200 {object} contract.Product
+// @Failure 400,403,404,500 {object} contract.Response{status=int,message=object}
+// @Param sku path string true "product sku"
+// @Param revision path int true "revision restored"
+// @Param X-User header string true "user rolling back the product"
+// @Param X-SKU-Rule header string false "name of the SKU rule"
+// @Param X-Seller header string false "seller selecting the SKU rule"
+// @Router /product/{sku}/revisions/{revision}/rollback [post]
+func (s *server) rollback(w http.ResponseWriter, req *http.Request) {
+	rev, ok := s.revisionFrom(w, req)
+	if !ok {
+		return
+	}
+
+	//products are validated with the SKU rule they were created with
+	ctx, ok := s.withSKURule(req)
+	if !ok {
+		writeResponse(w, http.StatusBadRequest, fmt.Sprintf("unknown sku rule %s", req.Header.Get(skuRuleHeader)))
+		return
+	}
+
+	user := actor(req)
+
+	prd, err := s.db.UpdateFunc(rev.SKU, func(prd *contract.Product) error {
+		restored := rev.Product
+		restored.Status = prd.Status
+		restored.UpdatedBy = user
+		*prd = restored
+
+		return s.validate(ctx, *prd)
+	})
+	if err != nil {
+		var vErr *validationError
+
+		switch {
+		case errors.As(err, &vErr):
+			writeResponse(w, http.StatusBadRequest, vErr.errs)
+		case errors.Is(err, db.ErrNotFound):
+			writeResponse(w, http.StatusNotFound, "product not found")
+		default:
+			logrus.Errorf("error rolling back the product: %s", err)
+			writeResponse(w, http.StatusInternalServerError, "could not roll back the product")
+		}
+		return
+	}
+
+	logrus.Infof("Product %s rolled back to the revision %d", rev.SKU, rev.Revision)
+
+	body, _ := json.Marshal(prd)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+//retrieves the revision of the path, writing the error response when it cannot be retrieved
+func (s *server) revisionFrom(w http.ResponseWriter, req *http.Request) (*contract.ProductRevision, bool) {
+	vars := mux.Vars(req)
+
+	number, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		writeResponse(w, http.StatusNotFound, "revision not found")
+		return nil, false
+	}
+
+	rev, err := s.revisions.Revision(vars["sku"], number)
+	if err != nil {
+		writeRevisionError(w, err)
+		return nil, false
+	}
+
+	return rev, true
+}
+
+//only lets the users read and roll back the revisions, since they include the products that are not public
+func requireUser(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if actor(req) == "" {
+			writeResponse(w, http.StatusForbidden, "the revisions are only available to users")
+			return
+		}
+
+		next(w, req)
+	})
+}
+
+//reads the version of the product at the time of the asOf parameter from its revisions. Requests without it read
+//the current version
+func (s *server) asOf(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		value := req.URL.Query().Get(asOfParam)
+		if value == "" {
+			next(w, req)
+			return
+		}
+
+		if s.revisions == nil {
+			writeResponse(w, http.StatusBadRequest, "the revisions of the products are not enabled")
+			return
+		}
+
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "asOf must be a RFC 3339 date")
+			return
+		}
+
+		rev, err := s.revisions.RevisionAsOf(mux.Vars(req)["sku"], at)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			writeRevisionError(w, err)
+			return
+		}
+
+		if err != nil || !visible(actor(req), &rev.Product) {
+			writeResponse(w, http.StatusNotFound, "product not found")
+			return
+		}
+
+		prd := rev.Product
+		s.localize(w, req, &prd)
+		body, _ := json.Marshal(&prd)
+		writeJSONResponse(w, http.StatusOK, body)
+	})
+}
+
+//writes the error of a revision lookup
+func writeRevisionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, db.ErrNotFound) {
+		writeResponse(w, http.StatusNotFound, "revision not found")
+		return
+	}
+
+	logrus.Errorf("db error: %v", err)
+	writeResponse(w, http.StatusInternalServerError, "could not get revision")
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestRevisions(t *testing.T) {
+	mdb := &mockDB{prdCount: 1}
+	revs := &mockRevisions{revs: mockHistory()}
+	srv := NewServer("8081", mdb, WithRevisions(revs), WithSKURules(skuRules(t)))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	tests := map[string]struct {
+		method          string
+		path            string
+		user            string
+		rule            string
+		throwError      bool
+		statusExpected  int
+		messageExpected string
+	}{
+		"#1: list":                   {path: "/product/FAL-1000000/revisions", user: "editor", statusExpected: http.StatusOK, messageExpected: `[{"sku":"FAL-1000000","revision":3`},
+		"#2: anonymous list":         {path: "/product/FAL-1000000/revisions", statusExpected: http.StatusForbidden},
+		"#3: list of unknown sku":    {path: "/product/FAL-9999999/revisions", user: "editor", statusExpected: http.StatusNotFound},
+		"#4: list with db error":     {path: "/product/FAL-1000000/revisions", user: "editor", throwError: true, statusExpected: http.StatusInternalServerError},
+		"#5: revision":               {path: "/product/FAL-1000000/revisions/1", user: "editor", statusExpected: http.StatusOK, messageExpected: `"createdBy":"author"`},
+		"#6: unknown revision":       {path: "/product/FAL-1000000/revisions/9", user: "editor", statusExpected: http.StatusNotFound},
+		"#7: diff":                   {path: "/product/FAL-1000000/revisions/diff?from=1&to=3", user: "editor", statusExpected: http.StatusOK, messageExpected: `"changes":[{"field":"attributes.color","to":"black"},{"field":"name","from":"draft name","to":"new name"},{"field":"status","from":"draft","to":"active"}]`},
+		"#8: diff without revisions": {path: "/product/FAL-1000000/revisions/diff?from=1", user: "editor", statusExpected: http.StatusBadRequest, messageExpected: "to must be a revision number"},
+		"#9: diff of unknown":        {path: "/product/FAL-1000000/revisions/diff?from=1&to=9", user: "editor", statusExpected: http.StatusNotFound},
+		"#10: rollback":              {method: http.MethodPost, path: "/product/FAL-1000000/revisions/1/rollback", user: "editor", statusExpected: http.StatusOK, messageExpected: `"name":"draft name"`},
+		"#11: invalid rollback":      {method: http.MethodPost, path: "/product/FAL-1000000/revisions/3/rollback", user: "editor", statusExpected: http.StatusBadRequest},
+		"#12: anonymous rollback":    {method: http.MethodPost, path: "/product/FAL-1000000/revisions/1/rollback", statusExpected: http.StatusForbidden},
+		"#13: rollback of unknown":   {method: http.MethodPost, path: "/product/FAL-1000000/revisions/9/rollback", user: "editor", statusExpected: http.StatusNotFound},
+		"#14: as of a date":          {path: "/product/FAL-1000000?asOf=2021-02-15T00:00:00Z", statusExpected: http.StatusOK, messageExpected: `"name":"draft name"`},
+		"#15: as of a draft":         {path: "/product/FAL-1000000?asOf=2021-01-15T00:00:00Z", statusExpected: http.StatusNotFound},
+		"#16: user as of a draft":    {path: "/product/FAL-1000000?asOf=2021-01-15T00:00:00Z", user: "editor", statusExpected: http.StatusOK, messageExpected: `"status":"draft"`},
+		"#17: as of before creation": {path: "/product/FAL-1000000?asOf=2020-01-01T00:00:00Z", user: "editor", statusExpected: http.StatusNotFound},
+		"#18: invalid date":          {path: "/product/FAL-1000000?asOf=yesterday", statusExpected: http.StatusBadRequest},
+		"#19: rollback with a rule":  {method: http.MethodPost, path: "/product/FAL-1000000/revisions/1/rollback", user: "editor", rule: "fal", statusExpected: http.StatusOK},
+		"#20: rollback unknown rule": {method: http.MethodPost, path: "/product/FAL-1000000/revisions/1/rollback", user: "editor", rule: "other", statusExpected: http.StatusBadRequest, messageExpected: "unknown sku rule other"},
+	}
+
+	for desc, tc := range tests {
+		revs.throwError = tc.throwError
+
+		method := tc.method
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		req, _ := http.NewRequest(method, "http://localhost:8081"+tc.path, nil)
+		if tc.user != "" {
+			req.Header.Set(userHeader, tc.user)
+		}
+		if tc.rule != "" {
+			req.Header.Set(skuRuleHeader, tc.rule)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected || !strings.Contains(string(body), tc.messageExpected) {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v %s", desc, resp.StatusCode, body, tc.statusExpected, tc.messageExpected)
+		}
+	}
+
+	//the rollback restores the content of the revision, keeping the current status
+	saved, _ := mdb.saved.Load().(contract.Product)
+	if saved.Name != "draft name" || saved.Status != contract.StatusActive || saved.UpdatedBy != "editor" {
+		t.Errorf("rolled back product got: %+v", saved)
+	}
+}
+
+func TestAsOfWithoutRevisions(t *testing.T) {
+	srv := NewServer("8081", &mockDB{prdCount: 1})
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	resp, err := http.Get("http://localhost:8081/product/FAL-1000000?asOf=2021-02-15T00:00:00Z")
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Got: %v\n Expected: %v", resp.StatusCode, http.StatusBadRequest)
+	}
+}
@@ -2,10 +2,19 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/garciacer87/product-api/internal/auth"
+	"github.com/garciacer87/product-api/internal/cache"
 	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/media"
+	"github.com/garciacer87/product-api/internal/sku"
+	"github.com/garciacer87/product-api/internal/storage"
+	"github.com/garciacer87/product-api/internal/validation"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -21,26 +30,296 @@ type server struct {
 	httpPort   string
 	httpServer *http.Server
 	db         db.Database
+	inventory  db.Inventory
+	webhooks   db.Webhooks
+	skus       db.SKUSequences
+	categories db.CategorySchemas
+	revisions  db.Revisions
+	brands     db.Brands
+
+	images      db.Images
+	storage     storage.Storage
+	imagesURL   string
+	imageLimits media.Limits
+	imageChecks db.ImageChecks
+
+	idempotency    db.IdempotencyKeys
+	idempotencyTTL time.Duration
+	cache          *cache.Database
+	events         *eventBroker
+	eventLog       db.EventLog
+	eventInterval  time.Duration
+	validator      *validation.Validator
+
+	//Cache-Control policy per route
+	cacheControl map[string]string
+	//language of the names and descriptions of the products
+	defaultLanguage string
+	//products are only activated once they are approved by a second user
+	approval bool
+	//users authenticated with bearer tokens. Without them, users are identified by the user header
+	tokens *auth.Tokens
+
+	//background jobs are bound to this context, which is cancelled on shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	sweepInterval time.Duration
+}
+
+//Option configures optional features of the server
+type Option func(*server)
+
+//WithInventory enables the stock endpoints and the availability of products in the responses.
+//Expired reservations are released every sweepInterval
+func WithInventory(inv db.Inventory, sweepInterval time.Duration) Option {
+	return func(s *server) {
+		s.inventory = inv
+		s.sweepInterval = sweepInterval
+	}
+}
+
+//WithWebhooks enables the webhook subscription endpoints
+func WithWebhooks(store db.Webhooks) Option {
+	return func(s *server) {
+		s.webhooks = store
+	}
+}
+
+//WithEventLog enables the event streams, which follow the product events written to the log. The log is read
+//every interval. Default interval: 1s
+func WithEventLog(log db.EventLog, interval time.Duration) Option {
+	return func(s *server) {
+		s.eventLog = log
+		s.eventInterval = interval
+		if interval <= 0 {
+			s.eventInterval = defaultEventPollInterval
+		}
+	}
+}
+
+//WithEventReplay sets how many product events are kept to resume the event streams. Default: 1000
+func WithEventReplay(size int) Option {
+	return func(s *server) {
+		s.events = newEventBroker(size)
+	}
+}
+
+//WithSKURules validates the SKUs with the given rules instead of the default FAL- rule. The rule of a
+//request is selected with the X-SKU-Rule or X-Seller headers
+func WithSKURules(rules *sku.Rules) Option {
+	return func(s *server) {
+		s.validator = validation.NewWithRules(rules)
+	}
+}
+
+//WithSKUGeneration generates the SKUs of the products created without one from the sequences of their SKU
+//rules, and enables the endpoint reserving blocks of SKUs for offline tools
+func WithSKUGeneration(seqs db.SKUSequences) Option {
+	return func(s *server) {
+		s.skus = seqs
+	}
+}
+
+//WithIdempotency replays the responses of the POST requests retried with the same Idempotency-Key header for ttl.
+//Default ttl: 24h
+func WithIdempotency(store db.IdempotencyKeys, ttl time.Duration) Option {
+	return func(s *server) {
+		s.idempotency = store
+		s.idempotencyTTL = ttl
+		if ttl <= 0 {
+			s.idempotencyTTL = defaultIdempotencyTTL
+		}
+	}
+}
+
+//WithImages enables the upload of product images, which are stored in objects along with their thumbnails and
+//served from baseURL/images. The images of the deleted products are removed
+func WithImages(images db.Images, objects storage.Storage, baseURL string, limits media.Limits) Option {
+	return func(s *server) {
+		s.images = images
+		s.storage = objects
+		s.imagesURL = strings.TrimSuffix(baseURL, "/")
+		s.imageLimits = limits
+	}
+}
+
+//WithImageReport exposes the report of the product images found broken by the image checker
+func WithImageReport(checks db.ImageChecks) Option {
+	return func(s *server) {
+		s.imageChecks = checks
+	}
+}
+
+//WithDefaultLanguage sets the language of the names and descriptions of the products, which is reported in the
+//Content-Language header when none of their translations is requested. Default: en
+func WithDefaultLanguage(tag string) Option {
+	return func(s *server) {
+		s.defaultLanguage = tag
+	}
+}
+
+//WithCategorySchemas enables the custom attributes of the products, which are validated with the schema of
+//their category, along with the endpoints managing the schemas. Without it, products cannot have attributes
+func WithCategorySchemas(schemas db.CategorySchemas) Option {
+	return func(s *server) {
+		s.categories = schemas
+	}
+}
+
+//WithRevisions enables the endpoints reading, comparing and rolling back the revisions of the products, along
+//with the reads of a product at a point in time
+func WithRevisions(revs db.Revisions) Option {
+	return func(s *server) {
+		s.revisions = revs
+	}
+}
+
+//WithBrands enables the endpoints managing the brand registry and counting the products of each brand
+func WithBrands(brands db.Brands) Option {
+	return func(s *server) {
+		s.brands = brands
+	}
+}
+
+//WithApproval requires the products to be submitted for review and approved by a second user to become active.
+//The users are told apart once they are authenticated, so products are not activated without WithAuthentication
+func WithApproval() Option {
+	return func(s *server) {
+		s.approval = true
+	}
+}
+
+//WithAuthentication identifies the users by their bearer tokens instead of the X-User header, which is ignored.
+//Requests without Authorization header are anonymous
+func WithAuthentication(tokens *auth.Tokens) Option {
+	return func(s *server) {
+		s.tokens = tokens
+	}
+}
+
+//WithCacheStats exposes the statistics of the product cache
+func WithCacheStats(c *cache.Database) Option {
+	return func(s *server) {
+		s.cache = c
+	}
 }
 
 //NewServer creates a new server object
-func NewServer(port string, db db.Database) Server {
+func NewServer(port string, db db.Database, opts ...Option) Server {
 	r := mux.NewRouter()
 
 	r.HandleFunc("/health", healthHandler).Methods(http.MethodGet)
 	r.PathPrefix("/swagger/{*}").Handler(httpSwagger.WrapHandler)
 
 	srv := &server{
-		httpPort: port,
-		db:       db,
+		httpPort:  port,
+		db:        db,
+		validator: validation.New(),
+		events:    newEventBroker(defaultReplaySize),
+
+		cacheControl:    make(map[string]string, len(defaultCacheControl)),
+		defaultLanguage: defaultLanguage,
+	}
+	srv.ctx, srv.cancel = context.WithCancel(context.Background())
+
+	for route, policy := range defaultCacheControl {
+		srv.cacheControl[route] = policy
+	}
+
+	for _, opt := range opts {
+		opt(srv)
+	}
+
+	r.Use(srv.authenticate, srv.languages)
+
+	r.HandleFunc("/graphql", srv.graphql()).Methods(http.MethodPost)
+
+	//the events are streamed as they happen, so they are registered apart from the negotiated routes
+	if srv.eventLog != nil {
+		r.HandleFunc("/product/events", srv.streamEvents).Methods(http.MethodGet)
 	}
 
 	product := r.PathPrefix("/product").Subrouter()
-	product.HandleFunc("", validateProduct(srv.create)).Methods(http.MethodPost)
+	product.Use(srv.negotiate)
+	product.HandleFunc("", srv.idempotent(srv.decodeProduct(srv.generateSKU(srv.initialStatus(srv.validateProduct(srv.create)))))).Methods(http.MethodPost)
 	product.HandleFunc("", srv.getAll).Methods(http.MethodGet)
-	product.HandleFunc("/{sku}", validateExistence(db, srv.get)).Methods(http.MethodGet)
-	product.HandleFunc("/{sku}", validateExistence(db, validatePatchFields(db, srv.update))).Methods(http.MethodPatch)
-	product.HandleFunc("/{sku}", validateExistence(db, srv.delete)).Methods(http.MethodDelete)
+	product.HandleFunc("/batch", srv.idempotent(srv.batchGet)).Methods(http.MethodPost)
+	product.HandleFunc("/{sku}", srv.asOf(srv.validateExistence(srv.get))).Methods(http.MethodGet)
+	product.HandleFunc("/{sku}", srv.decodeProduct(srv.replace)).Methods(http.MethodPut)
+	product.HandleFunc("/{sku}", srv.decodeProduct(srv.update)).Methods(http.MethodPatch)
+	product.HandleFunc("/{sku}", srv.validateExistence(srv.delete)).Methods(http.MethodDelete)
+	product.HandleFunc("/{sku}/status", srv.changeStatus).Methods(http.MethodPost)
+
+	if srv.revisions != nil {
+		product.HandleFunc("/{sku}/revisions", requireUser(srv.getRevisions)).Methods(http.MethodGet)
+		product.HandleFunc("/{sku}/revisions/diff", requireUser(srv.diffRevisions)).Methods(http.MethodGet)
+		product.HandleFunc("/{sku}/revisions/{revision:[0-9]+}", requireUser(srv.getRevision)).Methods(http.MethodGet)
+		product.HandleFunc("/{sku}/revisions/{revision:[0-9]+}/rollback", requireUser(srv.rollback)).Methods(http.MethodPost)
+	}
+
+	if srv.inventory != nil {
+		product.HandleFunc("/{sku}/stock", srv.validateExistence(srv.getStock)).Methods(http.MethodGet)
+		product.HandleFunc("/{sku}/stock", srv.idempotent(srv.validateExistence(srv.adjustStock))).Methods(http.MethodPost)
+		product.HandleFunc("/{sku}/reservations", srv.idempotent(srv.validateExistence(srv.reserve))).Methods(http.MethodPost)
+
+		reservation := r.PathPrefix("/reservations").Subrouter()
+		reservation.Use(srv.negotiate)
+		reservation.HandleFunc("/{id:[0-9]+}/commit", srv.commitReservation).Methods(http.MethodPost)
+		reservation.HandleFunc("/{id:[0-9]+}", srv.releaseReservation).Methods(http.MethodDelete)
+	}
+
+	if srv.images != nil {
+		product.HandleFunc("/{sku}/images", srv.validateExistence(srv.uploadImage)).Methods(http.MethodPost)
+		product.HandleFunc("/{sku}/images", srv.validateExistence(srv.getImages)).Methods(http.MethodGet)
+		product.HandleFunc("/{sku}/images/{id:[0-9]+}", srv.validateExistence(srv.deleteImage)).Methods(http.MethodDelete)
+
+		r.HandleFunc("/images/{key:.+}", srv.serveImage).Methods(http.MethodGet, http.MethodHead)
+	}
+
+	if srv.imageChecks != nil {
+		r.Handle("/reports/broken-images", srv.negotiate(http.HandlerFunc(srv.brokenImages))).Methods(http.MethodGet)
+	}
+
+	if srv.skus != nil {
+		r.Handle("/skus", srv.negotiate(srv.idempotent(srv.reserveSKUs))).Methods(http.MethodPost)
+	}
+
+	if srv.webhooks != nil {
+		webhooks := r.PathPrefix("/webhooks").Subrouter()
+		webhooks.Use(srv.negotiate)
+		webhooks.HandleFunc("", srv.createSubscription).Methods(http.MethodPost)
+		webhooks.HandleFunc("", srv.getSubscriptions).Methods(http.MethodGet)
+		webhooks.HandleFunc("/deliveries", srv.getDeliveries).Methods(http.MethodGet)
+		webhooks.HandleFunc("/deliveries/{id:[0-9]+}/replay", srv.replayDelivery).Methods(http.MethodPost)
+		webhooks.HandleFunc("/{id:[0-9]+}", srv.getSubscription).Methods(http.MethodGet)
+		webhooks.HandleFunc("/{id:[0-9]+}", srv.deleteSubscription).Methods(http.MethodDelete)
+		webhooks.HandleFunc("/{id:[0-9]+}/replay", srv.replaySubscription).Methods(http.MethodPost)
+	}
+
+	if srv.categories != nil {
+		categories := r.PathPrefix("/categories").Subrouter()
+		categories.Use(srv.negotiate)
+		categories.HandleFunc("", srv.getCategorySchemas).Methods(http.MethodGet)
+		categories.HandleFunc("/{category}", srv.getCategorySchema).Methods(http.MethodGet)
+		categories.HandleFunc("/{category}", srv.putCategorySchema).Methods(http.MethodPut)
+		categories.HandleFunc("/{category}", srv.deleteCategorySchema).Methods(http.MethodDelete)
+	}
+
+	if srv.brands != nil {
+		brands := r.PathPrefix("/brands").Subrouter()
+		brands.Use(srv.negotiate)
+		brands.HandleFunc("", srv.getBrands).Methods(http.MethodGet)
+		brands.HandleFunc("", srv.createBrand).Methods(http.MethodPost)
+		brands.HandleFunc("/facets", srv.getBrandFacets).Methods(http.MethodGet)
+		brands.HandleFunc("/{id:[0-9]+}", srv.getBrand).Methods(http.MethodGet)
+		brands.HandleFunc("/{id:[0-9]+}", srv.replaceBrand).Methods(http.MethodPut)
+		brands.HandleFunc("/{id:[0-9]+}", srv.deleteBrand).Methods(http.MethodDelete)
+	}
+
+	if srv.cache != nil {
+		r.Handle("/cache/stats", srv.negotiate(http.HandlerFunc(srv.cacheStats))).Methods(http.MethodGet)
+	}
 
 	srv.httpServer = &http.Server{
 		Addr:    fmt.Sprintf("0.0.0.0:%v", port),
@@ -52,6 +331,22 @@ func NewServer(port string, db db.Database) Server {
 
 //ListenAndServe starts the http server on the previously configurated port
 func (s *server) ListenAndServe() error {
+	if s.inventory != nil && s.sweepInterval > 0 {
+		go s.sweepReservations(s.ctx)
+	}
+
+	if s.idempotency != nil {
+		go s.sweepIdempotencyKeys(s.ctx)
+	}
+
+	if s.images != nil {
+		go s.sweepImages(s.ctx)
+	}
+
+	if s.eventLog != nil {
+		go s.events.follow(s.ctx, s.eventLog, s.eventInterval)
+	}
+
 	logrus.Printf("serving on port %s\n", s.httpPort)
 	return s.httpServer.ListenAndServe()
 }
@@ -60,6 +355,10 @@ func (s *server) ListenAndServe() error {
 func (s *server) Shutdown(ctx context.Context) error {
 	logrus.Infof("Shutting down API server")
 
+	// stop background jobs and close the event streams, which would keep the server from shutting down
+	s.cancel()
+	s.events.close()
+
 	// close DB connection
 	s.db.Close()
 
@@ -69,3 +368,14 @@ func (s *server) Shutdown(ctx context.Context) error {
 func healthHandler(w http.ResponseWriter, _ *http.Request) {
 	writeResponse(w, http.StatusOK, "healthy")
 }
+
+// cacheStats godoc
+// @Summary Statistics of the product cache
+// @Description Hits, misses, coalesced lookups and evictions of the product cache since the server started
+// @Tags cache
+// @Success 200 {object} cache.Stats
+// @Router /cache/stats [get]
+func (s *server) cacheStats(w http.ResponseWriter, _ *http.Request) {
+	body, _ := json.Marshal(s.cache.Stats())
+	writeJSONResponse(w, http.StatusOK, body)
+}
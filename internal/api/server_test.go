@@ -2,8 +2,12 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/cache"
 )
 
 func TestListenAndServe(t *testing.T) {
@@ -14,7 +18,7 @@ func TestListenAndServe(t *testing.T) {
 
 	defer srv.Shutdown(context.Background())
 
-	go srv.ListenAndServe() //serving in port 8081
+	serve(t, srv) //serving in port 8081
 
 	resp, err := http.Get("http://localhost:8081/health")
 	if err != nil {
@@ -36,3 +40,38 @@ func TestListenAndServe(t *testing.T) {
 		t.Errorf("error expected. Cannot serve in a port already used")
 	}
 }
+
+func TestCacheStats(t *testing.T) {
+	c := cache.New(&mockDB{prdCount: 1}, 10, time.Minute, time.Minute)
+	srv := NewServer("8081", c, WithCacheStats(c))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get("http://localhost:8081/product/FAL-1000000")
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get("http://localhost:8081/cache/stats")
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+	defer resp.Body.Close()
+
+	stats := cache.Stats{}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatalf("could not decode response body: %v", err)
+	}
+
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("stats got: %+v", stats)
+	}
+}
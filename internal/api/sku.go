@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/sirupsen/logrus"
+)
+
+//maxSKUAttempts number of generated SKUs tried when creating a product before giving up
+const maxSKUAttempts = 3
+
+// reserveSKUs godoc
+// @Summary Reserves a block of SKUs
+// @Description Allocates a block of SKUs from the sequence of the SKU rule for offline tools. The SKUs are never
+// @Description generated again, so the products can be created later with them
+// @Tags sku
+// @Accept json
+// @Success 201 {object} contract.SKUBlock
+// @Failure 400,409,422,500 {object} contract.Response{status=int,message=object}
+// @Param block body contract.SKUBlockRequest true "number of SKUs"
+// @Param X-SKU-Rule header string false "name of the SKU rule"
+// @Param X-Seller header string false "seller selecting the SKU rule"
+// @Param Idempotency-Key header string false "key of the request. Retries with the same key replay the original response"
+// @Router /skus [post]
+func (s *server) reserveSKUs(w http.ResponseWriter, req *http.Request) {
+	ctx, ok := s.withSKURule(req)
+	if !ok {
+		writeResponse(w, http.StatusBadRequest, fmt.Sprintf("unknown sku rule %s", req.Header.Get(skuRuleHeader)))
+		return
+	}
+	req = req.WithContext(ctx)
+
+	block := contract.SKUBlockRequest{}
+	if !s.decodeAndValidate(w, req, &block) {
+		return
+	}
+
+	skus, ok := s.nextSKUs(w, req, block.Count)
+	if !ok {
+		return
+	}
+
+	rule := s.validator.Rule(ctx)
+	logrus.Infof("%d SKUs of rule %s reserved by %s", len(skus), rule.Name, actor(req))
+
+	body, _ := json.Marshal(contract.SKUBlock{Rule: rule.Name, SKUs: skus})
+	writeJSONResponse(w, http.StatusCreated, body)
+}
+
+//allocates SKUs from the sequence of the rule of the request, writing the error response when it fails
+func (s *server) nextSKUs(w http.ResponseWriter, req *http.Request, count int) ([]string, bool) {
+	rule := s.validator.Rule(req.Context())
+	if !rule.Generates() {
+		writeResponse(w, http.StatusBadRequest, fmt.Sprintf("sku rule %s cannot generate SKUs, the sku is required", rule.Name))
+		return nil, false
+	}
+
+	skus, err := s.skus.NextSKUs(rule, count)
+	if err != nil {
+		if errors.Is(err, db.ErrSKURangeExhausted) {
+			writeResponse(w, http.StatusConflict, fmt.Sprintf("not enough SKUs left in the range of sku rule %s", rule.Name))
+			return nil, false
+		}
+
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not generate SKUs")
+		return nil, false
+	}
+
+	return skus, true
+}
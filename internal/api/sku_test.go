@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/sku"
+)
+
+func skuRules(t *testing.T) *sku.Rules {
+	t.Helper()
+
+	min, max := uint64(1000000), uint64(99999999)
+	rules, err := sku.New(sku.Config{Rules: []sku.Rule{
+		{Name: "fal", Prefix: "FAL-", Min: &min, Max: &max},
+		{Name: "mkp", Pattern: `MKP-[A-Z]{3}-\d{4}`},
+	}})
+	if err != nil {
+		t.Fatalf("could not create the sku rules: %v", err)
+	}
+
+	return rules
+}
+
+func TestGenerateSKU(t *testing.T) {
+	var (
+		mockDB   = &mockDB{}
+		mockSKUs = &mockSKUs{}
+		srv      = NewServer("8081", mockDB, WithSKURules(skuRules(t)), WithSKUGeneration(mockSKUs))
+	)
+
+	serve(t, srv)
+
+	//the server is replaced by one without generation at the end
+	defer func() {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}()
+
+	tests := []struct {
+		desc             string
+		sku              string
+		rule             string
+		conflicts        int32
+		skusLeft         int
+		statusExpected   int
+		locationExpected string
+	}{
+		{desc: "#1: generated sku", skusLeft: 10, statusExpected: http.StatusCreated, locationExpected: "/product/FAL-1000001"},
+		{desc: "#2: given sku", sku: "FAL-2000000", skusLeft: 10, statusExpected: http.StatusCreated, locationExpected: "/product/FAL-2000000"},
+		{desc: "#3: given sku already exists", sku: "FAL-2000000", conflicts: 1, skusLeft: 10, statusExpected: http.StatusConflict},
+		{desc: "#4: generated sku taken by hand", conflicts: 2, skusLeft: 10, statusExpected: http.StatusCreated, locationExpected: "/product/FAL-1000003"},
+		{desc: "#5: generated skus keep failing", conflicts: maxSKUAttempts, skusLeft: 10, statusExpected: http.StatusConflict},
+		{desc: "#6: range exhausted", statusExpected: http.StatusConflict},
+		{desc: "#7: rule without range", rule: "mkp", skusLeft: 10, statusExpected: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		mockDB.conflicts, mockSKUs.last, mockSKUs.left = tc.conflicts, 1000000, tc.skusLeft
+
+		body := fmt.Sprintf(`{"sku":"%s","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a"}`, tc.sku)
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost:8081/product", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.rule != "" {
+			req.Header.Set(skuRuleHeader, tc.rule)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected || resp.Header.Get("Location") != tc.locationExpected {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v %s", tc.desc, resp.StatusCode, resp.Header.Get("Location"), tc.statusExpected, tc.locationExpected)
+		}
+	}
+
+	//without generation the sku is required
+	mockDB.conflicts = 0
+	srv.Shutdown(context.Background())
+
+	srv = NewServer("8081", mockDB)
+	serve(t, srv)
+
+	resp, err := http.Post("http://localhost:8081/product", "application/json", strings.NewReader(`{"name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a"}`))
+	if err != nil {
+		t.Fatalf("error not expected")
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("#8: sku required:\n Got: %v\n Expected: %v", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestReserveSKUs(t *testing.T) {
+	mockSKUs := &mockSKUs{}
+	srv := NewServer("8081", &mockDB{}, WithSKURules(skuRules(t)), WithSKUGeneration(mockSKUs))
+
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	tests := map[string]struct {
+		body           string
+		rule           string
+		skusLeft       int
+		statusExpected int
+		skusExpected   []string
+	}{
+		"#1: valid case":       {body: `{"count":3}`, skusLeft: 10, statusExpected: http.StatusCreated, skusExpected: []string{"FAL-1000001", "FAL-1000002", "FAL-1000003"}},
+		"#2: no count":         {body: `{}`, skusLeft: 10, statusExpected: http.StatusBadRequest},
+		"#3: too many":         {body: `{"count":1001}`, skusLeft: 10000, statusExpected: http.StatusBadRequest},
+		"#4: range exhausted":  {body: `{"count":11}`, skusLeft: 10, statusExpected: http.StatusConflict},
+		"#5: unknown rule":     {body: `{"count":1}`, rule: "other", skusLeft: 10, statusExpected: http.StatusBadRequest},
+		"#6: rule cannot gen.": {body: `{"count":1}`, rule: "mkp", skusLeft: 10, statusExpected: http.StatusBadRequest},
+	}
+
+	for desc, tc := range tests {
+		mockSKUs.last, mockSKUs.left = 1000000, tc.skusLeft
+
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost:8081/skus", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.rule != "" {
+			req.Header.Set(skuRuleHeader, tc.rule)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected")
+		}
+
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v", desc, resp.StatusCode, respBody, tc.statusExpected)
+			continue
+		}
+
+		if tc.skusExpected != nil {
+			block := contract.SKUBlock{}
+			if err := json.Unmarshal(respBody, &block); err != nil || block.Rule != "fal" || !reflect.DeepEqual(block.SKUs, tc.skusExpected) {
+				t.Errorf("%s:\n block got: %+v, %v\n expected: %v", desc, block, err, tc.skusExpected)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+//errStatusChange rejects the changes of status out of the status endpoint
+var errStatusChange = &validationError{[]string{"the status of a product is changed with POST /product/{sku}/status"}}
+
+//transitionError rejects a change of status, with the status code of the response
+type transitionError struct {
+	code int
+	msg  string
+}
+
+func (e *transitionError) Error() string {
+	return e.msg
+}
+
+// changeStatus godoc
+// @Summary Changes the status of a product
+// @Description Moves the product through its lifecycle: draft, pending_review, active, discontinued and archived.
+// @Description Products are validated with all the rules unless they become drafts. When approval is required,
+// @Description products are activated from pending_review by an authenticated user other than the one who submitted them
+// @Tags product status
+// @Accept json
+// @Produce json
+// @Success 200 {object} contract.Product
+// @Failure 400,403,404,409,500 {object} contract.Response{status=int,message=object}
+// @Param sku path string true "product sku"
+// @Param change body contract.StatusChange true "new status"
+// @Param X-User header string false "user changing the status. Ignored when the users are authenticated"
+// @Param X-SKU-Rule header string false "name of the SKU rule"
+// @Param X-Seller header string false "seller selecting the SKU rule"
+// @Param Authorization header string false "bearer token of the user changing the status. Required to approve products"
+// @Router /product/{sku}/status [post]
+func (s *server) changeStatus(w http.ResponseWriter, req *http.Request) {
+	var (
+		sku    = mux.Vars(req)["sku"]
+		change = contract.StatusChange{}
+	)
+
+	if !s.decodeAndValidate(w, req, &change) {
+		return
+	}
+
+	//products are validated with the SKU rule they were created with
+	ctx, ok := s.withSKURule(req)
+	if !ok {
+		writeResponse(w, http.StatusBadRequest, fmt.Sprintf("unknown sku rule %s", req.Header.Get(skuRuleHeader)))
+		return
+	}
+
+	user := actor(req)
+
+	prd, err := s.db.UpdateFunc(sku, func(prd *contract.Product) error {
+		if err := s.checkTransition(prd, change.Status, user); err != nil {
+			return err
+		}
+
+		prd.Status = change.Status
+		prd.UpdatedBy = user
+
+		return s.validate(ctx, *prd)
+	})
+	if err != nil {
+		var (
+			tErr *transitionError
+			vErr *validationError
+		)
+
+		switch {
+		case errors.As(err, &tErr):
+			writeResponse(w, tErr.code, tErr.msg)
+		case errors.As(err, &vErr):
+			writeResponse(w, http.StatusBadRequest, vErr.errs)
+		case errors.Is(err, db.ErrNotFound):
+			writeResponse(w, http.StatusNotFound, "product not found")
+		default:
+			logrus.Errorf("error changing the status of the product: %s", err)
+			writeResponse(w, http.StatusInternalServerError, "could not change the status of the product")
+		}
+		return
+	}
+
+	logrus.Infof("Product %s is %s", sku, prd.Status)
+
+	body, _ := json.Marshal(prd)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+//sets the first status of the products to be created, which are drafts unless they are created with another status
+func (s *server) initialStatus(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		prd := bodyFrom(req)
+		if err := s.startStatus(&prd, actor(req)); err != nil {
+			writeTransitionError(w, err)
+			return
+		}
+
+		next(w, req.WithContext(context.WithValue(req.Context(), bodyKey, prd)))
+	})
+}
+
+//sets the status of a product to be created, checking it can be its first status
+func (s *server) startStatus(prd *contract.Product, user string) error {
+	status := prd.Status
+	if status == "" {
+		status = contract.StatusDraft
+	}
+
+	if err := s.checkTransition(&contract.Product{}, status, user); err != nil {
+		return err
+	}
+	prd.Status = status
+
+	return nil
+}
+
+//checks the product can change to the status. When approval is required, the products are activated once
+//they are reviewed, by an authenticated user other than the one who submitted them
+func (s *server) checkTransition(prd *contract.Product, status, user string) error {
+	if !contract.CanTransition(prd.Status, status) {
+		if prd.Status == "" {
+			return &transitionError{http.StatusConflict, fmt.Sprintf("products cannot be created with the status %s", status)}
+		}
+		return &transitionError{http.StatusConflict, fmt.Sprintf("the status cannot change from %s to %s", prd.Status, status)}
+	}
+
+	if !s.approval || status != contract.StatusActive {
+		return nil
+	}
+
+	if prd.Status != contract.StatusPendingReview {
+		return &transitionError{http.StatusConflict, "the product must be submitted for review before it is activated"}
+	}
+
+	//the user header can be set by anyone, so it cannot tell the reviewer apart from the author
+	if s.tokens == nil {
+		return &transitionError{http.StatusForbidden, "products cannot be approved until the users are authenticated"}
+	}
+
+	if user == "" || user == prd.StatusUpdatedBy {
+		return &transitionError{http.StatusForbidden, "the product must be approved by a user other than the one who submitted it"}
+	}
+
+	return nil
+}
+
+//writes the rejection of a status, or the errors of a failed validation
+func writeTransitionError(w http.ResponseWriter, err error) {
+	var tErr *transitionError
+	if errors.As(err, &tErr) {
+		writeResponse(w, tErr.code, tErr.msg)
+		return
+	}
+
+	writeValidationError(w, err)
+}
+
+//reports if the user can read the product. Anonymous requests, without user header, only read the active products
+func visible(user string, prd *contract.Product) bool {
+	return user != "" || prd.Public()
+}
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/auth"
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestChangeStatus(t *testing.T) {
+	mdb := &mockDB{prdCount: 1}
+	srv := NewServer("8081", mdb, WithSKURules(skuRules(t)))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	tests := map[string]struct {
+		edit            func(prd *contract.Product)
+		prdCount        int
+		body            string
+		rule            string
+		statusExpected  int
+		messageExpected string
+	}{
+		"#1: submitted for review": {edit: draft, prdCount: 1, body: `{"status":"pending_review"}`, statusExpected: http.StatusOK, messageExpected: `"status":"pending_review"`},
+		"#2: activated draft":      {edit: draft, prdCount: 1, body: `{"status":"active"}`, statusExpected: http.StatusOK, messageExpected: `"status":"active"`},
+		"#3: invalid transition":   {prdCount: 1, body: `{"status":"draft"}`, statusExpected: http.StatusConflict, messageExpected: "the status cannot change from active to draft"},
+		"#4: unknown status":       {prdCount: 1, body: `{"status":"sold"}`, statusExpected: http.StatusBadRequest},
+		"#5: incomplete product": {edit: func(prd *contract.Product) { draft(prd); prd.ImageURL = "" }, prdCount: 1, body: `{"status":"pending_review"}`,
+			statusExpected: http.StatusBadRequest, messageExpected: "ImageURL must have a value"},
+		"#6: discontinued product": {prdCount: 1, body: `{"status":"discontinued"}`, statusExpected: http.StatusOK, messageExpected: `"status":"discontinued"`},
+		"#7: product not found":    {body: `{"status":"active"}`, statusExpected: http.StatusNotFound},
+		"#8: sku of selected rule": {edit: marketplace, prdCount: 1, body: `{"status":"pending_review"}`, rule: "mkp", statusExpected: http.StatusOK},
+		"#9: sku of another rule": {edit: marketplace, prdCount: 1, body: `{"status":"pending_review"}`, statusExpected: http.StatusBadRequest,
+			messageExpected: "SKU does not satisfy the SKU rule fal"},
+		"#10: unknown rule": {prdCount: 1, body: `{"status":"discontinued"}`, rule: "other", statusExpected: http.StatusBadRequest, messageExpected: "unknown sku rule other"},
+	}
+
+	for desc, tc := range tests {
+		mdb.edit, mdb.prdCount = tc.edit, tc.prdCount
+
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost:8081/product/FAL-1000000/status", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userHeader, "editor")
+		if tc.rule != "" {
+			req.Header.Set(skuRuleHeader, tc.rule)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected || !strings.Contains(string(body), tc.messageExpected) {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v %s", desc, resp.StatusCode, body, tc.statusExpected, tc.messageExpected)
+		}
+	}
+}
+
+func TestApproval(t *testing.T) {
+	tokens, err := auth.New(map[string][]string{"author": {auth.Digest("author-token")}, "reviewer": {auth.Digest("reviewer-token")}})
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	mdb := &mockDB{prdCount: 1}
+	srv := NewServer("8081", mdb, WithApproval(), WithAuthentication(tokens))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	submitted := func(prd *contract.Product) {
+		prd.Status, prd.StatusUpdatedBy = contract.StatusPendingReview, "author"
+	}
+
+	const prd = `{"sku":"FAL-1000000","name":"name","brand":"brand","size":10,"price":100,"imageURL":"http://a"`
+
+	tests := map[string]struct {
+		edit           func(prd *contract.Product)
+		method         string
+		path           string
+		body           string
+		user           string
+		token          string
+		header         string
+		statusExpected int
+	}{
+		"#1: approved":                 {edit: submitted, path: "/product/FAL-1000000/status", body: `{"status":"active"}`, user: "reviewer", statusExpected: http.StatusOK},
+		"#2: approved by its author":   {edit: submitted, path: "/product/FAL-1000000/status", body: `{"status":"active"}`, user: "author", statusExpected: http.StatusForbidden},
+		"#3: approved anonymously":     {edit: submitted, path: "/product/FAL-1000000/status", body: `{"status":"active"}`, statusExpected: http.StatusForbidden},
+		"#4: rejected":                 {edit: submitted, path: "/product/FAL-1000000/status", body: `{"status":"draft"}`, user: "author", statusExpected: http.StatusOK},
+		"#5: draft not reviewed":       {edit: draft, path: "/product/FAL-1000000/status", body: `{"status":"active"}`, user: "reviewer", statusExpected: http.StatusConflict},
+		"#6: created as active":        {path: "/product", body: prd + `,"status":"active"}`, user: "author", statusExpected: http.StatusConflict},
+		"#7: created for review":       {path: "/product", body: prd + `,"status":"pending_review"}`, user: "author", statusExpected: http.StatusCreated},
+		"#8: replaced with a status":   {method: http.MethodPut, path: "/product/FAL-1000000", body: prd + `,"status":"archived"}`, user: "author", statusExpected: http.StatusBadRequest},
+		"#9: patched with a status":    {method: http.MethodPatch, path: "/product/FAL-1000000", body: `{"status":"archived"}`, user: "author", statusExpected: http.StatusBadRequest},
+		"#10: patched keeping status":  {method: http.MethodPatch, path: "/product/FAL-1000000", body: `{"name":"other name","status":"active"}`, user: "author", statusExpected: http.StatusOK},
+		"#11: approved by user header": {edit: submitted, path: "/product/FAL-1000000/status", body: `{"status":"active"}`, header: "reviewer", statusExpected: http.StatusForbidden},
+		"#12: unknown token":           {edit: submitted, path: "/product/FAL-1000000/status", body: `{"status":"active"}`, token: "unknown", statusExpected: http.StatusUnauthorized},
+	}
+
+	for desc, tc := range tests {
+		mdb.edit = tc.edit
+
+		method := tc.method
+		if method == "" {
+			method = http.MethodPost
+		}
+
+		req, _ := http.NewRequest(method, "http://localhost:8081"+tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.user != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.user+"-token")
+		}
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		if tc.header != "" {
+			req.Header.Set(userHeader, tc.header)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v", desc, resp.StatusCode, body, tc.statusExpected)
+		}
+	}
+}
+
+func TestApprovalWithoutAuthentication(t *testing.T) {
+	mdb := &mockDB{prdCount: 1, edit: func(prd *contract.Product) {
+		prd.Status, prd.StatusUpdatedBy = contract.StatusPendingReview, "author"
+	}}
+	srv := NewServer("8081", mdb, WithApproval())
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	req, _ := http.NewRequest(http.MethodPost, "http://localhost:8081/product/FAL-1000000/status", strings.NewReader(`{"status":"active"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(userHeader, "reviewer")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("products must not be approved by the user header. Got: %v", resp.StatusCode)
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	mdb := &mockDB{prdCount: 1, edit: draft}
+	srv := NewServer("8081", mdb)
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	tests := map[string]struct {
+		method         string
+		path           string
+		user           string
+		statusExpected int
+		filterExpected string
+	}{
+		"#1: anonymous read":        {method: http.MethodGet, path: "/product/FAL-1000000", statusExpected: http.StatusNotFound},
+		"#2: user read":             {method: http.MethodGet, path: "/product/FAL-1000000", user: "editor", statusExpected: http.StatusOK},
+		"#3: anonymous list":        {method: http.MethodGet, path: "/product?status=draft", statusExpected: http.StatusOK, filterExpected: contract.StatusActive},
+		"#4: user list":             {method: http.MethodGet, path: "/product", user: "editor", statusExpected: http.StatusOK},
+		"#5: user list by status":   {method: http.MethodGet, path: "/product?status=draft", user: "editor", statusExpected: http.StatusOK, filterExpected: contract.StatusDraft},
+		"#6: unknown status":        {method: http.MethodGet, path: "/product?status=sold", user: "editor", statusExpected: http.StatusBadRequest},
+		"#7: anonymous batch":       {method: http.MethodPost, path: "/product/batch", statusExpected: http.StatusOK},
+		"#8: anonymous graphql":     {method: http.MethodPost, path: "/graphql", statusExpected: http.StatusOK},
+		"#9: user graphql":          {method: http.MethodPost, path: "/graphql", user: "editor", statusExpected: http.StatusOK},
+		"#10: user batch":           {method: http.MethodPost, path: "/product/batch", user: "editor", statusExpected: http.StatusOK},
+		"#11: user read of reviews": {method: http.MethodGet, path: "/product?status=pending_review", user: "editor", statusExpected: http.StatusOK, filterExpected: contract.StatusPendingReview},
+	}
+
+	for desc, tc := range tests {
+		mdb.filter.Store(contract.ProductFilter{})
+
+		var body string
+		switch tc.path {
+		case "/product/batch":
+			body = `{"skus":["FAL-1000000"]}`
+		case "/graphql":
+			body = `{"query":"{ product(sku: \"FAL-1000000\") { sku status } }"}`
+		}
+
+		req, _ := http.NewRequest(tc.method, "http://localhost:8081"+tc.path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.user != "" {
+			req.Header.Set(userHeader, tc.user)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Got: %v %s\n Expected: %v", desc, resp.StatusCode, respBody, tc.statusExpected)
+			continue
+		}
+
+		switch tc.path {
+		case "/product/batch":
+			batch := contract.BatchResponse{}
+			json.Unmarshal(respBody, &batch)
+
+			if found := len(batch.Products) == 1; found != (tc.user != "") {
+				t.Errorf("%s:\n Got: %s", desc, respBody)
+			}
+		case "/graphql":
+			if found := strings.Contains(string(respBody), `"status":"draft"`); found != (tc.user != "") {
+				t.Errorf("%s:\n Got: %s", desc, respBody)
+			}
+		}
+
+		if resp.StatusCode == http.StatusOK && (tc.path == "/product" || strings.HasPrefix(tc.path, "/product?")) {
+			if filter := mdb.filter.Load().(contract.ProductFilter); filter.Status != tc.filterExpected {
+				t.Errorf("%s:\n Status filter got: %q\n Expected: %q", desc, filter.Status, tc.filterExpected)
+			}
+
+			if !strings.Contains(strings.Join(resp.Header.Values("Vary"), ","), userHeader) {
+				t.Errorf("%s: the response should vary by %s", desc, userHeader)
+			}
+		}
+	}
+}
+
+//turns the mocked product into a draft
+func draft(prd *contract.Product) {
+	prd.Status = contract.StatusDraft
+}
+
+//marketplace turns the product into a draft of the marketplace SKU rule
+func marketplace(prd *contract.Product) {
+	prd.SKU, prd.Status = "MKP-ABC-1234", contract.StatusDraft
+}
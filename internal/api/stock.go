@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+//defaultReservationTTL used when the reservation request does not define one
+const defaultReservationTTL = 15 * time.Minute
+
+// getStock godoc
+// @Summary Get the stock of a product
+// @Description Get the on-hand, reserved and available quantities of a product per warehouse
+// @Tags stock
+// @Success 200 {object} contract.Availability
+// @Failure 400,404,500 {object} contract.Response{status=int,message=object}
+// @Param sku path string true "product sku"
+// @Router /product/{sku}/stock [get]
+func (s *server) getStock(w http.ResponseWriter, req *http.Request) {
+	sku := mux.Vars(req)["sku"]
+
+	stocks, err := s.inventory.Stock(sku)
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not get the stock of the product")
+		return
+	}
+
+	body, _ := json.Marshal(contract.NewAvailability(stocks))
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// adjustStock godoc
+// @Summary Adjusts the stock of a product
+// @Description Adds a positive or negative delta to the on-hand quantity of a product in a warehouse
+// @Tags stock
+// @Accept json
+// @Success 200 {object} contract.Stock
+// @Failure 400,404,409,422,500 {object} contract.Response{status=int,message=object}
+// @Param sku path string true "product sku"
+// @Param adjustment body contract.StockAdjustment true "stock adjustment"
+// @Param Idempotency-Key header string false "key of the request. Retries with the same key replay the original response"
+// @Router /product/{sku}/stock [post]
+func (s *server) adjustStock(w http.ResponseWriter, req *http.Request) {
+	sku := mux.Vars(req)["sku"]
+
+	adj := contract.StockAdjustment{}
+	if !s.decodeAndValidate(w, req, &adj) {
+		return
+	}
+
+	stock, err := s.inventory.AdjustStock(sku, adj)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			writeResponse(w, http.StatusNotFound, "product not found")
+		case errors.Is(err, db.ErrInsufficientStock):
+			writeResponse(w, http.StatusConflict, "on-hand quantity cannot be lower than the reserved quantity")
+		default:
+			logrus.Errorf("db error: %v", err)
+			writeResponse(w, http.StatusInternalServerError, "could not adjust the stock of the product")
+		}
+		return
+	}
+
+	logrus.Infof("Stock of product %s in warehouse %s adjusted by %d", sku, adj.Warehouse, adj.Delta)
+	body, _ := json.Marshal(stock)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// reserve godoc
+// @Summary Reserves stock of a product
+// @Description Holds a quantity of a product in a warehouse until the reservation is committed, released or expired
+// @Tags stock
+// @Accept json
+// @Success 201 {object} contract.Reservation
+// @Failure 400,404,409,422,500 {object} contract.Response{status=int,message=object}
+// @Param sku path string true "product sku"
+// @Param reservation body contract.ReservationRequest true "reservation"
+// @Param Idempotency-Key header string false "key of the request. Retries with the same key replay the original response"
+// @Router /product/{sku}/reservations [post]
+func (s *server) reserve(w http.ResponseWriter, req *http.Request) {
+	sku := mux.Vars(req)["sku"]
+
+	rsvReq := contract.ReservationRequest{}
+	if !s.decodeAndValidate(w, req, &rsvReq) {
+		return
+	}
+
+	if rsvReq.TTLSeconds == 0 {
+		rsvReq.TTLSeconds = int(defaultReservationTTL.Seconds())
+	}
+
+	rsv, err := s.inventory.Reserve(sku, rsvReq)
+	if err != nil {
+		if errors.Is(err, db.ErrInsufficientStock) {
+			writeResponse(w, http.StatusConflict, "not enough stock available")
+			return
+		}
+
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not reserve stock")
+		return
+	}
+
+	logrus.Infof("Reservation %d of %d units of product %s created", rsv.ID, rsv.Quantity, sku)
+	body, _ := json.Marshal(rsv)
+	writeJSONResponse(w, http.StatusCreated, body)
+}
+
+// commitReservation godoc
+// @Summary Commits a reservation
+// @Description Consumes the reserved quantity from the on-hand stock
+// @Tags stock
+// @Success 200 {object} contract.Reservation
+// @Failure 400,404,409,500 {object} contract.Response{status=int,message=object}
+// @Param id path int true "reservation id"
+// @Router /reservations/{id}/commit [post]
+func (s *server) commitReservation(w http.ResponseWriter, req *http.Request) {
+	s.closeReservation(w, req, s.inventory.CommitReservation)
+}
+
+// releaseReservation godoc
+// @Summary Releases a reservation
+// @Description Gives back the reserved quantity to the available stock
+// @Tags stock
+// @Success 200 {object} contract.Reservation
+// @Failure 400,404,409,500 {object} contract.Response{status=int,message=object}
+// @Param id path int true "reservation id"
+// @Router /reservations/{id} [delete]
+func (s *server) releaseReservation(w http.ResponseWriter, req *http.Request) {
+	s.closeReservation(w, req, s.inventory.ReleaseReservation)
+}
+
+func (s *server) closeReservation(w http.ResponseWriter, req *http.Request, closeFn func(int64) (*contract.Reservation, error)) {
+	id, ok := pathID(w, req)
+	if !ok {
+		return
+	}
+
+	rsv, err := closeFn(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			writeResponse(w, http.StatusNotFound, "reservation not found")
+		case errors.Is(err, db.ErrInvalidState):
+			writeResponse(w, http.StatusConflict, "reservation is no longer pending")
+		default:
+			logrus.Errorf("db error: %v", err)
+			writeResponse(w, http.StatusInternalServerError, "could not update reservation")
+		}
+		return
+	}
+
+	logrus.Infof("Reservation %d %s", rsv.ID, rsv.Status)
+	body, _ := json.Marshal(rsv)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+//attaches the availability to the products when the inventory is enabled
+func (s *server) withAvailability(prds ...*contract.Product) error {
+	if s.inventory == nil || len(prds) == 0 {
+		return nil
+	}
+
+	skus := make([]string, 0, len(prds))
+	for _, prd := range prds {
+		skus = append(skus, prd.SKU)
+	}
+
+	stocks, err := s.inventory.Stock(skus...)
+	if err != nil {
+		return err
+	}
+
+	bySKU := make(map[string][]contract.Stock)
+	for _, stock := range stocks {
+		bySKU[stock.SKU] = append(bySKU[stock.SKU], stock)
+	}
+
+	for _, prd := range prds {
+		prd.Availability = contract.NewAvailability(bySKU[prd.SKU])
+	}
+
+	return nil
+}
+
+//releases expired reservations periodically until the context is done
+func (s *server) sweepReservations(ctx context.Context) {
+	ticker := time.NewTicker(s.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.inventory.ExpireReservations()
+			if err != nil {
+				logrus.Errorf("could not expire reservations: %v", err)
+				continue
+			}
+
+			if n > 0 {
+				logrus.Infof("%d expired reservation(s) released", n)
+			}
+		}
+	}
+}
+
+//decodes the json body into v and validates it. Writes the error response when it fails
+func (s *server) decodeAndValidate(w http.ResponseWriter, req *http.Request, v interface{}) bool {
+	if !decodeBody(w, req, v) {
+		return false
+	}
+
+	if err := s.validator.StructCtx(req.Context(), v); err != nil {
+		errs := s.validator.TranslateCtx(req.Context(), err)
+		logrus.Printf("Validation error(s):\n%s", strings.Join(errs, " | "))
+		writeResponse(w, http.StatusBadRequest, errs)
+		return false
+	}
+
+	return true
+}
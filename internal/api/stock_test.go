@@ -0,0 +1,188 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+)
+
+func TestAdjustStock(t *testing.T) {
+	tests := map[string]struct {
+		inv            *mockInventory
+		body           []byte
+		statusExpected int
+	}{
+		"#1: invalid body":          {inv: &mockInventory{}, body: []byte(`{//"warehouse":"main"}`), statusExpected: http.StatusBadRequest},
+		"#2: missing warehouse":     {inv: &mockInventory{}, body: []byte(`{"delta":10}`), statusExpected: http.StatusBadRequest},
+		"#3: zero delta":            {inv: &mockInventory{}, body: []byte(`{"warehouse":"main","delta":0}`), statusExpected: http.StatusBadRequest},
+		"#4: below reserved":        {inv: &mockInventory{onHand: 5}, body: []byte(`{"warehouse":"main","delta":-10}`), statusExpected: http.StatusConflict},
+		"#5: internal server error": {inv: &mockInventory{throwError: true}, body: []byte(`{"warehouse":"main","delta":10}`), statusExpected: http.StatusInternalServerError},
+		"#6: valid case":            {inv: &mockInventory{onHand: 5}, body: []byte(`{"warehouse":"main","delta":-5}`), statusExpected: http.StatusOK},
+		"#7: product deleted":       {inv: &mockInventory{adjustErr: fmt.Errorf("mocked error: %w", db.ErrNotFound)}, body: []byte(`{"warehouse":"main","delta":10}`), statusExpected: http.StatusNotFound},
+	}
+
+	for desc, tc := range tests {
+		srv := NewServer("8081", &mockDB{prdCount: 1}, WithInventory(tc.inv, 0))
+		serve(t, srv)
+
+		resp, err := http.Post("http://localhost:8081/product/FAL-1000000/stock", "application/json", bytes.NewBuffer(tc.body))
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Status code got: %v\n Status code expected: %v", desc, resp.StatusCode, tc.statusExpected)
+		}
+
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	tests := map[string]struct {
+		prdCount       int
+		body           []byte
+		statusExpected int
+	}{
+		"#1: product not found":  {prdCount: 0, body: []byte(`{"warehouse":"main","quantity":1}`), statusExpected: http.StatusNotFound},
+		"#2: invalid quantity":   {prdCount: 1, body: []byte(`{"warehouse":"main","quantity":-1}`), statusExpected: http.StatusBadRequest},
+		"#3: insufficient stock": {prdCount: 1, body: []byte(`{"warehouse":"main","quantity":11}`), statusExpected: http.StatusConflict},
+		"#4: valid case":         {prdCount: 1, body: []byte(`{"warehouse":"main","quantity":10}`), statusExpected: http.StatusCreated},
+	}
+
+	for desc, tc := range tests {
+		srv := NewServer("8081", &mockDB{prdCount: tc.prdCount}, WithInventory(&mockInventory{onHand: 10}, 0))
+		serve(t, srv)
+
+		resp, err := http.Post("http://localhost:8081/product/FAL-1000000/reservations", "application/json", bytes.NewBuffer(tc.body))
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Status code got: %v\n Status code expected: %v", desc, resp.StatusCode, tc.statusExpected)
+		}
+
+		if resp.StatusCode == http.StatusCreated {
+			rsv := contract.Reservation{}
+			if err := json.NewDecoder(resp.Body).Decode(&rsv); err != nil {
+				t.Fatalf("could not decode response body: %v", err)
+			}
+
+			if rsv.ExpiresAt.IsZero() {
+				t.Errorf("%s:\n default expiration time expected", desc)
+			}
+		}
+
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}
+}
+
+func TestCloseReservation(t *testing.T) {
+	tests := map[string]struct {
+		method         string
+		path           string
+		closeErr       error
+		statusExpected int
+	}{
+		"#1: commit not found":    {method: http.MethodPost, path: "/reservations/1/commit", closeErr: db.ErrNotFound, statusExpected: http.StatusNotFound},
+		"#2: commit not pending":  {method: http.MethodPost, path: "/reservations/1/commit", closeErr: fmt.Errorf("wrapped: %w", db.ErrInvalidState), statusExpected: http.StatusConflict},
+		"#3: commit valid case":   {method: http.MethodPost, path: "/reservations/1/commit", statusExpected: http.StatusOK},
+		"#4: release db error":    {method: http.MethodDelete, path: "/reservations/1", closeErr: fmt.Errorf("mocked error"), statusExpected: http.StatusInternalServerError},
+		"#5: release valid case":  {method: http.MethodDelete, path: "/reservations/1", statusExpected: http.StatusOK},
+		"#6: invalid reservation": {method: http.MethodDelete, path: "/reservations/abc", statusExpected: http.StatusNotFound},
+	}
+
+	for desc, tc := range tests {
+		srv := NewServer("8081", &mockDB{}, WithInventory(&mockInventory{closeErr: tc.closeErr}, 0))
+		serve(t, srv)
+
+		req, _ := http.NewRequest(tc.method, "http://localhost:8081"+tc.path, nil)
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Status code got: %v\n Status code expected: %v", desc, resp.StatusCode, tc.statusExpected)
+		}
+
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}
+}
+
+func TestDeleteWithStock(t *testing.T) {
+	tests := map[string]struct {
+		inStock        bool
+		query          string
+		statusExpected int
+	}{
+		"#1: product with stock": {inStock: true, statusExpected: http.StatusConflict},
+		"#2: cascade delete":     {inStock: true, query: "?cascade=true", statusExpected: http.StatusNoContent},
+		"#3: product sold out":   {statusExpected: http.StatusNoContent},
+	}
+
+	for desc, tc := range tests {
+		srv := NewServer("8081", &mockDB{prdCount: 1, inStock: tc.inStock}, WithInventory(&mockInventory{}, 0))
+		serve(t, srv)
+
+		req, _ := http.NewRequest(http.MethodDelete, "http://localhost:8081/product/FAL-1000000"+tc.query, nil)
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Status code got: %v\n Status code expected: %v", desc, resp.StatusCode, tc.statusExpected)
+		}
+
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}
+}
+
+func TestGetWithAvailability(t *testing.T) {
+	srv := NewServer("8081", &mockDB{prdCount: 1}, WithInventory(&mockInventory{onHand: 7}, 0))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	resp, err := http.Get("http://localhost:8081/product/FAL-1000000")
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	prd := contract.Product{}
+	if err := json.Unmarshal(body, &prd); err != nil {
+		t.Fatalf("could not decode response body: %v", err)
+	}
+
+	if prd.Availability == nil || prd.Availability.Available != 7 {
+		t.Errorf("availability different than expected: %s", body)
+	}
+}
@@ -2,21 +2,82 @@ package api
 
 import (
 	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
 
 	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/sku"
 )
 
+//serve starts the server in background and waits until it accepts connections
+func serve(t *testing.T, srv Server) {
+	t.Helper()
+
+	go srv.ListenAndServe()
+
+	for i := 0; i < 100; i++ {
+		resp, err := http.Get("http://localhost:8081/health")
+		if err == nil {
+			resp.Body.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("test server did not start")
+}
+
 type mockDB struct {
 	throwError bool
 	prdCount   int
+
+	getCalls     int32
+	getManyCalls int32
+
+	//number of the next creations failing because the sku already exists
+	conflicts int32
+
+	//last product created or updated
+	saved atomic.Value
+	//last filter of the listed products
+	filter atomic.Value
+	//custom attributes of the listed products, which are of the category of the filter
+	attributes map[string]interface{}
+	//changes the mocked products when set
+	edit func(prd *contract.Product)
+	//the products have stock, so they are only deleted with cascade
+	inStock bool
 }
 
-func (mdb *mockDB) Create(prd contract.Product) error {
+//retrieves a mocked product changed by edit
+func (mdb *mockDB) product() contract.Product {
+	prd := getMockProduct()
+	if mdb.edit != nil {
+		mdb.edit(&prd)
+	}
+
+	return prd
+}
+
+func (mdb *mockDB) Create(prd contract.Product) (*contract.Product, error) {
 	if mdb.throwError {
-		return fmt.Errorf("mocked error")
+		return nil, fmt.Errorf("mocked error")
 	}
 
-	return nil
+	if atomic.AddInt32(&mdb.conflicts, -1) >= 0 {
+		return nil, fmt.Errorf("mocked error: %w", db.ErrAlreadyExists)
+	}
+
+	prd.CreatedAt, prd.UpdatedAt = mockUpdatedAt, mockUpdatedAt
+	mdb.saved.Store(prd)
+
+	return &prd, nil
 }
 
 func (mdb *mockDB) GetAll() ([]contract.Product, error) {
@@ -26,13 +87,30 @@ func (mdb *mockDB) GetAll() ([]contract.Product, error) {
 
 	prds := []contract.Product{}
 	for i := 0; i < mdb.prdCount; i++ {
-		prds = append(prds, getMockProduct())
+		prds = append(prds, mdb.product())
+	}
+
+	return prds, nil
+}
+
+func (mdb *mockDB) List(filter contract.ProductFilter) ([]contract.Product, error) {
+	mdb.filter.Store(filter)
+
+	prds, err := mdb.GetAll()
+	if err != nil || filter.UpdatedSince.After(mockUpdatedAt) {
+		return []contract.Product{}, err
+	}
+
+	for i := range prds {
+		prds[i].Category, prds[i].Attributes = filter.Category, mdb.attributes
 	}
 
 	return prds, nil
 }
 
 func (mdb *mockDB) Get(sku string) (*contract.Product, error) {
+	atomic.AddInt32(&mdb.getCalls, 1)
+
 	if mdb.throwError && mdb.prdCount == 0 {
 		return nil, fmt.Errorf("mocked error")
 	}
@@ -41,11 +119,33 @@ func (mdb *mockDB) Get(sku string) (*contract.Product, error) {
 		return nil, nil
 	}
 
-	prd := getMockProduct()
+	prd := mdb.product()
 
 	return &prd, nil
 }
 
+func (mdb *mockDB) GetMany(skus []string) ([]contract.Product, []string, error) {
+	atomic.AddInt32(&mdb.getManyCalls, 1)
+
+	if mdb.throwError {
+		return nil, nil, fmt.Errorf("mocked error")
+	}
+
+	prds := []contract.Product{}
+	missing := []string{}
+	for i, sku := range skus {
+		if i < mdb.prdCount {
+			prd := mdb.product()
+			prd.SKU = sku
+			prds = append(prds, prd)
+		} else {
+			missing = append(missing, sku)
+		}
+	}
+
+	return prds, missing, nil
+}
+
 func (mdb *mockDB) Update(prd contract.Product) error {
 	if mdb.throwError {
 		return fmt.Errorf("mocked error")
@@ -54,16 +154,50 @@ func (mdb *mockDB) Update(prd contract.Product) error {
 	return nil
 }
 
-func (mdb *mockDB) Delete(sku string) error {
+func (mdb *mockDB) UpdateFunc(sku string, fn func(prd *contract.Product) error) (*contract.Product, error) {
+	if mdb.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	if mdb.prdCount == 0 {
+		return nil, fmt.Errorf("mocked error: %w", db.ErrNotFound)
+	}
+
+	prd := mdb.product()
+	if err := fn(&prd); err != nil {
+		return nil, fmt.Errorf("mocked error: %w", err)
+	}
+
+	mdb.saved.Store(prd)
+
+	return &prd, nil
+}
+
+func (mdb *mockDB) Delete(sku string, cascade bool) error {
 	if mdb.throwError {
 		return fmt.Errorf("mocked error")
 	}
 
+	if mdb.inStock && !cascade {
+		return fmt.Errorf("mocked error: %w", db.ErrInStock)
+	}
+
 	return nil
 }
 
+func (mdb *mockDB) LastModified() (time.Time, error) {
+	if mdb.throwError {
+		return time.Time{}, fmt.Errorf("mocked error")
+	}
+
+	return mockUpdatedAt, nil
+}
+
 func (mdb *mockDB) Close() {}
 
+//mockUpdatedAt last modification time of the mocked products
+var mockUpdatedAt = time.Date(2022, time.January, 10, 12, 30, 0, 0, time.UTC)
+
 func getMockProduct() contract.Product {
 	return contract.Product{
 		SKU:      "FAL-1000000",
@@ -76,5 +210,613 @@ func getMockProduct() contract.Product {
 			"http://bbbb",
 			"http://cccc",
 		},
+		Translations: map[string]contract.ProductTranslation{
+			"es": {Name: "nombre", Description: "descripción"},
+			"pt": {Name: "nome"},
+		},
+		Status:    contract.StatusActive,
+		UpdatedAt: mockUpdatedAt,
+	}
+}
+
+type mockInventory struct {
+	throwError bool
+	onHand     int
+	adjustErr  error
+	closeErr   error
+
+	stockCalls int32
+}
+
+func (mi *mockInventory) Stock(skus ...string) ([]contract.Stock, error) {
+	atomic.AddInt32(&mi.stockCalls, 1)
+
+	if mi.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	stocks := []contract.Stock{}
+	if mi.onHand > 0 {
+		for _, sku := range skus {
+			stocks = append(stocks, contract.Stock{SKU: sku, Warehouse: "main", OnHand: mi.onHand, Available: mi.onHand})
+		}
+	}
+
+	return stocks, nil
+}
+
+func (mi *mockInventory) AdjustStock(sku string, adj contract.StockAdjustment) (*contract.Stock, error) {
+	if mi.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	if mi.adjustErr != nil {
+		return nil, mi.adjustErr
+	}
+
+	if mi.onHand+adj.Delta < 0 {
+		return nil, fmt.Errorf("mocked error: %w", db.ErrInsufficientStock)
 	}
+
+	return &contract.Stock{SKU: sku, Warehouse: adj.Warehouse, OnHand: mi.onHand + adj.Delta, Available: mi.onHand + adj.Delta}, nil
+}
+
+func (mi *mockInventory) Reserve(sku string, req contract.ReservationRequest) (*contract.Reservation, error) {
+	if mi.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	if req.Quantity > mi.onHand {
+		return nil, fmt.Errorf("mocked error: %w", db.ErrInsufficientStock)
+	}
+
+	return &contract.Reservation{
+		ID:        1,
+		SKU:       sku,
+		Warehouse: req.Warehouse,
+		Quantity:  req.Quantity,
+		Status:    contract.ReservationPending,
+		ExpiresAt: time.Now().Add(time.Duration(req.TTLSeconds) * time.Second),
+	}, nil
+}
+
+func (mi *mockInventory) CommitReservation(id int64) (*contract.Reservation, error) {
+	if mi.closeErr != nil {
+		return nil, mi.closeErr
+	}
+
+	return &contract.Reservation{ID: id, Status: contract.ReservationCommitted}, nil
+}
+
+func (mi *mockInventory) ReleaseReservation(id int64) (*contract.Reservation, error) {
+	if mi.closeErr != nil {
+		return nil, mi.closeErr
+	}
+
+	return &contract.Reservation{ID: id, Status: contract.ReservationReleased}, nil
+}
+
+func (mi *mockInventory) ExpireReservations() (int64, error) {
+	return 0, nil
+}
+
+type mockSKUs struct {
+	//last value allocated
+	last uint64
+	//values left in the range
+	left int
+}
+
+func (ms *mockSKUs) NextSKUs(rule *sku.Rule, count int) ([]string, error) {
+	if count > ms.left {
+		return nil, fmt.Errorf("mocked error: %w", db.ErrSKURangeExhausted)
+	}
+
+	skus := make([]string, count)
+	for i := range skus {
+		ms.last++
+		skus[i] = rule.Format(ms.last)
+	}
+	ms.left -= count
+
+	return skus, nil
+}
+
+type mockIdempotency struct {
+	mu    sync.Mutex
+	resps map[string]contract.IdempotentResponse
+}
+
+func (mi *mockIdempotency) ClaimIdempotencyKey(key, fingerprint string, ttl time.Duration) (*contract.IdempotentResponse, error) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	if resp, ok := mi.resps[key]; ok {
+		return &resp, nil
+	}
+
+	mi.resps[key] = contract.IdempotentResponse{Key: key, Fingerprint: fingerprint, ExpiresAt: time.Now().Add(ttl)}
+
+	return nil, nil
+}
+
+func (mi *mockIdempotency) StoreIdempotentResponse(resp contract.IdempotentResponse) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	stored := mi.resps[resp.Key]
+	stored.Status, stored.Header, stored.Body = resp.Status, resp.Header, resp.Body
+	mi.resps[resp.Key] = stored
+
+	return nil
+}
+
+func (mi *mockIdempotency) ReleaseIdempotencyKey(key string) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	delete(mi.resps, key)
+
+	return nil
+}
+
+func (mi *mockIdempotency) DeleteExpiredIdempotencyKeys() (int64, error) {
+	return 0, nil
+}
+
+type mockImages struct {
+	mu     sync.Mutex
+	nextID int64
+	images map[int64]contract.ProductImage
+}
+
+func (mi *mockImages) CreateImage(img contract.ProductImage) (*contract.ProductImage, error) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	if mi.images == nil {
+		mi.images = make(map[int64]contract.ProductImage)
+	}
+
+	mi.nextID++
+	img.ID, img.CreatedAt = mi.nextID, mockUpdatedAt
+	mi.images[img.ID] = img
+
+	return &img, nil
+}
+
+func (mi *mockImages) Images(sku string) ([]contract.ProductImage, error) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	imgs := make([]contract.ProductImage, 0)
+	for id := int64(1); id <= mi.nextID; id++ {
+		if img, ok := mi.images[id]; ok && img.SKU == sku {
+			imgs = append(imgs, img)
+		}
+	}
+
+	return imgs, nil
+}
+
+func (mi *mockImages) Image(id int64) (*contract.ProductImage, error) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	img, ok := mi.images[id]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+
+	return &img, nil
+}
+
+func (mi *mockImages) DeleteImage(id int64) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	if _, ok := mi.images[id]; !ok {
+		return db.ErrNotFound
+	}
+	delete(mi.images, id)
+
+	return nil
+}
+
+func (mi *mockImages) OrphanImages(limit int) ([]contract.ProductImage, error) {
+	return []contract.ProductImage{}, nil
+}
+
+type mockImageChecks struct {
+	db.ImageChecks
+	throwError bool
+}
+
+func (mc *mockImageChecks) ImageReport() (*contract.ImageReport, error) {
+	if mc.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	return &contract.ImageReport{
+		CheckedURLs: 3,
+		BrokenURLs:  1,
+		Products: []contract.ProductBrokenImages{
+			{SKU: "FAL-1000000", Images: []contract.ImageCheck{{URL: "http://bbbb", Status: http.StatusNotFound, Failures: 2, CheckedAt: mockUpdatedAt}}},
+		},
+	}, nil
+}
+
+type mockCategories struct {
+	throwError bool
+
+	mu      sync.Mutex
+	schemas map[string]contract.CategorySchema
+	//categories with products having attributes
+	inUse map[string]bool
+}
+
+func (mc *mockCategories) CategorySchema(category string) (*contract.CategorySchema, error) {
+	if mc.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	schema, ok := mc.schemas[category]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+
+	return &schema, nil
+}
+
+func (mc *mockCategories) CategorySchemas() ([]contract.CategorySchema, error) {
+	if mc.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	schemas := []contract.CategorySchema{}
+	for _, schema := range mc.schemas {
+		schemas = append(schemas, schema)
+	}
+
+	return schemas, nil
+}
+
+func (mc *mockCategories) PutCategorySchema(schema contract.CategorySchema) (*contract.CategorySchema, bool, error) {
+	if mc.throwError {
+		return nil, false, fmt.Errorf("mocked error")
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.schemas == nil {
+		mc.schemas = make(map[string]contract.CategorySchema)
+	}
+
+	_, exists := mc.schemas[schema.Category]
+	schema.UpdatedAt = mockUpdatedAt
+	mc.schemas[schema.Category] = schema
+
+	return &schema, !exists, nil
+}
+
+func (mc *mockCategories) DeleteCategorySchema(category string) error {
+	if mc.throwError {
+		return fmt.Errorf("mocked error")
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if _, ok := mc.schemas[category]; !ok {
+		return fmt.Errorf("mocked error: %w", db.ErrNotFound)
+	}
+
+	if mc.inUse[category] {
+		return fmt.Errorf("mocked error: %w", db.ErrInUse)
+	}
+	delete(mc.schemas, category)
+
+	return nil
+}
+
+//mockShoes schema of the mocked shoes category
+func mockShoes() contract.CategorySchema {
+	min, max := 20.0, 50.0
+
+	return contract.CategorySchema{
+		Category: "shoes",
+		Attributes: []contract.AttributeDefinition{
+			{Key: "color", Type: contract.AttributeString, Values: []string{"black", "white"}},
+			{Key: "size", Type: contract.AttributeInteger, Required: true, Min: &min, Max: &max},
+			{Key: "waterproof", Type: contract.AttributeBoolean},
+			{Key: "weight", Type: contract.AttributeNumber, Unit: "kg"},
+		},
+	}
+}
+
+//mockRevisions revisions of the mocked product, oldest first
+type mockRevisions struct {
+	throwError bool
+	revs       []contract.ProductRevision
+}
+
+func (mr *mockRevisions) Revisions(sku string) ([]contract.ProductRevision, error) {
+	if mr.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	revs := make([]contract.ProductRevision, 0, len(mr.revs))
+	for i := len(mr.revs) - 1; i >= 0; i-- {
+		if mr.revs[i].SKU == sku {
+			revs = append(revs, mr.revs[i])
+		}
+	}
+
+	if len(revs) == 0 {
+		return nil, db.ErrNotFound
+	}
+
+	return revs, nil
+}
+
+func (mr *mockRevisions) Revision(sku string, revision int) (*contract.ProductRevision, error) {
+	if mr.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	for _, rev := range mr.revs {
+		if rev.SKU == sku && rev.Revision == revision {
+			return &rev, nil
+		}
+	}
+
+	return nil, db.ErrNotFound
+}
+
+func (mr *mockRevisions) RevisionAsOf(sku string, at time.Time) (*contract.ProductRevision, error) {
+	if mr.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	var found *contract.ProductRevision
+	for i, rev := range mr.revs {
+		if rev.SKU == sku && !rev.CreatedAt.After(at) {
+			found = &mr.revs[i]
+		}
+	}
+
+	if found == nil || found.Deleted {
+		return nil, db.ErrNotFound
+	}
+
+	return found, nil
+}
+
+//mockHistory revisions of the mocked product: created as a draft on 2021-01-01, activated on 2021-02-01 and
+//renamed on 2021-03-01
+func mockHistory() []contract.ProductRevision {
+	var (
+		first  = getMockProduct()
+		second = getMockProduct()
+		third  = getMockProduct()
+	)
+
+	first.Status, first.Name, first.Revision = contract.StatusDraft, "draft name", 1
+	second.Name, second.Revision = "draft name", 2
+	third.Name, third.Revision, third.Attributes = "new name", 3, map[string]interface{}{"color": "black"}
+
+	return []contract.ProductRevision{
+		{SKU: first.SKU, Revision: 1, Product: first, CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), CreatedBy: "author"},
+		{SKU: second.SKU, Revision: 2, Product: second, CreatedAt: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC), CreatedBy: "reviewer"},
+		{SKU: third.SKU, Revision: 3, Product: third, CreatedAt: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), CreatedBy: "editor"},
+	}
+}
+
+//mockBrands brand registry kept in memory
+type mockBrands struct {
+	throwError bool
+
+	mu     sync.Mutex
+	nextID int64
+	brands map[int64]contract.Brand
+	//brands referenced by products
+	inUse map[int64]bool
+	//last filter of the facets
+	filter contract.ProductFilter
+}
+
+func (mb *mockBrands) Brands() ([]contract.Brand, error) {
+	if mb.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	brands := make([]contract.Brand, 0, len(mb.brands))
+	for id := int64(1); id <= mb.nextID; id++ {
+		if brand, ok := mb.brands[id]; ok {
+			brands = append(brands, brand)
+		}
+	}
+
+	return brands, nil
+}
+
+func (mb *mockBrands) Brand(id int64) (*contract.Brand, error) {
+	if mb.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	brand, ok := mb.brands[id]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+
+	return &brand, nil
+}
+
+func (mb *mockBrands) CreateBrand(brand contract.Brand) (*contract.Brand, error) {
+	if mb.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if mb.taken(brand) {
+		return nil, fmt.Errorf("mocked error: %w", db.ErrAlreadyExists)
+	}
+
+	if mb.brands == nil {
+		mb.brands = make(map[int64]contract.Brand)
+	}
+
+	mb.nextID++
+	brand.ID = mb.nextID
+	if brand.Slug == "" {
+		brand.Slug = strings.ToLower(brand.Name)
+	}
+	mb.brands[brand.ID] = brand
+
+	return &brand, nil
+}
+
+func (mb *mockBrands) UpdateBrand(brand contract.Brand) (*contract.Brand, error) {
+	if mb.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	stored, ok := mb.brands[brand.ID]
+	if !ok {
+		return nil, fmt.Errorf("mocked error: %w", db.ErrNotFound)
+	}
+
+	if mb.taken(brand) {
+		return nil, fmt.Errorf("mocked error: %w", db.ErrAlreadyExists)
+	}
+
+	if brand.Slug == "" {
+		brand.Slug = stored.Slug
+	}
+	mb.brands[brand.ID] = brand
+
+	return &brand, nil
+}
+
+func (mb *mockBrands) DeleteBrand(id int64) error {
+	if mb.throwError {
+		return fmt.Errorf("mocked error")
+	}
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if _, ok := mb.brands[id]; !ok {
+		return fmt.Errorf("mocked error: %w", db.ErrNotFound)
+	}
+
+	if mb.inUse[id] {
+		return fmt.Errorf("mocked error: %w", db.ErrInUse)
+	}
+	delete(mb.brands, id)
+
+	return nil
+}
+
+func (mb *mockBrands) BrandFacets(filter contract.ProductFilter) ([]contract.BrandFacet, error) {
+	if mb.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	mb.filter = filter
+
+	facets := make([]contract.BrandFacet, 0)
+	for id := range mb.inUse {
+		if brand, ok := mb.brands[id]; ok {
+			facets = append(facets, contract.BrandFacet{ID: id, Name: brand.Name, Slug: brand.Slug, Products: 1})
+		}
+	}
+
+	return facets, nil
+}
+
+//reports if the name or an alias of the brand is the name or an alias of another brand, ignoring the case
+func (mb *mockBrands) taken(brand contract.Brand) bool {
+	for id, other := range mb.brands {
+		if id == brand.ID {
+			continue
+		}
+
+		for _, name := range append([]string{brand.Name}, brand.Aliases...) {
+			for _, otherName := range append([]string{other.Name}, other.Aliases...) {
+				if strings.EqualFold(name, otherName) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+//in memory event log. The tests choose the ids of the events they append
+type mockEventLog struct {
+	mu     sync.Mutex
+	events []contract.ProductEvent
+}
+
+func (ml *mockEventLog) append(events ...contract.ProductEvent) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	ml.events = append(ml.events, events...)
+	sort.Slice(ml.events, func(i, j int) bool { return ml.events[i].ID < ml.events[j].ID })
+}
+
+func (ml *mockEventLog) EventsAfter(id int64, limit int) ([]contract.ProductEvent, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	events := make([]contract.ProductEvent, 0)
+	for _, event := range ml.events {
+		if event.ID > id && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func (ml *mockEventLog) LastEventID() (int64, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	if len(ml.events) == 0 {
+		return 0, nil
+	}
+
+	return ml.events[len(ml.events)-1].ID, nil
+}
+
+//event of an active product with the given id
+func productEvent(id int64, eventType string) contract.ProductEvent {
+	prd := getMockProduct()
+	return contract.ProductEvent{ID: id, Type: eventType, SKU: prd.SKU, Payload: &prd}
 }
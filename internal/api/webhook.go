@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// createSubscription godoc
+// @Summary Subscribes a webhook to product changes
+// @Description Registers an endpoint notified of the product events matching the filters. Deliveries are signed with the secret
+// @Tags webhook
+// @Accept json
+// @Success 201 {object} contract.WebhookSubscription
+// @Failure 400,500 {object} contract.Response{status=int,message=object}
+// @Param subscription body contract.WebhookSubscription true "subscription"
+// @Router /webhooks [post]
+func (s *server) createSubscription(w http.ResponseWriter, req *http.Request) {
+	sub := contract.WebhookSubscription{}
+	if !s.decodeAndValidate(w, req, &sub) {
+		return
+	}
+
+	created, err := s.webhooks.CreateSubscription(sub)
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not create subscription")
+		return
+	}
+
+	logrus.Infof("Webhook subscription %d created", created.ID)
+	body, _ := json.Marshal(redactSecret(*created))
+	writeJSONResponse(w, http.StatusCreated, body)
+}
+
+// getSubscriptions godoc
+// @Summary Retrieves the webhook subscriptions
+// @Description Retrieves the webhook subscriptions
+// @Tags webhook
+// @Success 200 {array} contract.WebhookSubscription
+// @Failure 500 {object} contract.Response{status=int,message=object}
+// @Router /webhooks [get]
+func (s *server) getSubscriptions(w http.ResponseWriter, _ *http.Request) {
+	subs, err := s.webhooks.Subscriptions()
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not get subscriptions")
+		return
+	}
+
+	for i := range subs {
+		subs[i] = redactSecret(subs[i])
+	}
+
+	body, _ := json.Marshal(subs)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// getSubscription godoc
+// @Summary Get a webhook subscription
+// @Description Get a webhook subscription by its id
+// @Tags webhook
+// @Success 200 {object} contract.WebhookSubscription
+// @Failure 400,404,500 {object} contract.Response{status=int,message=object}
+// @Param id path int true "subscription id"
+// @Router /webhooks/{id} [get]
+func (s *server) getSubscription(w http.ResponseWriter, req *http.Request) {
+	id, ok := pathID(w, req)
+	if !ok {
+		return
+	}
+
+	sub, err := s.webhooks.Subscription(id)
+	if err != nil {
+		writeWebhookError(w, err, "could not get subscription")
+		return
+	}
+
+	body, _ := json.Marshal(redactSecret(*sub))
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// deleteSubscription godoc
+// @Summary Deletes a webhook subscription
+// @Description Deletes a webhook subscription and its deliveries
+// @Tags webhook
+// @Success 200 {object} contract.Response{status=int,message=object}
+// @Failure 400,404,500 {object} contract.Response{status=int,message=object}
+// @Param id path int true "subscription id"
+// @Router /webhooks/{id} [delete]
+func (s *server) deleteSubscription(w http.ResponseWriter, req *http.Request) {
+	id, ok := pathID(w, req)
+	if !ok {
+		return
+	}
+
+	if err := s.webhooks.DeleteSubscription(id); err != nil {
+		writeWebhookError(w, err, "could not delete subscription")
+		return
+	}
+
+	writeResponse(w, http.StatusOK, "subscription successfully deleted")
+}
+
+// getDeliveries godoc
+// @Summary Retrieves webhook deliveries
+// @Description Retrieves the deliveries of every subscription. Use status=dead to get the dead letter list
+// @Tags webhook
+// @Success 200 {array} contract.WebhookDelivery
+// @Failure 400,500 {object} contract.Response{status=int,message=object}
+// @Param status query string false "delivery status" Enums(pending, delivered, dead)
+// @Param subscription query int false "subscription id"
+// @Router /webhooks/deliveries [get]
+func (s *server) getDeliveries(w http.ResponseWriter, req *http.Request) {
+	status := req.URL.Query().Get("status")
+	switch status {
+	case "", contract.DeliveryPending, contract.DeliveryDelivered, contract.DeliveryDead:
+	default:
+		writeResponse(w, http.StatusBadRequest, "invalid delivery status")
+		return
+	}
+
+	var subID int64
+	if v := req.URL.Query().Get("subscription"); v != "" {
+		var err error
+		if subID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid subscription id")
+			return
+		}
+	}
+
+	deliveries, err := s.webhooks.Deliveries(subID, status)
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, "could not get deliveries")
+		return
+	}
+
+	body, _ := json.Marshal(deliveries)
+	writeJSONResponse(w, http.StatusOK, body)
+}
+
+// replayDelivery godoc
+// @Summary Replays a dead webhook delivery
+// @Description Schedules a delivery from the dead letter list to be sent again
+// @Tags webhook
+// @Success 202 {object} contract.Response{status=int,message=object}
+// @Failure 400,404,409,500 {object} contract.Response{status=int,message=object}
+// @Param id path int true "delivery id"
+// @Router /webhooks/deliveries/{id}/replay [post]
+func (s *server) replayDelivery(w http.ResponseWriter, req *http.Request) {
+	id, ok := pathID(w, req)
+	if !ok {
+		return
+	}
+
+	if err := s.webhooks.ReplayDelivery(id); err != nil {
+		writeWebhookError(w, err, "could not replay delivery")
+		return
+	}
+
+	writeResponse(w, http.StatusAccepted, "delivery scheduled")
+}
+
+// replaySubscription godoc
+// @Summary Replays the dead deliveries of a subscription
+// @Description Schedules every delivery of the subscription in the dead letter list to be sent again
+// @Tags webhook
+// @Success 202 {object} contract.Response{status=int,message=object}
+// @Failure 400,404,500 {object} contract.Response{status=int,message=object}
+// @Param id path int true "subscription id"
+// @Router /webhooks/{id}/replay [post]
+func (s *server) replaySubscription(w http.ResponseWriter, req *http.Request) {
+	id, ok := pathID(w, req)
+	if !ok {
+		return
+	}
+
+	if _, err := s.webhooks.Subscription(id); err != nil {
+		writeWebhookError(w, err, "could not replay deliveries")
+		return
+	}
+
+	n, err := s.webhooks.ReplayDeadDeliveries(id)
+	if err != nil {
+		writeWebhookError(w, err, "could not replay deliveries")
+		return
+	}
+
+	writeResponse(w, http.StatusAccepted, strconv.FormatInt(n, 10)+" deliveries scheduled")
+}
+
+//secrets are write-only, they are never part of the responses
+func redactSecret(sub contract.WebhookSubscription) contract.WebhookSubscription {
+	sub.Secret = ""
+	return sub
+}
+
+func writeWebhookError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		writeResponse(w, http.StatusNotFound, "not found")
+	case errors.Is(err, db.ErrInvalidState):
+		writeResponse(w, http.StatusConflict, "delivery is not in the dead letter list")
+	default:
+		logrus.Errorf("db error: %v", err)
+		writeResponse(w, http.StatusInternalServerError, msg)
+	}
+}
+
+//parses the numeric id path variable. Writes a bad request response when it fails
+func pathID(w http.ResponseWriter, req *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(req)["id"], 10, 64)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, "invalid id")
+		return 0, false
+	}
+
+	return id, true
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+)
+
+type mockWebhooks struct {
+	throwError bool
+	subs       map[int64]contract.WebhookSubscription
+	dead       map[int64]bool
+}
+
+func (m *mockWebhooks) CreateSubscription(sub contract.WebhookSubscription) (*contract.WebhookSubscription, error) {
+	if m.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	sub.ID = 1
+	return &sub, nil
+}
+
+func (m *mockWebhooks) Subscriptions() ([]contract.WebhookSubscription, error) {
+	subs := []contract.WebhookSubscription{}
+	for _, sub := range m.subs {
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
+
+func (m *mockWebhooks) Subscription(id int64) (*contract.WebhookSubscription, error) {
+	sub, ok := m.subs[id]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+
+	return &sub, nil
+}
+
+func (m *mockWebhooks) DeleteSubscription(id int64) error {
+	_, err := m.Subscription(id)
+	return err
+}
+
+func (m *mockWebhooks) EnqueueDeliveries(contract.ProductEvent, []int64) error {
+	return nil
+}
+
+func (m *mockWebhooks) DueDeliveries(int) ([]contract.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (m *mockWebhooks) MarkDeliverySucceeded(int64) error {
+	return nil
+}
+
+func (m *mockWebhooks) MarkDeliveryFailed(int64, string, *time.Time) error {
+	return nil
+}
+
+func (m *mockWebhooks) Deliveries(int64, string) ([]contract.WebhookDelivery, error) {
+	return []contract.WebhookDelivery{}, nil
+}
+
+func (m *mockWebhooks) ReplayDelivery(id int64) error {
+	dead, ok := m.dead[id]
+	if !ok {
+		return db.ErrNotFound
+	}
+
+	if !dead {
+		return db.ErrInvalidState
+	}
+
+	return nil
+}
+
+func (m *mockWebhooks) ReplayDeadDeliveries(int64) (int64, error) {
+	return 1, nil
+}
+
+func TestCreateSubscription(t *testing.T) {
+	store := &mockWebhooks{}
+	srv := NewServer("8081", &mockDB{}, WithWebhooks(store))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	tests := map[string]struct {
+		body           string
+		throwError     bool
+		statusExpected int
+	}{
+		"#1: invalid url":        {body: `{"url":"not-a-url","secret":"0123456789abcdef"}`, statusExpected: http.StatusBadRequest},
+		"#2: short secret":       {body: `{"url":"http://partner/hook","secret":"short"}`, statusExpected: http.StatusBadRequest},
+		"#3: invalid event type": {body: `{"url":"http://partner/hook","secret":"0123456789abcdef","eventTypes":["product.sold"]}`, statusExpected: http.StatusBadRequest},
+		"#4: database error":     {body: `{"url":"http://partner/hook","secret":"0123456789abcdef"}`, throwError: true, statusExpected: http.StatusInternalServerError},
+		"#5: valid case":         {body: `{"url":"http://partner/hook","secret":"0123456789abcdef","brands":["nike"]}`, statusExpected: http.StatusCreated},
+		"#6: not an http url":    {body: `{"url":"ftp://partner/hook","secret":"0123456789abcdef"}`, statusExpected: http.StatusBadRequest},
+		"#7: url without host":   {body: `{"url":"http:///hook","secret":"0123456789abcdef"}`, statusExpected: http.StatusBadRequest},
+	}
+
+	for desc, tc := range tests {
+		store.throwError = tc.throwError
+
+		resp, err := http.Post("http://localhost:8081/webhooks", "application/json", bytes.NewBufferString(tc.body))
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Status code got: %v\n Status code expected: %v", desc, resp.StatusCode, tc.statusExpected)
+		}
+
+		if resp.StatusCode == http.StatusCreated {
+			sub := contract.WebhookSubscription{}
+			json.NewDecoder(resp.Body).Decode(&sub)
+
+			if sub.Secret != "" {
+				t.Errorf("%s:\n secret must not be part of the response", desc)
+			}
+		}
+	}
+}
+
+func TestReplayDelivery(t *testing.T) {
+	store := &mockWebhooks{dead: map[int64]bool{1: true, 2: false}, subs: map[int64]contract.WebhookSubscription{1: {ID: 1}}}
+	srv := NewServer("8081", &mockDB{}, WithWebhooks(store))
+	serve(t, srv)
+
+	defer func(srv Server) {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			t.Fatalf("could not shutdown the test server")
+		}
+	}(srv)
+
+	tests := map[string]struct {
+		path           string
+		statusExpected int
+	}{
+		"#1: dead delivery":        {path: "/webhooks/deliveries/1/replay", statusExpected: http.StatusAccepted},
+		"#2: delivered delivery":   {path: "/webhooks/deliveries/2/replay", statusExpected: http.StatusConflict},
+		"#3: unknown delivery":     {path: "/webhooks/deliveries/3/replay", statusExpected: http.StatusNotFound},
+		"#4: subscription replay":  {path: "/webhooks/1/replay", statusExpected: http.StatusAccepted},
+		"#5: unknown subscription": {path: "/webhooks/2/replay", statusExpected: http.StatusNotFound},
+	}
+
+	for desc, tc := range tests {
+		resp, err := http.Post("http://localhost:8081"+tc.path, "application/json", nil)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		if resp.StatusCode != tc.statusExpected {
+			t.Errorf("%s:\n Status code got: %v\n Status code expected: %v", desc, resp.StatusCode, tc.statusExpected)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+//Package auth authenticates the users of the API with bearer tokens
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//Tokens identifies the users by their bearer tokens. Only the SHA-256 digests of the tokens are kept
+type Tokens struct {
+	users map[string]string
+}
+
+//New creates the tokens from the hexadecimal SHA-256 digests of the tokens of each user
+func New(digests map[string][]string) (*Tokens, error) {
+	t := &Tokens{users: make(map[string]string)}
+
+	for user, userDigests := range digests {
+		if strings.TrimSpace(user) != user || user == "" {
+			return nil, fmt.Errorf("invalid user %q", user)
+		}
+
+		for _, digest := range userDigests {
+			digest = strings.ToLower(digest)
+			if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("the token of %s is not a SHA-256 digest", user)
+			}
+
+			if other, ok := t.users[digest]; ok {
+				return nil, fmt.Errorf("the token of %s is the token of %s too", user, other)
+			}
+			t.users[digest] = user
+		}
+	}
+
+	return t, nil
+}
+
+//Load reads the JSON file mapping each user to the digests of their tokens
+func Load(path string) (*Tokens, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the user tokens: %v", err)
+	}
+
+	digests := make(map[string][]string)
+	if err := json.Unmarshal(data, &digests); err != nil {
+		return nil, fmt.Errorf("could not decode the user tokens: %v", err)
+	}
+
+	return New(digests)
+}
+
+//User retrieves the user of the token. Returns false when the token is unknown
+func (t *Tokens) User(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+
+	user, ok := t.users[Digest(token)]
+	return user, ok
+}
+
+//Digest retrieves the hexadecimal SHA-256 digest of the token, as it is written in the tokens file
+func Digest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+//Bearer retrieves the token of an Authorization header value with the Bearer scheme
+func Bearer(authorization string) (string, bool) {
+	parts := strings.SplitN(strings.TrimSpace(authorization), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return "", false
+	}
+
+	token := strings.TrimSpace(parts[1])
+	return token, token != ""
+}
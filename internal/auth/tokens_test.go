@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := map[string]struct {
+		digests       map[string][]string
+		errorExpected bool
+	}{
+		"#1: valid tokens":     {digests: map[string][]string{"alice": {Digest("a1"), Digest("a2")}, "bob": {Digest("b1")}}},
+		"#2: not a digest":     {digests: map[string][]string{"alice": {"a1"}}, errorExpected: true},
+		"#3: shared token":     {digests: map[string][]string{"alice": {Digest("a1")}, "bob": {Digest("a1")}}, errorExpected: true},
+		"#4: empty user":       {digests: map[string][]string{"": {Digest("a1")}}, errorExpected: true},
+		"#5: user with spaces": {digests: map[string][]string{" alice": {Digest("a1")}}, errorExpected: true},
+	}
+
+	for desc, tc := range tests {
+		if _, err := New(tc.digests); (err != nil) != tc.errorExpected {
+			t.Errorf("%s:\n error expected: %v\n error got: %v", desc, tc.errorExpected, err)
+		}
+	}
+}
+
+func TestUser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	if err := os.WriteFile(path, []byte(`{"alice":["`+Digest("secret")+`"]}`), 0600); err != nil {
+		t.Fatalf("could not write the tokens: %v", err)
+	}
+
+	tokens, err := Load(path)
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	if user, ok := tokens.User("secret"); !ok || user != "alice" {
+		t.Errorf("#1: alice expected. Got: %s %v", user, ok)
+	}
+
+	if _, ok := tokens.User("other"); ok {
+		t.Errorf("#2: unknown token must not identify a user")
+	}
+
+	if _, ok := tokens.User(""); ok {
+		t.Errorf("#3: empty token must not identify a user")
+	}
+}
+
+func TestBearer(t *testing.T) {
+	tests := map[string]struct {
+		header   string
+		token    string
+		expected bool
+	}{
+		"#1: bearer token":     {header: "Bearer secret", token: "secret", expected: true},
+		"#2: lowercase scheme": {header: "bearer secret", token: "secret", expected: true},
+		"#3: basic scheme":     {header: "Basic c2VjcmV0"},
+		"#4: missing token":    {header: "Bearer "},
+		"#5: missing scheme":   {header: "secret"},
+	}
+
+	for desc, tc := range tests {
+		token, ok := Bearer(tc.header)
+		if ok != tc.expected || token != tc.token {
+			t.Errorf("%s:\n got: %q %v\n expected: %q %v", desc, token, ok, tc.token, tc.expected)
+		}
+	}
+}
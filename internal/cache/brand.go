@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+)
+
+//Brands decorates a brand registry invalidating the cached products when a brand is updated, since its products
+//are renamed along with it
+type Brands struct {
+	registry db.Brands
+	cache    *Database
+}
+
+//WrapBrands wraps the brand registry storing the brands of the cached products
+func (c *Database) WrapBrands(brands db.Brands) *Brands {
+	return &Brands{registry: brands, cache: c}
+}
+
+//Brands retrieves every brand
+func (b *Brands) Brands() ([]contract.Brand, error) {
+	return b.registry.Brands()
+}
+
+//Brand retrieves a brand by its id
+func (b *Brands) Brand(id int64) (*contract.Brand, error) {
+	return b.registry.Brand(id)
+}
+
+//CreateBrand creates a brand, which has no products yet
+func (b *Brands) CreateBrand(brand contract.Brand) (*contract.Brand, error) {
+	return b.registry.CreateBrand(brand)
+}
+
+//UpdateBrand updates the brand and invalidates every cached product, since the renamed products are not known
+func (b *Brands) UpdateBrand(brand contract.Brand) (*contract.Brand, error) {
+	defer b.cache.purge()
+	return b.registry.UpdateBrand(brand)
+}
+
+//DeleteBrand deletes a brand, which has no products
+func (b *Brands) DeleteBrand(id int64) error {
+	return b.registry.DeleteBrand(id)
+}
+
+//BrandFacets counts the products of each brand
+func (b *Brands) BrandFacets(filter contract.ProductFilter) ([]contract.BrandFacet, error) {
+	return b.registry.BrandFacets(filter)
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+)
+
+//mockBrands renames the products of the mocked database like the brand registry
+type mockBrands struct {
+	mdb *mockDB
+}
+
+func (mb *mockBrands) Brands() ([]contract.Brand, error)                     { return nil, nil }
+func (mb *mockBrands) Brand(id int64) (*contract.Brand, error)               { return nil, db.ErrNotFound }
+func (mb *mockBrands) CreateBrand(b contract.Brand) (*contract.Brand, error) { return &b, nil }
+func (mb *mockBrands) DeleteBrand(id int64) error                            { return nil }
+func (mb *mockBrands) BrandFacets(contract.ProductFilter) ([]contract.BrandFacet, error) {
+	return nil, nil
+}
+
+func (mb *mockBrands) UpdateBrand(brand contract.Brand) (*contract.Brand, error) {
+	mb.mdb.mu.Lock()
+	defer mb.mdb.mu.Unlock()
+
+	for sku, prd := range mb.mdb.prds {
+		prd.Brand = brand.Name
+		mb.mdb.prds[sku] = prd
+	}
+
+	return &brand, nil
+}
+
+func TestBrandInvalidation(t *testing.T) {
+	mdb := newMockDB("FAL-1000000", "FAL-1000001")
+	c := New(mdb, 10, time.Minute, time.Minute)
+	brands := c.WrapBrands(&mockBrands{mdb: mdb})
+
+	c.Get("FAL-1000000")
+	c.Get("FAL-1000001")
+
+	if _, err := brands.UpdateBrand(contract.Brand{ID: 1, Name: "renamed"}); err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	for _, sku := range []string{"FAL-1000000", "FAL-1000001"} {
+		if prd, _ := c.Get(sku); prd == nil || prd.Brand != "renamed" {
+			t.Errorf("renamed product %s got: %+v", sku, prd)
+		}
+	}
+
+	if stats := c.Stats(); stats.Misses != 4 {
+		t.Errorf("the renamed products must be looked up again. Misses got: %d", stats.Misses)
+	}
+}
@@ -0,0 +1,237 @@
+//Package cache provides a read-through cache of products in front of any database
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"golang.org/x/sync/singleflight"
+)
+
+//Stats counters of the cache since it was created
+type Stats struct {
+	//Hits lookups served by the cache, including the NegativeHits of missing products
+	Hits         uint64 `json:"hits"`
+	NegativeHits uint64 `json:"negativeHits"`
+	//Misses lookups sent to the database, including the Coalesced ones that waited for a concurrent lookup of the same SKU
+	Misses    uint64 `json:"misses"`
+	Coalesced uint64 `json:"coalesced"`
+	Evictions uint64 `json:"evictions"`
+	//Size number of products currently cached
+	Size int `json:"size"`
+}
+
+//Database decorates a database caching the products looked up by SKU in an LRU. The products are
+//invalidated when they are changed through the same instance, so changes made by other instances are
+//only seen once the cached products expire
+type Database struct {
+	//64-bit counters first to keep them aligned for atomic operations on 32-bit platforms
+	hits, negativeHits, misses, coalesced, evictions uint64
+	//increased on every invalidation, so lookups started before a change do not cache stale products
+	generation uint64
+
+	db.Database
+
+	entries     *lru
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	//concurrent lookups of the same sku share a single query
+	flights singleflight.Group
+}
+
+//New wraps the backend with a cache of up to size products kept for ttl. Missing products are cached
+//for negativeTTL, which disables negative caching when it is zero
+func New(backend db.Database, size int, ttl, negativeTTL time.Duration) *Database {
+	return &Database{
+		Database:    backend,
+		entries:     newLRU(size),
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+	}
+}
+
+//Get retrieves a product by its SKU from the cache, querying the database on a miss
+func (c *Database) Get(sku string) (*contract.Product, error) {
+	if e, ok := c.entries.get(sku, c.now()); ok {
+		atomic.AddUint64(&c.hits, 1)
+		if e.prd == nil {
+			atomic.AddUint64(&c.negativeHits, 1)
+			return nil, nil
+		}
+
+		return clone(e.prd), nil
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+
+	executed := false
+	v, err, _ := c.flights.Do(sku, func() (interface{}, error) {
+		executed = true
+		generation := atomic.LoadUint64(&c.generation)
+
+		prd, err := c.Database.Get(sku)
+		if err != nil {
+			return nil, err
+		}
+
+		c.store(sku, prd, generation)
+
+		return prd, nil
+	})
+
+	if !executed {
+		atomic.AddUint64(&c.coalesced, 1)
+	}
+
+	if err != nil || v.(*contract.Product) == nil {
+		return nil, err
+	}
+
+	return clone(v.(*contract.Product)), nil
+}
+
+//GetMany retrieves the cached products and queries the database for the rest in a single query
+func (c *Database) GetMany(skus []string) ([]contract.Product, []string, error) {
+	var (
+		now     = c.now()
+		prds    = make([]contract.Product, 0, len(skus))
+		missing = make([]string, 0)
+		pending = make([]string, 0)
+	)
+
+	for _, sku := range skus {
+		e, ok := c.entries.get(sku, now)
+		switch {
+		case !ok:
+			pending = append(pending, sku)
+		case e.prd == nil:
+			atomic.AddUint64(&c.hits, 1)
+			atomic.AddUint64(&c.negativeHits, 1)
+			missing = append(missing, sku)
+		default:
+			atomic.AddUint64(&c.hits, 1)
+			prds = append(prds, *clone(e.prd))
+		}
+	}
+
+	if len(pending) == 0 {
+		return prds, missing, nil
+	}
+
+	atomic.AddUint64(&c.misses, uint64(len(pending)))
+	generation := atomic.LoadUint64(&c.generation)
+
+	found, notFound, err := c.Database.GetMany(pending)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range found {
+		c.store(found[i].SKU, &found[i], generation)
+		prds = append(prds, *clone(&found[i]))
+	}
+
+	for _, sku := range notFound {
+		c.store(sku, nil, generation)
+		missing = append(missing, sku)
+	}
+
+	return prds, missing, nil
+}
+
+//Create creates the product and invalidates its SKU, which may be cached as missing
+func (c *Database) Create(prd contract.Product) (*contract.Product, error) {
+	defer c.invalidate(prd.SKU)
+	return c.Database.Create(prd)
+}
+
+//Update updates the product and invalidates its SKU
+func (c *Database) Update(prd contract.Product) error {
+	defer c.invalidate(prd.SKU)
+	return c.Database.Update(prd)
+}
+
+//UpdateFunc updates the product and invalidates its SKU
+func (c *Database) UpdateFunc(sku string, fn func(prd *contract.Product) error) (*contract.Product, error) {
+	defer c.invalidate(sku)
+	return c.Database.UpdateFunc(sku, fn)
+}
+
+//Delete deletes the product and invalidates its SKU
+func (c *Database) Delete(sku string, cascade bool) error {
+	defer c.invalidate(sku)
+	return c.Database.Delete(sku, cascade)
+}
+
+//Stats retrieves the counters of the cache
+func (c *Database) Stats() Stats {
+	return Stats{
+		Hits:         atomic.LoadUint64(&c.hits),
+		NegativeHits: atomic.LoadUint64(&c.negativeHits),
+		Misses:       atomic.LoadUint64(&c.misses),
+		Coalesced:    atomic.LoadUint64(&c.coalesced),
+		Evictions:    atomic.LoadUint64(&c.evictions),
+		Size:         c.entries.len(),
+	}
+}
+
+//caches the product unless the cache was invalidated since the lookup started
+func (c *Database) store(sku string, prd *contract.Product, generation uint64) {
+	ttl := c.ttl
+	if prd == nil {
+		ttl = c.negativeTTL
+	}
+
+	if ttl <= 0 || atomic.LoadUint64(&c.generation) != generation {
+		return
+	}
+
+	if prd != nil {
+		prd = clone(prd)
+	}
+
+	if c.entries.add(&entry{sku: sku, prd: prd, expiresAt: c.now().Add(ttl)}) {
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+//removes the sku from the cache. The lookups in flight are discarded, so they do not cache the old product
+func (c *Database) invalidate(sku string) {
+	atomic.AddUint64(&c.generation, 1)
+	c.flights.Forget(sku)
+	c.entries.remove(sku)
+}
+
+//removes every product from the cache, discarding the lookups in flight
+func (c *Database) purge() {
+	atomic.AddUint64(&c.generation, 1)
+	c.entries.clear()
+}
+
+//copies the product so callers cannot modify the cached one
+func clone(prd *contract.Product) *contract.Product {
+	c := *prd
+	if prd.AltImages != nil {
+		c.AltImages = append([]string{}, prd.AltImages...)
+	}
+	if prd.Translations != nil {
+		c.Translations = make(map[string]contract.ProductTranslation, len(prd.Translations))
+		for lang, tr := range prd.Translations {
+			c.Translations[lang] = tr
+		}
+	}
+	if prd.Attributes != nil {
+		c.Attributes = make(map[string]interface{}, len(prd.Attributes))
+		for key, value := range prd.Attributes {
+			c.Attributes[key] = value
+		}
+	}
+	c.Availability = nil
+
+	return &c
+}
@@ -0,0 +1,342 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+//mockDB in-memory database counting the lookups
+type mockDB struct {
+	mu   sync.Mutex
+	prds map[string]contract.Product
+
+	gets     int32
+	getManys int32
+	//when set, lookups wait until it is closed
+	block chan struct{}
+}
+
+func newMockDB(skus ...string) *mockDB {
+	mdb := &mockDB{prds: make(map[string]contract.Product)}
+	for _, sku := range skus {
+		mdb.prds[sku] = contract.Product{SKU: sku, Name: "name", AltImages: []string{"http://a"}}
+	}
+
+	return mdb
+}
+
+func (mdb *mockDB) Create(prd contract.Product) (*contract.Product, error) {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	mdb.prds[prd.SKU] = prd
+	return &prd, nil
+}
+
+func (mdb *mockDB) GetAll() ([]contract.Product, error) {
+	return nil, nil
+}
+
+func (mdb *mockDB) List(filter contract.ProductFilter) ([]contract.Product, error) {
+	return nil, nil
+}
+
+func (mdb *mockDB) Get(sku string) (*contract.Product, error) {
+	atomic.AddInt32(&mdb.gets, 1)
+	if mdb.block != nil {
+		<-mdb.block
+	}
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if sku == "error" {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	prd, ok := mdb.prds[sku]
+	if !ok {
+		return nil, nil
+	}
+
+	return &prd, nil
+}
+
+func (mdb *mockDB) GetMany(skus []string) ([]contract.Product, []string, error) {
+	atomic.AddInt32(&mdb.getManys, 1)
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	prds, missing := []contract.Product{}, []string{}
+	for _, sku := range skus {
+		if prd, ok := mdb.prds[sku]; ok {
+			prds = append(prds, prd)
+		} else {
+			missing = append(missing, sku)
+		}
+	}
+
+	return prds, missing, nil
+}
+
+func (mdb *mockDB) Update(prd contract.Product) error {
+	_, err := mdb.Create(prd)
+	return err
+}
+
+func (mdb *mockDB) UpdateFunc(sku string, fn func(prd *contract.Product) error) (*contract.Product, error) {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	prd := mdb.prds[sku]
+	if err := fn(&prd); err != nil {
+		return nil, err
+	}
+	mdb.prds[sku] = prd
+
+	return &prd, nil
+}
+
+func (mdb *mockDB) Delete(sku string, _ bool) error {
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	delete(mdb.prds, sku)
+	return nil
+}
+
+func (mdb *mockDB) LastModified() (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func (mdb *mockDB) Close() {}
+
+func TestGet(t *testing.T) {
+	mdb := newMockDB("FAL-1000000")
+	c := New(mdb, 10, time.Minute, time.Second)
+
+	for i := 0; i < 3; i++ {
+		prd, err := c.Get("FAL-1000000")
+		if err != nil || prd == nil {
+			t.Fatalf("product expected. Error: %v", err)
+		}
+
+		//callers cannot modify the cached product
+		prd.Name = "modified"
+		prd.AltImages[0] = "modified"
+	}
+
+	prd, _ := c.Get("FAL-1000000")
+	if prd.Name != "name" || prd.AltImages[0] != "http://a" {
+		t.Errorf("#1: cached product was modified: %+v", prd)
+	}
+
+	if mdb.gets != 1 {
+		t.Errorf("#2: database lookups got: %d, expected: 1", mdb.gets)
+	}
+
+	if _, err := c.Get("error"); err == nil {
+		t.Errorf("#3: error expected")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 3 || stats.Misses != 2 || stats.Size != 1 {
+		t.Errorf("#4: stats got: %+v", stats)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	tests := map[string]struct {
+		sku              string
+		elapsed          time.Duration
+		lookupsExpected  int32
+		negativeExpected uint64
+	}{
+		"#1: fresh product":     {sku: "FAL-1000000", elapsed: 59 * time.Second, lookupsExpected: 1},
+		"#2: expired product":   {sku: "FAL-1000000", elapsed: time.Minute, lookupsExpected: 2},
+		"#3: fresh missing sku": {sku: "FAL-2000000", elapsed: 9 * time.Second, lookupsExpected: 1, negativeExpected: 1},
+		"#4: expired missing":   {sku: "FAL-2000000", elapsed: 10 * time.Second, lookupsExpected: 2},
+	}
+
+	for desc, tc := range tests {
+		var (
+			mdb = newMockDB("FAL-1000000")
+			c   = New(mdb, 10, time.Minute, 10*time.Second)
+			now = time.Now()
+		)
+		c.now = func() time.Time { return now }
+
+		c.Get(tc.sku)
+		now = now.Add(tc.elapsed)
+		c.Get(tc.sku)
+
+		if mdb.gets != tc.lookupsExpected {
+			t.Errorf("%s:\n lookups got: %d\n lookups expected: %d", desc, mdb.gets, tc.lookupsExpected)
+		}
+
+		if c.Stats().NegativeHits != tc.negativeExpected {
+			t.Errorf("%s:\n negative hits got: %d\n negative hits expected: %d", desc, c.Stats().NegativeHits, tc.negativeExpected)
+		}
+	}
+}
+
+func TestNegativeCachingDisabled(t *testing.T) {
+	mdb := newMockDB()
+	c := New(mdb, 10, time.Minute, 0)
+
+	c.Get("FAL-1000000")
+	c.Get("FAL-1000000")
+
+	if mdb.gets != 2 {
+		t.Errorf("missing skus must not be cached. Lookups: %d", mdb.gets)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	mdb := newMockDB("FAL-1000000", "FAL-1000001", "FAL-1000002")
+	c := New(mdb, 2, time.Minute, time.Minute)
+
+	c.Get("FAL-1000000")
+	c.Get("FAL-1000001")
+	//FAL-1000000 becomes the most recently used, so FAL-1000001 is evicted
+	c.Get("FAL-1000000")
+	c.Get("FAL-1000002")
+	c.Get("FAL-1000000")
+	c.Get("FAL-1000001")
+
+	if mdb.gets != 4 {
+		t.Errorf("#1: lookups got: %d, expected: 4", mdb.gets)
+	}
+
+	if stats := c.Stats(); stats.Evictions != 2 || stats.Size != 2 {
+		t.Errorf("#2: stats got: %+v", stats)
+	}
+}
+
+func TestInvalidation(t *testing.T) {
+	tests := map[string]struct {
+		write        func(c *Database)
+		nameExpected string
+	}{
+		"#1: create": {write: func(c *Database) { c.Create(contract.Product{SKU: "FAL-2000000", Name: "created"}) }},
+		"#2: update": {write: func(c *Database) { c.Update(contract.Product{SKU: "FAL-1000000", Name: "updated"}) }, nameExpected: "updated"},
+		"#3: update func": {write: func(c *Database) {
+			c.UpdateFunc("FAL-1000000", func(prd *contract.Product) error { prd.Name = "patched"; return nil })
+		}, nameExpected: "patched"},
+		"#4: delete": {write: func(c *Database) { c.Delete("FAL-1000000", false) }},
+	}
+
+	for desc, tc := range tests {
+		c := New(newMockDB("FAL-1000000"), 10, time.Minute, time.Minute)
+
+		c.Get("FAL-1000000")
+		c.Get("FAL-2000000")
+		tc.write(c)
+
+		prd, _ := c.Get("FAL-1000000")
+		switch {
+		case tc.nameExpected == "" && desc != "#1: create":
+			if prd != nil {
+				t.Errorf("%s:\n deleted product got: %+v", desc, prd)
+			}
+		case tc.nameExpected != "":
+			if prd == nil || prd.Name != tc.nameExpected {
+				t.Errorf("%s:\n product got: %+v\n name expected: %s", desc, prd, tc.nameExpected)
+			}
+		}
+
+		if desc == "#1: create" {
+			if prd, _ := c.Get("FAL-2000000"); prd == nil {
+				t.Errorf("%s:\n created product must not be cached as missing", desc)
+			}
+		}
+	}
+}
+
+func TestCoalescing(t *testing.T) {
+	mdb := newMockDB("FAL-1000000")
+	mdb.block = make(chan struct{})
+	c := New(mdb, 10, time.Minute, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if prd, err := c.Get("FAL-1000000"); err != nil || prd == nil {
+				t.Errorf("product expected. Error: %v", err)
+			}
+		}()
+	}
+
+	//waits until every lookup is in flight
+	for c.Stats().Misses < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(mdb.block)
+	wg.Wait()
+
+	if mdb.gets != 1 {
+		t.Errorf("#1: concurrent lookups must share a single query. Lookups: %d", mdb.gets)
+	}
+
+	if coalesced := c.Stats().Coalesced; coalesced != 9 {
+		t.Errorf("#2: coalesced lookups got: %d, expected: 9", coalesced)
+	}
+}
+
+func TestStaleLookup(t *testing.T) {
+	mdb := newMockDB("FAL-1000000")
+	mdb.block = make(chan struct{})
+	c := New(mdb, 10, time.Minute, time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		c.Get("FAL-1000000")
+		close(done)
+	}()
+
+	for c.Stats().Misses < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	//the product changes while the lookup is in flight
+	c.Delete("FAL-1000000", false)
+	close(mdb.block)
+	<-done
+
+	if prd, _ := c.Get("FAL-1000000"); prd != nil {
+		t.Errorf("a lookup started before a change must not be cached")
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	mdb := newMockDB("FAL-1000000", "FAL-1000001")
+	c := New(mdb, 10, time.Minute, time.Minute)
+
+	c.Get("FAL-1000000")
+	c.Get("FAL-2000000")
+
+	prds, missing, err := c.GetMany([]string{"FAL-1000000", "FAL-1000001", "FAL-2000000", "FAL-3000000"})
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	if len(prds) != 2 || fmt.Sprint(missing) != "[FAL-2000000 FAL-3000000]" {
+		t.Errorf("#1: products got: %v, missing got: %v", prds, missing)
+	}
+
+	//every sku is cached now
+	c.GetMany([]string{"FAL-1000000", "FAL-1000001", "FAL-2000000", "FAL-3000000"})
+
+	if mdb.getManys != 1 {
+		t.Errorf("#2: only the skus not cached must be queried. Queries: %d", mdb.getManys)
+	}
+}
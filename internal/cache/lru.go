@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+//lru bounded map evicting the least recently used entries. Safe for concurrent use
+type lru struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type entry struct {
+	sku string
+	//nil for the skus known to be missing
+	prd       *contract.Product
+	expiresAt time.Time
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+//get retrieves the entry of the sku unless it is missing or expired
+func (c *lru) get(sku string, now time.Time) (*entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[sku]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if !now.Before(e.expiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, sku)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+
+	return e, true
+}
+
+//add stores the entry and reports if another entry was evicted to make room for it
+func (c *lru) add(e *entry) (evicted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[e.sku]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return false
+	}
+
+	c.entries[e.sku] = c.order.PushFront(e)
+
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).sku)
+		return true
+	}
+
+	return false
+}
+
+func (c *lru) remove(sku string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[sku]; ok {
+		c.order.Remove(el)
+		delete(c.entries, sku)
+	}
+}
+
+//clear removes every entry
+func (c *lru) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element, c.size)
+	c.order.Init()
+}
+
+func (c *lru) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
@@ -0,0 +1,57 @@
+package contract
+
+import "time"
+
+//Types of the custom attributes
+const (
+	AttributeString  = "string"
+	AttributeNumber  = "number"
+	AttributeInteger = "integer"
+	AttributeBoolean = "boolean"
+)
+
+//CategorySchema type used to represent the custom attributes allowed for the products of a category
+type CategorySchema struct {
+	Category   string                `json:"category" validate:"slug,max=50"`
+	Attributes []AttributeDefinition `json:"attributes" validate:"dive"`
+
+	//UpdatedAt is set by the database and UpdatedBy comes from the user header, so both are ignored in requests
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+}
+
+//AttributeDefinition type used to define a custom attribute of the products of a category. Min and Max bound
+//the value of the numbers and the length of the strings
+type AttributeDefinition struct {
+	Key  string `json:"key" validate:"required,attributekey"`
+	Type string `json:"type" validate:"required,oneof=string number integer boolean"`
+	//Unit of the numbers, e.g. kg or cm. The values are stored in this unit
+	Unit     string   `json:"unit,omitempty" validate:"max=20"`
+	Required bool     `json:"required,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	//Pattern regular expression the strings must match
+	Pattern string `json:"pattern,omitempty" validate:"max=200"`
+	//Values allowed for the strings. Any value is allowed when empty
+	Values []string `json:"values,omitempty" validate:"dive,required"`
+}
+
+//Attribute retrieves the definition of an attribute by its key
+func (s *CategorySchema) Attribute(key string) (*AttributeDefinition, bool) {
+	for i := range s.Attributes {
+		if s.Attributes[i].Key == key {
+			return &s.Attributes[i], true
+		}
+	}
+
+	return nil, false
+}
+
+//AttributeFilter type used to select the products by the value of a custom attribute. Equals selects the
+//products with that value, while Min and Max select the ones with a number within the range
+type AttributeFilter struct {
+	Key    string
+	Equals interface{}
+	Min    *float64
+	Max    *float64
+}
@@ -0,0 +1,28 @@
+package contract
+
+import "time"
+
+//Brand type used to represent a brand of the registry. The products reference their brand by ID, while their
+//brand name is resolved with the name, the aliases or the slug of the brand, ignoring the case and the spaces
+type Brand struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name" validate:"required,notblank,min=3,max=50"`
+	//Slug identifies the brand in URLs. It is generated from the name when empty
+	Slug string `json:"slug,omitempty" validate:"omitempty,slug,max=60"`
+	//Aliases other spellings of the name, e.g. the former names of the brand
+	Aliases []string `json:"aliases,omitempty" validate:"max=50,dive,required,notblank,max=50"`
+
+	//CreatedAt and UpdatedAt are set by the database and UpdatedBy comes from the user header, so they are
+	//ignored in requests
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+}
+
+//BrandFacet type used to return the number of products of a brand
+type BrandFacet struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	Products int    `json:"products"`
+}
@@ -0,0 +1,23 @@
+package contract
+
+import "time"
+
+//Product event types
+const (
+	EventProductCreated = "product.created"
+	EventProductUpdated = "product.updated"
+	EventProductDeleted = "product.deleted"
+)
+
+//ProductEvent type used to represent a change of a product. Version is incremented on every change of the same SKU
+type ProductEvent struct {
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	SKU        string    `json:"sku"`
+	Version    int64     `json:"version,omitempty"`
+	Payload    *Product  `json:"payload"`
+	OccurredAt time.Time `json:"occurredAt"`
+
+	//Attempts is the number of failed deliveries of the event
+	Attempts int `json:"-"`
+}
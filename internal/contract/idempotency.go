@@ -0,0 +1,18 @@
+package contract
+
+import "time"
+
+//IdempotentResponse type used to represent the stored response of a request with an idempotency key, which is
+//replayed when the request is retried
+type IdempotentResponse struct {
+	Key string `json:"key"`
+	//Fingerprint identifies the request, so the key cannot be reused with a different one
+	Fingerprint string `json:"fingerprint"`
+
+	//Status is 0 while the request is in progress
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   []byte            `json:"body,omitempty"`
+
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,20 @@
+package contract
+
+import "time"
+
+//ProductImage type used to represent an image uploaded for a product, stored by the API along with its thumbnail
+type ProductImage struct {
+	ID           int64     `json:"id"`
+	SKU          string    `json:"sku"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnailURL"`
+	ContentType  string    `json:"contentType"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Size         int64     `json:"size"`
+	CreatedAt    time.Time `json:"createdAt"`
+
+	//keys of the image and its thumbnail in the storage
+	Key          string `json:"-"`
+	ThumbnailKey string `json:"-"`
+}
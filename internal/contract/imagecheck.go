@@ -0,0 +1,29 @@
+package contract
+
+import "time"
+
+//ImageCheck result of the last checks of an image URL referenced by the products
+type ImageCheck struct {
+	URL string `json:"url"`
+	OK  bool   `json:"ok"`
+	//Status HTTP status of the response, 0 when the request failed
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	//Failures consecutive failed checks
+	Failures     int        `json:"failures"`
+	CheckedAt    time.Time  `json:"checkedAt"`
+	FailingSince *time.Time `json:"failingSince,omitempty"`
+}
+
+//ProductBrokenImages images of a product whose last check failed
+type ProductBrokenImages struct {
+	SKU    string       `json:"sku"`
+	Images []ImageCheck `json:"images"`
+}
+
+//ImageReport report of the checks of the image URLs, flagging the products with broken images
+type ImageReport struct {
+	CheckedURLs int                   `json:"checkedURLs"`
+	BrokenURLs  int                   `json:"brokenURLs"`
+	Products    []ProductBrokenImages `json:"products"`
+}
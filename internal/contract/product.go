@@ -1,17 +1,61 @@
 package contract
 
+import "time"
+
 //Product type used to represent a product entity
 type Product struct {
-	SKU       string   `json:"sku" validate:"required,sku"`
-	Name      string   `json:"name" validate:"required,notblank,min=3,max=50"`
-	Brand     string   `json:"brand" validate:"required,notblank,min=3,max=50"`
-	Size      int      `json:"size" validate:"notblank,min=0,max=9999999999"`
-	Price     float64  `json:"price" validate:"required,min=1.00,max=99999999.00"`
-	ImageURL  string   `json:"imageURL" validate:"required,url"`
-	AltImages []string `json:"altImages" validate:"altimages"`
+	SKU         string   `json:"sku" validate:"required,sku"`
+	Name        string   `json:"name" validate:"required,notblank,min=3,max=50"`
+	Description string   `json:"description,omitempty" validate:"max=2000"`
+	Brand       string   `json:"brand" validate:"required,notblank,min=3,max=50"`
+	Size        int      `json:"size" validate:"notblank,min=0,max=9999999999"`
+	Price       float64  `json:"price" validate:"required,min=1.00,max=99999999.00"`
+	ImageURL    string   `json:"imageURL" validate:"required,url"`
+	AltImages   []string `json:"altImages" validate:"altimages"`
+	Category    string   `json:"category,omitempty" validate:"omitempty,slug,max=50"`
+
+	//BrandID identifies the brand of the registry resolved from the brand name, which is replaced by the name of
+	//the brand. It is set by the database and ignored in requests
+	BrandID int64 `json:"brandID,omitempty"`
+
+	//Attributes custom attributes of the product, validated with the schema of its category
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+
+	//Translations names and descriptions of the product by BCP 47 language tag, used instead of the name and
+	//description when the language is requested. Localized responses omit them
+	Translations map[string]ProductTranslation `json:"translations,omitempty" validate:"dive,keys,bcp47_language_tag,endkeys,translation"`
+
+	//Status of the product in its lifecycle, changed through the status endpoint. Only the active products are
+	//public, and only the drafts can be incomplete
+	Status string `json:"status,omitempty" validate:"omitempty,oneof=draft pending_review active discontinued archived"`
+	//StatusUpdatedAt and StatusUpdatedBy tell when and by whom the status was last changed. They are set by the
+	//database and ignored in requests
+	StatusUpdatedAt time.Time `json:"statusUpdatedAt"`
+	StatusUpdatedBy string    `json:"statusUpdatedBy,omitempty"`
+
+	//Revision number of the current version of the product, increased by every write. It is set by the
+	//database and ignored in requests
+	Revision int `json:"revision,omitempty"`
+
+	//CreatedAt and UpdatedAt are set by the database and ignored in requests
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	//CreatedBy and UpdatedBy identify the users who created and last changed the product. The database
+	//stores UpdatedBy as the author of every change and ignores CreatedBy
+	CreatedBy string `json:"createdBy,omitempty"`
+	UpdatedBy string `json:"updatedBy,omitempty" validate:"max=100"`
+
+	//Availability is only filled in responses when the inventory is enabled
+	Availability *Availability `json:"availability,omitempty"`
 }
 
-//Patch set new values from patch to this product object
+//Public reports if anyone can read the product, including anonymous users and partners
+func (p *Product) Public() bool {
+	return p.Status == StatusActive
+}
+
+//Patch set new values from patch to this product object. The status is not patched, it has its own transitions
 func (p *Product) Patch(patch Product) {
 	if patch.SKU != "" {
 		p.SKU = patch.SKU
@@ -40,4 +84,112 @@ func (p *Product) Patch(patch Product) {
 	if len(patch.AltImages) > 0 {
 		p.AltImages = patch.AltImages
 	}
+
+	if patch.Description != "" {
+		p.Description = patch.Description
+	}
+
+	if len(patch.Translations) > 0 {
+		p.Translations = patch.Translations
+	}
+
+	if patch.Category != "" {
+		p.Category = patch.Category
+	}
+
+	//the attributes are patched one by one, and null values remove them
+	for key, value := range patch.Attributes {
+		if value == nil {
+			delete(p.Attributes, key)
+			continue
+		}
+
+		if p.Attributes == nil {
+			p.Attributes = make(map[string]interface{})
+		}
+		p.Attributes[key] = value
+	}
+}
+
+//Localize replaces the name and description with the translation of the first language having one, looking up
+//the description in the following languages when the translation has none. The translations are removed.
+//Returns the language of the name, or an empty string when the name was not translated
+func (p *Product) Localize(languages []string) string {
+	var (
+		selected    string
+		description string
+	)
+
+	for _, lang := range languages {
+		tr, ok := p.Translations[lang]
+		if !ok {
+			continue
+		}
+
+		if selected == "" {
+			selected = lang
+			p.Name = tr.Name
+		}
+
+		if tr.Description != "" {
+			description = tr.Description
+			break
+		}
+	}
+
+	if description != "" {
+		p.Description = description
+	}
+	p.Translations = nil
+
+	return selected
+}
+
+//ProductTranslation name and description of a product in a language
+type ProductTranslation struct {
+	Name        string `json:"name" validate:"required,notblank,min=3,max=50"`
+	Description string `json:"description,omitempty" validate:"max=2000"`
+}
+
+//ProductFilter type used to select a page of products ordered by SKU. Zero values do not filter
+type ProductFilter struct {
+	//AfterSKU only selects the products with a greater SKU. Used as pagination cursor
+	AfterSKU string
+	//Limit maximum number of products selected
+	Limit int
+
+	Brand    string
+	MinPrice float64
+	MaxPrice float64
+
+	//UpdatedSince only selects the products updated at or after this time
+	UpdatedSince time.Time
+
+	Category   string
+	Attributes []AttributeFilter
+
+	//Status only selects the products with this status
+	Status string
+}
+
+//BatchRequest type used to request several products at once
+type BatchRequest struct {
+	SKUs []string `json:"skus" validate:"required,min=1,max=100,dive,required"`
+}
+
+//BatchResponse type used to return the products of a batch request along with the SKUs not found
+type BatchResponse struct {
+	Products []Product `json:"products"`
+	Missing  []string  `json:"missing"`
+}
+
+//SKUBlockRequest type used to reserve a block of generated SKUs for offline tools
+type SKUBlockRequest struct {
+	Count int `json:"count" validate:"required,min=1,max=1000"`
+}
+
+//SKUBlock type used to return a block of reserved SKUs, which are never generated again
+type SKUBlock struct {
+	Rule string   `json:"rule"`
+	SKUs []string `json:"skus"`
 }
@@ -11,6 +11,11 @@ func TestProductPatch(t *testing.T) {
 		Price:     10.00,
 		ImageURL:  "http://old",
 		AltImages: []string{"http://old"},
+		Translations: map[string]ProductTranslation{
+			"es": {Name: "nombre viejo"},
+		},
+		Category:   "shoes",
+		Attributes: map[string]interface{}{"color": "black", "size": 40.0},
 	}
 
 	patch := Product{
@@ -21,6 +26,12 @@ func TestProductPatch(t *testing.T) {
 		Price:     20.00,
 		ImageURL:  "http://new",
 		AltImages: []string{"http://new"},
+
+		Description: "new description",
+		Translations: map[string]ProductTranslation{
+			"pt": {Name: "nome novo"},
+		},
+		Attributes: map[string]interface{}{"color": nil, "size": 42.0, "waterproof": true},
 	}
 
 	prd.Patch(patch)
@@ -52,4 +63,56 @@ func TestProductPatch(t *testing.T) {
 	if prd.AltImages[0] != "http://new" {
 		t.Errorf("altImages[0] different than expected: %v", prd.AltImages[0])
 	}
+
+	if prd.Description != "new description" {
+		t.Errorf("description different than expected: %v", prd.Description)
+	}
+
+	if _, ok := prd.Translations["es"]; ok || prd.Translations["pt"].Name != "nome novo" {
+		t.Errorf("translations different than expected: %v", prd.Translations)
+	}
+
+	if prd.Category != "shoes" {
+		t.Errorf("category different than expected: %v", prd.Category)
+	}
+
+	if _, ok := prd.Attributes["color"]; ok || prd.Attributes["size"] != 42.0 || prd.Attributes["waterproof"] != true {
+		t.Errorf("attributes different than expected: %v", prd.Attributes)
+	}
+}
+
+func TestProductLocalize(t *testing.T) {
+	translations := map[string]ProductTranslation{
+		"es":    {Name: "nombre", Description: "descripción"},
+		"es-AR": {Name: "nombre argentino"},
+		"pt":    {Name: "nome", Description: "descrição"},
+	}
+
+	tests := map[string]struct {
+		languages           []string
+		langExpected        string
+		nameExpected        string
+		descriptionExpected string
+	}{
+		"#1: no languages":                {nameExpected: "name", descriptionExpected: "description"},
+		"#2: translated language":         {languages: []string{"pt"}, langExpected: "pt", nameExpected: "nome", descriptionExpected: "descrição"},
+		"#3: untranslated language":       {languages: []string{"fr"}, nameExpected: "name", descriptionExpected: "description"},
+		"#4: first translated language":   {languages: []string{"fr", "pt", "es"}, langExpected: "pt", nameExpected: "nome", descriptionExpected: "descrição"},
+		"#5: description of the fallback": {languages: []string{"es-AR", "es"}, langExpected: "es-AR", nameExpected: "nombre argentino", descriptionExpected: "descripción"},
+		"#6: description not translated":  {languages: []string{"es-AR"}, langExpected: "es-AR", nameExpected: "nombre argentino", descriptionExpected: "description"},
+	}
+
+	for desc, tc := range tests {
+		prd := Product{Name: "name", Description: "description", Translations: translations}
+
+		lang := prd.Localize(tc.languages)
+		if lang != tc.langExpected || prd.Name != tc.nameExpected || prd.Description != tc.descriptionExpected {
+			t.Errorf("%s:\n Got: %q %q %q\n Expected: %q %q %q", desc, lang, prd.Name, prd.Description,
+				tc.langExpected, tc.nameExpected, tc.descriptionExpected)
+		}
+
+		if prd.Translations != nil {
+			t.Errorf("%s:\n translations not expected. Got: %v", desc, prd.Translations)
+		}
+	}
 }
@@ -0,0 +1,100 @@
+package contract
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+//ProductRevision type used to represent an immutable version of a product, stored on every write. The deletions
+//are stored as revisions too, along with the last version of the deleted product
+type ProductRevision struct {
+	SKU       string    `json:"sku"`
+	Revision  int       `json:"revision"`
+	Deleted   bool      `json:"deleted,omitempty"`
+	Product   Product   `json:"product"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+}
+
+//RevisionDiff type used to return the changes of a product from one revision to another
+type RevisionDiff struct {
+	SKU     string        `json:"sku"`
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
+
+//FieldChange type used to represent the change of a field, named by its JSON path, e.g. attributes.color.
+//From is omitted for the added fields and To for the removed ones
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from,omitempty"`
+	To    interface{} `json:"to,omitempty"`
+}
+
+//fields of the products left out of the diffs, since they change with every write or follow the brand name
+var auditFields = []string{"revision", "createdAt", "createdBy", "updatedAt", "updatedBy", "statusUpdatedAt",
+	"statusUpdatedBy", "availability", "brandID"}
+
+//Diff retrieves the changes of the fields from one version of a product to another, sorted by field. The
+//attributes and translations are compared key by key, while the lists are compared as a whole
+func Diff(from, to Product) []FieldChange {
+	var (
+		before = fields(from)
+		after  = fields(to)
+		names  = make([]string, 0, len(before)+len(after))
+	)
+
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []FieldChange{}
+	for _, name := range names {
+		if !reflect.DeepEqual(before[name], after[name]) {
+			changes = append(changes, FieldChange{Field: name, From: before[name], To: after[name]})
+		}
+	}
+
+	return changes
+}
+
+//retrieves the fields of the JSON representation of the product by their path, leaving out the audit fields
+func fields(prd Product) map[string]interface{} {
+	var (
+		body, _ = json.Marshal(prd)
+		obj     = make(map[string]interface{})
+	)
+	json.Unmarshal(body, &obj)
+
+	for _, field := range auditFields {
+		delete(obj, field)
+	}
+
+	flat := make(map[string]interface{})
+	flatten("", obj, flat)
+
+	return flat
+}
+
+//adds the values of the object to the flat map, with the path of the nested objects joined by dots
+func flatten(prefix string, obj map[string]interface{}, flat map[string]interface{}) {
+	for key, value := range obj {
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(prefix+key+".", nested, flat)
+			continue
+		}
+
+		if value != nil {
+			flat[prefix+key] = value
+		}
+	}
+}
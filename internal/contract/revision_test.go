@@ -0,0 +1,57 @@
+package contract
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	prd := Product{
+		SKU:          "FAL-1000000",
+		Name:         "name",
+		Brand:        "brand",
+		Price:        100,
+		ImageURL:     "http://a",
+		AltImages:    []string{"http://b"},
+		Category:     "shoes",
+		Attributes:   map[string]interface{}{"color": "black", "size": 40.0},
+		Translations: map[string]ProductTranslation{"es": {Name: "nombre"}},
+		Revision:     1,
+	}
+
+	tests := map[string]struct {
+		edit     func(prd *Product)
+		expected []FieldChange
+	}{
+		"#1: same product": {edit: func(prd *Product) {}, expected: []FieldChange{}},
+		"#2: audit fields": {edit: func(prd *Product) {
+			prd.Revision, prd.UpdatedAt, prd.UpdatedBy = 2, time.Now(), "editor"
+		}, expected: []FieldChange{}},
+		"#3: changed fields": {edit: func(prd *Product) { prd.Name, prd.Price = "other name", 150 },
+			expected: []FieldChange{{Field: "name", From: "name", To: "other name"}, {Field: "price", From: 100.0, To: 150.0}}},
+		"#4: changed list": {edit: func(prd *Product) { prd.AltImages = []string{"http://b", "http://c"} },
+			expected: []FieldChange{{Field: "altImages", From: []interface{}{"http://b"}, To: []interface{}{"http://b", "http://c"}}}},
+		"#5: nested fields": {edit: func(prd *Product) {
+			prd.Attributes = map[string]interface{}{"size": 42.0, "waterproof": true}
+			prd.Translations = map[string]ProductTranslation{"es": {Name: "nombre"}, "pt": {Name: "nome"}}
+		}, expected: []FieldChange{
+			{Field: "attributes.color", From: "black"},
+			{Field: "attributes.size", From: 40.0, To: 42.0},
+			{Field: "attributes.waterproof", To: true},
+			{Field: "translations.pt.name", To: "nome"},
+		}},
+		"#6: added field": {edit: func(prd *Product) { prd.Description = "description" },
+			expected: []FieldChange{{Field: "description", To: "description"}}},
+	}
+
+	for desc, tc := range tests {
+		to := prd
+		to.Attributes = map[string]interface{}{"color": "black", "size": 40.0}
+		tc.edit(&to)
+
+		if got := Diff(prd, to); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s:\n Got: %v\n Expected: %v", desc, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package contract
+
+//Statuses of the products in their lifecycle
+const (
+	//StatusDraft products can be incomplete, and they are not public
+	StatusDraft = "draft"
+	//StatusPendingReview products wait to be approved
+	StatusPendingReview = "pending_review"
+	//StatusActive products are public
+	StatusActive = "active"
+	//StatusDiscontinued products are no longer sold, and they are not public
+	StatusDiscontinued = "discontinued"
+	//StatusArchived products are kept for the record
+	StatusArchived = "archived"
+)
+
+//statuses each status can change to. The empty status is the one of the products to be created
+var transitions = map[string][]string{
+	"":                  {StatusDraft, StatusPendingReview, StatusActive},
+	StatusDraft:         {StatusPendingReview, StatusActive, StatusArchived},
+	StatusPendingReview: {StatusDraft, StatusActive},
+	StatusActive:        {StatusDiscontinued, StatusArchived},
+	StatusDiscontinued:  {StatusPendingReview, StatusActive, StatusArchived},
+	StatusArchived:      {StatusDraft},
+}
+
+//CanTransition reports if the status of a product can change from one status to another. Products are created
+//with the statuses the empty status can change to
+func CanTransition(from, to string) bool {
+	for _, status := range transitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+//IsStatus reports if the status is one of the statuses of the products
+func IsStatus(status string) bool {
+	_, ok := transitions[status]
+	return ok && status != ""
+}
+
+//StatusChange type used to request a change of the status of a product
+type StatusChange struct {
+	Status string `json:"status" validate:"required,oneof=draft pending_review active discontinued archived"`
+}
@@ -0,0 +1,30 @@
+package contract
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := map[string]struct {
+		from     string
+		to       string
+		expected bool
+	}{
+		"#1: created as draft":        {from: "", to: StatusDraft, expected: true},
+		"#2: created as archived":     {from: "", to: StatusArchived, expected: false},
+		"#3: submitted for review":    {from: StatusDraft, to: StatusPendingReview, expected: true},
+		"#4: rejected":                {from: StatusPendingReview, to: StatusDraft, expected: true},
+		"#5: approved":                {from: StatusPendingReview, to: StatusActive, expected: true},
+		"#6: active back to draft":    {from: StatusActive, to: StatusDraft, expected: false},
+		"#7: discontinued":            {from: StatusActive, to: StatusDiscontinued, expected: true},
+		"#8: archived to active":      {from: StatusArchived, to: StatusActive, expected: false},
+		"#9: same status":             {from: StatusActive, to: StatusActive, expected: false},
+		"#10: unknown status":         {from: StatusDraft, to: "sold", expected: false},
+		"#11: restored from archive":  {from: StatusArchived, to: StatusDraft, expected: true},
+		"#12: reviewed again to sell": {from: StatusDiscontinued, to: StatusPendingReview, expected: true},
+	}
+
+	for desc, tc := range tests {
+		if got := CanTransition(tc.from, tc.to); got != tc.expected {
+			t.Errorf("%s:\n Got: %v\n Expected: %v", desc, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package contract
+
+import "time"
+
+//Reservation statuses
+const (
+	ReservationPending   = "pending"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+//Stock type used to represent the stock level of a product in a warehouse
+type Stock struct {
+	SKU       string `json:"sku"`
+	Warehouse string `json:"warehouse"`
+	OnHand    int    `json:"onHand"`
+	Reserved  int    `json:"reserved"`
+	Available int    `json:"available"`
+
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+//StockAdjustment type used to represent a change of the on-hand quantity in a warehouse
+type StockAdjustment struct {
+	Warehouse string `json:"warehouse" validate:"required,notblank,max=50"`
+	Delta     int    `json:"delta" validate:"required"`
+}
+
+//ReservationRequest type used to represent a request to hold stock of a product
+type ReservationRequest struct {
+	Warehouse  string `json:"warehouse" validate:"required,notblank,max=50"`
+	Quantity   int    `json:"quantity" validate:"required,min=1"`
+	TTLSeconds int    `json:"ttlSeconds" validate:"min=0,max=86400"`
+}
+
+//Reservation type used to represent stock held for a product until it is committed, released or expired
+type Reservation struct {
+	ID        int64     `json:"id"`
+	SKU       string    `json:"sku"`
+	Warehouse string    `json:"warehouse"`
+	Quantity  int       `json:"quantity"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+//Availability type used to summarize the stock of a product across warehouses
+type Availability struct {
+	OnHand     int     `json:"onHand"`
+	Reserved   int     `json:"reserved"`
+	Available  int     `json:"available"`
+	Warehouses []Stock `json:"warehouses"`
+}
+
+//NewAvailability aggregates the stock levels of a single product
+func NewAvailability(stocks []Stock) *Availability {
+	av := &Availability{Warehouses: make([]Stock, 0, len(stocks))}
+
+	for _, s := range stocks {
+		av.OnHand += s.OnHand
+		av.Reserved += s.Reserved
+		av.Available += s.Available
+		av.Warehouses = append(av.Warehouses, s)
+	}
+
+	return av
+}
+
+//InStock reports if there are units on hand or reserved
+func (a *Availability) InStock() bool {
+	return a.OnHand > 0 || a.Reserved > 0
+}
+
+//LastModified retrieves the last time the stock of any warehouse changed
+func (a *Availability) LastModified() time.Time {
+	var last time.Time
+	for _, s := range a.Warehouses {
+		if s.UpdatedAt.After(last) {
+			last = s.UpdatedAt
+		}
+	}
+
+	return last
+}
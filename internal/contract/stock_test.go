@@ -0,0 +1,34 @@
+package contract
+
+import "testing"
+
+func TestNewAvailability(t *testing.T) {
+	av := NewAvailability([]Stock{
+		{SKU: "FAL-10000001", Warehouse: "north", OnHand: 10, Reserved: 4, Available: 6},
+		{SKU: "FAL-10000001", Warehouse: "south", OnHand: 5, Reserved: 0, Available: 5},
+	})
+
+	if av.OnHand != 15 {
+		t.Errorf("on hand different than expected: %v", av.OnHand)
+	}
+
+	if av.Reserved != 4 {
+		t.Errorf("reserved different than expected: %v", av.Reserved)
+	}
+
+	if av.Available != 11 {
+		t.Errorf("available different than expected: %v", av.Available)
+	}
+
+	if len(av.Warehouses) != 2 {
+		t.Errorf("warehouses different than expected: %v", len(av.Warehouses))
+	}
+
+	if !av.InStock() {
+		t.Errorf("product must be in stock")
+	}
+
+	if NewAvailability(nil).InStock() {
+		t.Errorf("product without stock entries must not be in stock")
+	}
+}
@@ -0,0 +1,95 @@
+package contract
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+//Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+//WebhookSubscription type used to represent a partner endpoint notified of product changes.
+//Empty filters match every event
+type WebhookSubscription struct {
+	ID          int64     `json:"id"`
+	URL         string    `json:"url" validate:"required,httpurl"`
+	EventTypes  []string  `json:"eventTypes" validate:"dive,oneof=product.created product.updated product.deleted"`
+	Brands      []string  `json:"brands" validate:"dive,notblank"`
+	SKUPrefixes []string  `json:"skuPrefixes" validate:"dive,notblank"`
+	Secret      string    `json:"secret,omitempty" validate:"required,min=16,max=256"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+//Matches reports if the event passes the filters of the subscription
+func (s *WebhookSubscription) Matches(event ProductEvent) bool {
+	if len(s.EventTypes) > 0 && !contains(s.EventTypes, event.Type) {
+		return false
+	}
+
+	if len(s.Brands) > 0 {
+		if event.Payload == nil {
+			return false
+		}
+
+		found := false
+		for _, brand := range s.Brands {
+			if strings.EqualFold(strings.TrimSpace(brand), strings.TrimSpace(event.Payload.Brand)) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if len(s.SKUPrefixes) > 0 {
+		found := false
+		for _, prefix := range s.SKUPrefixes {
+			if strings.HasPrefix(event.SKU, prefix) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+//WebhookDelivery type used to represent the delivery of a product event to a subscription
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID int64           `json:"subscriptionId"`
+	EventID        int64           `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	SKU            string          `json:"sku"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastError      string          `json:"lastError,omitempty"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	Payload        json.RawMessage `json:"-"`
+	URL            string          `json:"-"`
+	Secret         string          `json:"-"`
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
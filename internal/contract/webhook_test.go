@@ -0,0 +1,31 @@
+package contract
+
+import "testing"
+
+func TestWebhookSubscriptionMatches(t *testing.T) {
+	event := ProductEvent{
+		Type:    EventProductUpdated,
+		SKU:     "FAL-1000001",
+		Payload: &Product{SKU: "FAL-1000001", Brand: "Nike"},
+	}
+
+	tests := map[string]struct {
+		sub      WebhookSubscription
+		expected bool
+	}{
+		"#1: no filters":          {sub: WebhookSubscription{}, expected: true},
+		"#2: event type matches":  {sub: WebhookSubscription{EventTypes: []string{EventProductUpdated}}, expected: true},
+		"#3: other event type":    {sub: WebhookSubscription{EventTypes: []string{EventProductCreated}}, expected: false},
+		"#4: brand ignores case":  {sub: WebhookSubscription{Brands: []string{"nike "}}, expected: true},
+		"#5: other brand":         {sub: WebhookSubscription{Brands: []string{"adidas"}}, expected: false},
+		"#6: sku prefix matches":  {sub: WebhookSubscription{SKUPrefixes: []string{"FAL-100"}}, expected: true},
+		"#7: other sku prefix":    {sub: WebhookSubscription{SKUPrefixes: []string{"ABC-"}}, expected: false},
+		"#8: every filter passes": {sub: WebhookSubscription{EventTypes: []string{EventProductUpdated}, Brands: []string{"NIKE"}, SKUPrefixes: []string{"FAL-"}}, expected: true},
+	}
+
+	for desc, tc := range tests {
+		if got := tc.sub.Matches(event); got != tc.expected {
+			t.Errorf("%s:\n Got: %v\n Expected: %v", desc, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package db
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const brandColumns = "id, name, slug, aliases, created_at, updated_at, updated_by"
+
+//slugSeparators characters replaced by hyphens in the slugs
+var slugSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+//Brands retrieves the brands of the registry ordered by name
+func (db *PostgreSQLDB) Brands() ([]contract.Brand, error) {
+	query := "SELECT " + brandColumns + " FROM public.brand ORDER BY name, id"
+
+	rows, err := db.pool.Query(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("could not get brands: %v", err)
+	}
+	defer rows.Close()
+
+	brands := make([]contract.Brand, 0)
+	for rows.Next() {
+		brand, err := scanBrand(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not get brands: %v", err)
+		}
+		brands = append(brands, *brand)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get brands: %v", err)
+	}
+
+	return brands, nil
+}
+
+//Brand retrieves a brand by its ID. Returns ErrNotFound when it does not exist
+func (db *PostgreSQLDB) Brand(id int64) (*contract.Brand, error) {
+	query := "SELECT " + brandColumns + " FROM public.brand WHERE id = $1"
+
+	brand, err := scanBrand(db.pool.QueryRow(context.Background(), query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get brand: %v", err)
+	}
+
+	return brand, nil
+}
+
+//CreateBrand registers a brand, generating its slug from the name when it has none. Returns ErrAlreadyExists
+//when the slug, the name or an alias belongs to another brand
+func (db *PostgreSQLDB) CreateBrand(brand contract.Brand) (*contract.Brand, error) {
+	query := `INSERT INTO public.brand(name, slug, aliases, updated_by) VALUES($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`
+
+	brand = normalizeBrand(brand)
+	if brand.Slug == "" {
+		brand.Slug = brandSlug(brand.Name)
+	}
+
+	ctx := context.Background()
+	err := db.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, query, brand.Name, brand.Slug, brand.Aliases, brand.UpdatedBy).
+			Scan(&brand.ID, &brand.CreatedAt, &brand.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		return putBrandKeys(ctx, tx, brand)
+	})
+	if err != nil {
+		return nil, brandError("could not create brand", err)
+	}
+
+	return &brand, nil
+}
+
+//UpdateBrand replaces the name, slug and aliases of a brand, renaming its products. The former name is kept as
+//an alias and the slug is kept when the brand has none. Returns ErrNotFound when the brand does not exist, and
+//ErrAlreadyExists when the slug, the name or an alias belongs to another brand
+func (db *PostgreSQLDB) UpdateBrand(brand contract.Brand) (*contract.Brand, error) {
+	brand = normalizeBrand(brand)
+
+	ctx := context.Background()
+	err := db.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var name, slug string
+		err := tx.QueryRow(ctx, "SELECT name, slug FROM public.brand WHERE id = $1 FOR UPDATE", brand.ID).Scan(&name, &slug)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		if brand.Slug == "" {
+			brand.Slug = slug
+		}
+
+		//the products written with the former name keep resolving to the brand
+		if brandKey(name) != brandKey(brand.Name) {
+			brand = normalizeBrand(contract.Brand{ID: brand.ID, Name: brand.Name, Slug: brand.Slug,
+				Aliases: append(brand.Aliases, name), UpdatedBy: brand.UpdatedBy})
+		}
+
+		query := `UPDATE public.brand SET name=$1, slug=$2, aliases=$3, updated_by=$4, updated_at=NOW() WHERE id=$5
+			RETURNING created_at, updated_at`
+		err = tx.QueryRow(ctx, query, brand.Name, brand.Slug, brand.Aliases, brand.UpdatedBy, brand.ID).
+			Scan(&brand.CreatedAt, &brand.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		if err = putBrandKeys(ctx, tx, brand); err != nil {
+			return err
+		}
+
+		return renameProducts(ctx, tx, brand)
+	})
+	if err != nil {
+		return nil, brandError("could not update brand", err)
+	}
+
+	return &brand, nil
+}
+
+//writes the name of the brand to its products, recording the new revision and the updated event of each one like
+//any other change of the products
+func renameProducts(ctx context.Context, tx pgx.Tx, brand contract.Brand) error {
+	query := `UPDATE public.product SET brand = $1, updated_by = $3, revision = revision + 1 WHERE brand_id = $2 AND brand <> $1
+		RETURNING ` + productColumns
+
+	rows, err := tx.Query(ctx, query, brand.Name, brand.ID, brand.UpdatedBy)
+	if err != nil {
+		return err
+	}
+
+	var prds []contract.Product
+	for rows.Next() {
+		prd, err := scanProduct(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		prds = append(prds, *prd)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, prd := range prds {
+		if err := insertRevision(ctx, tx, prd, false); err != nil {
+			return err
+		}
+
+		if err := insertEvent(ctx, tx, contract.EventProductUpdated, prd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//DeleteBrand deletes a brand. Returns ErrNotFound when it does not exist, and ErrInUse when products reference it
+func (db *PostgreSQLDB) DeleteBrand(id int64) error {
+	ctx := context.Background()
+
+	err := db.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var inUse bool
+		query := "SELECT EXISTS (SELECT 1 FROM public.product WHERE brand_id = $1)"
+		if err := tx.QueryRow(ctx, query, id).Scan(&inUse); err != nil {
+			return err
+		}
+
+		if inUse {
+			return ErrInUse
+		}
+
+		tag, err := tx.Exec(ctx, "DELETE FROM public.brand WHERE id = $1", id)
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation {
+			//a product referenced the brand while it was deleted
+			return fmt.Errorf("could not delete brand: %w", ErrInUse)
+		}
+		return fmt.Errorf("could not delete brand: %w", err)
+	}
+
+	return nil
+}
+
+//BrandFacets retrieves the brands with the number of their products selected by the status and category of the
+//filter, ordered by number of products. The brands without products are left out
+func (db *PostgreSQLDB) BrandFacets(filter contract.ProductFilter) ([]contract.BrandFacet, error) {
+	query := `SELECT b.id, b.name, b.slug, COUNT(*) FROM public.product p JOIN public.brand b ON b.id = p.brand_id
+		WHERE ($1 = '' OR p.status = $1) AND ($2 = '' OR p.category = $2)
+		GROUP BY b.id ORDER BY COUNT(*) DESC, b.name`
+
+	rows, err := db.pool.Query(context.Background(), query, filter.Status, filter.Category)
+	if err != nil {
+		return nil, fmt.Errorf("could not get brand facets: %v", err)
+	}
+	defer rows.Close()
+
+	facets := make([]contract.BrandFacet, 0)
+	for rows.Next() {
+		var f contract.BrandFacet
+		if err := rows.Scan(&f.ID, &f.Name, &f.Slug, &f.Products); err != nil {
+			return nil, fmt.Errorf("could not get brand facets: %v", err)
+		}
+		facets = append(facets, f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get brand facets: %v", err)
+	}
+
+	return facets, nil
+}
+
+//resolves the brand name of the product with the registry, by name or alias and then by slug, setting the ID
+//and canonical name of the brand. Names not registered yet are registered as new brands. Drafts without brand
+//reference none
+func resolveBrand(ctx context.Context, tx pgx.Tx, prd *contract.Product) error {
+	name := strings.Join(strings.Fields(prd.Brand), " ")
+	if name == "" {
+		prd.BrandID = 0
+		return nil
+	}
+
+	var (
+		key  = brandKey(name)
+		slug = brandSlug(name)
+	)
+
+	query := `SELECT id, name FROM public.brand
+		WHERE id = (SELECT brand_id FROM public.brand_alias WHERE key = $1) OR slug = $2
+		ORDER BY slug = $2 LIMIT 1`
+
+	err := tx.QueryRow(ctx, query, key, slug).Scan(&prd.BrandID, &prd.Brand)
+	if err == nil || !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	//concurrent writes may register the same brand, so the brand is read once it is inserted by any of them
+	query = "INSERT INTO public.brand(name, slug, updated_by) VALUES($1, $2, $3) ON CONFLICT (slug) DO NOTHING"
+	if _, err = tx.Exec(ctx, query, name, slug, prd.UpdatedBy); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, "SELECT id, name FROM public.brand WHERE slug = $1", slug).Scan(&prd.BrandID, &prd.Brand)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO public.brand_alias(key, brand_id) VALUES($1, $2) ON CONFLICT DO NOTHING", key, prd.BrandID)
+
+	return err
+}
+
+//replaces the keys resolving the brand with the ones of its name and aliases
+func putBrandKeys(ctx context.Context, tx pgx.Tx, brand contract.Brand) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM public.brand_alias WHERE brand_id = $1", brand.ID); err != nil {
+		return err
+	}
+
+	for _, name := range append([]string{brand.Name}, brand.Aliases...) {
+		_, err := tx.Exec(ctx, "INSERT INTO public.brand_alias(key, brand_id) VALUES($1, $2)", brandKey(name), brand.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//collapses the spaces of the name and aliases of the brand, leaving out the aliases spelled as the name or as a
+//previous alias, ignoring the case
+func normalizeBrand(brand contract.Brand) contract.Brand {
+	brand.Name = strings.Join(strings.Fields(brand.Name), " ")
+
+	var (
+		aliases = make([]string, 0, len(brand.Aliases))
+		seen    = map[string]bool{brandKey(brand.Name): true}
+	)
+
+	for _, alias := range brand.Aliases {
+		alias = strings.Join(strings.Fields(alias), " ")
+		if key := brandKey(alias); !seen[key] {
+			seen[key] = true
+			aliases = append(aliases, alias)
+		}
+	}
+	brand.Aliases = aliases
+
+	return brand
+}
+
+//lowercase name with its spaces collapsed, which resolves the brand of the name
+func brandKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+//slug of the brand name, with the runs of characters other than lowercase letters and digits replaced by hyphens.
+//Names without any of them get a slug derived from their hash. Matches the slugs of the brand migration
+func brandSlug(name string) string {
+	key := brandKey(name)
+
+	slug := strings.Trim(slugSeparators.ReplaceAllString(key, "-"), "-")
+	if slug == "" {
+		slug = fmt.Sprintf("brand-%x", md5.Sum([]byte(key)))[:len("brand-")+8]
+	}
+
+	return slug
+}
+
+//wraps the error of a brand write, reporting the names and slugs taken by other brands as ErrAlreadyExists
+func brandError(msg string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return fmt.Errorf("%s: %w", msg, ErrAlreadyExists)
+	}
+
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+//scans a row of the brand columns
+func scanBrand(row pgx.Row) (*contract.Brand, error) {
+	brand := &contract.Brand{}
+	err := row.Scan(&brand.ID, &brand.Name, &brand.Slug, &brand.Aliases, &brand.CreatedAt, &brand.UpdatedAt, &brand.UpdatedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(brand.Aliases) == 0 {
+		brand.Aliases = nil
+	}
+
+	return brand, nil
+}
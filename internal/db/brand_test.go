@@ -0,0 +1,117 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestBrands(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	nike, err := db.CreateBrand(contract.Brand{Name: " Nike ", Aliases: []string{"NIKE", "Nike Inc"}, UpdatedBy: "merchandiser"})
+	if err != nil || nike.Name != "Nike" || nike.Slug != "nike" || len(nike.Aliases) != 1 {
+		t.Fatalf("#1: brand must be created. Got: %+v, error: %v", nike, err)
+	}
+
+	if _, err := db.CreateBrand(contract.Brand{Name: "Other", Aliases: []string{"nike  inc"}}); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("#2: alias of another brand must be taken. Got: %v", err)
+	}
+
+	prd := getMockProduct()
+	prd.Brand = "nike   INC"
+	created, err := db.Create(prd)
+	if err != nil || created.BrandID != nike.ID || created.Brand != "Nike" {
+		t.Errorf("#3: brand must be resolved with its alias. Got: %+v, error: %v", created, err)
+	}
+
+	prd.SKU, prd.Brand = "FAL-1000001", "Adidas!"
+	created, err = db.Create(prd)
+	if err != nil || created.BrandID == 0 || created.Brand != "Adidas!" {
+		t.Fatalf("#4: unknown brand must be registered. Got: %+v, error: %v", created, err)
+	}
+
+	prd.SKU, prd.Brand = "FAL-1000002", "adidas"
+	if created, err := db.Create(prd); err != nil || created.Brand != "Adidas!" {
+		t.Errorf("#5: brand must be resolved with its slug. Got: %+v, error: %v", created, err)
+	}
+
+	if prds, err := db.List(contract.ProductFilter{Brand: "NIKE"}); err != nil || len(prds) != 1 {
+		t.Errorf("#6: products of the brand got: %+v, error: %v", prds, err)
+	}
+
+	renamed, err := db.UpdateBrand(contract.Brand{ID: nike.ID, Name: "Nike Sportswear", UpdatedBy: "merchandiser"})
+	if err != nil || renamed.Slug != "nike" || len(renamed.Aliases) != 1 || renamed.Aliases[0] != "Nike" {
+		t.Errorf("#7: former name must be kept as alias. Got: %+v, error: %v", renamed, err)
+	}
+
+	if prd, err := db.Get("FAL-1000000"); err != nil || prd.Brand != "Nike Sportswear" || prd.Revision != 2 || prd.UpdatedBy != "merchandiser" {
+		t.Errorf("#8: products must be renamed. Got: %+v, error: %v", prd, err)
+	}
+
+	//the renamed products get a revision and an updated event like any other change
+	if rev, err := db.Revision("FAL-1000000", 2); err != nil || rev.Product.Brand != "Nike Sportswear" {
+		t.Errorf("#8: revision of the renamed product got: %+v, error: %v", rev, err)
+	}
+
+	events, err := db.PendingEvents(10)
+	if err != nil || len(events) == 0 || events[len(events)-1].SKU != "FAL-1000000" || events[len(events)-1].Payload.Brand != "Nike Sportswear" {
+		t.Errorf("#8: updated event of the renamed product got: %+v, error: %v", events, err)
+	}
+
+	facets, err := db.BrandFacets(contract.ProductFilter{})
+	if err != nil || len(facets) != 2 || facets[0].Name != "Adidas!" || facets[0].Products != 2 {
+		t.Errorf("#9: facets got: %+v, error: %v", facets, err)
+	}
+
+	if err := db.DeleteBrand(nike.ID); !errors.Is(err, ErrInUse) {
+		t.Errorf("#10: brand of products must be in use. Got: %v", err)
+	}
+
+	if err := db.Delete("FAL-1000000", false); err != nil {
+		t.Fatalf("could not delete product: %v", err)
+	}
+
+	if err := db.DeleteBrand(nike.ID); err != nil {
+		t.Errorf("#11: error not expected: %v", err)
+	}
+
+	if _, err := db.Brand(nike.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("#12: deleted brand must not be found. Got: %v", err)
+	}
+
+	if brands, err := db.Brands(); err != nil || len(brands) != 1 {
+		t.Errorf("#13: brands got: %+v, error: %v", brands, err)
+	}
+}
+
+func TestBrandSlug(t *testing.T) {
+	tests := map[string]struct {
+		name     string
+		expected string
+	}{
+		"#1: lowercase":       {name: "Nike", expected: "nike"},
+		"#2: spaces":          {name: "  New   Balance ", expected: "new-balance"},
+		"#3: punctuation":     {name: "Levi's & Co.", expected: "levi-s-co"},
+		"#4: without letters": {name: "***", expected: "brand-8a7ab20e"},
+	}
+
+	for desc, tc := range tests {
+		if got := brandSlug(tc.name); got != tc.expected {
+			t.Errorf("%s:\n Got: %v\n Expected: %v", desc, got, tc.expected)
+		}
+	}
+}
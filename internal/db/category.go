@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/jackc/pgx/v4"
+)
+
+const categorySchemaColumns = "category, attributes, updated_at, updated_by"
+
+//CategorySchema retrieves the schema of the custom attributes of a category. Returns ErrNotFound when the
+//category has no schema
+func (db *PostgreSQLDB) CategorySchema(category string) (*contract.CategorySchema, error) {
+	query := "SELECT " + categorySchemaColumns + " FROM public.category_schema WHERE category = $1"
+
+	schema, err := scanCategorySchema(db.pool.QueryRow(context.Background(), query, category))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get category schema: %v", err)
+	}
+
+	return schema, nil
+}
+
+//CategorySchemas retrieves the schemas of all the categories ordered by category
+func (db *PostgreSQLDB) CategorySchemas() ([]contract.CategorySchema, error) {
+	query := "SELECT " + categorySchemaColumns + " FROM public.category_schema ORDER BY category"
+
+	rows, err := db.pool.Query(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("could not get category schemas: %v", err)
+	}
+	defer rows.Close()
+
+	schemas := make([]contract.CategorySchema, 0)
+	for rows.Next() {
+		schema, err := scanCategorySchema(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not get category schemas: %v", err)
+		}
+		schemas = append(schemas, *schema)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get category schemas: %v", err)
+	}
+
+	return schemas, nil
+}
+
+//PutCategorySchema creates or replaces the schema of a category, reporting if it was created
+func (db *PostgreSQLDB) PutCategorySchema(schema contract.CategorySchema) (*contract.CategorySchema, bool, error) {
+	query := `INSERT INTO public.category_schema(category, attributes, updated_by) VALUES($1, $2, $3)
+		ON CONFLICT (category) DO UPDATE SET attributes = EXCLUDED.attributes, updated_at = NOW(), updated_by = EXCLUDED.updated_by
+		RETURNING updated_at, xmax = 0`
+
+	if schema.Attributes == nil {
+		schema.Attributes = []contract.AttributeDefinition{}
+	}
+
+	var created bool
+	err := db.pool.QueryRow(context.Background(), query, schema.Category, schema.Attributes, schema.UpdatedBy).
+		Scan(&schema.UpdatedAt, &created)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not store category schema: %v", err)
+	}
+
+	return &schema, created, nil
+}
+
+//DeleteCategorySchema deletes the schema of a category. Returns ErrNotFound when the category has no schema, and
+//ErrInUse when products of the category have custom attributes
+func (db *PostgreSQLDB) DeleteCategorySchema(category string) error {
+	ctx := context.Background()
+
+	err := db.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		//the schema is locked, so it cannot be replaced while it is deleted
+		var exists bool
+		err := tx.QueryRow(ctx, "SELECT true FROM public.category_schema WHERE category = $1 FOR UPDATE", category).Scan(&exists)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		var inUse bool
+		query := "SELECT EXISTS (SELECT 1 FROM public.product WHERE category = $1 AND attributes <> '{}')"
+		if err := tx.QueryRow(ctx, query, category).Scan(&inUse); err != nil {
+			return err
+		}
+
+		if inUse {
+			return ErrInUse
+		}
+
+		_, err = tx.Exec(ctx, "DELETE FROM public.category_schema WHERE category = $1", category)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not delete category schema: %w", err)
+	}
+
+	return nil
+}
+
+//scans a row of the category schema columns
+func scanCategorySchema(row pgx.Row) (*contract.CategorySchema, error) {
+	schema := &contract.CategorySchema{}
+
+	err := row.Scan(&schema.Category, &schema.Attributes, &schema.UpdatedAt, &schema.UpdatedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestCategorySchemas(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	min := 20.0
+	schema := contract.CategorySchema{
+		Category: "shoes",
+		Attributes: []contract.AttributeDefinition{
+			{Key: "color", Type: contract.AttributeString, Values: []string{"black", "white"}},
+			{Key: "size", Type: contract.AttributeInteger, Required: true, Min: &min},
+		},
+		UpdatedBy: "merchandiser",
+	}
+
+	stored, created, err := db.PutCategorySchema(schema)
+	if err != nil || !created || stored.UpdatedAt.IsZero() {
+		t.Fatalf("#1: schema must be created. Got: %+v %v, error: %v", stored, created, err)
+	}
+
+	if _, created, err := db.PutCategorySchema(schema); err != nil || created {
+		t.Errorf("#2: schema must be replaced. Got: %v, error: %v", created, err)
+	}
+
+	got, err := db.CategorySchema("shoes")
+	if err != nil || len(got.Attributes) != 2 || *got.Attributes[1].Min != min || got.UpdatedBy != "merchandiser" {
+		t.Errorf("#3: stored schema got: %+v, error: %v", got, err)
+	}
+
+	if _, err := db.CategorySchema("hats"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("#4: unknown category must not be found. Got: %v", err)
+	}
+
+	prd := getMockProduct()
+	prd.Category, prd.Attributes = "shoes", map[string]interface{}{"size": 42, "color": "black"}
+	if _, err := db.Create(prd); err != nil {
+		t.Fatalf("could not create product: %v", err)
+	}
+
+	if err := db.DeleteCategorySchema("shoes"); !errors.Is(err, ErrInUse) {
+		t.Errorf("#5: schema of products with attributes must be in use. Got: %v", err)
+	}
+
+	if err := db.DeleteCategorySchema("hats"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("#6: unknown category must not be found. Got: %v", err)
+	}
+
+	if err := db.Delete(prd.SKU, false); err != nil {
+		t.Fatalf("could not delete product: %v", err)
+	}
+
+	if err := db.DeleteCategorySchema("shoes"); err != nil {
+		t.Errorf("#7: error not expected: %v", err)
+	}
+
+	if schemas, err := db.CategorySchemas(); err != nil || len(schemas) != 0 {
+		t.Errorf("#8: no schemas expected. Got: %+v, error: %v", schemas, err)
+	}
+}
+
+func TestAttributeFilters(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	attrs := []map[string]interface{}{
+		{"color": "black", "size": 40, "waterproof": true},
+		{"color": "white", "size": 42},
+		{"color": "black", "size": 44},
+	}
+	for i, a := range attrs {
+		prd := getMockProduct()
+		prd.SKU = fmt.Sprintf("FAL-100000%d", i)
+		prd.Category, prd.Attributes = "shoes", a
+		if _, err := db.Create(prd); err != nil {
+			t.Fatalf("could not create product: %v", err)
+		}
+	}
+
+	other := getMockProduct()
+	other.SKU, other.Category = "FAL-1000009", "hats"
+	if _, err := db.Create(other); err != nil {
+		t.Fatalf("could not create product: %v", err)
+	}
+
+	min, max := 41.0, 44.0
+
+	tests := map[string]struct {
+		filter       contract.ProductFilter
+		skusExpected []string
+	}{
+		"#1: category": {filter: contract.ProductFilter{Category: "shoes"}, skusExpected: []string{"FAL-1000000", "FAL-1000001", "FAL-1000002"}},
+		"#2: value": {filter: contract.ProductFilter{Category: "shoes", Attributes: []contract.AttributeFilter{{Key: "color", Equals: "black"}}},
+			skusExpected: []string{"FAL-1000000", "FAL-1000002"}},
+		"#3: boolean": {filter: contract.ProductFilter{Category: "shoes", Attributes: []contract.AttributeFilter{{Key: "waterproof", Equals: true}}},
+			skusExpected: []string{"FAL-1000000"}},
+		"#4: range": {filter: contract.ProductFilter{Category: "shoes", Attributes: []contract.AttributeFilter{{Key: "size", Min: &min, Max: &max}}},
+			skusExpected: []string{"FAL-1000001", "FAL-1000002"}},
+		"#5: value and range": {filter: contract.ProductFilter{Category: "shoes", Attributes: []contract.AttributeFilter{{Key: "color", Equals: "black"}, {Key: "size", Min: &min}}},
+			skusExpected: []string{"FAL-1000002"}},
+		"#6: number value": {filter: contract.ProductFilter{Category: "shoes", Attributes: []contract.AttributeFilter{{Key: "size", Equals: 42.0}}},
+			skusExpected: []string{"FAL-1000001"}},
+	}
+
+	for desc, tc := range tests {
+		prds, err := db.List(tc.filter)
+		if err != nil {
+			t.Fatalf("%s: error not expected: %v", desc, err)
+		}
+
+		skus := make([]string, 0)
+		for _, prd := range prds {
+			skus = append(skus, prd.SKU)
+		}
+
+		if fmt.Sprint(skus) != fmt.Sprint(tc.skusExpected) {
+			t.Errorf("%s:\n skus got: %v\n skus expected: %v", desc, skus, tc.skusExpected)
+		}
+	}
+}
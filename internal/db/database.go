@@ -1,15 +1,133 @@
 package db
 
 import (
+	"errors"
+	"time"
+
 	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/sku"
+)
+
+var (
+	//ErrNotFound returned when the requested entity does not exist
+	ErrNotFound = errors.New("not found")
+	//ErrInsufficientStock returned when a stock operation would leave a negative available quantity
+	ErrInsufficientStock = errors.New("insufficient stock")
+	//ErrInvalidState returned when an entity is not in a state that allows the requested operation
+	ErrInvalidState = errors.New("invalid state")
+	//ErrAlreadyExists returned when an entity with the same key is already stored
+	ErrAlreadyExists = errors.New("already exists")
+	//ErrSKURangeExhausted returned when the range of a SKU rule has not enough SKUs left to generate
+	ErrSKURangeExhausted = errors.New("sku range exhausted")
+	//ErrInUse returned when an entity cannot be deleted because other entities depend on it
+	ErrInUse = errors.New("in use")
+	//ErrInStock returned when deleting a product with units on hand or reserved without deleting its stock
+	ErrInStock = errors.New("in stock")
 )
 
 //Database abstraction of database connection
 type Database interface {
-	Create(prd contract.Product) error
+	Create(prd contract.Product) (*contract.Product, error)
 	GetAll() ([]contract.Product, error)
+	List(filter contract.ProductFilter) ([]contract.Product, error)
 	Get(sku string) (*contract.Product, error)
+	GetMany(skus []string) ([]contract.Product, []string, error)
 	Update(prd contract.Product) error
-	Delete(sku string) error
+	UpdateFunc(sku string, fn func(prd *contract.Product) error) (*contract.Product, error)
+	Delete(sku string, cascade bool) error
+	LastModified() (time.Time, error)
 	Close()
 }
+
+//Inventory abstraction of the stock storage
+type Inventory interface {
+	Stock(skus ...string) ([]contract.Stock, error)
+	AdjustStock(sku string, adj contract.StockAdjustment) (*contract.Stock, error)
+	Reserve(sku string, req contract.ReservationRequest) (*contract.Reservation, error)
+	CommitReservation(id int64) (*contract.Reservation, error)
+	ReleaseReservation(id int64) (*contract.Reservation, error)
+	ExpireReservations() (int64, error)
+}
+
+//SKUSequences abstraction of the sequences the SKUs of the new products are generated from
+type SKUSequences interface {
+	NextSKUs(rule *sku.Rule, count int) ([]string, error)
+}
+
+//IdempotencyKeys abstraction of the stored responses of the requests with an idempotency key
+type IdempotencyKeys interface {
+	ClaimIdempotencyKey(key, fingerprint string, ttl time.Duration) (*contract.IdempotentResponse, error)
+	StoreIdempotentResponse(resp contract.IdempotentResponse) error
+	ReleaseIdempotencyKey(key string) error
+	DeleteExpiredIdempotencyKeys() (int64, error)
+}
+
+//Images abstraction of the images uploaded for the products
+type Images interface {
+	CreateImage(img contract.ProductImage) (*contract.ProductImage, error)
+	Images(sku string) ([]contract.ProductImage, error)
+	Image(id int64) (*contract.ProductImage, error)
+	DeleteImage(id int64) error
+	OrphanImages(limit int) ([]contract.ProductImage, error)
+}
+
+//ImageChecks abstraction of the checks of the image URLs referenced by the products
+type ImageChecks interface {
+	DueImageURLs(checkedBefore time.Time, limit int) ([]string, error)
+	RecordImageCheck(check contract.ImageCheck) error
+	DeleteUnusedImageChecks() (int64, error)
+	ImageReport() (*contract.ImageReport, error)
+}
+
+//CategorySchemas abstraction of the schemas of the custom attributes of the categories
+type CategorySchemas interface {
+	CategorySchema(category string) (*contract.CategorySchema, error)
+	CategorySchemas() ([]contract.CategorySchema, error)
+	PutCategorySchema(schema contract.CategorySchema) (*contract.CategorySchema, bool, error)
+	DeleteCategorySchema(category string) error
+}
+
+//Brands abstraction of the brand registry
+type Brands interface {
+	Brands() ([]contract.Brand, error)
+	Brand(id int64) (*contract.Brand, error)
+	CreateBrand(brand contract.Brand) (*contract.Brand, error)
+	UpdateBrand(brand contract.Brand) (*contract.Brand, error)
+	DeleteBrand(id int64) error
+	BrandFacets(filter contract.ProductFilter) ([]contract.BrandFacet, error)
+}
+
+//Revisions abstraction of the immutable revisions of the products
+type Revisions interface {
+	Revisions(sku string) ([]contract.ProductRevision, error)
+	Revision(sku string, revision int) (*contract.ProductRevision, error)
+	RevisionAsOf(sku string, at time.Time) (*contract.ProductRevision, error)
+}
+
+//Outbox abstraction of the product events waiting to be delivered
+type Outbox interface {
+	PendingEvents(limit int) ([]contract.ProductEvent, error)
+	MarkDelivered(id int64) error
+	MarkFailed(id int64, reason string, retryAt time.Time) error
+}
+
+//EventLog abstraction of the written product events, read in order by the event streams
+type EventLog interface {
+	EventsAfter(id int64, limit int) ([]contract.ProductEvent, error)
+	LastEventID() (int64, error)
+}
+
+//Webhooks abstraction of the webhook subscriptions and their deliveries
+type Webhooks interface {
+	CreateSubscription(sub contract.WebhookSubscription) (*contract.WebhookSubscription, error)
+	Subscriptions() ([]contract.WebhookSubscription, error)
+	Subscription(id int64) (*contract.WebhookSubscription, error)
+	DeleteSubscription(id int64) error
+	EnqueueDeliveries(event contract.ProductEvent, subscriptionIDs []int64) error
+	DueDeliveries(limit int) ([]contract.WebhookDelivery, error)
+	MarkDeliverySucceeded(id int64) error
+	MarkDeliveryFailed(id int64, reason string, retryAt *time.Time) error
+	Deliveries(subscriptionID int64, status string) ([]contract.WebhookDelivery, error)
+	ReplayDelivery(id int64) error
+	ReplayDeadDeliveries(subscriptionID int64) (int64, error)
+}
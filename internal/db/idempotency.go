@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/jackc/pgx/v4"
+)
+
+//idempotencyClaimTimeout time after which a request that did not store its response is considered abandoned,
+//so its key can be claimed again
+const idempotencyClaimTimeout = time.Minute
+
+//ClaimIdempotencyKey claims the key for a new request and keeps it for ttl. Returns nil when the key is claimed,
+//or the response of the request that claimed it before, whose status is 0 while it is in progress. Expired keys
+//and the keys of abandoned requests are claimed again
+func (db *PostgreSQLDB) ClaimIdempotencyKey(key, fingerprint string, ttl time.Duration) (*contract.IdempotentResponse, error) {
+	claim := `INSERT INTO public.idempotency_key(key, fingerprint, expires_at) VALUES($1, $2, NOW() + $3 * INTERVAL '1 millisecond')
+		ON CONFLICT (key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, status = 0, headers = NULL, body = NULL,
+			created_at = NOW(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_key.expires_at <= NOW()
+			OR (idempotency_key.status = 0 AND idempotency_key.created_at <= NOW() - $4 * INTERVAL '1 millisecond')
+		RETURNING key`
+
+	query := "SELECT key, fingerprint, status, headers, body, expires_at FROM public.idempotency_key WHERE key = $1"
+
+	var (
+		ctx     = context.Background()
+		claimed string
+	)
+
+	//the stored response may expire and be deleted between both queries, so the key is claimed again
+	for attempt := 0; attempt < 2; attempt++ {
+		err := db.pool.QueryRow(ctx, claim, key, fingerprint, ttl.Milliseconds(), idempotencyClaimTimeout.Milliseconds()).Scan(&claimed)
+		if err == nil {
+			return nil, nil
+		}
+
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("could not claim idempotency key: %v", err)
+		}
+
+		resp := &contract.IdempotentResponse{}
+		err = db.pool.QueryRow(ctx, query, key).Scan(&resp.Key, &resp.Fingerprint, &resp.Status, &resp.Header, &resp.Body, &resp.ExpiresAt)
+		if err == nil {
+			return resp, nil
+		}
+
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("could not get idempotent response: %v", err)
+		}
+	}
+
+	return nil, fmt.Errorf("could not claim idempotency key %s", key)
+}
+
+//StoreIdempotentResponse stores the response of the request that claimed the key
+func (db *PostgreSQLDB) StoreIdempotentResponse(resp contract.IdempotentResponse) error {
+	query := "UPDATE public.idempotency_key SET status = $2, headers = $3, body = $4 WHERE key = $1"
+
+	_, err := db.pool.Exec(context.Background(), query, resp.Key, resp.Status, resp.Header, resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not store idempotent response: %v", err)
+	}
+
+	return nil
+}
+
+//ReleaseIdempotencyKey deletes the key, so the request can be retried as a new one
+func (db *PostgreSQLDB) ReleaseIdempotencyKey(key string) error {
+	_, err := db.pool.Exec(context.Background(), "DELETE FROM public.idempotency_key WHERE key = $1", key)
+	if err != nil {
+		return fmt.Errorf("could not release idempotency key: %v", err)
+	}
+
+	return nil
+}
+
+//DeleteExpiredIdempotencyKeys deletes the keys whose responses are no longer replayed, returning how many were deleted
+func (db *PostgreSQLDB) DeleteExpiredIdempotencyKeys() (int64, error) {
+	tag, err := db.pool.Exec(context.Background(), "DELETE FROM public.idempotency_key WHERE expires_at <= NOW()")
+	if err != nil {
+		return 0, fmt.Errorf("could not delete expired idempotency keys: %v", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	if resp, err := db.ClaimIdempotencyKey("key", "fingerprint", time.Hour); err != nil || resp != nil {
+		t.Fatalf("#1: the key should be claimed. Got: %+v, %v", resp, err)
+	}
+
+	resp, err := db.ClaimIdempotencyKey("key", "other", time.Hour)
+	if err != nil || resp == nil || resp.Status != 0 || resp.Fingerprint != "fingerprint" {
+		t.Errorf("#2: the request in progress expected. Got: %+v, %v", resp, err)
+	}
+
+	stored := contract.IdempotentResponse{Key: "key", Status: 201, Header: map[string]string{"Location": "/product/FAL-1000000"}, Body: []byte(`{}`)}
+	if err := db.StoreIdempotentResponse(stored); err != nil {
+		t.Fatalf("#3: error not expected: %v", err)
+	}
+
+	resp, err = db.ClaimIdempotencyKey("key", "fingerprint", time.Hour)
+	if err != nil || resp == nil || resp.Status != 201 || resp.Header["Location"] != "/product/FAL-1000000" || string(resp.Body) != "{}" {
+		t.Errorf("#4: the stored response expected. Got: %+v, %v", resp, err)
+	}
+
+	if err := db.ReleaseIdempotencyKey("key"); err != nil {
+		t.Fatalf("#5: error not expected: %v", err)
+	}
+
+	//an expired key is claimed again
+	if resp, err := db.ClaimIdempotencyKey("key", "fingerprint", -time.Second); err != nil || resp != nil {
+		t.Errorf("#6: the released key should be claimed. Got: %+v, %v", resp, err)
+	}
+
+	if resp, err := db.ClaimIdempotencyKey("key", "other", time.Hour); err != nil || resp != nil {
+		t.Errorf("#7: the expired key should be claimed. Got: %+v, %v", resp, err)
+	}
+
+	if n, err := db.DeleteExpiredIdempotencyKeys(); err != nil || n != 0 {
+		t.Errorf("#8: no expired keys expected. Got: %d, %v", n, err)
+	}
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/jackc/pgx/v4"
+)
+
+const imageColumns = "id, sku, object_key, thumbnail_key, content_type, width, height, size, created_at"
+
+//CreateImage inserts the image of a product
+func (db *PostgreSQLDB) CreateImage(img contract.ProductImage) (*contract.ProductImage, error) {
+	query := `INSERT INTO public.product_image(sku, object_key, thumbnail_key, content_type, width, height, size)
+		VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING ` + imageColumns
+
+	row := db.pool.QueryRow(context.Background(), query,
+		img.SKU, img.Key, img.ThumbnailKey, img.ContentType, img.Width, img.Height, img.Size)
+
+	created, err := scanImage(row)
+	if err != nil {
+		return nil, fmt.Errorf("could not create image: %v", err)
+	}
+
+	return created, nil
+}
+
+//Images retrieves the images of a product in upload order
+func (db *PostgreSQLDB) Images(sku string) ([]contract.ProductImage, error) {
+	query := "SELECT " + imageColumns + " FROM public.product_image WHERE sku = $1 ORDER BY id"
+	return db.queryImages(query, sku)
+}
+
+//Image retrieves an image by its id
+func (db *PostgreSQLDB) Image(id int64) (*contract.ProductImage, error) {
+	query := "SELECT " + imageColumns + " FROM public.product_image WHERE id = $1"
+
+	img, err := scanImage(db.pool.QueryRow(context.Background(), query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get image: %v", err)
+	}
+
+	return img, nil
+}
+
+//DeleteImage deletes an image. Its files must be removed from the storage first
+func (db *PostgreSQLDB) DeleteImage(id int64) error {
+	tag, err := db.pool.Exec(context.Background(), "DELETE FROM public.product_image WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("could not delete image: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//OrphanImages retrieves up to limit images whose product was deleted
+func (db *PostgreSQLDB) OrphanImages(limit int) ([]contract.ProductImage, error) {
+	query := `SELECT ` + imageColumns + ` FROM public.product_image i
+		WHERE NOT EXISTS (SELECT 1 FROM public.product p WHERE p.sku = i.sku)
+		ORDER BY id LIMIT $1`
+	return db.queryImages(query, limit)
+}
+
+func (db *PostgreSQLDB) queryImages(query string, args ...interface{}) ([]contract.ProductImage, error) {
+	rows, err := db.pool.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not get images: %v", err)
+	}
+	defer rows.Close()
+
+	imgs := make([]contract.ProductImage, 0)
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not get images: %v", err)
+		}
+		imgs = append(imgs, *img)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get images: %v", err)
+	}
+
+	return imgs, nil
+}
+
+func scanImage(row pgx.Row) (*contract.ProductImage, error) {
+	img := &contract.ProductImage{}
+
+	err := row.Scan(&img.ID, &img.SKU, &img.Key, &img.ThumbnailKey, &img.ContentType, &img.Width, &img.Height, &img.Size, &img.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestImages(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	prd := contract.Product{SKU: "FAL-1000000", Name: "name", Brand: "brand", Size: 10, Price: 100, ImageURL: "http://a"}
+	if _, err := db.Create(prd); err != nil {
+		t.Fatalf("could not create product: %v", err)
+	}
+
+	img := contract.ProductImage{SKU: prd.SKU, Key: "a1.png", ThumbnailKey: "a1_thumb.png", ContentType: "image/png", Width: 400, Height: 200, Size: 1024}
+
+	created, err := db.CreateImage(img)
+	if err != nil {
+		t.Fatalf("could not create image: %v", err)
+	}
+
+	if created.ID == 0 || created.Key != img.Key || created.CreatedAt.IsZero() {
+		t.Errorf("#1: created image got: %+v", created)
+	}
+
+	if imgs, err := db.Images(prd.SKU); err != nil || len(imgs) != 1 || imgs[0].ThumbnailKey != img.ThumbnailKey {
+		t.Errorf("#2: one image expected. Got: %+v, error: %v", imgs, err)
+	}
+
+	if orphans, err := db.OrphanImages(10); err != nil || len(orphans) != 0 {
+		t.Errorf("#3: the image of an existing product is not an orphan. Got: %+v, error: %v", orphans, err)
+	}
+
+	if err := db.Delete(prd.SKU, false); err != nil {
+		t.Fatalf("could not delete product: %v", err)
+	}
+
+	if orphans, err := db.OrphanImages(10); err != nil || len(orphans) != 1 || orphans[0].ID != created.ID {
+		t.Errorf("#4: the image of a deleted product is an orphan. Got: %+v, error: %v", orphans, err)
+	}
+
+	if err := db.DeleteImage(created.ID); err != nil {
+		t.Errorf("#5: error not expected: %v", err)
+	}
+
+	if _, err := db.Image(created.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("#6: image must be deleted. Got: %v", err)
+	}
+}
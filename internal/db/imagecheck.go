@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+//imageURLs URLs of the main and alternative images of every product
+const imageURLs = `SELECT image_url AS url FROM public.product
+	UNION SELECT unnest(alt_images) FROM public.product`
+
+//DueImageURLs retrieves up to limit image URLs referenced by the products that were never checked or were last checked
+//before checkedBefore, starting with the oldest checks
+func (db *PostgreSQLDB) DueImageURLs(checkedBefore time.Time, limit int) ([]string, error) {
+	query := `SELECT u.url FROM (` + imageURLs + `) u
+		LEFT JOIN public.image_check c ON c.url = u.url
+		WHERE u.url <> '' AND (c.checked_at IS NULL OR c.checked_at < $1)
+		ORDER BY c.checked_at NULLS FIRST, u.url LIMIT $2`
+
+	rows, err := db.pool.Query(context.Background(), query, checkedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get due image urls: %v", err)
+	}
+	defer rows.Close()
+
+	urls := make([]string, 0)
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, fmt.Errorf("could not get due image urls: %v", err)
+		}
+		urls = append(urls, url)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get due image urls: %v", err)
+	}
+
+	return urls, nil
+}
+
+//RecordImageCheck stores the result of checking an image URL, counting its consecutive failures
+func (db *PostgreSQLDB) RecordImageCheck(check contract.ImageCheck) error {
+	query := `INSERT INTO public.image_check(url, ok, status, error, failures, checked_at, failing_since)
+		VALUES($1, $2, $3, NULLIF($4, ''), CASE WHEN $2 THEN 0 ELSE 1 END, $5, CASE WHEN $2 THEN NULL ELSE $5::TIMESTAMPTZ END)
+		ON CONFLICT (url) DO UPDATE SET ok = EXCLUDED.ok, status = EXCLUDED.status, error = EXCLUDED.error,
+			failures = CASE WHEN EXCLUDED.ok THEN 0 ELSE image_check.failures + 1 END,
+			checked_at = EXCLUDED.checked_at,
+			failing_since = CASE WHEN EXCLUDED.ok THEN NULL ELSE COALESCE(image_check.failing_since, EXCLUDED.checked_at) END`
+
+	_, err := db.pool.Exec(context.Background(), query, check.URL, check.OK, check.Status, check.Error, check.CheckedAt)
+	if err != nil {
+		return fmt.Errorf("could not record image check: %v", err)
+	}
+
+	return nil
+}
+
+//DeleteUnusedImageChecks deletes the checks of the URLs no product references anymore
+func (db *PostgreSQLDB) DeleteUnusedImageChecks() (int64, error) {
+	query := `DELETE FROM public.image_check c WHERE NOT EXISTS (SELECT 1 FROM (` + imageURLs + `) u WHERE u.url = c.url)`
+
+	tag, err := db.pool.Exec(context.Background(), query)
+	if err != nil {
+		return 0, fmt.Errorf("could not delete unused image checks: %v", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+//ImageReport counts the checked and broken image URLs and lists the products with broken images
+func (db *PostgreSQLDB) ImageReport() (*contract.ImageReport, error) {
+	ctx := context.Background()
+	report := &contract.ImageReport{Products: []contract.ProductBrokenImages{}}
+
+	err := db.pool.QueryRow(ctx, "SELECT COUNT(*), COUNT(*) FILTER (WHERE NOT ok) FROM public.image_check").
+		Scan(&report.CheckedURLs, &report.BrokenURLs)
+	if err != nil {
+		return nil, fmt.Errorf("could not get image report: %v", err)
+	}
+
+	query := `SELECT p.sku, c.url, c.ok, c.status, COALESCE(c.error, ''), c.failures, c.checked_at, c.failing_since
+		FROM public.product p
+		CROSS JOIN LATERAL (SELECT p.image_url AS url UNION SELECT unnest(p.alt_images)) u
+		JOIN public.image_check c ON c.url = u.url
+		WHERE NOT c.ok
+		ORDER BY p.sku, c.url`
+
+	rows, err := db.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not get image report: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			sku   string
+			check contract.ImageCheck
+		)
+
+		err := rows.Scan(&sku, &check.URL, &check.OK, &check.Status, &check.Error, &check.Failures, &check.CheckedAt, &check.FailingSince)
+		if err != nil {
+			return nil, fmt.Errorf("could not get image report: %v", err)
+		}
+
+		n := len(report.Products)
+		if n == 0 || report.Products[n-1].SKU != sku {
+			report.Products = append(report.Products, contract.ProductBrokenImages{SKU: sku})
+			n++
+		}
+		report.Products[n-1].Images = append(report.Products[n-1].Images, check)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get image report: %v", err)
+	}
+
+	return report, nil
+}
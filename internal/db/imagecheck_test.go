@@ -0,0 +1,74 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestImageChecks(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	prd := contract.Product{SKU: "FAL-1000000", Name: "name", Brand: "brand", Size: 10, Price: 100, ImageURL: "http://a", AltImages: []string{"http://b"}}
+	if _, err := db.Create(prd); err != nil {
+		t.Fatalf("could not create product: %v", err)
+	}
+
+	now := time.Now()
+
+	if urls, err := db.DueImageURLs(now, 10); err != nil || len(urls) != 2 {
+		t.Fatalf("#1: two due urls expected. Got: %v, error: %v", urls, err)
+	}
+
+	checks := []contract.ImageCheck{
+		{URL: "http://a", OK: true, Status: 200, CheckedAt: now},
+		{URL: "http://b", Status: 404, Error: "unexpected status 404", CheckedAt: now},
+		{URL: "http://b", Error: "connection refused", CheckedAt: now.Add(time.Second)},
+	}
+	for _, check := range checks {
+		if err := db.RecordImageCheck(check); err != nil {
+			t.Fatalf("could not record check: %v", err)
+		}
+	}
+
+	if urls, err := db.DueImageURLs(now, 10); err != nil || len(urls) != 0 {
+		t.Errorf("#2: no due urls expected. Got: %v, error: %v", urls, err)
+	}
+
+	report, err := db.ImageReport()
+	if err != nil {
+		t.Fatalf("could not get report: %v", err)
+	}
+
+	if report.CheckedURLs != 2 || report.BrokenURLs != 1 || len(report.Products) != 1 {
+		t.Fatalf("#3: one product with a broken image expected. Got: %+v", report)
+	}
+
+	broken := report.Products[0].Images
+	if len(broken) != 1 || broken[0].URL != "http://b" || broken[0].Failures != 2 || broken[0].Error != "connection refused" ||
+		broken[0].FailingSince == nil || !broken[0].FailingSince.Before(broken[0].CheckedAt) {
+		t.Errorf("#4: broken image got: %+v", broken)
+	}
+
+	prd.AltImages = nil
+	if err := db.Update(prd); err != nil {
+		t.Fatalf("could not update product: %v", err)
+	}
+
+	if n, err := db.DeleteUnusedImageChecks(); err != nil || n != 1 {
+		t.Errorf("#5: the check of the removed image should be deleted. Got: %v, error: %v", n, err)
+	}
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	pgCheckViolation      = "23514"
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+)
+
+//releases the stock held by pending reservations whose expiration time has passed.
+//$1 and $2 optionally restrict the sweep to a single sku and warehouse
+const expireReservationsQuery = `
+WITH expired AS (
+	UPDATE public.reservation SET status = 'expired'
+	WHERE status = 'pending' AND expires_at <= NOW()
+		AND ($1::TEXT IS NULL OR sku = $1) AND ($2::TEXT IS NULL OR warehouse = $2)
+	RETURNING sku, warehouse, quantity
+), totals AS (
+	SELECT sku, warehouse, SUM(quantity) AS quantity FROM expired GROUP BY sku, warehouse
+)
+UPDATE public.stock s SET reserved = s.reserved - t.quantity
+FROM totals t WHERE s.sku = t.sku AND s.warehouse = t.warehouse`
+
+//Stock retrieves the stock levels per warehouse of the given SKUs
+func (db *PostgreSQLDB) Stock(skus ...string) ([]contract.Stock, error) {
+	query := "SELECT sku, warehouse, on_hand, reserved, updated_at FROM public.stock WHERE sku = ANY($1) ORDER BY sku, warehouse"
+
+	rows, err := db.pool.Query(context.Background(), query, skus)
+	if err != nil {
+		return nil, fmt.Errorf("could not get stock: %v", err)
+	}
+	defer rows.Close()
+
+	stocks := make([]contract.Stock, 0)
+	for rows.Next() {
+		s := contract.Stock{}
+		if err = rows.Scan(&s.SKU, &s.Warehouse, &s.OnHand, &s.Reserved, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("could not get stock: %v", err)
+		}
+		s.Available = s.OnHand - s.Reserved
+		stocks = append(stocks, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get stock: %v", err)
+	}
+
+	return stocks, nil
+}
+
+//AdjustStock adds the delta to the on-hand quantity of a warehouse, creating the stock entry if needed.
+//The on-hand quantity can never drop below the reserved quantity
+func (db *PostgreSQLDB) AdjustStock(sku string, adj contract.StockAdjustment) (*contract.Stock, error) {
+	query := `INSERT INTO public.stock(sku, warehouse, on_hand) VALUES($1, $2, $3)
+		ON CONFLICT (sku, warehouse) DO UPDATE SET on_hand = public.stock.on_hand + EXCLUDED.on_hand
+		RETURNING on_hand, reserved, updated_at`
+
+	s := contract.Stock{SKU: sku, Warehouse: adj.Warehouse}
+
+	err := db.pool.QueryRow(context.Background(), query, sku, adj.Warehouse, adj.Delta).Scan(&s.OnHand, &s.Reserved, &s.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not adjust stock: %w", mapStockError(err))
+	}
+	s.Available = s.OnHand - s.Reserved
+
+	return &s, nil
+}
+
+//Reserve holds the requested quantity of a product in a warehouse. The availability check and the
+//reservation are done in a single conditional update, so concurrent requests can never oversell
+func (db *PostgreSQLDB) Reserve(sku string, req contract.ReservationRequest) (*contract.Reservation, error) {
+	ctx := context.Background()
+	rsv := &contract.Reservation{SKU: sku, Warehouse: req.Warehouse, Quantity: req.Quantity}
+
+	err := db.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		//gives back the stock of expired reservations before checking the availability
+		if _, err := tx.Exec(ctx, expireReservationsQuery, sku, req.Warehouse); err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(ctx,
+			"UPDATE public.stock SET reserved = reserved + $3 WHERE sku = $1 AND warehouse = $2 AND on_hand - reserved >= $3",
+			sku, req.Warehouse, req.Quantity)
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() == 0 {
+			return ErrInsufficientStock
+		}
+
+		query := `INSERT INTO public.reservation(sku, warehouse, quantity, expires_at)
+			VALUES($1, $2, $3, NOW() + make_interval(secs => $4))
+			RETURNING id, status, expires_at, created_at`
+
+		return tx.QueryRow(ctx, query, sku, req.Warehouse, req.Quantity, req.TTLSeconds).
+			Scan(&rsv.ID, &rsv.Status, &rsv.ExpiresAt, &rsv.CreatedAt)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not reserve stock: %w", mapStockError(err))
+	}
+
+	return rsv, nil
+}
+
+//CommitReservation consumes the reserved quantity from the on-hand stock
+func (db *PostgreSQLDB) CommitReservation(id int64) (*contract.Reservation, error) {
+	rsv, err := db.closeReservation(id, contract.ReservationCommitted,
+		"UPDATE public.stock SET on_hand = on_hand - $3, reserved = reserved - $3 WHERE sku = $1 AND warehouse = $2")
+	if err != nil {
+		return nil, fmt.Errorf("could not commit reservation: %w", err)
+	}
+
+	return rsv, nil
+}
+
+//ReleaseReservation gives back the reserved quantity to the available stock
+func (db *PostgreSQLDB) ReleaseReservation(id int64) (*contract.Reservation, error) {
+	rsv, err := db.closeReservation(id, contract.ReservationReleased,
+		"UPDATE public.stock SET reserved = reserved - $3 WHERE sku = $1 AND warehouse = $2")
+	if err != nil {
+		return nil, fmt.Errorf("could not release reservation: %w", err)
+	}
+
+	return rsv, nil
+}
+
+//ExpireReservations releases every pending reservation whose expiration time has passed
+func (db *PostgreSQLDB) ExpireReservations() (int64, error) {
+	tag, err := db.pool.Exec(context.Background(), expireReservationsQuery, nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not expire reservations: %v", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+//moves a pending reservation to its final status applying stockQuery to the reserved stock.
+//stockQuery receives the sku, warehouse and quantity of the reservation
+func (db *PostgreSQLDB) closeReservation(id int64, status, stockQuery string) (*contract.Reservation, error) {
+	ctx := context.Background()
+	rsv := &contract.Reservation{ID: id}
+
+	err := db.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		query := `SELECT sku, warehouse, quantity, status, expires_at, created_at, expires_at <= NOW()
+			FROM public.reservation WHERE id = $1 FOR UPDATE`
+
+		var expired bool
+		err := tx.QueryRow(ctx, query, id).
+			Scan(&rsv.SKU, &rsv.Warehouse, &rsv.Quantity, &rsv.Status, &rsv.ExpiresAt, &rsv.CreatedAt, &expired)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		if rsv.Status != contract.ReservationPending {
+			return ErrInvalidState
+		}
+
+		//an expired reservation can only be released
+		if expired && status == contract.ReservationCommitted {
+			return ErrInvalidState
+		}
+
+		if _, err = tx.Exec(ctx, stockQuery, rsv.SKU, rsv.Warehouse, rsv.Quantity); err != nil {
+			return err
+		}
+
+		if _, err = tx.Exec(ctx, "UPDATE public.reservation SET status = $2 WHERE id = $1", id, status); err != nil {
+			return err
+		}
+
+		rsv.Status = status
+
+		return nil
+	})
+	if err != nil {
+		return nil, mapStockError(err)
+	}
+
+	return rsv, nil
+}
+
+//translates constraint violations of the stock tables into package errors
+func mapStockError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgCheckViolation:
+			return ErrInsufficientStock
+		case pgForeignKeyViolation:
+			return ErrNotFound
+		}
+	}
+
+	return err
+}
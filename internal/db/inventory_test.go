@@ -0,0 +1,161 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestAdjustStock(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	db.Create(getMockProduct())
+
+	tests := []struct {
+		desc           string
+		sku            string
+		delta          int
+		errExpected    error
+		onHandExpected int
+	}{
+		{desc: "#1: unknown product", sku: "FAL-123", delta: 10, errExpected: ErrNotFound},
+		{desc: "#2: new stock entry", sku: "FAL-1000000", delta: 10, onHandExpected: 10},
+		{desc: "#3: existing stock entry", sku: "FAL-1000000", delta: -4, onHandExpected: 6},
+		{desc: "#4: negative stock", sku: "FAL-1000000", delta: -7, errExpected: ErrInsufficientStock},
+	}
+
+	for _, tc := range tests {
+		stock, err := db.AdjustStock(tc.sku, contract.StockAdjustment{Warehouse: "main", Delta: tc.delta})
+		if !errors.Is(err, tc.errExpected) {
+			t.Errorf("%s:\n Error expected: %v\n Got error: %v", tc.desc, tc.errExpected, err)
+			continue
+		}
+
+		if stock != nil && stock.OnHand != tc.onHandExpected {
+			t.Errorf("%s:\n On hand expected: %v\n Got: %v", tc.desc, tc.onHandExpected, stock.OnHand)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	db.Create(getMockProduct())
+	db.AdjustStock("FAL-1000000", contract.StockAdjustment{Warehouse: "main", Delta: 10})
+
+	//20 concurrent reservations of one unit over a stock of 10 units
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reserved int
+	)
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := db.Reserve("FAL-1000000", contract.ReservationRequest{Warehouse: "main", Quantity: 1, TTLSeconds: 60})
+			if err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrInsufficientStock) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reserved != 10 {
+		t.Errorf("#1: stock oversold. Reservations expected: 10. Got: %v", reserved)
+	}
+
+	stocks, err := db.Stock("FAL-1000000")
+	if err != nil {
+		t.Fatalf("could not get stock: %v", err)
+	}
+
+	if len(stocks) != 1 || stocks[0].Available != 0 {
+		t.Errorf("#2: no available stock expected: %+v", stocks)
+	}
+}
+
+func TestCloseReservation(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	db.Create(getMockProduct())
+	db.AdjustStock("FAL-1000000", contract.StockAdjustment{Warehouse: "main", Delta: 10})
+
+	committed, _ := db.Reserve("FAL-1000000", contract.ReservationRequest{Warehouse: "main", Quantity: 3, TTLSeconds: 60})
+	released, _ := db.Reserve("FAL-1000000", contract.ReservationRequest{Warehouse: "main", Quantity: 2, TTLSeconds: 60})
+	expired, _ := db.Reserve("FAL-1000000", contract.ReservationRequest{Warehouse: "main", Quantity: 5, TTLSeconds: 0})
+
+	if _, err := db.CommitReservation(committed.ID); err != nil {
+		t.Errorf("#1: commit: error not expected: %v", err)
+	}
+
+	if _, err := db.ReleaseReservation(released.ID); err != nil {
+		t.Errorf("#2: release: error not expected: %v", err)
+	}
+
+	if _, err := db.CommitReservation(released.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("#3: commit released reservation: invalid state expected. Got: %v", err)
+	}
+
+	if _, err := db.CommitReservation(expired.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("#4: commit expired reservation: invalid state expected. Got: %v", err)
+	}
+
+	if _, err := db.ReleaseReservation(-1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("#5: unknown reservation: not found expected. Got: %v", err)
+	}
+
+	n, err := db.ExpireReservations()
+	if err != nil || n != 1 {
+		t.Errorf("#6: one expired reservation expected. Got: %v, error: %v", n, err)
+	}
+
+	stocks, _ := db.Stock("FAL-1000000")
+	if len(stocks) != 1 || stocks[0].OnHand != 7 || stocks[0].Reserved != 0 {
+		t.Errorf("#7: stock different than expected: %+v", stocks)
+	}
+}
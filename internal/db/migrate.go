@@ -0,0 +1,178 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/garciacer87/product-api/sql/postgresql"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	//registers the pgx driver of database/sql, so the migrations connect like the application
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/sirupsen/logrus"
+)
+
+//ErrSchemaVersion returned when the schema of the database is not the one expected by the application
+var ErrSchemaVersion = errors.New("unexpected schema version")
+
+//MigrationStatus version of the schema applied to the database and the latest one known by the application
+type MigrationStatus struct {
+	//Version 0 means that no migration was applied
+	Version uint
+	//Dirty reports that the last migration failed and must be fixed manually
+	Dirty  bool
+	Latest uint
+}
+
+//Migrator applies the schema migrations embedded in the binary. Every change of the schema holds an
+//advisory lock, so the replicas of the application can migrate the database on start without racing
+type Migrator struct {
+	m      *migrate.Migrate
+	latest uint
+}
+
+//NewMigrator creates a migrator of the database. lockTimeout limits how long it waits for another replica migrating the database
+func NewMigrator(dbURI string, lockTimeout time.Duration) (*Migrator, error) {
+	src, err := iofs.New(postgresql.Migrations, ".")
+	if err != nil {
+		return nil, fmt.Errorf("could not read the migrations: %v", err)
+	}
+
+	latest, err := latestVersion(src)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the migrations: %v", err)
+	}
+
+	conn, err := sql.Open("pgx", dbURI)
+	if err != nil {
+		return nil, fmt.Errorf("could not create database connection: %v", err)
+	}
+
+	driver, err := postgres.WithInstance(conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not create database connection: %v", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not create migrator: %v", err)
+	}
+
+	m.LockTimeout = lockTimeout
+	m.Log = migrateLogger{}
+
+	return &Migrator{m: m, latest: latest}, nil
+}
+
+//Up applies the pending migrations
+func (mg *Migrator) Up() error {
+	if err := mg.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("could not apply the migrations: %w", err)
+	}
+
+	return nil
+}
+
+//Down reverts the last steps migrations, or all of them when steps is not positive
+func (mg *Migrator) Down(steps int) error {
+	revert := mg.m.Down
+	if steps > 0 {
+		revert = func() error { return mg.m.Steps(-steps) }
+	}
+
+	if err := revert(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("could not revert the migrations: %w", err)
+	}
+
+	return nil
+}
+
+//Force sets the schema version without running any migration and clears the dirty state. Used to recover from a failed migration
+func (mg *Migrator) Force(version int) error {
+	if err := mg.m.Force(version); err != nil {
+		return fmt.Errorf("could not force the schema version: %w", err)
+	}
+
+	return nil
+}
+
+//Status retrieves the schema version of the database
+func (mg *Migrator) Status() (MigrationStatus, error) {
+	status := MigrationStatus{Latest: mg.latest}
+
+	version, dirty, err := mg.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return status, fmt.Errorf("could not get the schema version: %v", err)
+	}
+
+	status.Version, status.Dirty = version, dirty
+
+	return status, nil
+}
+
+//CheckVersion returns ErrSchemaVersion unless the database is at the latest schema version known by the application
+func (mg *Migrator) CheckVersion() error {
+	status, err := mg.Status()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case status.Dirty:
+		return fmt.Errorf("%w: migration %d failed and must be fixed manually", ErrSchemaVersion, status.Version)
+	case status.Version > status.Latest:
+		return fmt.Errorf("%w: schema version %d is newer than the latest known version %d", ErrSchemaVersion, status.Version, status.Latest)
+	case status.Version < status.Latest:
+		return fmt.Errorf("%w: schema version %d is older than the expected version %d. The migrations must be applied", ErrSchemaVersion, status.Version, status.Latest)
+	}
+
+	return nil
+}
+
+//Close closes the connection of the migrator
+func (mg *Migrator) Close() error {
+	srcErr, dbErr := mg.m.Close()
+	if srcErr != nil {
+		return srcErr
+	}
+
+	return dbErr
+}
+
+//retrieves the version of the last migration of the source
+func latestVersion(src source.Driver) (uint, error) {
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+//logs the progress of the migrations
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...interface{}) {
+	logrus.Infof(format, v...)
+}
+
+func (migrateLogger) Verbose() bool {
+	return false
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/sql/postgresql"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+func TestLatestVersion(t *testing.T) {
+	src, err := iofs.New(postgresql.Migrations, ".")
+	if err != nil {
+		t.Fatalf("could not read the embedded migrations: %v", err)
+	}
+
+	ups, _ := fs.Glob(postgresql.Migrations, "*.up.sql")
+	downs, _ := fs.Glob(postgresql.Migrations, "*.down.sql")
+	if len(ups) == 0 || len(ups) != len(downs) {
+		t.Fatalf("every migration must have an up and a down file. Up: %d, down: %d", len(ups), len(downs))
+	}
+
+	latest, err := latestVersion(src)
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	//migrations are numbered sequentially
+	if latest != uint(len(ups)) {
+		t.Errorf("latest version got: %d\n expected: %d", latest, len(ups))
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	mg, err := NewMigrator(dbURI, time.Minute)
+	if err != nil {
+		t.Fatalf("could not create migrator: %v", err)
+	}
+	defer mg.Close()
+
+	if err := mg.CheckVersion(); !errors.Is(err, ErrSchemaVersion) {
+		t.Errorf("#1: schema version error expected for an empty database. Got: %v", err)
+	}
+
+	if err := mg.Up(); err != nil {
+		t.Fatalf("#2: error not expected: %v", err)
+	}
+
+	defer func() {
+		if err := mg.Down(0); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	status, err := mg.Status()
+	if err != nil || status.Version != status.Latest || status.Dirty {
+		t.Errorf("#2: latest version expected. Got: %+v, %v", status, err)
+	}
+
+	if err := mg.CheckVersion(); err != nil {
+		t.Errorf("#2: error not expected: %v", err)
+	}
+
+	//a newer binary migrated the database
+	if err := mg.Force(int(status.Latest) + 1); err != nil {
+		t.Fatalf("#3: error not expected: %v", err)
+	}
+
+	if err := mg.CheckVersion(); !errors.Is(err, ErrSchemaVersion) {
+		t.Errorf("#3: schema version error expected for an unknown version. Got: %v", err)
+	}
+
+	if err := mg.Force(int(status.Latest)); err != nil {
+		t.Fatalf("#4: error not expected: %v", err)
+	}
+
+	if err := mg.Down(1); err != nil {
+		t.Fatalf("#4: error not expected: %v", err)
+	}
+
+	if err := mg.CheckVersion(); !errors.Is(err, ErrSchemaVersion) {
+		t.Errorf("#4: schema version error expected for an old version. Got: %v", err)
+	}
+
+	if err := mg.Up(); err != nil {
+		t.Fatalf("#5: error not expected: %v", err)
+	}
+}
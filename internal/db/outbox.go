@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/jackc/pgx/v4"
+)
+
+//writes a product event into the outbox within the transaction of the product change.
+//The product row is locked by the change, so versions of the same SKU are assigned sequentially
+func insertEvent(ctx context.Context, tx pgx.Tx, eventType string, prd contract.Product) error {
+	prd.Availability = nil
+
+	payload, err := json.Marshal(&prd)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO public.product_event(sku, type, version, payload)
+		SELECT $1, $2, COALESCE(MAX(version), 0) + 1, $3 FROM public.product_event WHERE sku = $1`
+
+	_, err = tx.Exec(ctx, query, prd.SKU, eventType, payload)
+
+	return err
+}
+
+//PendingEvents retrieves the undelivered events that are due, in the order they were written.
+//Events of a SKU whose previous event is waiting for a retry are held back to keep the order per SKU
+func (db *PostgreSQLDB) PendingEvents(limit int) ([]contract.ProductEvent, error) {
+	query := `SELECT e.id, e.sku, e.type, e.version, e.payload, e.occurred_at, e.attempts
+		FROM public.product_event e
+		WHERE e.delivered_at IS NULL AND e.next_attempt_at <= NOW()
+			AND NOT EXISTS (
+				SELECT 1 FROM public.product_event p
+				WHERE p.sku = e.sku AND p.id < e.id AND p.delivered_at IS NULL AND p.next_attempt_at > NOW()
+			)
+		ORDER BY e.id
+		LIMIT $1`
+
+	events, err := db.queryEvents(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get pending events: %w", err)
+	}
+
+	return events, nil
+}
+
+//EventsAfter retrieves up to limit events with a greater id than the given one, delivered or not, in id order.
+//The ids are assigned when the events are written, so an event of a transaction not committed yet can show up
+//after the events with greater ids
+func (db *PostgreSQLDB) EventsAfter(id int64, limit int) ([]contract.ProductEvent, error) {
+	query := `SELECT id, sku, type, version, payload, occurred_at, attempts
+		FROM public.product_event
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2`
+
+	events, err := db.queryEvents(query, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get events: %w", err)
+	}
+
+	return events, nil
+}
+
+//LastEventID retrieves the id of the last written event. 0 when no event was written
+func (db *PostgreSQLDB) LastEventID() (int64, error) {
+	var id int64
+
+	err := db.pool.QueryRow(context.Background(), "SELECT COALESCE(MAX(id), 0) FROM public.product_event").Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("could not get the last event: %v", err)
+	}
+
+	return id, nil
+}
+
+//runs a query selecting the event columns and scans its rows
+func (db *PostgreSQLDB) queryEvents(query string, args ...interface{}) ([]contract.ProductEvent, error) {
+	rows, err := db.pool.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]contract.ProductEvent, 0)
+	for rows.Next() {
+		var (
+			e       contract.ProductEvent
+			payload []byte
+		)
+
+		if err = rows.Scan(&e.ID, &e.SKU, &e.Type, &e.Version, &payload, &e.OccurredAt, &e.Attempts); err != nil {
+			return nil, err
+		}
+
+		if err = json.Unmarshal(payload, &e.Payload); err != nil {
+			return nil, fmt.Errorf("could not decode event %d payload: %v", e.ID, err)
+		}
+
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+//MarkDelivered flags the event as delivered to every sink
+func (db *PostgreSQLDB) MarkDelivered(id int64) error {
+	query := "UPDATE public.product_event SET delivered_at = NOW(), last_error = NULL WHERE id = $1"
+
+	if _, err := db.pool.Exec(context.Background(), query, id); err != nil {
+		return fmt.Errorf("could not mark event %d as delivered: %v", id, err)
+	}
+
+	return nil
+}
+
+//MarkFailed records a failed delivery of the event and schedules its next attempt
+func (db *PostgreSQLDB) MarkFailed(id int64, reason string, retryAt time.Time) error {
+	query := "UPDATE public.product_event SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1"
+
+	if _, err := db.pool.Exec(context.Background(), query, id, reason, retryAt); err != nil {
+		return fmt.Errorf("could not mark event %d as failed: %v", id, err)
+	}
+
+	return nil
+}
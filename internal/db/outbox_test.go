@@ -0,0 +1,71 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestProductEvents(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	prd := getMockProduct()
+	db.Create(prd)
+	prd.Name = "new name"
+	db.Update(prd)
+	db.Delete(prd.SKU, false)
+
+	events, err := db.PendingEvents(10)
+	if err != nil {
+		t.Fatalf("could not get pending events: %v", err)
+	}
+
+	expected := []string{contract.EventProductCreated, contract.EventProductUpdated, contract.EventProductDeleted}
+	if len(events) != len(expected) {
+		t.Fatalf("#1: events expected: %v. Got: %v", len(expected), len(events))
+	}
+
+	for i, e := range events {
+		if e.Type != expected[i] || e.Version != int64(i+1) {
+			t.Errorf("#2: event %d different than expected: %s v%d", i, e.Type, e.Version)
+		}
+	}
+
+	if events[2].Payload == nil || events[2].Payload.Name != "new name" {
+		t.Errorf("#3: deleted event must carry the last state of the product")
+	}
+
+	//a failed event holds back the following events of the same SKU
+	if err := db.MarkFailed(events[0].ID, "mocked error", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("could not mark event as failed: %v", err)
+	}
+
+	events, _ = db.PendingEvents(10)
+	if len(events) != 0 {
+		t.Errorf("#4: no pending events expected. Got: %v", len(events))
+	}
+
+	//the streams read every event, delivered or not
+	last, err := db.LastEventID()
+	if err != nil || last == 0 {
+		t.Fatalf("#5: last event id expected. Got: %v, error: %v", last, err)
+	}
+
+	events, err = db.EventsAfter(last-2, 10)
+	if err != nil || len(events) != 2 || events[0].ID != last-1 || events[1].ID != last {
+		t.Errorf("#6: events after %d expected. Got: %+v, error: %v", last-2, events, err)
+	}
+}
@@ -2,21 +2,28 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
+const productColumns = "sku, name, description, brand, brand_id, size, price, image_url, alt_images, translations, category, attributes, status, status_updated_at, status_updated_by, revision, " +
+	"created_at, created_by, updated_at, updated_by"
+
 //PostgreSQLDB implementation of postgresql database
 type PostgreSQLDB struct {
 	pool *pgxpool.Pool
 }
 
 // NewPostgreSQLDB retrieves a new PostgreSQLDB object
-func NewPostgreSQLDB(dbURI string) (Database, error) {
+func NewPostgreSQLDB(dbURI string) (*PostgreSQLDB, error) {
 	pool, err := pgxpool.Connect(context.Background(), dbURI)
 	if err != nil {
 		return nil, fmt.Errorf("could not create database connection: %v", err)
@@ -37,67 +44,168 @@ func (db *PostgreSQLDB) Close() {
 	db.pool.Close()
 }
 
-//Create inserts a new product
-func (db *PostgreSQLDB) Create(prd contract.Product) error {
-	query := "INSERT INTO public.product(sku, name, brand, size, price, image_url, alt_images) VALUES($1, $2, $3, $4, $5, $6, $7)"
+//Create inserts a new product along with its created event and first revision, returning the stored product.
+//Returns ErrAlreadyExists when the SKU is taken
+func (db *PostgreSQLDB) Create(prd contract.Product) (*contract.Product, error) {
+	query := `INSERT INTO public.product(sku, name, description, brand, size, price, image_url, alt_images, translations, category,
+		attributes, status, status_updated_by, created_by, updated_by, revision, brand_id) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11,
+		$12, $13, $13, $13, (SELECT COALESCE(MAX(revision), 0) + 1 FROM public.product_revision WHERE sku = $1), NULLIF($14::BIGINT, 0))
+		RETURNING status_updated_at, revision, created_at, updated_at`
+
+	ctx := context.Background()
+	err := db.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		prd.CreatedBy, prd.StatusUpdatedBy = prd.UpdatedBy, prd.UpdatedBy
+		if prd.Status == "" {
+			prd.Status = contract.StatusDraft
+		}
+
+		if err := resolveBrand(ctx, tx, &prd); err != nil {
+			return err
+		}
+
+		err := tx.QueryRow(ctx, query, prd.SKU, prd.Name, prd.Description, prd.Brand, prd.Size, prd.Price, prd.ImageURL, prd.AltImages,
+			translations(prd), prd.Category, attributes(prd), prd.Status, prd.UpdatedBy, prd.BrandID).
+			Scan(&prd.StatusUpdatedAt, &prd.Revision, &prd.CreatedAt, &prd.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		if err = insertRevision(ctx, tx, prd, false); err != nil {
+			return err
+		}
 
-	_, err := db.pool.Exec(context.Background(), query, prd.SKU, prd.Name, prd.Brand, prd.Size, prd.Price, prd.ImageURL, prd.AltImages)
+		return insertEvent(ctx, tx, contract.EventProductCreated, prd)
+	})
 	if err != nil {
-		return fmt.Errorf("could not create product: %v", err)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation && pgErr.TableName == "product" {
+			return nil, fmt.Errorf("could not create product %s: %w", prd.SKU, ErrAlreadyExists)
+		}
+		return nil, fmt.Errorf("could not create product: %v", err)
 	}
 
-	return nil
+	return &prd, nil
 }
 
 //GetAll retrieves a slice of the products stored in database
 func (db *PostgreSQLDB) GetAll() ([]contract.Product, error) {
-	query := "SELECT sku, name, brand, size, price, image_url, alt_images FROM public.product"
+	return db.List(contract.ProductFilter{})
+}
 
+//List retrieves the products selected by the filter ordered by SKU
+func (db *PostgreSQLDB) List(filter contract.ProductFilter) ([]contract.Product, error) {
 	var (
-		sku, name, brand, imageURL string
-		size                       int
-		price                      float64
-		altImages                  []string
+		query      = "SELECT " + productColumns + " FROM public.product"
+		conditions []string
+		args       []interface{}
 	)
 
-	rows, err := db.pool.Query(context.Background(), query)
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.AfterSKU != "" {
+		where("sku > $%d", filter.AfterSKU)
+	}
+
+	//the brands are selected like the products resolve them, by name or alias and then by slug
+	if filter.Brand != "" {
+		args = append(args, brandKey(filter.Brand), brandSlug(filter.Brand))
+		conditions = append(conditions, fmt.Sprintf(`brand_id = (SELECT id FROM public.brand
+			WHERE id = (SELECT brand_id FROM public.brand_alias WHERE key = $%d) OR slug = $%d ORDER BY slug = $%[2]d LIMIT 1)`,
+			len(args)-1, len(args)))
+	}
+
+	if filter.MinPrice > 0 {
+		where("price >= $%d", filter.MinPrice)
+	}
+
+	if filter.MaxPrice > 0 {
+		where("price <= $%d", filter.MaxPrice)
+	}
+
+	if !filter.UpdatedSince.IsZero() {
+		where("updated_at >= $%d", filter.UpdatedSince)
+	}
+
+	if filter.Category != "" {
+		where("category = $%d", filter.Category)
+	}
+
+	if filter.Status != "" {
+		where("status = $%d", filter.Status)
+	}
+
+	for _, attr := range filter.Attributes {
+		if attr.Equals != nil {
+			//the containment is answered by the index of the attributes
+			where("attributes @> $%d", map[string]interface{}{attr.Key: attr.Equals})
+		}
+
+		//the attributes of other categories may have the same key with another type, so only the numbers are cast
+		number := func(key string) string {
+			args = append(args, key)
+			return fmt.Sprintf("(CASE WHEN jsonb_typeof(attributes->$%d::text) = 'number' THEN (attributes->>$%[1]d::text)::numeric END)", len(args))
+		}
+		if attr.Min != nil {
+			where(number(attr.Key)+" >= $%d", *attr.Min)
+		}
+		if attr.Max != nil {
+			where(number(attr.Key)+" <= $%d", *attr.Max)
+		}
+	}
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query += " ORDER BY sku"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	prds, err := db.queryProducts(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("could not get products: %v", err)
 	}
-	defer rows.Close()
 
-	prds := make([]contract.Product, 0)
-	for rows.Next() {
-		if err = rows.Scan(&sku, &name, &brand, &size, &price, &imageURL, &altImages); err != nil {
-			return nil, fmt.Errorf("could not get products: %v", err)
+	return prds, nil
+}
+
+//GetMany retrieves the products of the given SKUs in a single query, along with the SKUs that were not found
+func (db *PostgreSQLDB) GetMany(skus []string) ([]contract.Product, []string, error) {
+	query := "SELECT " + productColumns + " FROM public.product WHERE sku = ANY($1) ORDER BY sku"
+
+	prds, err := db.queryProducts(query, skus)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get products: %v", err)
+	}
+
+	found := make(map[string]bool, len(prds))
+	for _, prd := range prds {
+		found[prd.SKU] = true
+	}
+
+	missing := make([]string, 0)
+	for _, sku := range skus {
+		if !found[sku] {
+			missing = append(missing, sku)
+			//a repeated sku is reported once
+			found[sku] = true
 		}
-		prds = append(prds, contract.Product{
-			SKU:       sku,
-			Name:      name,
-			Brand:     brand,
-			Size:      size,
-			Price:     price,
-			ImageURL:  imageURL,
-			AltImages: altImages,
-		})
 	}
 
-	return prds, nil
+	return prds, missing, nil
 }
 
 //Get retrieves a product by its SKU
 func (db *PostgreSQLDB) Get(sku string) (*contract.Product, error) {
-	query := "SELECT name, brand, size, price, image_url, alt_images FROM public.product WHERE sku = $1"
+	query := "SELECT " + productColumns + " FROM public.product WHERE sku = $1"
 
-	var (
-		name, brand, imageURL string
-		size                  int
-		price                 float64
-		altImages             []string
-	)
-
-	row := db.pool.QueryRow(context.Background(), query, sku)
-	err := row.Scan(&name, &brand, &size, &price, &imageURL, &altImages)
+	prd, err := scanProduct(db.pool.QueryRow(context.Background(), query, sku))
 	if err != nil {
 		switch err {
 		case pgx.ErrNoRows:
@@ -107,36 +215,249 @@ func (db *PostgreSQLDB) Get(sku string) (*contract.Product, error) {
 		}
 	}
 
-	return &contract.Product{
-		SKU:       sku,
-		Name:      name,
-		Brand:     brand,
-		Size:      size,
-		Price:     price,
-		ImageURL:  imageURL,
-		AltImages: altImages,
-	}, nil
+	return prd, nil
 }
 
-//Update updates a product by its SKU
+//LastModified retrieves the last time a product or its stock was created, updated or deleted
+func (db *PostgreSQLDB) LastModified() (time.Time, error) {
+	query := `SELECT COALESCE(GREATEST(
+		(SELECT MAX(updated_at) FROM public.product),
+		(SELECT MAX(updated_at) FROM public.stock),
+		(SELECT MAX(occurred_at) FROM public.product_event WHERE type = $1)
+	), 'epoch')`
+
+	var last time.Time
+	if err := db.pool.QueryRow(context.Background(), query, contract.EventProductDeleted).Scan(&last); err != nil {
+		return time.Time{}, fmt.Errorf("could not get the last modification time: %v", err)
+	}
+
+	return last, nil
+}
+
+//Update updates a product by its SKU and records its updated event and new revision. Products without status keep
+//the stored one, and the brand is resolved with the brand registry. Returns ErrNotFound when the product does not exist
 func (db *PostgreSQLDB) Update(prd contract.Product) error {
-	query := "UPDATE public.product SET name=$1, brand=$2, size=$3, price=$4, image_url=$5, alt_images=$6 WHERE sku=$7"
+	query := `UPDATE public.product SET name=$1, description=$2, brand=$3, size=$4, price=$5, image_url=$6, alt_images=$7,
+		translations=$8, category=$9, attributes=$10, updated_by=$11, revision=revision + 1, brand_id=NULLIF($14::BIGINT, 0),
+		` + statusAssignment("COALESCE(NULLIF($12, ''), status)", "$11") + ` WHERE sku=$13 RETURNING ` + productColumns
 
-	_, err := db.pool.Exec(context.Background(), query, prd.Name, prd.Brand, prd.Size, prd.Price, prd.ImageURL, prd.AltImages, prd.SKU)
+	ctx := context.Background()
+	err := db.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if err := resolveBrand(ctx, tx, &prd); err != nil {
+			return err
+		}
+
+		stored, err := scanProduct(tx.QueryRow(ctx, query, prd.Name, prd.Description, prd.Brand, prd.Size, prd.Price, prd.ImageURL,
+			prd.AltImages, translations(prd), prd.Category, attributes(prd), prd.UpdatedBy, prd.Status, prd.SKU, prd.BrandID))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		if err = insertRevision(ctx, tx, *stored, false); err != nil {
+			return err
+		}
+
+		return insertEvent(ctx, tx, contract.EventProductUpdated, *stored)
+	})
 	if err != nil {
-		return fmt.Errorf("could not update product: %v", err)
+		return fmt.Errorf("could not update product: %w", err)
 	}
 
 	return nil
 }
 
-//Delete deletes product by its SKU
-func (db *PostgreSQLDB) Delete(sku string) error {
-	query := `DELETE FROM public.product WHERE sku=$1`
-	_, err := db.pool.Exec(context.Background(), query, sku)
+//UpdateFunc locks the product, applies fn to it and stores the result along with its updated event and new
+//revision in a single transaction. Returns ErrNotFound when the product does not exist, and the error of fn when it fails
+func (db *PostgreSQLDB) UpdateFunc(sku string, fn func(prd *contract.Product) error) (*contract.Product, error) {
+	var (
+		ctx = context.Background()
+		prd *contract.Product
+	)
+
+	err := db.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var err error
+
+		prd, err = scanProduct(tx.QueryRow(ctx, "SELECT "+productColumns+" FROM public.product WHERE sku = $1 FOR UPDATE", sku))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		//the author of the previous change is not the author of this one
+		prd.UpdatedBy = ""
+
+		if err = fn(prd); err != nil {
+			return err
+		}
+
+		//the sku identifies the product and the creation is tracked once, so they cannot be changed
+		prd.SKU = sku
+
+		if err = resolveBrand(ctx, tx, prd); err != nil {
+			return err
+		}
+
+		query := `UPDATE public.product SET name=$1, description=$2, brand=$3, size=$4, price=$5, image_url=$6, alt_images=$7,
+			translations=$8, category=$9, attributes=$10, updated_by=$11, revision=revision + 1, brand_id=NULLIF($14::BIGINT, 0),
+			` + statusAssignment("$12", "$11") + `
+			WHERE sku=$13 RETURNING status_updated_at, status_updated_by, revision, created_at, created_by, updated_at`
+		err = tx.QueryRow(ctx, query, prd.Name, prd.Description, prd.Brand, prd.Size, prd.Price, prd.ImageURL, prd.AltImages,
+			translations(*prd), prd.Category, attributes(*prd), prd.UpdatedBy, prd.Status, sku, prd.BrandID).
+			Scan(&prd.StatusUpdatedAt, &prd.StatusUpdatedBy, &prd.Revision, &prd.CreatedAt, &prd.CreatedBy, &prd.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		if err = insertRevision(ctx, tx, *prd, false); err != nil {
+			return err
+		}
+
+		return insertEvent(ctx, tx, contract.EventProductUpdated, *prd)
+	})
 	if err != nil {
-		return fmt.Errorf("could not delete product: %v", err)
+		return nil, fmt.Errorf("could not update product: %w", err)
+	}
+
+	return prd, nil
+}
+
+//Delete deletes product by its SKU and records its deleted event and revision with the last state of the product.
+//The stock of the product is deleted along with it only with cascade, otherwise products with units on hand or
+//reserved fail with ErrInStock
+func (db *PostgreSQLDB) Delete(sku string, cascade bool) error {
+	query := "DELETE FROM public.product WHERE sku=$1 RETURNING " + productColumns
+
+	ctx := context.Background()
+	err := db.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if !cascade {
+			if err := checkNoStock(ctx, tx, sku); err != nil {
+				return err
+			}
+		}
+
+		prd, err := scanProduct(tx.QueryRow(ctx, query, sku))
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+
+		//the deletion is the next revision of the product
+		prd.Revision++
+		if err = insertRevision(ctx, tx, *prd, true); err != nil {
+			return err
+		}
+
+		return insertEvent(ctx, tx, contract.EventProductDeleted, *prd)
+	})
+	if err != nil {
+		return fmt.Errorf("could not delete product: %w", err)
+	}
+
+	return nil
+}
+
+//fails with ErrInStock when the product has units on hand or reserved. The product and its stock are locked until
+//the end of the transaction, so no stock is added to it or adjusted before it is deleted
+func checkNoStock(ctx context.Context, tx pgx.Tx, sku string) error {
+	//new stock references the product, which waits for its lock
+	if _, err := tx.Exec(ctx, "SELECT 1 FROM public.product WHERE sku=$1 FOR UPDATE", sku); err != nil {
+		return err
+	}
+
+	query := `SELECT EXISTS (SELECT 1 FROM (SELECT on_hand, reserved FROM public.stock WHERE sku=$1 FOR UPDATE) s
+		WHERE s.on_hand > 0 OR s.reserved > 0)`
+
+	var inStock bool
+	if err := tx.QueryRow(ctx, query, sku).Scan(&inStock); err != nil {
+		return err
+	}
+
+	if inStock {
+		return ErrInStock
 	}
 
 	return nil
 }
+
+//runs a query selecting the product columns and scans its rows
+func (db *PostgreSQLDB) queryProducts(query string, args ...interface{}) ([]contract.Product, error) {
+	rows, err := db.pool.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prds := make([]contract.Product, 0)
+	for rows.Next() {
+		prd, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		prds = append(prds, *prd)
+	}
+
+	return prds, rows.Err()
+}
+
+//scans a row of the product columns
+func scanProduct(row pgx.Row) (*contract.Product, error) {
+	var (
+		prd     = &contract.Product{}
+		brandID *int64
+	)
+
+	err := row.Scan(&prd.SKU, &prd.Name, &prd.Description, &prd.Brand, &brandID, &prd.Size, &prd.Price, &prd.ImageURL, &prd.AltImages,
+		&prd.Translations, &prd.Category, &prd.Attributes, &prd.Status, &prd.StatusUpdatedAt, &prd.StatusUpdatedBy, &prd.Revision,
+		&prd.CreatedAt, &prd.CreatedBy, &prd.UpdatedAt, &prd.UpdatedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	//drafts without brand reference none
+	if brandID != nil {
+		prd.BrandID = *brandID
+	}
+
+	//products without translations or attributes are stored with empty objects
+	if len(prd.Translations) == 0 {
+		prd.Translations = nil
+	}
+	if len(prd.Attributes) == 0 {
+		prd.Attributes = nil
+	}
+
+	return prd, nil
+}
+
+//assigns the status of an update, tracking when and by whom it was changed when it differs from the stored one.
+//The expressions are evaluated with the stored values of the columns
+func statusAssignment(status, user string) string {
+	return fmt.Sprintf(`status = %[1]s,
+		status_updated_at = CASE WHEN status = %[1]s THEN status_updated_at ELSE NOW() END,
+		status_updated_by = CASE WHEN status = %[1]s THEN status_updated_by ELSE %[2]s END`, status, user)
+}
+
+//translations of the product to store, which are never null
+func translations(prd contract.Product) map[string]contract.ProductTranslation {
+	if prd.Translations == nil {
+		return map[string]contract.ProductTranslation{}
+	}
+
+	return prd.Translations
+}
+
+//custom attributes of the product to store, which are never null
+func attributes(prd contract.Product) map[string]interface{} {
+	if prd.Attributes == nil {
+		return map[string]interface{}{}
+	}
+
+	return prd.Attributes
+}
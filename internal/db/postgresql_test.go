@@ -1,8 +1,12 @@
 package db
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/garciacer87/product-api/internal/contract"
 	"github.com/golang-migrate/migrate/v4"
@@ -101,7 +105,7 @@ func TestCreate(t *testing.T) {
 	}
 
 	for desc, tc := range tests {
-		err = db.Create(tc.prd)
+		_, err = db.Create(tc.prd)
 		isErr := err != nil
 		if isErr != tc.errExpected {
 			t.Errorf("%s:\n got Error? %v.\n Error expected? %v.\n Error: %v", desc, isErr, tc.errExpected, err)
@@ -145,6 +149,93 @@ func TestGetAll(t *testing.T) {
 	}
 }
 
+func TestList(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	for i, sku := range []string{"FAL-1000002", "FAL-1000000", "FAL-1000001"} {
+		prd := getMockProduct()
+		prd.SKU = sku
+		prd.Price = float64(100 * (i + 1))
+		if i == 0 {
+			prd.Brand = "other brand"
+		}
+		if _, err := db.Create(prd); err != nil {
+			t.Fatalf("could not create product: %v", err)
+		}
+	}
+
+	tests := map[string]struct {
+		filter       contract.ProductFilter
+		skusExpected []string
+	}{
+		"#1: no filter":   {skusExpected: []string{"FAL-1000000", "FAL-1000001", "FAL-1000002"}},
+		"#2: first page":  {filter: contract.ProductFilter{Limit: 2}, skusExpected: []string{"FAL-1000000", "FAL-1000001"}},
+		"#3: second page": {filter: contract.ProductFilter{AfterSKU: "FAL-1000001", Limit: 2}, skusExpected: []string{"FAL-1000002"}},
+		"#4: last page":   {filter: contract.ProductFilter{AfterSKU: "FAL-1000002", Limit: 2}, skusExpected: []string{}},
+		"#5: brand":       {filter: contract.ProductFilter{Brand: "other brand"}, skusExpected: []string{"FAL-1000002"}},
+		"#6: price range": {filter: contract.ProductFilter{MinPrice: 150, MaxPrice: 300}, skusExpected: []string{"FAL-1000000", "FAL-1000001"}},
+	}
+
+	for desc, tc := range tests {
+		prds, err := db.List(tc.filter)
+		if err != nil {
+			t.Fatalf("%s: error not expected: %v", desc, err)
+		}
+
+		skus := make([]string, 0)
+		for _, prd := range prds {
+			skus = append(skus, prd.SKU)
+		}
+
+		if fmt.Sprint(skus) != fmt.Sprint(tc.skusExpected) {
+			t.Errorf("%s:\n skus got: %v\n skus expected: %v", desc, skus, tc.skusExpected)
+		}
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	db.Create(getMockProduct())
+
+	prds, missing, err := db.GetMany([]string{"FAL-1000000", "FAL-2000000", "FAL-2000000"})
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	if len(prds) != 1 || prds[0].SKU != "FAL-1000000" {
+		t.Errorf("#1: FAL-1000000 must be the only product found. Got: %v", prds)
+	}
+
+	if len(missing) != 1 || missing[0] != "FAL-2000000" {
+		t.Errorf("#2: FAL-2000000 must be reported once as missing. Got: %v", missing)
+	}
+}
+
 func TestGet(t *testing.T) {
 	m := initTestDB(t)
 	defer func() {
@@ -206,20 +297,29 @@ func TestDelete(t *testing.T) {
 	defer db.Close()
 
 	db.Create(getMockProduct())
-
-	tests := map[string]struct {
-		sku         string
-		errExpected bool
+	db.AdjustStock("FAL-1000000", contract.StockAdjustment{Warehouse: "main", Delta: 10})
+
+	//the steps depend on the previous ones
+	tests := []struct {
+		desc          string
+		sku           string
+		cascade       bool
+		errExpected   error
+		stockExpected int
 	}{
-		"#1: valid case": {sku: "FAL-1000000", errExpected: false},
+		{desc: "#1: product with stock", sku: "FAL-1000000", errExpected: ErrInStock, stockExpected: 1},
+		{desc: "#2: cascade", sku: "FAL-1000000", cascade: true, stockExpected: 0},
+		{desc: "#3: deleted product", sku: "FAL-1000000"},
 	}
 
-	for desc, tc := range tests {
-		err := db.Delete(tc.sku)
-		isErr := err != nil
+	for _, tc := range tests {
+		err := db.Delete(tc.sku, tc.cascade)
+		if !errors.Is(err, tc.errExpected) {
+			t.Errorf("%s:\n Error expected: %v\n Got: %v", tc.desc, tc.errExpected, err)
+		}
 
-		if isErr != tc.errExpected {
-			t.Errorf("%s:\n Error expected? %v\n Got error? %v", desc, tc.errExpected, isErr)
+		if stocks, err := db.Stock(tc.sku); err != nil || len(stocks) != tc.stockExpected {
+			t.Errorf("%s:\n stock got: %+v, error: %v\n Expected: %d", tc.desc, stocks, err, tc.stockExpected)
 		}
 	}
 }
@@ -245,7 +345,8 @@ func TestUpdate(t *testing.T) {
 		patch       contract.Product
 		errExpected bool
 	}{
-		"#1: valid case": {patch: contract.Product{SKU: "FAL-1000000", Size: 10}, errExpected: false},
+		"#1: valid case":  {patch: contract.Product{SKU: "FAL-1000000", Size: 10}, errExpected: false},
+		"#2: missing sku": {patch: contract.Product{SKU: "FAL-9999999", Size: 10}, errExpected: true},
 	}
 
 	for desc, tc := range tests {
@@ -257,3 +358,232 @@ func TestUpdate(t *testing.T) {
 		}
 	}
 }
+
+func TestUpdateFunc(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	db.Create(getMockProduct())
+
+	_, err = db.UpdateFunc("FAL-2000000", func(prd *contract.Product) error { return nil })
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("#1: not found error expected. Got: %v", err)
+	}
+
+	fnErr := fmt.Errorf("invalid patch")
+	_, err = db.UpdateFunc("FAL-1000000", func(prd *contract.Product) error {
+		prd.Name = "discarded"
+		return fnErr
+	})
+	if !errors.Is(err, fnErr) {
+		t.Errorf("#2: error of the function expected. Got: %v", err)
+	}
+
+	if prd, _ := db.Get("FAL-1000000"); prd.Name != "name" {
+		t.Errorf("#2: changes of a failed function must be rolled back. Name: %s", prd.Name)
+	}
+
+	//concurrent increments are serialized by the row lock
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := db.UpdateFunc("FAL-1000000", func(prd *contract.Product) error {
+				prd.Size++
+				return nil
+			})
+			if err != nil {
+				t.Errorf("#3: error not expected: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if prd, _ := db.Get("FAL-1000000"); prd.Size != getMockProduct().Size+10 {
+		t.Errorf("#3: no update must be lost. Size: %d", prd.Size)
+	}
+}
+
+func TestAuditFields(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	prd := getMockProduct()
+	prd.CreatedBy, prd.UpdatedBy = "ignored", "alice"
+	if _, err := db.Create(prd); err != nil {
+		t.Fatalf("could not create product: %v", err)
+	}
+
+	created, _ := db.Get(prd.SKU)
+	if created.CreatedBy != "alice" || created.UpdatedBy != "alice" || created.CreatedAt.IsZero() {
+		t.Errorf("#1: audit fields of a new product got: %+v", created)
+	}
+
+	updated, err := db.UpdateFunc(prd.SKU, func(prd *contract.Product) error {
+		prd.UpdatedBy = "bob"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not update product: %v", err)
+	}
+
+	if updated.CreatedBy != "alice" || updated.UpdatedBy != "bob" || !updated.CreatedAt.Equal(created.CreatedAt) || !updated.UpdatedAt.After(created.UpdatedAt) {
+		t.Errorf("#2: audit fields of an updated product got: %+v", updated)
+	}
+
+	prds, err := db.List(contract.ProductFilter{UpdatedSince: updated.UpdatedAt})
+	if err != nil || len(prds) != 1 {
+		t.Errorf("#3: updated product expected. Got: %v, %v", prds, err)
+	}
+
+	prds, err = db.List(contract.ProductFilter{UpdatedSince: updated.UpdatedAt.Add(time.Millisecond)})
+	if err != nil || len(prds) != 0 {
+		t.Errorf("#4: no products expected. Got: %v, %v", prds, err)
+	}
+}
+
+func TestTranslations(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	prd := getMockProduct()
+	if _, err := db.Create(prd); err != nil {
+		t.Fatalf("could not create product: %v", err)
+	}
+
+	created, _ := db.Get(prd.SKU)
+	if created.Description != "" || created.Translations != nil {
+		t.Errorf("#1: product without translations expected. Got: %+v", created)
+	}
+
+	translations := map[string]contract.ProductTranslation{
+		"es":    {Name: "nombre", Description: "descripción"},
+		"pt-BR": {Name: "nome"},
+	}
+
+	_, err = db.UpdateFunc(prd.SKU, func(prd *contract.Product) error {
+		prd.Description, prd.Translations = "description", translations
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not update product: %v", err)
+	}
+
+	updated, _ := db.Get(prd.SKU)
+	if updated.Description != "description" || !reflect.DeepEqual(updated.Translations, translations) {
+		t.Errorf("#2: translations got: %q %v\n Expected: %v", updated.Description, updated.Translations, translations)
+	}
+}
+
+func TestProductStatus(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	prd := getMockProduct()
+	prd.UpdatedBy = "author"
+	created, err := db.Create(prd)
+	if err != nil {
+		t.Fatalf("could not create product: %v", err)
+	}
+
+	if created.Status != contract.StatusDraft || created.StatusUpdatedBy != "author" || created.StatusUpdatedAt.IsZero() {
+		t.Errorf("#1: new products are drafts. Got: %q %q %v", created.Status, created.StatusUpdatedBy, created.StatusUpdatedAt)
+	}
+
+	//changes other than the status keep who changed the status
+	updated, err := db.UpdateFunc(prd.SKU, func(prd *contract.Product) error {
+		prd.Name, prd.UpdatedBy = "other name", "editor"
+		return nil
+	})
+	if err != nil || updated.Status != contract.StatusDraft || updated.StatusUpdatedBy != "author" || !updated.StatusUpdatedAt.Equal(created.StatusUpdatedAt) {
+		t.Errorf("#2: status must not change. Got: %+v, error: %v", updated, err)
+	}
+
+	activated, err := db.UpdateFunc(prd.SKU, func(prd *contract.Product) error {
+		prd.Status, prd.UpdatedBy = contract.StatusActive, "reviewer"
+		return nil
+	})
+	if err != nil || activated.Status != contract.StatusActive || activated.StatusUpdatedBy != "reviewer" {
+		t.Errorf("#3: status must change. Got: %+v, error: %v", activated, err)
+	}
+
+	//the import updates the products without status
+	prd.Status, prd.UpdatedBy = "", "importer"
+	if err := db.Update(prd); err != nil {
+		t.Fatalf("could not update product: %v", err)
+	}
+
+	if stored, _ := db.Get(prd.SKU); stored.Status != contract.StatusActive || stored.StatusUpdatedBy != "reviewer" {
+		t.Errorf("#4: status must be kept. Got: %q %q", stored.Status, stored.StatusUpdatedBy)
+	}
+
+	tests := map[string]struct {
+		status       string
+		skusExpected []string
+	}{
+		"#5: active": {status: contract.StatusActive, skusExpected: []string{prd.SKU}},
+		"#6: draft":  {status: contract.StatusDraft, skusExpected: []string{}},
+		"#7: any":    {skusExpected: []string{prd.SKU}},
+	}
+
+	for desc, tc := range tests {
+		prds, err := db.List(contract.ProductFilter{Status: tc.status})
+		if err != nil {
+			t.Fatalf("%s: error not expected: %v", desc, err)
+		}
+
+		skus := make([]string, 0)
+		for _, prd := range prds {
+			skus = append(skus, prd.SKU)
+		}
+
+		if fmt.Sprint(skus) != fmt.Sprint(tc.skusExpected) {
+			t.Errorf("%s:\n skus got: %v\n skus expected: %v", desc, skus, tc.skusExpected)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/jackc/pgx/v4"
+)
+
+const revisionColumns = "sku, revision, product, deleted, created_at, created_by"
+
+//writes a revision of a product within the transaction of the product change, with the revision number set by
+//the change. Deleted revisions keep the last version of the deleted product
+func insertRevision(ctx context.Context, tx pgx.Tx, prd contract.Product, deleted bool) error {
+	prd.Availability = nil
+
+	payload, err := json.Marshal(&prd)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO public.product_revision(sku, revision, product, deleted, created_by) VALUES($1, $2, $3, $4, $5)`
+
+	_, err = tx.Exec(ctx, query, prd.SKU, prd.Revision, payload, deleted, prd.UpdatedBy)
+
+	return err
+}
+
+//Revisions retrieves the revisions of a product, newest first. Returns ErrNotFound when the SKU has none
+func (db *PostgreSQLDB) Revisions(sku string) ([]contract.ProductRevision, error) {
+	query := "SELECT " + revisionColumns + " FROM public.product_revision WHERE sku = $1 ORDER BY revision DESC"
+
+	rows, err := db.pool.Query(context.Background(), query, sku)
+	if err != nil {
+		return nil, fmt.Errorf("could not get revisions: %v", err)
+	}
+	defer rows.Close()
+
+	revs := make([]contract.ProductRevision, 0)
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not get revisions: %v", err)
+		}
+		revs = append(revs, *rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get revisions: %v", err)
+	}
+
+	if len(revs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return revs, nil
+}
+
+//Revision retrieves a revision of a product by its number. Returns ErrNotFound when it does not exist
+func (db *PostgreSQLDB) Revision(sku string, revision int) (*contract.ProductRevision, error) {
+	query := "SELECT " + revisionColumns + " FROM public.product_revision WHERE sku = $1 AND revision = $2"
+
+	rev, err := scanRevision(db.pool.QueryRow(context.Background(), query, sku, revision))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get revision: %v", err)
+	}
+
+	return rev, nil
+}
+
+//RevisionAsOf retrieves the revision of a product that was current at the given time. Returns ErrNotFound when
+//the product did not exist at that time, either because it was not created yet or because it was deleted
+func (db *PostgreSQLDB) RevisionAsOf(sku string, at time.Time) (*contract.ProductRevision, error) {
+	query := "SELECT " + revisionColumns + ` FROM public.product_revision WHERE sku = $1 AND created_at <= $2
+		ORDER BY created_at DESC, revision DESC LIMIT 1`
+
+	rev, err := scanRevision(db.pool.QueryRow(context.Background(), query, sku, at))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get revision: %v", err)
+	}
+
+	if rev.Deleted {
+		return nil, ErrNotFound
+	}
+
+	return rev, nil
+}
+
+//scans a row of the revision columns
+func scanRevision(row pgx.Row) (*contract.ProductRevision, error) {
+	var (
+		rev     = &contract.ProductRevision{}
+		payload []byte
+	)
+
+	err := row.Scan(&rev.SKU, &rev.Revision, &payload, &rev.Deleted, &rev.CreatedAt, &rev.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(payload, &rev.Product); err != nil {
+		return nil, err
+	}
+
+	return rev, nil
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestRevisions(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	before := time.Now().Add(-time.Minute)
+
+	prd := getMockProduct()
+	prd.UpdatedBy = "author"
+	created, err := db.Create(prd)
+	if err != nil || created.Revision != 1 {
+		t.Fatalf("#1: product must be created with the first revision. Got: %+v, error: %v", created, err)
+	}
+
+	prd.Name = "new name"
+	if err := db.Update(prd); err != nil {
+		t.Fatalf("could not update product: %v", err)
+	}
+
+	updated, err := db.UpdateFunc(prd.SKU, func(prd *contract.Product) error {
+		prd.Size, prd.UpdatedBy = 20, "editor"
+		return nil
+	})
+	if err != nil || updated.Revision != 3 {
+		t.Fatalf("#2: product must be at the third revision. Got: %+v, error: %v", updated, err)
+	}
+
+	revs, err := db.Revisions(prd.SKU)
+	if err != nil || len(revs) != 3 || revs[0].Revision != 3 || revs[0].Product.Size != 20 || revs[0].CreatedBy != "editor" {
+		t.Fatalf("#3: revisions got: %+v, error: %v", revs, err)
+	}
+
+	rev, err := db.Revision(prd.SKU, 2)
+	if err != nil || rev.Product.Name != "new name" || rev.Product.Revision != 2 {
+		t.Errorf("#4: second revision got: %+v, error: %v", rev, err)
+	}
+
+	if _, err := db.Revision(prd.SKU, 4); !errors.Is(err, ErrNotFound) {
+		t.Errorf("#5: unknown revision must not be found. Got: %v", err)
+	}
+
+	if rev, err := db.RevisionAsOf(prd.SKU, time.Now()); err != nil || rev.Revision != 3 {
+		t.Errorf("#6: current revision got: %+v, error: %v", rev, err)
+	}
+
+	if _, err := db.RevisionAsOf(prd.SKU, before); !errors.Is(err, ErrNotFound) {
+		t.Errorf("#7: product must not exist before its creation. Got: %v", err)
+	}
+
+	if err := db.Delete(prd.SKU, false); err != nil {
+		t.Fatalf("could not delete product: %v", err)
+	}
+
+	if rev, err := db.Revision(prd.SKU, 4); err != nil || !rev.Deleted || rev.Product.Name != "new name" {
+		t.Errorf("#8: deleted revision got: %+v, error: %v", rev, err)
+	}
+
+	if _, err := db.RevisionAsOf(prd.SKU, time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("#9: deleted product must not be found. Got: %v", err)
+	}
+
+	if created, err := db.Create(prd); err != nil || created.Revision != 5 {
+		t.Errorf("#10: product created again must continue its revisions. Got: %+v, error: %v", created, err)
+	}
+
+	if _, err := db.Revisions("FAL-9999999"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("#11: unknown product must not have revisions. Got: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/garciacer87/product-api/internal/sku"
+	"github.com/jackc/pgx/v4"
+)
+
+//NextSKUs allocates count SKUs of the rule from its sequence, so they are never generated again. The sequence
+//starts after the greatest SKU of the rule already stored. Returns ErrSKURangeExhausted when the range of the
+//rule has not enough SKUs left
+func (db *PostgreSQLDB) NextSKUs(rule *sku.Rule, count int) ([]string, error) {
+	if !rule.Generates() {
+		return nil, fmt.Errorf("rule %s has no range to generate SKUs from", rule.Name)
+	}
+
+	ctx := context.Background()
+
+	if err := db.seedSKUSequence(ctx, rule); err != nil {
+		return nil, fmt.Errorf("could not create the sequence of rule %s: %v", rule.Name, err)
+	}
+
+	_, bound := rule.Bounds()
+	query := `UPDATE public.sku_sequence SET last_value = last_value + $2, updated_at = NOW()
+		WHERE rule = $1 AND last_value + $2 <= $3 RETURNING last_value`
+
+	skus := make([]string, 0, count)
+	for len(skus) < count {
+		n := count - len(skus)
+
+		var last int64
+		err := db.pool.QueryRow(ctx, query, rule.Name, n, int64(bound)).Scan(&last)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, fmt.Errorf("could not allocate %d SKUs of rule %s: %w", count, rule.Name, ErrSKURangeExhausted)
+			}
+			return nil, fmt.Errorf("could not allocate SKUs of rule %s: %v", rule.Name, err)
+		}
+
+		//values whose SKU does not satisfy the rule, like the last ones of a range with check digit, are skipped
+		generated := 0
+		for value := uint64(last) - uint64(n) + 1; value <= uint64(last); value++ {
+			if s := rule.Format(value); rule.Check(s) == nil {
+				skus = append(skus, s)
+				generated++
+			}
+		}
+
+		if generated == 0 {
+			return nil, fmt.Errorf("rule %s generates SKUs that do not satisfy it", rule.Name)
+		}
+	}
+
+	return skus, nil
+}
+
+//creates the sequence of the rule the first time it generates SKUs, starting after the greatest SKU of the rule
+//already stored so the SKUs created by hand are not generated again
+func (db *PostgreSQLDB) seedSKUSequence(ctx context.Context, rule *sku.Rule) error {
+	var exists bool
+	err := db.pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM public.sku_sequence WHERE rule = $1)", rule.Name).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	first, _ := rule.Bounds()
+	last := int64(first) - 1
+
+	rows, err := db.pool.Query(ctx, "SELECT sku FROM public.product WHERE starts_with(sku, $1)", rule.Prefix)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return err
+		}
+
+		if value, ok := rule.Value(s); ok && int64(value) > last {
+			last = int64(value)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	//a concurrent request may have created the sequence meanwhile
+	_, err = db.pool.Exec(ctx, "INSERT INTO public.sku_sequence(rule, last_value) VALUES($1, $2) ON CONFLICT (rule) DO NOTHING", rule.Name, last)
+
+	return err
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/sku"
+)
+
+func TestNextSKUs(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	prd := getMockProduct()
+	prd.SKU = "FAL-1000041"
+	if _, err := db.Create(prd); err != nil {
+		t.Fatalf("could not create product: %v", err)
+	}
+
+	if _, err := db.Create(prd); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("#1: already exists error expected for a duplicated sku. Got: %v", err)
+	}
+
+	rule := sku.DefaultRules().Default()
+
+	//the sequence starts after the SKUs created by hand
+	skus, err := db.NextSKUs(rule, 2)
+	if err != nil || !reflect.DeepEqual(skus, []string{"FAL-1000042", "FAL-1000043"}) {
+		t.Errorf("#2: skus got: %v, %v", skus, err)
+	}
+
+	skus, err = db.NextSKUs(rule, 1)
+	if err != nil || !reflect.DeepEqual(skus, []string{"FAL-1000044"}) {
+		t.Errorf("#3: skus got: %v, %v", skus, err)
+	}
+
+	if _, err = db.NextSKUs(rule, 100000000); !errors.Is(err, ErrSKURangeExhausted) {
+		t.Errorf("#4: range exhausted error expected. Got: %v", err)
+	}
+
+	if _, err = db.NextSKUs(&sku.Rule{Name: "free"}, 1); err == nil {
+		t.Errorf("#5: error expected for a rule without range")
+	}
+}
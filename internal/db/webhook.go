@@ -0,0 +1,258 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/jackc/pgx/v4"
+)
+
+const subscriptionColumns = "id, url, event_types, brands, sku_prefixes, secret, created_at"
+
+const deliveryColumns = `d.id, d.subscription_id, d.event_id, d.event_type, d.sku, d.status, d.attempts,
+	COALESCE(d.last_error, ''), d.next_attempt_at, d.delivered_at, d.created_at`
+
+//CreateSubscription inserts a new webhook subscription
+func (db *PostgreSQLDB) CreateSubscription(sub contract.WebhookSubscription) (*contract.WebhookSubscription, error) {
+	query := `INSERT INTO public.webhook_subscription(url, event_types, brands, sku_prefixes, secret)
+		VALUES($1, $2, $3, $4, $5) RETURNING ` + subscriptionColumns
+
+	row := db.pool.QueryRow(context.Background(), query,
+		sub.URL, nonNil(sub.EventTypes), nonNil(sub.Brands), nonNil(sub.SKUPrefixes), sub.Secret)
+
+	created, err := scanSubscription(row)
+	if err != nil {
+		return nil, fmt.Errorf("could not create subscription: %v", err)
+	}
+
+	return created, nil
+}
+
+//Subscriptions retrieves every webhook subscription
+func (db *PostgreSQLDB) Subscriptions() ([]contract.WebhookSubscription, error) {
+	query := "SELECT " + subscriptionColumns + " FROM public.webhook_subscription ORDER BY id"
+
+	rows, err := db.pool.Query(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("could not get subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	subs := make([]contract.WebhookSubscription, 0)
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not get subscriptions: %v", err)
+		}
+		subs = append(subs, *sub)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get subscriptions: %v", err)
+	}
+
+	return subs, nil
+}
+
+//Subscription retrieves a webhook subscription by its id
+func (db *PostgreSQLDB) Subscription(id int64) (*contract.WebhookSubscription, error) {
+	query := "SELECT " + subscriptionColumns + " FROM public.webhook_subscription WHERE id = $1"
+
+	sub, err := scanSubscription(db.pool.QueryRow(context.Background(), query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get subscription: %v", err)
+	}
+
+	return sub, nil
+}
+
+//DeleteSubscription deletes a webhook subscription and its deliveries
+func (db *PostgreSQLDB) DeleteSubscription(id int64) error {
+	tag, err := db.pool.Exec(context.Background(), "DELETE FROM public.webhook_subscription WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("could not delete subscription: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//EnqueueDeliveries schedules the delivery of the event to the subscriptions.
+//Enqueuing the same event twice for a subscription has no effect
+func (db *PostgreSQLDB) EnqueueDeliveries(event contract.ProductEvent, subscriptionIDs []int64) error {
+	payload, err := json.Marshal(&event)
+	if err != nil {
+		return fmt.Errorf("could not enqueue deliveries: %v", err)
+	}
+
+	query := `INSERT INTO public.webhook_delivery(subscription_id, event_id, event_type, sku, payload)
+		SELECT s, $2, $3, $4, $5 FROM unnest($1::BIGINT[]) s
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`
+
+	_, err = db.pool.Exec(context.Background(), query, subscriptionIDs, event.ID, event.Type, event.SKU, payload)
+	if err != nil {
+		return fmt.Errorf("could not enqueue deliveries: %v", err)
+	}
+
+	return nil
+}
+
+//DueDeliveries retrieves the pending deliveries whose next attempt is due, along with the url and secret of their subscription
+func (db *PostgreSQLDB) DueDeliveries(limit int) ([]contract.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + `, d.payload, s.url, s.secret
+		FROM public.webhook_delivery d JOIN public.webhook_subscription s ON s.id = d.subscription_id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= NOW()
+		ORDER BY d.id
+		LIMIT $1`
+
+	rows, err := db.pool.Query(context.Background(), query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get due deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]contract.WebhookDelivery, 0)
+	for rows.Next() {
+		d := contract.WebhookDelivery{}
+		if err = rows.Scan(deliveryFields(&d, &d.Payload, &d.URL, &d.Secret)...); err != nil {
+			return nil, fmt.Errorf("could not get due deliveries: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get due deliveries: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+//MarkDeliverySucceeded flags the delivery as delivered
+func (db *PostgreSQLDB) MarkDeliverySucceeded(id int64) error {
+	query := "UPDATE public.webhook_delivery SET status = 'delivered', attempts = attempts + 1, delivered_at = NOW(), last_error = NULL WHERE id = $1"
+
+	if _, err := db.pool.Exec(context.Background(), query, id); err != nil {
+		return fmt.Errorf("could not mark delivery %d as delivered: %v", id, err)
+	}
+
+	return nil
+}
+
+//MarkDeliveryFailed records a failed attempt. The delivery is retried at retryAt, or moved to the dead letter list when it is nil
+func (db *PostgreSQLDB) MarkDeliveryFailed(id int64, reason string, retryAt *time.Time) error {
+	query := `UPDATE public.webhook_delivery SET attempts = attempts + 1, last_error = $2,
+		status = CASE WHEN $3::TIMESTAMPTZ IS NULL THEN 'dead' ELSE 'pending' END,
+		next_attempt_at = COALESCE($3, next_attempt_at)
+		WHERE id = $1`
+
+	if _, err := db.pool.Exec(context.Background(), query, id, reason, retryAt); err != nil {
+		return fmt.Errorf("could not mark delivery %d as failed: %v", id, err)
+	}
+
+	return nil
+}
+
+//Deliveries retrieves the deliveries of a subscription, optionally filtered by status. A zero subscriptionID lists all subscriptions
+func (db *PostgreSQLDB) Deliveries(subscriptionID int64, status string) ([]contract.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM public.webhook_delivery d
+		WHERE ($1::BIGINT = 0 OR d.subscription_id = $1) AND ($2::TEXT = '' OR d.status = $2)
+		ORDER BY d.id`
+
+	rows, err := db.pool.Query(context.Background(), query, subscriptionID, status)
+	if err != nil {
+		return nil, fmt.Errorf("could not get deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]contract.WebhookDelivery, 0)
+	for rows.Next() {
+		d := contract.WebhookDelivery{}
+		if err = rows.Scan(deliveryFields(&d)...); err != nil {
+			return nil, fmt.Errorf("could not get deliveries: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get deliveries: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+//ReplayDelivery schedules a dead delivery to be attempted again right away
+func (db *PostgreSQLDB) ReplayDelivery(id int64) error {
+	ctx := context.Background()
+
+	var status string
+	err := db.pool.QueryRow(ctx, "SELECT status FROM public.webhook_delivery WHERE id = $1", id).Scan(&status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("could not replay delivery: %v", err)
+	}
+
+	tag, err := db.pool.Exec(ctx, replayQuery+" WHERE id = $1 AND status = 'dead'", id)
+	if err != nil {
+		return fmt.Errorf("could not replay delivery: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidState
+	}
+
+	return nil
+}
+
+//ReplayDeadDeliveries schedules every dead delivery of a subscription to be attempted again right away
+func (db *PostgreSQLDB) ReplayDeadDeliveries(subscriptionID int64) (int64, error) {
+	tag, err := db.pool.Exec(context.Background(), replayQuery+" WHERE subscription_id = $1 AND status = 'dead'", subscriptionID)
+	if err != nil {
+		return 0, fmt.Errorf("could not replay deliveries: %v", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+const replayQuery = "UPDATE public.webhook_delivery SET status = 'pending', attempts = 0, next_attempt_at = NOW()"
+
+func scanSubscription(row pgx.Row) (*contract.WebhookSubscription, error) {
+	sub := &contract.WebhookSubscription{}
+
+	err := row.Scan(&sub.ID, &sub.URL, &sub.EventTypes, &sub.Brands, &sub.SKUPrefixes, &sub.Secret, &sub.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+//scan destinations of deliveryColumns followed by the extra ones
+func deliveryFields(d *contract.WebhookDelivery, extra ...interface{}) []interface{} {
+	fields := []interface{}{
+		&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.SKU, &d.Status, &d.Attempts,
+		&d.LastError, &d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt,
+	}
+
+	return append(fields, extra...)
+}
+
+//arrays are stored as empty instead of NULL
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestWebhookDeliveries(t *testing.T) {
+	m := initTestDB(t)
+	defer func() {
+		if err := m.Down(); err != nil {
+			t.Fatalf("could not down migrate %s", err)
+		}
+	}()
+
+	db, err := NewPostgreSQLDB(dbURI)
+	if err != nil {
+		t.Fatalf("could not init database connection: %s", err)
+	}
+
+	defer db.Close()
+
+	sub, err := db.CreateSubscription(contract.WebhookSubscription{URL: "http://partner/hook", Secret: "0123456789abcdef"})
+	if err != nil {
+		t.Fatalf("could not create subscription: %v", err)
+	}
+
+	event := contract.ProductEvent{ID: 1, Type: contract.EventProductCreated, SKU: "FAL-1000000"}
+
+	//enqueuing the same event twice must create a single delivery
+	for i := 0; i < 2; i++ {
+		if err := db.EnqueueDeliveries(event, []int64{sub.ID}); err != nil {
+			t.Fatalf("could not enqueue deliveries: %v", err)
+		}
+	}
+
+	due, err := db.DueDeliveries(10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("#1: one due delivery expected. Got: %v, error: %v", len(due), err)
+	}
+
+	if due[0].URL != sub.URL || due[0].Secret != sub.Secret || len(due[0].Payload) == 0 {
+		t.Errorf("#2: due delivery must carry the subscription and payload: %+v", due[0])
+	}
+
+	if err := db.ReplayDelivery(due[0].ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("#3: only dead deliveries can be replayed. Got: %v", err)
+	}
+
+	db.MarkDeliveryFailed(due[0].ID, "mocked error", nil)
+
+	dead, _ := db.Deliveries(sub.ID, contract.DeliveryDead)
+	if len(dead) != 1 || dead[0].LastError != "mocked error" {
+		t.Errorf("#4: delivery must be in the dead letter list: %+v", dead)
+	}
+
+	if n, err := db.ReplayDeadDeliveries(sub.ID); err != nil || n != 1 {
+		t.Errorf("#5: one delivery must be replayed. Got: %v, error: %v", n, err)
+	}
+
+	retryAt := time.Now().Add(time.Hour)
+	db.MarkDeliveryFailed(due[0].ID, "mocked error", &retryAt)
+
+	if due, _ = db.DueDeliveries(10); len(due) != 0 {
+		t.Errorf("#6: delivery must wait for its retry")
+	}
+
+	if err := db.DeleteSubscription(sub.ID); err != nil {
+		t.Errorf("#7: error not expected: %v", err)
+	}
+
+	if _, err := db.Subscription(sub.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("#8: subscription must be deleted. Got: %v", err)
+	}
+}
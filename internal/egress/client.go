@@ -0,0 +1,76 @@
+//Package egress builds the http clients sending requests to the URLs chosen by the users, like the image URLs
+//of the products and the webhook endpoints
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+//maxRedirects how many redirects are followed
+const maxRedirects = 5
+
+//ErrForbiddenAddress returned when a URL resolves to an address that is not public, like loopback, private or
+//link-local ones
+var ErrForbiddenAddress = errors.New("the url does not resolve to a public address")
+
+//NewClient creates an http client only connecting to public addresses, also when following redirects, to keep the
+//requests to the URLs of the users from reaching the internal network
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: publicAddress,
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			//a proxy would dial the addresses in place of the dialer
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+//rejects the connections to the addresses that are not public. It runs once the host is resolved, so names
+//resolving to internal addresses are rejected too
+func publicAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !public(ip) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+func public(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+
+	//this network and the shared address space of the carrier-grade NATs
+	if ip4 := ip.To4(); ip4 != nil && (ip4[0] == 0 || (ip4[0] == 100 && ip4[1]&0xc0 == 64)) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,58 @@
+package egress
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	client := NewClient(time.Second)
+
+	for desc, u := range map[string]string{
+		"#1: loopback":   "http://127.0.0.1:8081/hook",
+		"#2: localhost":  "http://localhost:8081/hook",
+		"#3: metadata":   "http://169.254.169.254/latest/meta-data/",
+		"#4: private":    "http://10.0.0.1:8080/hook",
+		"#5: unassigned": "http://0.0.0.0/hook",
+	} {
+		resp, err := client.Get(u)
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		if !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("%s:\n Got: %v\n Expected: %v", desc, err, ErrForbiddenAddress)
+		}
+	}
+
+	tests := map[string]struct {
+		ip             string
+		publicExpected bool
+	}{
+		"#6: public ipv4":     {ip: "93.184.216.34", publicExpected: true},
+		"#7: public ipv6":     {ip: "2606:2800:220:1:248:1893:25c8:1946", publicExpected: true},
+		"#8: private ipv4":    {ip: "192.168.1.10"},
+		"#9: loopback ipv6":   {ip: "::1"},
+		"#10: unique local":   {ip: "fd00::1"},
+		"#11: link-local":     {ip: "fe80::1"},
+		"#12: mapped private": {ip: "::ffff:172.16.0.1"},
+		"#13: shared address": {ip: "100.64.0.1"},
+	}
+
+	for desc, tc := range tests {
+		if got := public(net.ParseIP(tc.ip)); got != tc.publicExpected {
+			t.Errorf("%s:\n Got: %v\n Expected: %v", desc, got, tc.publicExpected)
+		}
+	}
+
+	if err := client.CheckRedirect(&http.Request{}, make([]*http.Request, maxRedirects-1)); err != nil {
+		t.Errorf("#14: %d redirects must be followed. Got: %v", maxRedirects, err)
+	}
+
+	if err := client.CheckRedirect(&http.Request{}, make([]*http.Request, maxRedirects)); err == nil {
+		t.Errorf("#15: more than %d redirects must fail", maxRedirects)
+	}
+}
@@ -0,0 +1,121 @@
+//Package i18n selects the languages of the requests from their Accept-Language header
+package i18n
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+type languagesKey struct{}
+
+//WithLanguages passes the languages of the request through the context, by preference
+func WithLanguages(ctx context.Context, tags []string) context.Context {
+	return context.WithValue(ctx, languagesKey{}, tags)
+}
+
+//Languages retrieves the languages passed through the context by WithLanguages
+func Languages(ctx context.Context) []string {
+	tags, _ := ctx.Value(languagesKey{}).([]string)
+	return tags
+}
+
+//Canonical retrieves the canonical form of a BCP 47 language tag. e.g.: pt-br -> pt-BR
+func Canonical(tag string) (string, error) {
+	t, err := language.Parse(strings.TrimSpace(tag))
+	if err != nil {
+		return "", err
+	}
+
+	return t.String(), nil
+}
+
+//ParseAcceptLanguage retrieves the canonical languages of an Accept-Language header ordered by preference. The
+//invalid and unacceptable (q=0) ones are skipped, and so is the * wildcard, which accepts any language
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var (
+		ranges []weighted
+		seen   = make(map[string]bool)
+	)
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) != 2 || kv[0] != "q" {
+				continue
+			}
+
+			var err error
+			if q, err = strconv.ParseFloat(kv[1], 64); err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+		}
+
+		tag, err := Canonical(fields[0])
+		if err != nil || tag == "und" || q == 0 || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+
+		ranges = append(ranges, weighted{tag, q})
+	}
+
+	//the ranges with the same quality keep the order of the header
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	tags := make([]string, len(ranges))
+	for i, r := range ranges {
+		tags[i] = r.tag
+	}
+
+	return tags
+}
+
+//Fallbacks expands the languages with their less specific forms, so each language is followed by the ones
+//to look up when it is not available. e.g.: es-AR, pt-BR -> es-AR, es, pt-BR, pt
+func Fallbacks(tags []string) []string {
+	var (
+		chain []string
+		seen  = make(map[string]bool)
+	)
+
+	for _, tag := range tags {
+		for tag != "" {
+			if !seen[tag] {
+				seen[tag] = true
+				chain = append(chain, tag)
+			}
+			tag = parent(tag)
+		}
+	}
+
+	return chain
+}
+
+//removes the last subtag of the language tag, along with the singletons left at its end. e.g.: zh-Hant-TW -> zh-Hant
+func parent(tag string) string {
+	for {
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			return ""
+		}
+
+		tag = tag[:i]
+		if j := strings.LastIndex(tag, "-"); j < 0 || len(tag)-j-1 > 1 {
+			return tag
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package i18n
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := map[string]struct {
+		header        string
+		tagsExpected  []string
+		fallsExpected []string
+	}{
+		"#1: empty header":         {header: "", tagsExpected: []string{}, fallsExpected: nil},
+		"#2: single language":      {header: "es", tagsExpected: []string{"es"}, fallsExpected: []string{"es"}},
+		"#3: canonical forms":      {header: "PT-br, zh-hant-tw", tagsExpected: []string{"pt-BR", "zh-Hant-TW"}, fallsExpected: []string{"pt-BR", "pt", "zh-Hant-TW", "zh-Hant", "zh"}},
+		"#4: ordered by quality":   {header: "en;q=0.5, es-AR, pt;q=0.8", tagsExpected: []string{"es-AR", "pt", "en"}, fallsExpected: []string{"es-AR", "es", "pt", "en"}},
+		"#5: unacceptable skipped": {header: "fr;q=0, es", tagsExpected: []string{"es"}, fallsExpected: []string{"es"}},
+		"#6: wildcard skipped":     {header: "*;q=0.1, pt", tagsExpected: []string{"pt"}, fallsExpected: []string{"pt"}},
+		"#7: invalid skipped":      {header: "x-bad!!, es;q=abc, pt-PT", tagsExpected: []string{"pt-PT"}, fallsExpected: []string{"pt-PT", "pt"}},
+		"#8: repeated languages":   {header: "es-ES, es, es-es;q=0.5", tagsExpected: []string{"es-ES", "es"}, fallsExpected: []string{"es-ES", "es"}},
+		"#9: extensions":           {header: "en-US-u-ca-buddhist", tagsExpected: []string{"en-US-u-ca-buddhist"}, fallsExpected: []string{"en-US-u-ca-buddhist", "en-US-u-ca", "en-US", "en"}},
+	}
+
+	for desc, tc := range tests {
+		tags := ParseAcceptLanguage(tc.header)
+		if !reflect.DeepEqual(tags, tc.tagsExpected) {
+			t.Errorf("%s:\n Got: %v\n Expected: %v", desc, tags, tc.tagsExpected)
+		}
+
+		if falls := Fallbacks(tags); !reflect.DeepEqual(falls, tc.fallsExpected) {
+			t.Errorf("%s:\n fallbacks got: %v\n Expected: %v", desc, falls, tc.fallsExpected)
+		}
+	}
+}
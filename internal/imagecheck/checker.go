@@ -0,0 +1,291 @@
+//Package imagecheck checks periodically that the image URLs of the products are reachable
+package imagecheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/egress"
+	"github.com/garciacer87/product-api/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBatchSize    = 100
+	defaultConcurrency  = 4
+	defaultRate         = 10
+	defaultRecheckAfter = 24 * time.Hour
+	defaultTimeout      = 10 * time.Second
+
+	userAgent = "product-api-image-checker/1.0"
+)
+
+//Doer abstraction of the http client checking the URLs
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+//Checker issues HEAD requests to the image URLs of the products and records whether they are reachable.
+//URLs are checked again once RecheckAfter has passed since their last check
+type Checker struct {
+	store    db.ImageChecks
+	client   Doer
+	interval time.Duration
+
+	BatchSize int
+	//Concurrency how many URLs are checked at the same time
+	Concurrency int
+	//Rate maximum requests per second. 0 does not limit the requests
+	Rate         float64
+	RecheckAfter time.Duration
+	//Timeout of the check of a URL
+	Timeout time.Duration
+
+	//prefix of the URLs of the images uploaded to the API, which are checked against the objects of the storage
+	uploadedURL string
+	objects     storage.Storage
+}
+
+//NewChecker creates a checker looking for due URLs every interval
+func NewChecker(store db.ImageChecks, client Doer, interval time.Duration) *Checker {
+	return &Checker{
+		store:        store,
+		client:       client,
+		interval:     interval,
+		BatchSize:    defaultBatchSize,
+		Concurrency:  defaultConcurrency,
+		Rate:         defaultRate,
+		RecheckAfter: defaultRecheckAfter,
+		Timeout:      defaultTimeout,
+	}
+}
+
+//Uploaded checks the URLs of the images uploaded to the API, served from baseURL/images, against the objects of the
+//storage instead of requesting them. The API is usually served from an internal address the checker cannot reach
+func (c *Checker) Uploaded(baseURL string, objects storage.Storage) {
+	c.uploadedURL = strings.TrimSuffix(baseURL, "/") + "/images/"
+	c.objects = objects
+}
+
+//Run checks the due URLs until the context is done
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		//the batches are checked back to back until no URL is due
+		for ctx.Err() == nil {
+			n, err := c.CheckDue(ctx)
+			if err != nil {
+				logrus.Errorf("image checker: %v", err)
+				break
+			}
+
+			if n < c.BatchSize {
+				break
+			}
+		}
+
+		if n, err := c.store.DeleteUnusedImageChecks(); err != nil {
+			logrus.Errorf("image checker: %v", err)
+		} else if n > 0 {
+			logrus.Infof("%d unused image check(s) deleted", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//CheckDue checks one batch of due URLs and returns how many were checked
+func (c *Checker) CheckDue(ctx context.Context) (int, error) {
+	urls, err := c.store.DueImageURLs(time.Now().Add(-c.RecheckAfter), c.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var limit <-chan time.Time
+	if c.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / c.Rate))
+		defer ticker.Stop()
+		limit = ticker.C
+	}
+
+	var (
+		jobs    = make(chan string)
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		checked int
+		errs    []error
+	)
+
+	workers := c.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for u := range jobs {
+				check := c.Check(ctx, u)
+				if ctx.Err() != nil {
+					//the result of a cancelled check tells nothing about the URL
+					continue
+				}
+
+				err := c.store.RecordImageCheck(check)
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					checked++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, u := range urls {
+		if limit != nil {
+			select {
+			case <-ctx.Done():
+				break dispatch
+			case <-limit:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- u:
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return checked, fmt.Errorf("could not record %d check(s): %v", len(errs), errs[0])
+	}
+
+	return checked, nil
+}
+
+//Check requests the headers of the URL. It is reachable when it answers a successful status with an image, or no
+//content type at all. Servers not allowing HEAD requests are asked for the first byte of the image instead
+func (c *Checker) Check(ctx context.Context, rawURL string) contract.ImageCheck {
+	check := contract.ImageCheck{URL: rawURL, CheckedAt: time.Now()}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		check.Error = "not an http or https url"
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	if c.objects != nil && strings.HasPrefix(rawURL, c.uploadedURL) {
+		return c.checkObject(ctx, check, strings.TrimPrefix(rawURL, c.uploadedURL))
+	}
+
+	resp, err := c.request(ctx, http.MethodHead, rawURL)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp, err = c.request(ctx, http.MethodGet, rawURL)
+	}
+
+	if err != nil {
+		check.Error = err.Error()
+		//the internal addresses the url resolves to are not told
+		if errors.Is(err, egress.ErrForbiddenAddress) {
+			check.Error = egress.ErrForbiddenAddress.Error()
+		}
+		return check
+	}
+
+	check.Status = resp.StatusCode
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		check.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+		return check
+	}
+
+	//servers answering missing images with a page are caught by its content type
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		if mediaType, _, _ := mime.ParseMediaType(ct); !strings.HasPrefix(mediaType, "image/") {
+			check.Error = fmt.Sprintf("not an image: %s", ct)
+			return check
+		}
+	}
+
+	check.OK = true
+	return check
+}
+
+//checks that the object of an uploaded image is stored
+func (c *Checker) checkObject(ctx context.Context, check contract.ImageCheck, key string) contract.ImageCheck {
+	var obj *storage.Object
+
+	err := storage.ErrNotFound
+	if storage.ValidKey(key) {
+		obj, err = c.objects.Get(ctx, key)
+	}
+
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			check.Status = http.StatusNotFound
+			check.Error = "the uploaded image does not exist"
+			return check
+		}
+
+		check.Error = fmt.Sprintf("could not get the uploaded image: %v", err)
+		return check
+	}
+	obj.Body.Close()
+
+	check.Status = http.StatusOK
+	check.OK = true
+	return check
+}
+
+//sends a request to the URL, discarding the body of its response
+func (c *Checker) request(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<10))
+	resp.Body.Close()
+
+	return resp, nil
+}
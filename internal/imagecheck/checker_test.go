@@ -0,0 +1,221 @@
+package imagecheck
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/egress"
+	"github.com/garciacer87/product-api/internal/storage"
+)
+
+//fakeClient answers the requests without network access
+type fakeClient func(req *http.Request) (*http.Response, error)
+
+func (f fakeClient) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func response(status int, contentType string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}
+	if contentType != "" {
+		resp.Header.Set("Content-Type", contentType)
+	}
+	return resp
+}
+
+//in memory store of the checks
+type mockStore struct {
+	db.ImageChecks
+
+	mu     sync.Mutex
+	urls   []string
+	checks map[string]contract.ImageCheck
+}
+
+func (m *mockStore) DueImageURLs(_ time.Time, limit int) ([]string, error) {
+	if len(m.urls) > limit {
+		return m.urls[:limit], nil
+	}
+	return m.urls, nil
+}
+
+func (m *mockStore) RecordImageCheck(check contract.ImageCheck) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.checks[check.URL] = check
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	client := fakeClient(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("User-Agent") != userAgent {
+			return nil, errors.New("missing user agent")
+		}
+
+		switch req.URL.Path {
+		case "/ok.png":
+			return response(http.StatusOK, "image/png"), nil
+		case "/untyped":
+			return response(http.StatusOK, ""), nil
+		case "/missing.png":
+			return response(http.StatusNotFound, "text/html"), nil
+		case "/soft-404.png":
+			return response(http.StatusOK, "text/html; charset=utf-8"), nil
+		case "/no-head.jpg":
+			if req.Method == http.MethodHead {
+				return response(http.StatusMethodNotAllowed, ""), nil
+			}
+			if req.Header.Get("Range") != "bytes=0-0" {
+				return nil, errors.New("the whole image was requested")
+			}
+			return response(http.StatusPartialContent, "image/jpeg"), nil
+		}
+
+		return nil, errors.New("connection refused")
+	})
+
+	checker := NewChecker(nil, client, time.Hour)
+
+	tests := map[string]struct {
+		url            string
+		okExpected     bool
+		statusExpected int
+		errorExpected  string
+	}{
+		"#1: image":              {url: "http://cdn/ok.png", okExpected: true, statusExpected: http.StatusOK},
+		"#2: without type":       {url: "http://cdn/untyped", okExpected: true, statusExpected: http.StatusOK},
+		"#3: not found":          {url: "http://cdn/missing.png", statusExpected: http.StatusNotFound, errorExpected: "unexpected status 404"},
+		"#4: not an image":       {url: "http://cdn/soft-404.png", statusExpected: http.StatusOK, errorExpected: "not an image: text/html"},
+		"#5: head not allowed":   {url: "http://cdn/no-head.jpg", okExpected: true, statusExpected: http.StatusPartialContent},
+		"#6: unreachable":        {url: "http://down/a.png", errorExpected: "connection refused"},
+		"#7: unsupported scheme": {url: "ftp://cdn/a.png", errorExpected: "not an http or https url"},
+		"#8: relative url":       {url: "/a.png", errorExpected: "not an http or https url"},
+	}
+
+	for desc, tc := range tests {
+		check := checker.Check(context.Background(), tc.url)
+		if check.OK != tc.okExpected || check.Status != tc.statusExpected || !strings.Contains(check.Error, tc.errorExpected) || check.CheckedAt.IsZero() {
+			t.Errorf("%s:\n Got: %+v\n Expected: %v %v %s", desc, check, tc.okExpected, tc.statusExpected, tc.errorExpected)
+		}
+	}
+}
+
+func TestCheckUploaded(t *testing.T) {
+	objects, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create the storage: %v", err)
+	}
+	objects.Put(context.Background(), "products/a.png", []byte("image"), "image/png")
+
+	//the uploaded images are not requested, the API may not be reachable from the checker
+	client := fakeClient(func(req *http.Request) (*http.Response, error) {
+		return nil, egress.ErrForbiddenAddress
+	})
+
+	checker := NewChecker(nil, client, time.Hour)
+	checker.Uploaded("http://localhost:8080/", objects)
+
+	tests := map[string]struct {
+		url            string
+		okExpected     bool
+		statusExpected int
+		errorExpected  string
+	}{
+		"#1: uploaded image":   {url: "http://localhost:8080/images/products/a.png", okExpected: true, statusExpected: http.StatusOK},
+		"#2: missing image":    {url: "http://localhost:8080/images/products/b.png", statusExpected: http.StatusNotFound, errorExpected: "the uploaded image does not exist"},
+		"#3: invalid key":      {url: "http://localhost:8080/images/../a.png", statusExpected: http.StatusNotFound, errorExpected: "the uploaded image does not exist"},
+		"#4: other api url":    {url: "http://localhost:8080/product/FAL-1000000", errorExpected: egress.ErrForbiddenAddress.Error()},
+		"#5: other server url": {url: "http://localhost:9090/images/products/a.png", errorExpected: egress.ErrForbiddenAddress.Error()},
+	}
+
+	for desc, tc := range tests {
+		check := checker.Check(context.Background(), tc.url)
+		if check.OK != tc.okExpected || check.Status != tc.statusExpected || !strings.Contains(check.Error, tc.errorExpected) {
+			t.Errorf("%s:\n Got: %+v\n Expected: %v %v %s", desc, check, tc.okExpected, tc.statusExpected, tc.errorExpected)
+		}
+	}
+}
+
+func TestCheckDue(t *testing.T) {
+	var inFlight, maxInFlight int32
+
+	client := fakeClient(func(req *http.Request) (*http.Response, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		return response(http.StatusOK, "image/png"), nil
+	})
+
+	store := &mockStore{checks: map[string]contract.ImageCheck{}}
+	for _, u := range []string{"a", "b", "c", "d", "e", "f"} {
+		store.urls = append(store.urls, "http://cdn/"+u+".png")
+	}
+
+	checker := NewChecker(store, client, time.Hour)
+	checker.BatchSize, checker.Concurrency, checker.Rate = 5, 2, 100
+
+	start := time.Now()
+	n, err := checker.CheckDue(context.Background())
+	elapsed := time.Since(start)
+
+	if err != nil || n != 5 || len(store.checks) != 5 {
+		t.Errorf("#1: five urls checked expected. Got: %d, %d recorded, error: %v", n, len(store.checks), err)
+	}
+
+	if maxInFlight > 2 {
+		t.Errorf("#2: at most 2 concurrent checks expected. Got: %d", maxInFlight)
+	}
+
+	//five requests at 100 per second cannot start before 50ms
+	if elapsed < 50*time.Millisecond {
+		t.Errorf("#3: the rate limit was not respected. Elapsed: %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if n, _ := checker.CheckDue(ctx); n != 0 {
+		t.Errorf("#4: no url should be checked once cancelled. Got: %d", n)
+	}
+}
+
+func TestCheckInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+	}))
+	defer srv.Close()
+
+	checker := NewChecker(nil, egress.NewClient(time.Second), time.Hour)
+
+	for desc, u := range map[string]string{
+		"#1: loopback":   srv.URL + "/a.png",
+		"#2: localhost":  strings.Replace(srv.URL, "127.0.0.1", "localhost", 1) + "/a.png",
+		"#3: metadata":   "http://169.254.169.254/latest/meta-data/",
+		"#4: private":    "http://10.0.0.1:8080/a.png",
+		"#5: unassigned": "http://0.0.0.0/a.png",
+	} {
+		check := checker.Check(context.Background(), u)
+		if check.OK || check.Status != 0 || check.Error != egress.ErrForbiddenAddress.Error() {
+			t.Errorf("%s:\n Got: %+v\n Expected: %v", desc, check, egress.ErrForbiddenAddress)
+		}
+	}
+}
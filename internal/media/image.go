@@ -0,0 +1,170 @@
+//Package media validates the uploaded product images and generates their thumbnails
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	//decoders of the accepted formats
+	_ "image/gif"
+)
+
+//Formats of the accepted images by content type, with the extension of their files
+var Formats = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+var (
+	//ErrUnsupportedType returned when the image is not JPEG, PNG or GIF
+	ErrUnsupportedType = errors.New("unsupported image type")
+	//ErrTooLarge returned when the image file exceeds the maximum size
+	ErrTooLarge = errors.New("image too large")
+	//ErrInvalidImage returned when the image cannot be decoded or its dimensions are out of the limits
+	ErrInvalidImage = errors.New("invalid image")
+)
+
+//Limits of the accepted images
+type Limits struct {
+	//MaxSize maximum size of the image file in bytes
+	MaxSize int64
+	//MinWidth and MinHeight minimum dimensions in pixels
+	MinWidth, MinHeight int
+	//MaxWidth and MaxHeight maximum dimensions in pixels. Images are checked before being decoded, so large
+	//images cannot exhaust the memory
+	MaxWidth, MaxHeight int
+	//ThumbnailSize maximum width and height of the thumbnails in pixels
+	ThumbnailSize int
+}
+
+//DefaultLimits accepts images of up to 5MB between 100x100 and 5000x5000 pixels, generating 256px thumbnails
+var DefaultLimits = Limits{
+	MaxSize:       5 << 20,
+	MinWidth:      100,
+	MinHeight:     100,
+	MaxWidth:      5000,
+	MaxHeight:     5000,
+	ThumbnailSize: 256,
+}
+
+//Image validated image along with its thumbnail
+type Image struct {
+	ContentType string
+	Width       int
+	Height      int
+	Data        []byte
+
+	Thumbnail            []byte
+	ThumbnailContentType string
+}
+
+//Process validates the type, size and dimensions of the image and generates its thumbnail. The type is sniffed from
+//the content, never taken from the client. The errors wrap ErrUnsupportedType, ErrTooLarge or ErrInvalidImage
+func Process(data []byte, limits Limits) (*Image, error) {
+	if int64(len(data)) > limits.MaxSize {
+		return nil, fmt.Errorf("%w: the maximum size is %d bytes", ErrTooLarge, limits.MaxSize)
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := Formats[contentType]; !ok {
+		return nil, fmt.Errorf("%w: %s. The accepted types are image/jpeg, image/png and image/gif", ErrUnsupportedType, contentType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not decode the image: %v", ErrInvalidImage, err)
+	}
+
+	if cfg.Width < limits.MinWidth || cfg.Height < limits.MinHeight || cfg.Width > limits.MaxWidth || cfg.Height > limits.MaxHeight {
+		return nil, fmt.Errorf("%w: the image is %dx%d pixels and must be between %dx%d and %dx%d", ErrInvalidImage,
+			cfg.Width, cfg.Height, limits.MinWidth, limits.MinHeight, limits.MaxWidth, limits.MaxHeight)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not decode the image: %v", ErrInvalidImage, err)
+	}
+
+	img := &Image{ContentType: contentType, Width: cfg.Width, Height: cfg.Height, Data: data}
+
+	//JPEG thumbnails of photos are smaller, while PNG keeps the transparency of the other formats
+	var buf bytes.Buffer
+	thumb := Thumbnail(src, limits.ThumbnailSize)
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+		img.ThumbnailContentType = "image/jpeg"
+	} else {
+		err = png.Encode(&buf, thumb)
+		img.ThumbnailContentType = "image/png"
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not encode the thumbnail: %v", err)
+	}
+
+	img.Thumbnail = buf.Bytes()
+	return img, nil
+}
+
+//Thumbnail scales the image down to fit a square of size pixels, keeping its aspect ratio. Each pixel of the
+//thumbnail is the average of the pixels of the image it covers. Smaller images are kept as they are
+func Thumbnail(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	if w <= size && h <= size {
+		return src
+	}
+
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+
+	rgba := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, (y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, (x+1)*w/tw
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				i := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					pa := uint64(rgba.Pix[i+3])
+					//the colors are weighted by their alpha, so transparent pixels do not darken the edges
+					r += uint64(rgba.Pix[i]) * pa
+					g += uint64(rgba.Pix[i+1]) * pa
+					b += uint64(rgba.Pix[i+2]) * pa
+					a += pa
+					n++
+					i += 4
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			if a > 0 {
+				dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2] = uint8(r/a), uint8(g/a), uint8(b/a)
+			}
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,85 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(w, h int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, 0, color.NRGBA{R: 255, A: 255})
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func encodeJPEG(w, h int) []byte {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil)
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	tests := map[string]struct {
+		data          []byte
+		limits        Limits
+		typeExpected  string
+		thumbExpected image.Point
+		errExpected   error
+	}{
+		"#1: png":             {data: encodePNG(400, 200), limits: DefaultLimits, typeExpected: "image/png", thumbExpected: image.Pt(256, 128)},
+		"#2: jpeg":            {data: encodeJPEG(300, 600), limits: DefaultLimits, typeExpected: "image/jpeg", thumbExpected: image.Pt(128, 256)},
+		"#3: small thumbnail": {data: encodePNG(120, 100), limits: DefaultLimits, typeExpected: "image/png", thumbExpected: image.Pt(120, 100)},
+		"#4: too small":       {data: encodePNG(50, 200), limits: DefaultLimits, errExpected: ErrInvalidImage},
+		"#5: too wide":        {data: encodePNG(400, 200), limits: Limits{MaxSize: 1 << 20, MaxWidth: 300, MaxHeight: 300}, errExpected: ErrInvalidImage},
+		"#6: too large":       {data: encodePNG(400, 200), limits: Limits{MaxSize: 10, MaxWidth: 500, MaxHeight: 500}, errExpected: ErrTooLarge},
+		"#7: unsupported":     {data: []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"), limits: DefaultLimits, errExpected: ErrUnsupportedType},
+		"#8: truncated":       {data: encodePNG(400, 200)[:60], limits: DefaultLimits, errExpected: ErrInvalidImage},
+	}
+
+	for desc, tc := range tests {
+		img, err := Process(tc.data, tc.limits)
+		if !errors.Is(err, tc.errExpected) {
+			t.Errorf("%s:\n error got: %v\n expected: %v", desc, err, tc.errExpected)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		thumb, _, err := image.Decode(bytes.NewReader(img.Thumbnail))
+		if err != nil {
+			t.Fatalf("%s: could not decode the thumbnail: %v", desc, err)
+		}
+
+		if img.ContentType != tc.typeExpected || img.ThumbnailContentType != tc.typeExpected || thumb.Bounds().Size() != tc.thumbExpected {
+			t.Errorf("%s:\n Got: %s %s %v\n Expected: %s %v", desc, img.ContentType, img.ThumbnailContentType, thumb.Bounds().Size(), tc.typeExpected, tc.thumbExpected)
+		}
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	//half transparent, half opaque red: the transparent pixels do not change the color
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		src.Set(x, 0, color.NRGBA{R: 200, A: 255})
+	}
+
+	thumb := Thumbnail(src, 2).(*image.NRGBA)
+	if thumb.Bounds().Size() != image.Pt(2, 1) {
+		t.Fatalf("size got: %v", thumb.Bounds().Size())
+	}
+
+	if c := thumb.NRGBAAt(0, 0); c != (color.NRGBA{R: 200, A: 127}) {
+		t.Errorf("color got: %v", c)
+	}
+}
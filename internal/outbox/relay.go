@@ -0,0 +1,129 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBatchSize  = 100
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = 10 * time.Minute
+)
+
+//Relay delivers the events written in the outbox to the sinks.
+//Delivery is at-least-once: an event is marked as delivered only after every sink accepted it,
+//so a failure on one sink re-delivers the event to all of them on the next attempt.
+//Events of the same SKU are delivered in version order. Only one relay should run against an outbox
+type Relay struct {
+	store    db.Outbox
+	sinks    []Sink
+	interval time.Duration
+
+	BatchSize  int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+//NewRelay creates a relay polling the outbox every interval
+func NewRelay(store db.Outbox, interval time.Duration, sinks ...Sink) *Relay {
+	return &Relay{
+		store:      store,
+		sinks:      sinks,
+		interval:   interval,
+		BatchSize:  defaultBatchSize,
+		MinBackoff: defaultMinBackoff,
+		MaxBackoff: defaultMaxBackoff,
+	}
+}
+
+//Run delivers pending events until the context is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Flush(ctx); err != nil {
+			logrus.Errorf("outbox relay: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//Flush delivers one batch of pending events and returns how many were delivered
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	events, err := r.store.PendingEvents(r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		delivered int
+		blocked   = make(map[string]bool)
+	)
+
+	for _, event := range events {
+		if ctx.Err() != nil {
+			return delivered, nil
+		}
+
+		//a failed event blocks the following events of the same SKU
+		if blocked[event.SKU] {
+			continue
+		}
+
+		if err := r.publish(ctx, event); err != nil {
+			blocked[event.SKU] = true
+
+			retryAt := time.Now().Add(r.backoff(event.Attempts))
+			logrus.Warnf("could not deliver event %d of product %s (attempt %d), retrying at %s: %v",
+				event.ID, event.SKU, event.Attempts+1, retryAt.Format(time.RFC3339), err)
+
+			if err := r.store.MarkFailed(event.ID, err.Error(), retryAt); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+
+		if err := r.store.MarkDelivered(event.ID); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+
+	return delivered, nil
+}
+
+func (r *Relay) publish(ctx context.Context, event contract.ProductEvent) error {
+	for _, sink := range r.sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			return fmt.Errorf("%s sink: %v", sink.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+//exponential backoff bounded by MaxBackoff
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.MinBackoff
+	for i := 0; i < attempts && d < r.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > r.MaxBackoff {
+		d = r.MaxBackoff
+	}
+
+	return d
+}
@@ -0,0 +1,124 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+type mockOutbox struct {
+	events    []contract.ProductEvent
+	delivered map[int64]bool
+	failed    map[int64]int
+}
+
+func newMockOutbox(events ...contract.ProductEvent) *mockOutbox {
+	return &mockOutbox{events: events, delivered: map[int64]bool{}, failed: map[int64]int{}}
+}
+
+func (m *mockOutbox) PendingEvents(limit int) ([]contract.ProductEvent, error) {
+	pending := []contract.ProductEvent{}
+	for _, e := range m.events {
+		if !m.delivered[e.ID] && len(pending) < limit {
+			e.Attempts = m.failed[e.ID]
+			pending = append(pending, e)
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+
+	return pending, nil
+}
+
+func (m *mockOutbox) MarkDelivered(id int64) error {
+	m.delivered[id] = true
+	return nil
+}
+
+func (m *mockOutbox) MarkFailed(id int64, reason string, retryAt time.Time) error {
+	m.failed[id]++
+	return nil
+}
+
+//records published events and fails the events of the configured SKUs
+type mockSink struct {
+	failSKU   map[string]bool
+	published []int64
+}
+
+func (s *mockSink) Name() string {
+	return "mock"
+}
+
+func (s *mockSink) Publish(_ context.Context, event contract.ProductEvent) error {
+	if s.failSKU[event.SKU] {
+		return fmt.Errorf("mocked error")
+	}
+
+	s.published = append(s.published, event.ID)
+
+	return nil
+}
+
+func TestFlush(t *testing.T) {
+	store := newMockOutbox(
+		contract.ProductEvent{ID: 1, SKU: "FAL-1000001", Version: 1},
+		contract.ProductEvent{ID: 2, SKU: "FAL-1000002", Version: 1},
+		contract.ProductEvent{ID: 3, SKU: "FAL-1000001", Version: 2},
+		contract.ProductEvent{ID: 4, SKU: "FAL-1000002", Version: 2},
+	)
+	sink := &mockSink{failSKU: map[string]bool{"FAL-1000002": true}}
+
+	relay := NewRelay(store, time.Second, sink)
+
+	delivered, err := relay.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	if delivered != 2 {
+		t.Errorf("#1: delivered events expected: 2. Got: %v", delivered)
+	}
+
+	if fmt.Sprint(sink.published) != "[1 3]" {
+		t.Errorf("#2: events must be published in order: %v", sink.published)
+	}
+
+	//the second event of the failing SKU must not be attempted before the first one
+	if store.failed[2] != 1 || store.failed[4] != 0 {
+		t.Errorf("#3: only the first event of the failing SKU must be attempted: %v", store.failed)
+	}
+
+	delete(sink.failSKU, "FAL-1000002")
+
+	if _, err := relay.Flush(context.Background()); err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	if fmt.Sprint(sink.published) != "[1 3 2 4]" {
+		t.Errorf("#4: retried events must be published in order: %v", sink.published)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	relay := &Relay{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	tests := map[string]struct {
+		attempts int
+		expected time.Duration
+	}{
+		"#1: first retry":  {attempts: 0, expected: time.Second},
+		"#2: third retry":  {attempts: 2, expected: 4 * time.Second},
+		"#3: bounded wait": {attempts: 50, expected: 10 * time.Second},
+	}
+
+	for desc, tc := range tests {
+		if got := relay.backoff(tc.attempts); got != tc.expected {
+			t.Errorf("%s:\n Got: %v\n Expected: %v", desc, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+//Sink abstraction of a destination of product events
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event contract.ProductEvent) error
+}
+
+//StdoutSink writes every event as a json line
+type StdoutSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+//NewStdoutSink creates a sink writing into w. Usually os.Stdout
+func NewStdoutSink(w io.Writer) *StdoutSink {
+	return &StdoutSink{w: w}
+}
+
+//Name of the sink
+func (s *StdoutSink) Name() string {
+	return "stdout"
+}
+
+//Publish writes the event
+func (s *StdoutSink) Publish(_ context.Context, event contract.ProductEvent) error {
+	line, err := json.Marshal(&event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(append(line, '\n'))
+
+	return err
+}
+
+//WebhookSink posts every event to an http endpoint. Any non 2xx response is a failed delivery
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+//NewWebhookSink creates a sink posting to url
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	return &WebhookSink{url: url, client: client}
+}
+
+//Name of the sink
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+//Publish posts the event
+func (s *WebhookSink) Publish(ctx context.Context, event contract.ProductEvent) error {
+	body, err := json.Marshal(&event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatInt(event.ID, 10))
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+//Publisher abstraction of a message broker client, such as NATS or Kafka.
+//The key is the SKU of the product, so brokers partitioning by key keep the order per SKU
+type Publisher interface {
+	Publish(ctx context.Context, topic, key string, value []byte) error
+}
+
+//BrokerSink publishes every event into a topic of a message broker
+type BrokerSink struct {
+	topic     string
+	publisher Publisher
+}
+
+//NewBrokerSink creates a sink publishing into topic
+func NewBrokerSink(topic string, publisher Publisher) *BrokerSink {
+	return &BrokerSink{topic: topic, publisher: publisher}
+}
+
+//Name of the sink
+func (s *BrokerSink) Name() string {
+	return "broker"
+}
+
+//Publish sends the event to the broker
+func (s *BrokerSink) Publish(ctx context.Context, event contract.ProductEvent) error {
+	value, err := json.Marshal(&event)
+	if err != nil {
+		return err
+	}
+
+	return s.publisher.Publish(ctx, s.topic, event.SKU, value)
+}
@@ -0,0 +1,86 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+func TestWebhookSink(t *testing.T) {
+	var received contract.ProductEvent
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Event-Type") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		json.NewDecoder(req.Body).Decode(&received)
+
+		if received.SKU == "FAL-1000002" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}))
+	defer ts.Close()
+
+	sink := NewWebhookSink(ts.URL, ts.Client())
+
+	tests := map[string]struct {
+		event       contract.ProductEvent
+		errExpected bool
+	}{
+		"#1: valid case":     {event: contract.ProductEvent{ID: 1, SKU: "FAL-1000001", Type: contract.EventProductCreated}},
+		"#2: endpoint error": {event: contract.ProductEvent{ID: 2, SKU: "FAL-1000002", Type: contract.EventProductCreated}, errExpected: true},
+	}
+
+	for desc, tc := range tests {
+		err := sink.Publish(context.Background(), tc.event)
+		isErr := err != nil
+
+		if isErr != tc.errExpected {
+			t.Errorf("%s:\n got Error? %v.\n Error expected? %v.\n Error: %v", desc, isErr, tc.errExpected, err)
+		}
+
+		if received.ID != tc.event.ID {
+			t.Errorf("%s:\n event not received", desc)
+		}
+	}
+}
+
+type mockPublisher struct {
+	topic, key string
+}
+
+func (p *mockPublisher) Publish(_ context.Context, topic, key string, _ []byte) error {
+	p.topic, p.key = topic, key
+	return nil
+}
+
+func TestBrokerAndStdoutSinks(t *testing.T) {
+	event := contract.ProductEvent{ID: 1, SKU: "FAL-1000001", Type: contract.EventProductUpdated}
+
+	publisher := &mockPublisher{}
+	if err := NewBrokerSink("products", publisher).Publish(context.Background(), event); err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	if publisher.topic != "products" || publisher.key != "FAL-1000001" {
+		t.Errorf("message must be keyed by SKU: %+v", publisher)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := NewStdoutSink(buf).Publish(context.Background(), event); err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	written := contract.ProductEvent{}
+	if err := json.Unmarshal(buf.Bytes(), &written); err != nil || written.ID != 1 {
+		t.Errorf("event not written: %s", buf.String())
+	}
+}
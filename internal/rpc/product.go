@@ -0,0 +1,396 @@
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/i18n"
+	"github.com/garciacer87/product-api/internal/rpc/productv1"
+	"github.com/garciacer87/product-api/internal/validation"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	//userMetadata identifies the user making the request, who is tracked as the author of the changes. It is
+	//ignored when the users are authenticated
+	userMetadata = "x-user"
+	//authorizationMetadata carries the bearer token of the user when the users are authenticated
+	authorizationMetadata = "authorization"
+	//skuRuleMetadata selects the SKU rule by name
+	skuRuleMetadata = "x-sku-rule"
+	//sellerMetadata selects the SKU rule of the seller
+	sellerMetadata = "x-seller"
+	//languageMetadata selects the language of the validation messages, with the syntax of the Accept-Language header
+	languageMetadata = "accept-language"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+	maxBatchSize    = 100
+)
+
+//identityKey passes the user authenticated by the server through the context of the calls
+type identityKey struct{}
+
+type productService struct {
+	productv1.UnimplementedProductServiceServer
+
+	db         db.Database
+	validator  *validation.Validator
+	categories db.CategorySchemas
+}
+
+//CreateProduct creates a new product
+func (s *productService) CreateProduct(ctx context.Context, req *productv1.CreateProductRequest) (*productv1.Product, error) {
+	prd := fromProto(req.GetProduct())
+	prd.UpdatedBy = actor(ctx)
+
+	if err := s.validate(ctx, prd); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.db.Get(prd.SKU)
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		return nil, status.Error(codes.Internal, "could not create new product")
+	}
+
+	if existing != nil {
+		return nil, status.Errorf(codes.AlreadyExists, "product %s already exists", prd.SKU)
+	}
+
+	created, err := s.db.Create(prd)
+	if err != nil {
+		//the product may have been created after checking its existence
+		if errors.Is(err, db.ErrAlreadyExists) {
+			return nil, status.Errorf(codes.AlreadyExists, "product %s already exists", prd.SKU)
+		}
+		logrus.Errorf("db error: %v", err)
+		return nil, status.Error(codes.Internal, "could not create new product")
+	}
+
+	logrus.Infof("Product %s created", prd.SKU)
+	return toProto(*created), nil
+}
+
+//GetProduct retrieves a product by its SKU. Calls without user only read the active products
+func (s *productService) GetProduct(ctx context.Context, req *productv1.GetProductRequest) (*productv1.Product, error) {
+	prd, err := s.get(req.GetSku())
+	if err != nil {
+		return nil, err
+	}
+
+	if !visible(ctx, prd) {
+		return nil, status.Errorf(codes.NotFound, "product %s not found", req.GetSku())
+	}
+
+	return toProto(*prd), nil
+}
+
+//ListProducts retrieves a page of products ordered by SKU. The page token is the last SKU of the previous page.
+//Calls without user only list the active products
+func (s *productService) ListProducts(ctx context.Context, req *productv1.ListProductsRequest) (*productv1.ListProductsResponse, error) {
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size cannot be negative")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	afterSKU, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+
+	filter := contract.ProductFilter{AfterSKU: afterSKU, Limit: pageSize + 1}
+	if req.GetUpdatedSince() != nil {
+		if err := req.GetUpdatedSince().CheckValid(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid updated_since")
+		}
+		filter.UpdatedSince = req.GetUpdatedSince().AsTime()
+	}
+
+	if actor(ctx) == "" {
+		filter.Status = contract.StatusActive
+	}
+
+	//one more product than the page size tells if there is a next page
+	prds, err := s.db.List(filter)
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		return nil, status.Error(codes.Internal, "could not get the list of products")
+	}
+
+	resp := &productv1.ListProductsResponse{}
+	if len(prds) > pageSize {
+		prds = prds[:pageSize]
+		resp.NextPageToken = encodePageToken(prds[pageSize-1].SKU)
+	}
+
+	for _, prd := range prds {
+		resp.Products = append(resp.Products, toProto(prd))
+	}
+
+	return resp, nil
+}
+
+//UpdateProduct updates the fields of the product selected by the update mask while the product is locked
+func (s *productService) UpdateProduct(ctx context.Context, req *productv1.UpdateProductRequest) (*productv1.Product, error) {
+	patch := fromProto(req.GetProduct())
+	if patch.SKU == "" {
+		return nil, status.Error(codes.InvalidArgument, "sku is not present")
+	}
+
+	paths := req.GetUpdateMask().GetPaths()
+
+	prd, err := s.db.UpdateFunc(patch.SKU, func(prd *contract.Product) error {
+		prd.UpdatedBy = actor(ctx)
+
+		if len(paths) > 0 {
+			if err := applyMask(prd, patch, paths); err != nil {
+				return err
+			}
+		} else {
+			prd.Patch(patch)
+		}
+
+		return s.validate(ctx, *prd)
+	})
+	if err != nil {
+		var st interface{ GRPCStatus() *status.Status }
+
+		switch {
+		case errors.As(err, &st):
+			return nil, st.GRPCStatus().Err()
+		case errors.Is(err, db.ErrNotFound):
+			return nil, status.Errorf(codes.NotFound, "product %s not found", patch.SKU)
+		default:
+			logrus.Errorf("error updating product: %s", err)
+			return nil, status.Error(codes.Internal, "could not update product")
+		}
+	}
+
+	logrus.Infof("Product %s updated", prd.SKU)
+	return toProto(*prd), nil
+}
+
+//DeleteProduct deletes a product by its SKU. Products with stock are only deleted with cascade
+func (s *productService) DeleteProduct(_ context.Context, req *productv1.DeleteProductRequest) (*emptypb.Empty, error) {
+	if _, err := s.get(req.GetSku()); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Delete(req.GetSku(), req.GetCascade()); err != nil {
+		if errors.Is(err, db.ErrInStock) {
+			return nil, status.Error(codes.FailedPrecondition, "product has stock. Use cascade to delete it along with its stock")
+		}
+
+		logrus.Errorf("error deleting product: %s", err)
+		return nil, status.Error(codes.Internal, "could not delete product")
+	}
+
+	logrus.Infof("Product %s deleted", req.GetSku())
+	return &emptypb.Empty{}, nil
+}
+
+//BatchGetProducts retrieves several products with a single query. The products keep the order of the request.
+//Calls without user get the products that are not active as missing
+func (s *productService) BatchGetProducts(ctx context.Context, req *productv1.BatchGetProductsRequest) (*productv1.BatchGetProductsResponse, error) {
+	skus := req.GetSkus()
+	if len(skus) == 0 || len(skus) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "between 1 and %d skus must be requested", maxBatchSize)
+	}
+
+	prds, missing, err := s.db.GetMany(skus)
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		return nil, status.Error(codes.Internal, "could not get the products")
+	}
+
+	bySKU := make(map[string]contract.Product, len(prds))
+	for _, prd := range prds {
+		if !visible(ctx, &prd) {
+			missing = append(missing, prd.SKU)
+			continue
+		}
+		bySKU[prd.SKU] = prd
+	}
+
+	resp := &productv1.BatchGetProductsResponse{MissingSkus: missing}
+	for _, sku := range skus {
+		if prd, ok := bySKU[sku]; ok {
+			resp.Products = append(resp.Products, toProto(prd))
+			delete(bySKU, sku)
+		}
+	}
+
+	return resp, nil
+}
+
+//retrieves a product translating its absence into a NotFound status
+func (s *productService) get(sku string) (*contract.Product, error) {
+	if sku == "" {
+		return nil, status.Error(codes.InvalidArgument, "sku is not present")
+	}
+
+	prd, err := s.db.Get(sku)
+	if err != nil {
+		logrus.Errorf("error retrieving product: %s", err)
+		return nil, status.Error(codes.Internal, "could not retrieve product")
+	}
+
+	if prd == nil {
+		return nil, status.Errorf(codes.NotFound, "product %s not found", sku)
+	}
+
+	return prd, nil
+}
+
+//validates the product with the same rules of the REST API, which are relaxed for drafts. The SKU rule is selected
+//by name or by seller with the x-sku-rule and x-seller metadata, and the language of the messages with the
+//accept-language metadata
+func (s *productService) validate(ctx context.Context, prd contract.Product) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	rule, ok := s.validator.Rules().Select(first(md.Get(skuRuleMetadata)), first(md.Get(sellerMetadata)))
+	if !ok {
+		return status.Errorf(codes.InvalidArgument, "unknown sku rule %s", first(md.Get(skuRuleMetadata)))
+	}
+
+	ctx = validation.WithSKURule(ctx, rule)
+	ctx = i18n.WithLanguages(ctx, i18n.Fallbacks(i18n.ParseAcceptLanguage(first(md.Get(languageMetadata)))))
+
+	schema, err := s.categorySchema(prd.Category)
+	if err != nil {
+		logrus.Errorf("error retrieving category schema: %s", err)
+		return status.Error(codes.Internal, "could not validate product")
+	}
+
+	if errs := s.validator.Product(ctx, prd, schema); len(errs) > 0 {
+		logrus.Printf("Validation error(s):\n%s", strings.Join(errs, " | "))
+		return status.Error(codes.InvalidArgument, strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+//retrieves the schema of the category of a product, which is nil when the category has no schema
+func (s *productService) categorySchema(category string) (*contract.CategorySchema, error) {
+	if category == "" || s.categories == nil {
+		return nil, nil
+	}
+
+	schema, err := s.categories.CategorySchema(category)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+
+	return schema, err
+}
+
+//sets the fields of the update mask from patch to the product
+func applyMask(prd *contract.Product, patch contract.Product, paths []string) error {
+	for _, path := range paths {
+		switch path {
+		case "name":
+			prd.Name = patch.Name
+		case "brand":
+			prd.Brand = patch.Brand
+		case "size":
+			prd.Size = patch.Size
+		case "price":
+			prd.Price = patch.Price
+		case "image_url":
+			prd.ImageURL = patch.ImageURL
+		case "alt_images":
+			prd.AltImages = patch.AltImages
+		default:
+			return status.Errorf(codes.InvalidArgument, "field %q cannot be updated", path)
+		}
+	}
+
+	return nil
+}
+
+func encodePageToken(sku string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(sku))
+}
+
+func decodePageToken(token string) (string, error) {
+	sku, err := base64.RawURLEncoding.DecodeString(token)
+	return string(sku), err
+}
+
+//retrieves the user making the request, authenticated by the server or taken from the x-user metadata
+func actor(ctx context.Context) string {
+	if user, ok := ctx.Value(identityKey{}).(string); ok {
+		return user
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	return strings.TrimSpace(first(md.Get(userMetadata)))
+}
+
+//reports if the caller can read the product. Calls without user, like the anonymous requests of the REST API,
+//only read the active products
+func visible(ctx context.Context, prd *contract.Product) bool {
+	return actor(ctx) != "" || prd.Public()
+}
+
+//retrieves the first value of a metadata key
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func toProto(prd contract.Product) *productv1.Product {
+	msg := &productv1.Product{
+		Sku:       prd.SKU,
+		Name:      prd.Name,
+		Brand:     prd.Brand,
+		Size:      int64(prd.Size),
+		Price:     prd.Price,
+		ImageUrl:  prd.ImageURL,
+		AltImages: prd.AltImages,
+		CreatedBy: prd.CreatedBy,
+		UpdatedBy: prd.UpdatedBy,
+		Status:    prd.Status,
+	}
+
+	if !prd.CreatedAt.IsZero() {
+		msg.CreateTime = timestamppb.New(prd.CreatedAt)
+	}
+
+	if !prd.UpdatedAt.IsZero() {
+		msg.UpdateTime = timestamppb.New(prd.UpdatedAt)
+	}
+
+	return msg
+}
+
+func fromProto(prd *productv1.Product) contract.Product {
+	return contract.Product{
+		SKU:       prd.GetSku(),
+		Name:      prd.GetName(),
+		Brand:     prd.GetBrand(),
+		Size:      int(prd.GetSize()),
+		Price:     prd.GetPrice(),
+		ImageURL:  prd.GetImageUrl(),
+		AltImages: prd.GetAltImages(),
+	}
+}
@@ -0,0 +1,258 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/garciacer87/product-api/internal/auth"
+	"github.com/garciacer87/product-api/internal/rpc/productv1"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestCreateProduct(t *testing.T) {
+	invalid := getMockProto()
+	invalid.Sku = "FAL-1"
+
+	tests := map[string]struct {
+		db           *mockDB
+		prd          *productv1.Product
+		codeExpected codes.Code
+	}{
+		"#1: invalid product":       {db: newMockDB(), prd: invalid, codeExpected: codes.InvalidArgument},
+		"#2: empty product":         {db: newMockDB(), prd: nil, codeExpected: codes.InvalidArgument},
+		"#3: already exists":        {db: newMockDB("FAL-1000000"), prd: getMockProto(), codeExpected: codes.AlreadyExists},
+		"#4: internal server error": {db: &mockDB{throwError: true}, prd: getMockProto(), codeExpected: codes.Internal},
+		"#5: valid case":            {db: newMockDB(), prd: getMockProto(), codeExpected: codes.OK},
+	}
+
+	for desc, tc := range tests {
+		client := productv1.NewProductServiceClient(dial(t, tc.db))
+
+		_, err := client.CreateProduct(context.Background(), &productv1.CreateProductRequest{Product: tc.prd})
+		if code := status.Code(err); code != tc.codeExpected {
+			t.Errorf("%s:\n code got: %v\n code expected: %v\n error: %v", desc, code, tc.codeExpected, err)
+		}
+	}
+}
+
+func TestGetProduct(t *testing.T) {
+	tests := map[string]struct {
+		sku          string
+		user         string
+		codeExpected codes.Code
+	}{
+		"#1: missing sku":          {sku: "", codeExpected: codes.InvalidArgument},
+		"#2: not found":            {sku: "FAL-2000000", codeExpected: codes.NotFound},
+		"#3: valid case":           {sku: "FAL-1000000", codeExpected: codes.OK},
+		"#4: draft without user":   {sku: "FAL-3000000", codeExpected: codes.NotFound},
+		"#5: draft read by a user": {sku: "FAL-3000000", user: "alice", codeExpected: codes.OK},
+	}
+
+	client := productv1.NewProductServiceClient(dial(t, newMockDB("FAL-1000000").withDraft("FAL-3000000")))
+
+	for desc, tc := range tests {
+		ctx := context.Background()
+		if tc.user != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, userMetadata, tc.user)
+		}
+
+		prd, err := client.GetProduct(ctx, &productv1.GetProductRequest{Sku: tc.sku})
+		if code := status.Code(err); code != tc.codeExpected {
+			t.Errorf("%s:\n code got: %v\n code expected: %v\n error: %v", desc, code, tc.codeExpected, err)
+		}
+
+		if err == nil && prd.GetSku() != tc.sku {
+			t.Errorf("%s:\n sku got: %s\n sku expected: %s", desc, prd.GetSku(), tc.sku)
+		}
+	}
+}
+
+func TestListProducts(t *testing.T) {
+	client := productv1.NewProductServiceClient(dial(t, newMockDB("FAL-1000000", "FAL-1000001", "FAL-1000002").withDraft("FAL-1000003")))
+
+	var (
+		skus  []string
+		pages int
+		req   = &productv1.ListProductsRequest{PageSize: 2}
+	)
+
+	for {
+		resp, err := client.ListProducts(context.Background(), req)
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+		pages++
+
+		for _, prd := range resp.GetProducts() {
+			skus = append(skus, prd.GetSku())
+		}
+
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+
+	if pages != 2 || len(skus) != 3 || skus[0] != "FAL-1000000" || skus[2] != "FAL-1000002" {
+		t.Errorf("pages got: %d, skus got: %v", pages, skus)
+	}
+
+	_, err := client.ListProducts(context.Background(), &productv1.ListProductsRequest{PageToken: "%%%"})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("invalid page token:\n code got: %v\n code expected: %v", code, codes.InvalidArgument)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), userMetadata, "alice")
+	resp, err := client.ListProducts(ctx, &productv1.ListProductsRequest{})
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	if prds := resp.GetProducts(); len(prds) != 4 || prds[3].GetStatus() != "draft" {
+		t.Errorf("users must list the drafts. Got: %v", prds)
+	}
+}
+
+func TestUpdateProduct(t *testing.T) {
+	tests := map[string]struct {
+		prd           *productv1.Product
+		paths         []string
+		codeExpected  codes.Code
+		nameExpected  string
+		brandExpected string
+	}{
+		"#1: not found":        {prd: &productv1.Product{Sku: "FAL-2000000", Name: "new name"}, codeExpected: codes.NotFound},
+		"#2: unknown field":    {prd: &productv1.Product{Sku: "FAL-1000000"}, paths: []string{"sku"}, codeExpected: codes.InvalidArgument},
+		"#3: invalid value":    {prd: &productv1.Product{Sku: "FAL-1000000"}, paths: []string{"name"}, codeExpected: codes.InvalidArgument},
+		"#4: masked update":    {prd: &productv1.Product{Sku: "FAL-1000000", Name: "new name", Brand: "ignored"}, paths: []string{"name"}, codeExpected: codes.OK, nameExpected: "new name", brandExpected: "brand"},
+		"#5: update w/o mask":  {prd: &productv1.Product{Sku: "FAL-1000000", Brand: "new brand"}, codeExpected: codes.OK, nameExpected: "name", brandExpected: "new brand"},
+		"#6: incomplete draft": {prd: &productv1.Product{Sku: "FAL-3000000", Brand: "new brand"}, codeExpected: codes.OK, brandExpected: "new brand"},
+	}
+
+	for desc, tc := range tests {
+		mdb := newMockDB("FAL-1000000").withDraft("FAL-3000000")
+		draft := mdb.prds["FAL-3000000"]
+		draft.Name = ""
+		mdb.prds["FAL-3000000"] = draft
+
+		client := productv1.NewProductServiceClient(dial(t, mdb))
+
+		req := &productv1.UpdateProductRequest{Product: tc.prd}
+		if tc.paths != nil {
+			req.UpdateMask = &fieldmaskpb.FieldMask{Paths: tc.paths}
+		}
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), userMetadata, "alice")
+
+		prd, err := client.UpdateProduct(ctx, req)
+		if code := status.Code(err); code != tc.codeExpected {
+			t.Errorf("%s:\n code got: %v\n code expected: %v\n error: %v", desc, code, tc.codeExpected, err)
+			continue
+		}
+
+		if err == nil && (prd.GetName() != tc.nameExpected || prd.GetBrand() != tc.brandExpected || prd.GetUpdatedBy() != "alice") {
+			t.Errorf("%s:\n product got: %v", desc, prd)
+		}
+	}
+}
+
+func TestDeleteProduct(t *testing.T) {
+	mdb := newMockDB("FAL-1000000", "FAL-1000001").withStock("FAL-1000001")
+	client := productv1.NewProductServiceClient(dial(t, mdb))
+
+	//the steps depend on the previous ones
+	tests := []struct {
+		desc            string
+		req             *productv1.DeleteProductRequest
+		codeExpected    codes.Code
+		deletedExpected bool
+	}{
+		{desc: "#1: product", req: &productv1.DeleteProductRequest{Sku: "FAL-1000000"}, codeExpected: codes.OK, deletedExpected: true},
+		{desc: "#2: deleted product", req: &productv1.DeleteProductRequest{Sku: "FAL-1000000"}, codeExpected: codes.NotFound, deletedExpected: true},
+		{desc: "#3: product with stock", req: &productv1.DeleteProductRequest{Sku: "FAL-1000001"}, codeExpected: codes.FailedPrecondition},
+		{desc: "#4: cascade", req: &productv1.DeleteProductRequest{Sku: "FAL-1000001", Cascade: true}, codeExpected: codes.OK, deletedExpected: true},
+	}
+
+	for _, tc := range tests {
+		_, err := client.DeleteProduct(context.Background(), tc.req)
+		if code := status.Code(err); code != tc.codeExpected {
+			t.Errorf("%s:\n code got: %v\n code expected: %v", tc.desc, code, tc.codeExpected)
+		}
+
+		if _, ok := mdb.prds[tc.req.GetSku()]; ok == tc.deletedExpected {
+			t.Errorf("%s:\n deleted got: %v\n deleted expected: %v", tc.desc, !ok, tc.deletedExpected)
+		}
+	}
+}
+
+func TestBatchGetProducts(t *testing.T) {
+	client := productv1.NewProductServiceClient(dial(t, newMockDB("FAL-1000000", "FAL-1000001").withDraft("FAL-3000000")))
+
+	resp, err := client.BatchGetProducts(context.Background(), &productv1.BatchGetProductsRequest{
+		Skus: []string{"FAL-1000001", "FAL-2000000", "FAL-1000000", "FAL-3000000"},
+	})
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	prds := resp.GetProducts()
+	if len(prds) != 2 || prds[0].GetSku() != "FAL-1000001" || prds[1].GetSku() != "FAL-1000000" {
+		t.Errorf("#1: products must keep the order of the request. Got: %v", prds)
+	}
+
+	if missing := resp.GetMissingSkus(); len(missing) != 2 || missing[0] != "FAL-2000000" || missing[1] != "FAL-3000000" {
+		t.Errorf("#2: missing skus got: %v", missing)
+	}
+
+	_, err = client.BatchGetProducts(context.Background(), &productv1.BatchGetProductsRequest{})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("#3: code got: %v\n code expected: %v", code, codes.InvalidArgument)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	tokens, err := auth.New(map[string][]string{"alice": {auth.Digest("alice-token")}})
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	client := productv1.NewProductServiceClient(dial(t, newMockDB().withDraft("FAL-3000000"), WithAuthentication(tokens)))
+
+	tests := map[string]struct {
+		md           []string
+		codeExpected codes.Code
+	}{
+		"#1: authenticated user": {md: []string{authorizationMetadata, "Bearer alice-token"}, codeExpected: codes.OK},
+		"#2: unknown token":      {md: []string{authorizationMetadata, "Bearer other"}, codeExpected: codes.Unauthenticated},
+		"#3: user metadata":      {md: []string{userMetadata, "alice"}, codeExpected: codes.NotFound},
+		"#4: anonymous":          {codeExpected: codes.NotFound},
+	}
+
+	for desc, tc := range tests {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), tc.md...)
+
+		_, err := client.GetProduct(ctx, &productv1.GetProductRequest{Sku: "FAL-3000000"})
+		if code := status.Code(err); code != tc.codeExpected {
+			t.Errorf("%s:\n code got: %v\n code expected: %v\n error: %v", desc, code, tc.codeExpected, err)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	client := healthpb.NewHealthClient(dial(t, newMockDB()))
+
+	for _, service := range []string{"", productv1.ProductService_ServiceDesc.ServiceName} {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("error not expected: %v", err)
+		}
+
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("service %q status got: %v", service, resp.GetStatus())
+		}
+	}
+}
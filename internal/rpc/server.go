@@ -0,0 +1,143 @@
+//Package rpc serves the product API over gRPC
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/garciacer87/product-api/internal/auth"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/rpc/productv1"
+	"github.com/garciacer87/product-api/internal/sku"
+	"github.com/garciacer87/product-api/internal/validation"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+//Server gRPC server of the product service
+type Server struct {
+	port       string
+	grpcServer *grpc.Server
+	health     *health.Server
+	validator  *validation.Validator
+	tokens     *auth.Tokens
+	categories db.CategorySchemas
+}
+
+//Option configures optional features of the server
+type Option func(*Server)
+
+//WithSKURules validates the SKUs with the given rules instead of the default FAL- rule
+func WithSKURules(rules *sku.Rules) Option {
+	return func(s *Server) {
+		s.validator = validation.NewWithRules(rules)
+	}
+}
+
+//WithCategorySchemas validates the custom attributes of the products with the schema of their category
+func WithCategorySchemas(schemas db.CategorySchemas) Option {
+	return func(s *Server) {
+		s.categories = schemas
+	}
+}
+
+//WithAuthentication identifies the users by the bearer tokens of the authorization metadata instead of the
+//x-user metadata, which is ignored. Calls without token are anonymous
+func WithAuthentication(tokens *auth.Tokens) Option {
+	return func(s *Server) {
+		s.tokens = tokens
+	}
+}
+
+//NewServer creates a gRPC server exposing the product service along with the health checking
+//and the reflection services
+func NewServer(port string, db db.Database, opts ...Option) *Server {
+	srv := &Server{
+		port:      port,
+		health:    health.NewServer(),
+		validator: validation.New(),
+	}
+
+	for _, opt := range opts {
+		opt(srv)
+	}
+
+	srv.grpcServer = grpc.NewServer(grpc.UnaryInterceptor(srv.authenticate))
+
+	productv1.RegisterProductServiceServer(srv.grpcServer, &productService{db: db, validator: srv.validator, categories: srv.categories})
+	healthpb.RegisterHealthServer(srv.grpcServer, srv.health)
+	reflection.Register(srv.grpcServer)
+
+	return srv
+}
+
+//identifies the user of the call by its bearer token when the authentication is enabled. Calls with an
+//unknown token are rejected
+func (s *Server) authenticate(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if s.tokens == nil {
+		return handler(ctx, req)
+	}
+
+	var user string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if header := first(md.Get(authorizationMetadata)); header != "" {
+		token, ok := auth.Bearer(header)
+		if ok {
+			user, ok = s.tokens.User(token)
+		}
+
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "invalid bearer token")
+		}
+	}
+
+	return handler(context.WithValue(ctx, identityKey{}, user), req)
+}
+
+//ListenAndServe starts the gRPC server on the previously configurated port
+func (s *Server) ListenAndServe() error {
+	lis, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%v", s.port))
+	if err != nil {
+		return fmt.Errorf("could not listen on port %s: %v", s.port, err)
+	}
+
+	return s.Serve(lis)
+}
+
+//Serve accepts gRPC connections on the listener
+func (s *Server) Serve(lis net.Listener) error {
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	s.health.SetServingStatus(productv1.ProductService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	logrus.Printf("serving gRPC on port %s\n", s.port)
+	return s.grpcServer.Serve(lis)
+}
+
+//Shutdown stops the gRPC server waiting for the pending calls until the context is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	logrus.Infof("Shutting down gRPC server")
+
+	//health checks report the server as not serving while the pending calls finish
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		return ctx.Err()
+	}
+}
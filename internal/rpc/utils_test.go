@@ -0,0 +1,207 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/garciacer87/product-api/internal/rpc/productv1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+//dial serves the database through an in-memory connection and retrieves a client connected to it
+func dial(t *testing.T, db *mockDB, opts ...Option) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := NewServer("0", db, opts...)
+	go srv.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("could not dial the test server: %v", err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Shutdown(context.Background())
+	})
+
+	return conn
+}
+
+//mockDB in-memory database
+type mockDB struct {
+	throwError bool
+	prds       map[string]contract.Product
+	//products with stock, which are only deleted with cascade
+	stocked map[string]bool
+}
+
+func newMockDB(skus ...string) *mockDB {
+	mdb := &mockDB{prds: make(map[string]contract.Product)}
+	for _, sku := range skus {
+		prd := getMockProduct()
+		prd.SKU = sku
+		mdb.prds[sku] = prd
+	}
+
+	return mdb
+}
+
+//withDraft adds a draft product to the database
+func (mdb *mockDB) withDraft(sku string) *mockDB {
+	prd := getMockProduct()
+	prd.SKU = sku
+	prd.Status = contract.StatusDraft
+	mdb.prds[sku] = prd
+
+	return mdb
+}
+
+//withStock gives stock to a product of the database
+func (mdb *mockDB) withStock(sku string) *mockDB {
+	if mdb.stocked == nil {
+		mdb.stocked = make(map[string]bool)
+	}
+	mdb.stocked[sku] = true
+
+	return mdb
+}
+
+func (mdb *mockDB) Create(prd contract.Product) (*contract.Product, error) {
+	if mdb.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	mdb.prds[prd.SKU] = prd
+	return &prd, nil
+}
+
+func (mdb *mockDB) GetAll() ([]contract.Product, error) {
+	return mdb.List(contract.ProductFilter{})
+}
+
+func (mdb *mockDB) List(filter contract.ProductFilter) ([]contract.Product, error) {
+	if mdb.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	prds := []contract.Product{}
+	for sku, prd := range mdb.prds {
+		if sku > filter.AfterSKU && (filter.Status == "" || prd.Status == filter.Status) {
+			prds = append(prds, prd)
+		}
+	}
+	sort.Slice(prds, func(i, j int) bool { return prds[i].SKU < prds[j].SKU })
+
+	if filter.Limit > 0 && len(prds) > filter.Limit {
+		prds = prds[:filter.Limit]
+	}
+
+	return prds, nil
+}
+
+func (mdb *mockDB) Get(sku string) (*contract.Product, error) {
+	if mdb.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	prd, ok := mdb.prds[sku]
+	if !ok {
+		return nil, nil
+	}
+
+	return &prd, nil
+}
+
+func (mdb *mockDB) GetMany(skus []string) ([]contract.Product, []string, error) {
+	if mdb.throwError {
+		return nil, nil, fmt.Errorf("mocked error")
+	}
+
+	prds, missing := []contract.Product{}, []string{}
+	for _, sku := range skus {
+		if prd, ok := mdb.prds[sku]; ok {
+			prds = append(prds, prd)
+		} else {
+			missing = append(missing, sku)
+		}
+	}
+
+	return prds, missing, nil
+}
+
+func (mdb *mockDB) Update(prd contract.Product) error {
+	if mdb.throwError {
+		return fmt.Errorf("mocked error")
+	}
+
+	mdb.prds[prd.SKU] = prd
+	return nil
+}
+
+func (mdb *mockDB) UpdateFunc(sku string, fn func(prd *contract.Product) error) (*contract.Product, error) {
+	if mdb.throwError {
+		return nil, fmt.Errorf("mocked error")
+	}
+
+	prd, ok := mdb.prds[sku]
+	if !ok {
+		return nil, fmt.Errorf("mocked error: %w", db.ErrNotFound)
+	}
+
+	if err := fn(&prd); err != nil {
+		return nil, fmt.Errorf("mocked error: %w", err)
+	}
+
+	mdb.prds[sku] = prd
+	return &prd, nil
+}
+
+func (mdb *mockDB) Delete(sku string, cascade bool) error {
+	if mdb.throwError {
+		return fmt.Errorf("mocked error")
+	}
+
+	if mdb.stocked[sku] && !cascade {
+		return fmt.Errorf("mocked error: %w", db.ErrInStock)
+	}
+
+	delete(mdb.prds, sku)
+	return nil
+}
+
+func (mdb *mockDB) LastModified() (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func (mdb *mockDB) Close() {}
+
+func getMockProduct() contract.Product {
+	return contract.Product{
+		SKU:      "FAL-1000000",
+		Name:     "name",
+		Brand:    "brand",
+		Size:     10,
+		Price:    100.00,
+		ImageURL: "http://aaaa",
+		Status:   contract.StatusActive,
+		AltImages: []string{
+			"http://bbbb",
+			"http://cccc",
+		},
+	}
+}
+
+func getMockProto() *productv1.Product {
+	return toProto(getMockProduct())
+}
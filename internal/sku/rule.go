@@ -0,0 +1,236 @@
+//Package sku defines the configurable formats of the product SKUs
+package sku
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//MaxLength maximum length of a SKU, whatever its rule
+const MaxLength = 64
+
+//Check digit algorithms
+const (
+	//Luhn check digit of credit cards and IMEIs
+	Luhn = "luhn"
+	//GTIN check digit of GTIN-8, GTIN-12 (UPC), GTIN-13 (EAN) and GTIN-14 codes
+	GTIN = "gtin"
+)
+
+//Rule named SKU format. A SKU satisfies the rule when it satisfies all of the defined conditions
+type Rule struct {
+	Name string `json:"name"`
+	//Prefix the SKU must start with. The other conditions apply to the rest of the SKU, except Pattern
+	Prefix string `json:"prefix,omitempty"`
+	//Pattern regular expression the whole SKU must match
+	Pattern string `json:"pattern,omitempty"`
+	//Min and Max inclusive range of the number after the prefix
+	Min *uint64 `json:"min,omitempty"`
+	Max *uint64 `json:"max,omitempty"`
+	//CheckDigit algorithm validating the last digit of the number after the prefix: luhn or gtin
+	CheckDigit string `json:"checkDigit,omitempty"`
+
+	re *regexp.Regexp
+}
+
+//compiles the pattern and checks the consistency of the rule
+func (r *Rule) init() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("rule without name")
+	}
+
+	if r.Pattern != "" {
+		re, err := regexp.Compile("^(?:" + r.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("rule %s: invalid pattern: %v", r.Name, err)
+		}
+		r.re = re
+	}
+
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return fmt.Errorf("rule %s: min is greater than max", r.Name)
+	}
+
+	switch r.CheckDigit {
+	case "", Luhn, GTIN:
+	default:
+		return fmt.Errorf("rule %s: unknown check digit algorithm %s", r.Name, r.CheckDigit)
+	}
+
+	return nil
+}
+
+//Check returns the reason why the SKU does not satisfy the rule, or nil when it does
+func (r *Rule) Check(sku string) error {
+	if sku == "" {
+		return errors.New("it is empty")
+	}
+
+	if len(sku) > MaxLength {
+		return fmt.Errorf("it is longer than %d characters", MaxLength)
+	}
+
+	if !strings.HasPrefix(sku, r.Prefix) {
+		return fmt.Errorf("it does not start with %s", r.Prefix)
+	}
+
+	if r.re != nil && !r.re.MatchString(sku) {
+		return fmt.Errorf("it does not match the pattern %s", r.Pattern)
+	}
+
+	number := sku[len(r.Prefix):]
+
+	if r.Min != nil || r.Max != nil {
+		n, err := strconv.ParseUint(number, 10, 64)
+		if err != nil || !digits(number) {
+			return errors.New("it is not a number after the prefix")
+		}
+
+		if (r.Min != nil && n < *r.Min) || (r.Max != nil && n > *r.Max) {
+			return fmt.Errorf("its number is out of the range %s", r.rangeString())
+		}
+	}
+
+	switch r.CheckDigit {
+	case Luhn:
+		if !digits(number) || len(number) < 2 || !validLuhn(number) {
+			return errors.New("it has an invalid Luhn check digit")
+		}
+	case GTIN:
+		if !digits(number) || !validGTIN(number) {
+			return errors.New("it is not a valid GTIN")
+		}
+	}
+
+	return nil
+}
+
+//Generates reports if the SKUs of the rule can be generated from a sequence, which requires a range whose
+//values fit in the sequences of the database
+func (r *Rule) Generates() bool {
+	return r.Min != nil && r.Max != nil && *r.Max <= math.MaxInt64
+}
+
+//Bounds first and last values of the sequence the SKUs of the rule are generated from. The check digit is
+//not part of the values. Only meaningful when the rule generates SKUs
+func (r *Rule) Bounds() (first, last uint64) {
+	if r.CheckDigit == "" {
+		return *r.Min, *r.Max
+	}
+
+	return (*r.Min + 9) / 10, *r.Max / 10
+}
+
+//Format builds the SKU of a value of the sequence, appending the check digit of the rule. GTIN values are
+//padded with zeros to the length of Max. The SKU may still not satisfy the rule, e.g. when it does not match the pattern
+func (r *Rule) Format(value uint64) string {
+	number := strconv.FormatUint(value, 10)
+
+	switch r.CheckDigit {
+	case Luhn:
+		number += strconv.Itoa(luhnDigit(number))
+	case GTIN:
+		if width := len(strconv.FormatUint(*r.Max, 10)) - 1; len(number) < width {
+			number = strings.Repeat("0", width-len(number)) + number
+		}
+		number += strconv.Itoa(gtinDigit(number))
+	}
+
+	return r.Prefix + number
+}
+
+//Value retrieves the value of the sequence of a SKU satisfying the rule, the inverse of Format
+func (r *Rule) Value(sku string) (uint64, bool) {
+	if !r.Generates() || r.Check(sku) != nil {
+		return 0, false
+	}
+
+	number := sku[len(r.Prefix):]
+	if r.CheckDigit != "" {
+		number = number[:len(number)-1]
+	}
+
+	value, err := strconv.ParseUint(number, 10, 64)
+
+	return value, err == nil
+}
+
+func (r *Rule) rangeString() string {
+	min, max := "0", "∞"
+	if r.Min != nil {
+		min = strconv.FormatUint(*r.Min, 10)
+	}
+	if r.Max != nil {
+		max = strconv.FormatUint(*r.Max, 10)
+	}
+
+	return fmt.Sprintf("%s-%s", min, max)
+}
+
+//reports if s is a non empty string of ASCII digits
+func digits(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+//validates the Luhn check digit, the last one of the number
+func validLuhn(number string) bool {
+	return luhnDigit(number[:len(number)-1]) == int(number[len(number)-1]-'0')
+}
+
+//computes the Luhn check digit of a number of ASCII digits
+func luhnDigit(number string) int {
+	sum := 0
+	for i := 0; i < len(number); i++ {
+		d := int(number[len(number)-1-i] - '0')
+		//every second digit from the right, starting with the last one of the number, is doubled
+		if i%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+
+	return (10 - sum%10) % 10
+}
+
+//validates the length and the check digit of a GTIN
+func validGTIN(number string) bool {
+	switch len(number) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+
+	return gtinDigit(number[:len(number)-1]) == int(number[len(number)-1]-'0')
+}
+
+//computes the GTIN check digit of a number of ASCII digits
+func gtinDigit(number string) int {
+	sum := 0
+	for i := 0; i < len(number); i++ {
+		d := int(number[len(number)-1-i] - '0')
+		//weights alternate 3 and 1 starting with the last digit of the number
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+
+	return (10 - sum%10) % 10
+}
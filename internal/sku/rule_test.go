@@ -0,0 +1,157 @@
+package sku
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	min, max := uint64(1000000), uint64(99999999)
+
+	rules, err := New(Config{Rules: []Rule{
+		{Name: "fal", Prefix: "FAL-", Min: &min, Max: &max},
+		{Name: "gtin", CheckDigit: GTIN},
+		{Name: "imei", Prefix: "IMEI-", CheckDigit: Luhn},
+		{Name: "mkp", Pattern: `MKP-[A-Z]{3}-\d{4}`},
+	}})
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	tests := map[string]struct {
+		rule          string
+		sku           string
+		validExpected bool
+	}{
+		"#1: valid fal":            {rule: "fal", sku: "FAL-1000000", validExpected: true},
+		"#2: fal without prefix":   {rule: "fal", sku: "1000000"},
+		"#3: fal out of range":     {rule: "fal", sku: "FAL-999999"},
+		"#4: fal not a number":     {rule: "fal", sku: "FAL-+1000000"},
+		"#5: valid gtin-13":        {rule: "gtin", sku: "4006381333931", validExpected: true},
+		"#6: valid gtin-12":        {rule: "gtin", sku: "036000291452", validExpected: true},
+		"#7: invalid gtin digit":   {rule: "gtin", sku: "4006381333932"},
+		"#8: invalid gtin length":  {rule: "gtin", sku: "40063813339"},
+		"#9: valid luhn":           {rule: "imei", sku: "IMEI-490154203237518", validExpected: true},
+		"#10: invalid luhn":        {rule: "imei", sku: "IMEI-490154203237519"},
+		"#11: matching pattern":    {rule: "mkp", sku: "MKP-ABC-1234", validExpected: true},
+		"#12: mismatching pattern": {rule: "mkp", sku: "MKP-ABC-1234-5"},
+		"#13: empty sku":           {rule: "mkp", sku: ""},
+		"#14: too long":            {rule: "gtin", sku: strings.Repeat("0", MaxLength+1)},
+	}
+
+	for desc, tc := range tests {
+		rule, _ := rules.Rule(tc.rule)
+
+		err := rule.Check(tc.sku)
+		if (err == nil) != tc.validExpected {
+			t.Errorf("%s:\n valid expected: %v\n error got: %v", desc, tc.validExpected, err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	min, max := uint64(10), uint64(1)
+
+	tests := map[string]struct {
+		cfg           Config
+		errorExpected bool
+	}{
+		"#1: valid config":      {cfg: Config{Rules: []Rule{{Name: "a"}, {Name: "b"}}, Default: "b", Sellers: map[string]string{"acme": "a"}}},
+		"#2: no rules":          {cfg: Config{}, errorExpected: true},
+		"#3: unnamed rule":      {cfg: Config{Rules: []Rule{{Prefix: "A"}}}, errorExpected: true},
+		"#4: repeated rule":     {cfg: Config{Rules: []Rule{{Name: "a"}, {Name: "a"}}}, errorExpected: true},
+		"#5: invalid pattern":   {cfg: Config{Rules: []Rule{{Name: "a", Pattern: "("}}}, errorExpected: true},
+		"#6: invalid range":     {cfg: Config{Rules: []Rule{{Name: "a", Min: &min, Max: &max}}}, errorExpected: true},
+		"#7: unknown algorithm": {cfg: Config{Rules: []Rule{{Name: "a", CheckDigit: "crc"}}}, errorExpected: true},
+		"#8: unknown default":   {cfg: Config{Rules: []Rule{{Name: "a"}}, Default: "b"}, errorExpected: true},
+		"#9: unknown seller":    {cfg: Config{Rules: []Rule{{Name: "a"}}, Sellers: map[string]string{"acme": "b"}}, errorExpected: true},
+	}
+
+	for desc, tc := range tests {
+		_, err := New(tc.cfg)
+		if (err != nil) != tc.errorExpected {
+			t.Errorf("%s:\n error expected: %v\n error got: %v", desc, tc.errorExpected, err)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	cfg := `{"rules":[{"name":"fal","prefix":"FAL-"},{"name":"gtin","checkDigit":"gtin"}],"sellers":{"acme":"gtin"}}`
+	if err := os.WriteFile(path, []byte(cfg), 0600); err != nil {
+		t.Fatalf("could not write the rules: %v", err)
+	}
+
+	rules, err := Load(path)
+	if err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	tests := map[string]struct {
+		name, seller string
+		ruleExpected string
+		okExpected   bool
+	}{
+		"#1: default rule":          {ruleExpected: "fal", okExpected: true},
+		"#2: named rule":            {name: "gtin", ruleExpected: "gtin", okExpected: true},
+		"#3: seller rule":           {seller: "acme", ruleExpected: "gtin", okExpected: true},
+		"#4: unknown seller":        {seller: "other", ruleExpected: "fal", okExpected: true},
+		"#5: name overrides seller": {name: "fal", seller: "acme", ruleExpected: "fal", okExpected: true},
+		"#6: unknown rule":          {name: "other"},
+	}
+
+	for desc, tc := range tests {
+		rule, ok := rules.Select(tc.name, tc.seller)
+		if ok != tc.okExpected || (ok && rule.Name != tc.ruleExpected) {
+			t.Errorf("%s:\n rule got: %v, %v", desc, rule, ok)
+		}
+	}
+}
+
+func uint64p(v uint64) *uint64 {
+	return &v
+}
+
+func TestFormat(t *testing.T) {
+	tests := map[string]struct {
+		rule          Rule
+		value         uint64
+		skuExpected   string
+		firstExpected uint64
+		lastExpected  uint64
+	}{
+		"#1: fal":   {rule: Rule{Prefix: "FAL-", Min: uint64p(1000000), Max: uint64p(99999999)}, value: 1000123, skuExpected: "FAL-1000123", firstExpected: 1000000, lastExpected: 99999999},
+		"#2: gtin":  {rule: Rule{Min: uint64p(1), Max: uint64p(9999999999999), CheckDigit: GTIN}, value: 400638133393, skuExpected: "4006381333931", firstExpected: 1, lastExpected: 999999999999},
+		"#3: zeros": {rule: Rule{Min: uint64p(1), Max: uint64p(999999999999), CheckDigit: GTIN}, value: 3600029145, skuExpected: "036000291452", firstExpected: 1, lastExpected: 99999999999},
+		"#4: luhn":  {rule: Rule{Prefix: "IMEI-", Min: uint64p(15), Max: uint64p(999999999999999), CheckDigit: Luhn}, value: 49015420323751, skuExpected: "IMEI-490154203237518", firstExpected: 2, lastExpected: 99999999999999},
+	}
+
+	for desc, tc := range tests {
+		if !tc.rule.Generates() {
+			t.Fatalf("%s: the rule should generate SKUs", desc)
+		}
+
+		if first, last := tc.rule.Bounds(); first != tc.firstExpected || last != tc.lastExpected {
+			t.Errorf("%s:\n bounds got: %d-%d\n expected: %d-%d", desc, first, last, tc.firstExpected, tc.lastExpected)
+		}
+
+		sku := tc.rule.Format(tc.value)
+		if sku != tc.skuExpected {
+			t.Errorf("%s:\n sku got: %s\n expected: %s", desc, sku, tc.skuExpected)
+		}
+
+		if err := tc.rule.Check(sku); err != nil {
+			t.Errorf("%s: the generated sku does not satisfy the rule: %v", desc, err)
+		}
+
+		if value, ok := tc.rule.Value(sku); !ok || value != tc.value {
+			t.Errorf("%s:\n value got: %d, %v\n expected: %d", desc, value, ok, tc.value)
+		}
+	}
+
+	if (&Rule{Prefix: "A-"}).Generates() {
+		t.Errorf("a rule without range should not generate SKUs")
+	}
+}
@@ -0,0 +1,118 @@
+package sku
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+//DefaultRuleName name of the rule of the original SKUs: FAL- followed by a number between 1000000 and 99999999
+const DefaultRuleName = "fal"
+
+//Rules set of named SKU rules. The rule of a product is selected by name, by its seller or, when neither
+//is given, the default rule applies
+type Rules struct {
+	rules   map[string]*Rule
+	def     *Rule
+	sellers map[string]*Rule
+}
+
+//Config definition of the rules, as read from the configuration file
+type Config struct {
+	Rules []Rule `json:"rules"`
+	//Default name of the rule applied when no rule is selected. Defaults to the first rule
+	Default string `json:"default,omitempty"`
+	//Sellers maps the sellers to the name of the rule of their SKUs
+	Sellers map[string]string `json:"sellers,omitempty"`
+}
+
+//DefaultRules rules holding only the original FAL- rule
+func DefaultRules() *Rules {
+	min, max := uint64(1000000), uint64(99999999)
+
+	rules, _ := New(Config{Rules: []Rule{{Name: DefaultRuleName, Prefix: "FAL-", Min: &min, Max: &max}}})
+
+	return rules
+}
+
+//New creates the rules of the configuration, failing when it is inconsistent
+func New(cfg Config) (*Rules, error) {
+	if len(cfg.Rules) == 0 {
+		return nil, fmt.Errorf("no sku rules defined")
+	}
+
+	rs := &Rules{
+		rules:   make(map[string]*Rule, len(cfg.Rules)),
+		sellers: make(map[string]*Rule, len(cfg.Sellers)),
+	}
+
+	for i := range cfg.Rules {
+		rule := cfg.Rules[i]
+		if err := rule.init(); err != nil {
+			return nil, err
+		}
+
+		if _, ok := rs.rules[rule.Name]; ok {
+			return nil, fmt.Errorf("rule %s defined twice", rule.Name)
+		}
+		rs.rules[rule.Name] = &rule
+	}
+
+	def := cfg.Default
+	if def == "" {
+		def = cfg.Rules[0].Name
+	}
+
+	var ok bool
+	if rs.def, ok = rs.rules[def]; !ok {
+		return nil, fmt.Errorf("unknown default rule %s", def)
+	}
+
+	for seller, name := range cfg.Sellers {
+		if rs.sellers[seller], ok = rs.rules[name]; !ok {
+			return nil, fmt.Errorf("unknown rule %s of seller %s", name, seller)
+		}
+	}
+
+	return rs, nil
+}
+
+//Load reads the rules of a JSON configuration file
+func Load(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the sku rules: %v", err)
+	}
+
+	cfg := Config{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("could not decode the sku rules: %v", err)
+	}
+
+	return New(cfg)
+}
+
+//Default retrieves the rule applied when no rule is selected
+func (rs *Rules) Default() *Rule {
+	return rs.def
+}
+
+//Rule retrieves a rule by its name
+func (rs *Rules) Rule(name string) (*Rule, bool) {
+	rule, ok := rs.rules[name]
+	return rule, ok
+}
+
+//Select retrieves the rule named name or, when name is empty, the rule of the seller. The default rule
+//applies to unknown sellers. Returns false when the named rule does not exist
+func (rs *Rules) Select(name, seller string) (*Rule, bool) {
+	if name != "" {
+		return rs.Rule(name)
+	}
+
+	if rule, ok := rs.sellers[seller]; ok {
+		return rule, true
+	}
+
+	return rs.def, true
+}
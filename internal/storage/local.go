@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+//Local stores the objects as files of a directory. The content type of the objects is given by the extension
+//of their keys
+type Local struct {
+	dir string
+}
+
+//NewLocal creates the storage on the directory, creating it if it does not exist
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create the storage directory: %v", err)
+	}
+
+	return &Local{dir: dir}, nil
+}
+
+//Put writes the object to a temporary file that is renamed once written, so readers never see partial objects
+func (l *Local) Put(_ context.Context, key string, data []byte, _ string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not store object %s: %v", key, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("could not store object %s: %v", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not store object %s: %v", key, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not store object %s: %v", key, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not store object %s: %v", key, err)
+	}
+
+	return nil
+}
+
+//Get opens the file of the object
+func (l *Local) Get(_ context.Context, key string) (*Object, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("could not get object %s: %v", key, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not get object %s: %v", key, err)
+	}
+
+	return &Object{Body: f, ContentType: mime.TypeByExtension(filepath.Ext(key)), Size: info.Size()}, nil
+}
+
+//Delete removes the file of the object
+func (l *Local) Delete(_ context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not delete object %s: %v", key, err)
+	}
+
+	return nil
+}
+
+//path of the file of the object. The key cannot leave the directory of the storage
+func (l *Local) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
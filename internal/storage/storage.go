@@ -0,0 +1,37 @@
+//Package storage stores the files managed by the API, like the product images, in a pluggable backend
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+)
+
+//ErrNotFound returned when the requested object does not exist
+var ErrNotFound = errors.New("object not found")
+
+//keyPattern keys are relative paths of letters, digits, dashes, underscores and dots, with no empty or dot segments
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*(/[A-Za-z0-9_-][A-Za-z0-9._-]*)*$`)
+
+//Storage abstraction of the backend storing the objects by key
+type Storage interface {
+	//Put stores the object, replacing the one with the same key
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	//Get retrieves the object. The caller must close its body
+	Get(ctx context.Context, key string) (*Object, error)
+	//Delete deletes the object. Deleting an object that does not exist is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+//Object stored object
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+//ValidKey reports if the key can be stored by every backend
+func ValidKey(key string) bool {
+	return len(key) <= 255 && keyPattern.MatchString(key)
+}
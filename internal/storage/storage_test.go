@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//s3StandIn local stand-in of an S3-compatible service keeping the objects of a bucket in memory
+type s3StandIn struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string]standInObject
+}
+
+type standInObject struct {
+	data        []byte
+	contentType string
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, signAlgorithm+" Credential=access/") || !strings.Contains(auth, "/us-east-1/s3/aws4_request") ||
+		req.Header.Get("X-Amz-Date") == "" || req.Header.Get("X-Amz-Content-Sha256") == "" {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	key := strings.TrimPrefix(req.URL.Path, "/"+s.bucket+"/")
+	if key == req.URL.Path {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.Method {
+	case http.MethodPut:
+		data, _ := ioutil.ReadAll(req.Body)
+		if hashHex(data) != req.Header.Get("X-Amz-Content-Sha256") {
+			http.Error(w, "<Error><Code>XAmzContentSHA256Mismatch</Code></Error>", http.StatusBadRequest)
+			return
+		}
+		s.objects[key] = standInObject{data, req.Header.Get("Content-Type")}
+	case http.MethodGet:
+		obj, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Write(obj.data)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	local, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create the local storage: %v", err)
+	}
+
+	standIn := httptest.NewServer(&s3StandIn{bucket: "images", objects: map[string]standInObject{}})
+	defer standIn.Close()
+
+	s3, err := NewS3(S3Config{Endpoint: standIn.URL, Region: "us-east-1", Bucket: "images", AccessKey: "access", SecretKey: "secret"}, nil)
+	if err != nil {
+		t.Fatalf("could not create the s3 storage: %v", err)
+	}
+
+	backends := map[string]Storage{"#1: local": local, "#2: s3": s3}
+
+	for desc, store := range backends {
+		ctx := context.Background()
+
+		if err := store.Put(ctx, "products/a1.png", []byte("image"), "image/png"); err != nil {
+			t.Fatalf("%s: could not put the object: %v", desc, err)
+		}
+
+		obj, err := store.Get(ctx, "products/a1.png")
+		if err != nil {
+			t.Fatalf("%s: could not get the object: %v", desc, err)
+		}
+
+		data, _ := ioutil.ReadAll(obj.Body)
+		obj.Body.Close()
+
+		if string(data) != "image" || obj.ContentType != "image/png" || obj.Size != 5 {
+			t.Errorf("%s:\n object got: %s %s %d", desc, data, obj.ContentType, obj.Size)
+		}
+
+		if err := store.Delete(ctx, "products/a1.png"); err != nil {
+			t.Errorf("%s: could not delete the object: %v", desc, err)
+		}
+
+		if _, err := store.Get(ctx, "products/a1.png"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s:\n error got: %v\n expected: %v", desc, err, ErrNotFound)
+		}
+
+		if err := store.Delete(ctx, "products/a1.png"); err != nil {
+			t.Errorf("%s: deleting a missing object should not fail: %v", desc, err)
+		}
+
+		if err := store.Put(ctx, "../a1.png", []byte("image"), "image/png"); err == nil {
+			t.Errorf("%s: a key out of the storage should fail", desc)
+		}
+	}
+}
+
+func TestValidKey(t *testing.T) {
+	tests := map[string]struct {
+		key           string
+		validExpected bool
+	}{
+		"#1: file":              {key: "a1.png", validExpected: true},
+		"#2: nested":            {key: "products/a1_thumb.png", validExpected: true},
+		"#3: parent":            {key: "../a1.png"},
+		"#4: hidden":            {key: "products/.a1"},
+		"#5: absolute":          {key: "/a1.png"},
+		"#6: empty segment":     {key: "products//a1.png"},
+		"#7: special character": {key: "a 1.png"},
+		"#8: empty":             {key: ""},
+	}
+
+	for desc, tc := range tests {
+		if valid := ValidKey(tc.key); valid != tc.validExpected {
+			t.Errorf("%s:\n Got: %v\n Expected: %v", desc, valid, tc.validExpected)
+		}
+	}
+}
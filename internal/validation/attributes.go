@@ -0,0 +1,245 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	ut "github.com/go-playground/universal-translator"
+)
+
+//messages of the errors of the custom attributes and of the category schemas, by language
+var attributeMessages = map[string]map[string]string{
+	"en": {
+		"attribute_category":  "{0} require a category",
+		"attribute_unknown":   "{0} is not an attribute of the category {1}",
+		"attribute_required":  "{0} is required by the category {1}",
+		"attribute_type":      "{0} must be of type {1}",
+		"attribute_min":       "{0} must be {1} or greater",
+		"attribute_max":       "{0} must be {1} or less",
+		"attribute_min_len":   "{0} must be at least {1} characters in length",
+		"attribute_max_len":   "{0} must be a maximum of {1} characters in length",
+		"attribute_pattern":   "{0} does not match the pattern {1}",
+		"attribute_values":    "{0} must be one of [{1}]",
+		"attribute_duplicate": "{0} is defined more than once",
+		"attribute_range":     "{0} has a min greater than its max",
+		"attribute_regexp":    "{0} has an invalid pattern",
+		"attribute_option":    "{0} cannot have {1} with the type {2}",
+	},
+	"es": {
+		"attribute_category":  "{0} requieren una categoría",
+		"attribute_unknown":   "{0} no es un atributo de la categoría {1}",
+		"attribute_required":  "{0} es requerido por la categoría {1}",
+		"attribute_type":      "{0} debe ser de tipo {1}",
+		"attribute_min":       "{0} debe ser {1} o más",
+		"attribute_max":       "{0} debe ser {1} o menos",
+		"attribute_min_len":   "{0} debe tener al menos {1} caracteres",
+		"attribute_max_len":   "{0} debe tener un máximo de {1} caracteres",
+		"attribute_pattern":   "{0} no cumple el patrón {1}",
+		"attribute_values":    "{0} debe ser uno de [{1}]",
+		"attribute_duplicate": "{0} está definido más de una vez",
+		"attribute_range":     "{0} tiene un mínimo mayor que su máximo",
+		"attribute_regexp":    "{0} tiene un patrón inválido",
+		"attribute_option":    "{0} no puede tener {1} con el tipo {2}",
+	},
+	"pt": {
+		"attribute_category":  "{0} requerem uma categoria",
+		"attribute_unknown":   "{0} não é um atributo da categoria {1}",
+		"attribute_required":  "{0} é obrigatório na categoria {1}",
+		"attribute_type":      "{0} deve ser do tipo {1}",
+		"attribute_min":       "{0} deve ser {1} ou maior",
+		"attribute_max":       "{0} deve ser {1} ou menor",
+		"attribute_min_len":   "{0} deve ter pelo menos {1} caracteres",
+		"attribute_max_len":   "{0} deve ter no máximo {1} caracteres",
+		"attribute_pattern":   "{0} não satisfaz o padrão {1}",
+		"attribute_values":    "{0} deve ser um de [{1}]",
+		"attribute_duplicate": "{0} está definido mais de uma vez",
+		"attribute_range":     "{0} tem um mínimo maior que seu máximo",
+		"attribute_regexp":    "{0} tem um padrão inválido",
+		"attribute_option":    "{0} não pode ter {1} com o tipo {2}",
+	},
+}
+
+//Attributes validates the custom attributes of a product of the category with the schema of the category,
+//returning the translated errors. A nil schema allows no attributes. The required attributes can be missing
+//unless the product is complete
+func (v *Validator) Attributes(ctx context.Context, category string, schema *contract.CategorySchema, attrs map[string]interface{},
+	complete bool) []string {
+	var (
+		errs []string
+		t    = v.translator(ctx)
+	)
+
+	if category == "" {
+		if len(attrs) > 0 {
+			errs = append(errs, translate(t, "attribute_category", "Attributes"))
+		}
+		return errs
+	}
+
+	//the keys are sorted, so the errors keep their order between requests
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var def *contract.AttributeDefinition
+		if schema != nil {
+			def, _ = schema.Attribute(key)
+		}
+
+		if def == nil {
+			errs = append(errs, translate(t, "attribute_unknown", attributeField(key), category))
+			continue
+		}
+
+		if msg := checkAttribute(t, def, attrs[key]); msg != "" {
+			errs = append(errs, msg)
+		}
+	}
+
+	if schema != nil && complete {
+		for _, def := range schema.Attributes {
+			if _, ok := attrs[def.Key]; def.Required && !ok {
+				errs = append(errs, translate(t, "attribute_required", attributeField(def.Key), category))
+			}
+		}
+	}
+
+	return errs
+}
+
+//Schema validates the attribute definitions of a category schema, returning the translated errors
+func (v *Validator) Schema(ctx context.Context, schema contract.CategorySchema) []string {
+	if err := v.StructCtx(ctx, schema); err != nil {
+		return v.TranslateCtx(ctx, err)
+	}
+
+	var (
+		errs []string
+		t    = v.translator(ctx)
+		seen = make(map[string]bool)
+	)
+
+	for _, def := range schema.Attributes {
+		field := attributeField(def.Key)
+
+		if seen[def.Key] {
+			errs = append(errs, translate(t, "attribute_duplicate", field))
+		}
+		seen[def.Key] = true
+
+		if def.Min != nil && def.Max != nil && *def.Min > *def.Max {
+			errs = append(errs, translate(t, "attribute_range", field))
+		}
+
+		if def.Pattern != "" {
+			if _, err := regexp.Compile(def.Pattern); err != nil {
+				errs = append(errs, translate(t, "attribute_regexp", field))
+			}
+		}
+
+		//options not applying to the type of the attribute
+		options := map[string]bool{
+			"unit":    def.Unit != "" && def.Type != contract.AttributeNumber && def.Type != contract.AttributeInteger,
+			"pattern": def.Pattern != "" && def.Type != contract.AttributeString,
+			"values":  len(def.Values) > 0 && def.Type != contract.AttributeString,
+			"min":     def.Min != nil && def.Type == contract.AttributeBoolean,
+			"max":     def.Max != nil && def.Type == contract.AttributeBoolean,
+		}
+		for _, option := range []string{"unit", "pattern", "values", "min", "max"} {
+			if options[option] {
+				errs = append(errs, translate(t, "attribute_option", field, option, def.Type))
+			}
+		}
+	}
+
+	return errs
+}
+
+//checks the value of an attribute with its definition, returning the translated error or an empty string
+func checkAttribute(t ut.Translator, def *contract.AttributeDefinition, value interface{}) string {
+	field := attributeField(def.Key)
+
+	switch def.Type {
+	case contract.AttributeString:
+		s, ok := value.(string)
+		if !ok {
+			return translate(t, "attribute_type", field, def.Type)
+		}
+
+		length := float64(utf8.RuneCountInString(s))
+		if def.Min != nil && length < *def.Min {
+			return translate(t, "attribute_min_len", field, formatNumber(*def.Min))
+		}
+		if def.Max != nil && length > *def.Max {
+			return translate(t, "attribute_max_len", field, formatNumber(*def.Max))
+		}
+
+		if def.Pattern != "" {
+			if re, err := regexp.Compile(def.Pattern); err == nil && !re.MatchString(s) {
+				return translate(t, "attribute_pattern", field, def.Pattern)
+			}
+		}
+
+		if len(def.Values) > 0 && !contains(def.Values, s) {
+			return translate(t, "attribute_values", field, strings.Join(def.Values, " "))
+		}
+
+	case contract.AttributeNumber, contract.AttributeInteger:
+		n, ok := value.(float64)
+		if !ok || (def.Type == contract.AttributeInteger && n != math.Trunc(n)) {
+			return translate(t, "attribute_type", field, def.Type)
+		}
+
+		if def.Min != nil && n < *def.Min {
+			return translate(t, "attribute_min", field, formatNumber(*def.Min))
+		}
+		if def.Max != nil && n > *def.Max {
+			return translate(t, "attribute_max", field, formatNumber(*def.Max))
+		}
+
+	case contract.AttributeBoolean:
+		if _, ok := value.(bool); !ok {
+			return translate(t, "attribute_type", field, def.Type)
+		}
+	}
+
+	return ""
+}
+
+//name of the attribute in the error messages, like the ones of the map fields
+func attributeField(key string) string {
+	return "Attributes[" + key + "]"
+}
+
+func translate(t ut.Translator, key string, params ...string) string {
+	msg, err := t.T(key, params...)
+	if err != nil {
+		return fmt.Sprintf("%s: %s", params[0], key)
+	}
+
+	return msg
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,26 @@
+package validation
+
+import (
+	"context"
+
+	"github.com/garciacer87/product-api/internal/contract"
+)
+
+//Product validates the product with the validation rules and the schema of its category, returning the translated
+//errors. The rules are relaxed for drafts, which can be incomplete but are identified by their SKU
+func (v *Validator) Product(ctx context.Context, prd contract.Product, schema *contract.CategorySchema) []string {
+	complete := prd.Status != contract.StatusDraft
+
+	var err error
+	if complete {
+		err = v.StructCtx(ctx, prd)
+	} else {
+		err = v.DraftCtx(ctx, prd, "SKU")
+	}
+
+	if err != nil {
+		return v.TranslateCtx(ctx, err)
+	}
+
+	return v.Attributes(ctx, prd.Category, schema, prd.Attributes, complete)
+}
@@ -0,0 +1,280 @@
+//Package validation holds the validation rules of the products shared by the APIs
+package validation
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/garciacer87/product-api/internal/i18n"
+	"github.com/garciacer87/product-api/internal/sku"
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/pt"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	pt_translations "github.com/go-playground/validator/v10/translations/pt"
+)
+
+var (
+	//lowercase words joined by hyphens, e.g.: running-shoes
+	slugRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	//lowercase identifiers of up to 50 characters, e.g.: max_weight
+	attributeKeyRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+)
+
+//Validator validates structs and translates their validation errors into readable messages
+type Validator struct {
+	*validator.Validate
+	uni   *ut.UniversalTranslator
+	rules *sku.Rules
+}
+
+//languages of the validation messages along with their default translations. The first one is used when none
+//of the languages of the context is available
+var languages = []struct {
+	locale   locales.Translator
+	register func(*validator.Validate, ut.Translator) error
+}{
+	{en.New(), en_translations.RegisterDefaultTranslations},
+	{es.New(), es_translations.RegisterDefaultTranslations},
+	{pt.New(), pt_translations.RegisterDefaultTranslations},
+}
+
+//messages of the custom validations and of the ones replacing the default translations, by language
+var messages = map[string]map[string]string{
+	"en": {
+		"required":           "{0} must have a value",
+		"sku":                "{0} does not satisfy the SKU rule {1}: {2}",
+		"notblank":           "{0} is blank",
+		"altimages":          "{0} has an invalid url value",
+		"url":                "{0} is not a valid url value",
+		"httpurl":            "{0} must be an http or https url with a host",
+		"bcp47_language_tag": "{0} is not a valid language tag",
+		"slug":               "{0} must only have lowercase letters, digits and hyphens",
+		"attributekey":       "{0} must be a lowercase letter followed by lowercase letters, digits or underscores",
+	},
+	"es": {
+		"required":           "{0} debe tener un valor",
+		"sku":                "{0} no cumple la regla de SKU {1}: {2}",
+		"notblank":           "{0} está en blanco",
+		"altimages":          "{0} tiene una url inválida",
+		"url":                "{0} no es una url válida",
+		"httpurl":            "{0} debe ser una url http o https con un host",
+		"bcp47_language_tag": "{0} no es una etiqueta de idioma válida",
+		"slug":               "{0} solo puede tener letras minúsculas, dígitos y guiones",
+		"attributekey":       "{0} debe ser una letra minúscula seguida de letras minúsculas, dígitos o guiones bajos",
+	},
+	"pt": {
+		"required":           "{0} deve ter um valor",
+		"sku":                "{0} não satisfaz a regra de SKU {1}: {2}",
+		"notblank":           "{0} está em branco",
+		"altimages":          "{0} tem uma url inválida",
+		"url":                "{0} não é uma url válida",
+		"httpurl":            "{0} deve ser uma url http ou https com um host",
+		"bcp47_language_tag": "{0} não é uma etiqueta de idioma válida",
+		"slug":               "{0} só pode ter letras minúsculas, dígitos e hífens",
+		"attributekey":       "{0} deve ser uma letra minúscula seguida de letras minúsculas, dígitos ou sublinhados",
+	},
+}
+
+type ruleKey struct{}
+
+//WithSKURule selects the rule of the sku fields validated with StructCtx and translated with TranslateCtx
+func WithSKURule(ctx context.Context, rule *sku.Rule) context.Context {
+	return context.WithValue(ctx, ruleKey{}, rule)
+}
+
+//DraftCtx validates a draft like StructCtx, but drafts can be incomplete, so their missing fields are not errors
+//unless they are kept. The fields of the nested structs, like the translations, are still required
+func (v *Validator) DraftCtx(ctx context.Context, s interface{}, keep ...string) error {
+	err := v.StructCtx(ctx, s)
+
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+
+	var left validator.ValidationErrors
+	for _, fe := range errs {
+		missing := fe.Tag() == "required" && strings.Count(fe.StructNamespace(), ".") == 1
+		if missing && !contains(keep, fe.StructField()) {
+			continue
+		}
+		left = append(left, fe)
+	}
+
+	if len(left) == 0 {
+		return nil
+	}
+
+	return left
+}
+
+//Translate retrieves the messages of the validation errors
+func (v *Validator) Translate(err error) []string {
+	return v.TranslateCtx(context.Background(), err)
+}
+
+//TranslateCtx retrieves the messages of the validation errors in the first available language of the context,
+//explaining why the sku does not satisfy the rule of the context
+func (v *Validator) TranslateCtx(ctx context.Context, err error) []string {
+	var (
+		result []string
+		t      = v.translator(ctx)
+	)
+
+	for _, fe := range err.(validator.ValidationErrors) {
+		if fe.Tag() == "sku" {
+			result = append(result, translateSKU(t, fe, v.Rule(ctx)))
+			continue
+		}
+
+		result = append(result, fe.Translate(t))
+	}
+
+	return result
+}
+
+//retrieves the translator of the first available language of the context
+func (v *Validator) translator(ctx context.Context) ut.Translator {
+	t, _ := v.uni.FindTranslator(i18n.Languages(ctx)...)
+	return t
+}
+
+//Rules retrieves the SKU rules of the validator
+func (v *Validator) Rules() *sku.Rules {
+	return v.rules
+}
+
+//Rule retrieves the SKU rule selected in the context, or the default one
+func (v *Validator) Rule(ctx context.Context) *sku.Rule {
+	if rule, ok := ctx.Value(ruleKey{}).(*sku.Rule); ok && rule != nil {
+		return rule
+	}
+
+	return v.rules.Default()
+}
+
+//New creates a validator with the product validation rules and the default SKU rules
+func New() *Validator {
+	return NewWithRules(sku.DefaultRules())
+}
+
+//NewWithRules creates a validator with the product validation rules and the given SKU rules
+func NewWithRules(rules *sku.Rules) *Validator {
+	fallback := languages[0].locale
+	uni := ut.New(fallback, fallback)
+	for _, lang := range languages[1:] {
+		uni.AddTranslator(lang.locale, true)
+	}
+
+	v := validator.New()
+	//the values of a map are only validated along with its keys when they have a tag, so the translations are
+	//tagged with this alias, which keeps the generated docs from taking them for a required field
+	v.RegisterAlias("translation", "required")
+
+	for _, lang := range languages {
+		trans, _ := uni.GetTranslator(lang.locale.Locale())
+		lang.register(v, trans)
+
+		for tag, msg := range messages[lang.locale.Locale()] {
+			tag, msg := tag, msg
+
+			translate := translateField
+			if tag == "sku" {
+				translate = func(ut ut.Translator, fe validator.FieldError) string {
+					return translateSKU(ut, fe, rules.Default())
+				}
+			}
+
+			v.RegisterTranslation(tag, trans, func(ut ut.Translator) error {
+				return ut.Add(tag, msg, true)
+			}, translate)
+		}
+
+		for key, msg := range attributeMessages[lang.locale.Locale()] {
+			trans.Add(key, msg, false)
+		}
+	}
+
+	result := &Validator{v, uni, rules}
+
+	v.RegisterValidationCtx("sku", func(ctx context.Context, fl validator.FieldLevel) bool {
+		return result.Rule(ctx).Check(fl.Field().String()) == nil
+	})
+
+	v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		v := fl.Field().String()
+		return validateBlank(v)
+	})
+
+	v.RegisterValidation("altimages", func(fl validator.FieldLevel) bool {
+		arr := fl.Field().Interface().([]string)
+		return validateAltImages(arr)
+	})
+
+	v.RegisterValidation("httpurl", func(fl validator.FieldLevel) bool {
+		return validateHTTPURL(fl.Field().String())
+	})
+
+	v.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		return slugRegexp.MatchString(fl.Field().String())
+	})
+
+	v.RegisterValidation("attributekey", func(fl validator.FieldLevel) bool {
+		return attributeKeyRegexp.MatchString(fl.Field().String())
+	})
+
+	return result
+}
+
+//translates the error of a field whose message only has the name of the field
+func translateField(ut ut.Translator, fe validator.FieldError) string {
+	t, _ := ut.T(fe.Tag(), fe.Field())
+	return t
+}
+
+//translates the error of a sku field, telling the rule and why the value does not satisfy it
+func translateSKU(ut ut.Translator, fe validator.FieldError, rule *sku.Rule) string {
+	reason := "invalid value"
+	if value, ok := fe.Value().(string); ok {
+		if err := rule.Check(value); err != nil {
+			reason = err.Error()
+		}
+	}
+
+	t, _ := ut.T("sku", fe.Field(), rule.Name, reason)
+	return t
+}
+
+//Validates if the value is not blank. e.g.: "   " or ""
+func validateBlank(v string) bool {
+	return strings.TrimSpace(v) != ""
+}
+
+//Validates if the values of the string slice are valid URL
+//reports if the value is an absolute http or https url with a host
+func validateHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Hostname() != ""
+}
+
+func validateAltImages(arr []string) bool {
+	for _, imgURL := range arr {
+		if _, err := url.ParseRequestURI(imgURL); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBatchSize   = 50
+	defaultMaxAttempts = 8
+	defaultMinBackoff  = 10 * time.Second
+	defaultMaxBackoff  = time.Hour
+)
+
+//Doer abstraction of the http client sending the deliveries
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+//Dispatcher sends the scheduled deliveries to the subscriptions.
+//Failed deliveries are retried with exponential backoff and moved to the dead letter list after MaxAttempts
+type Dispatcher struct {
+	store    db.Webhooks
+	client   Doer
+	interval time.Duration
+
+	BatchSize   int
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+//NewDispatcher creates a dispatcher polling the due deliveries every interval
+func NewDispatcher(store db.Webhooks, client Doer, interval time.Duration) *Dispatcher {
+	return &Dispatcher{
+		store:       store,
+		client:      client,
+		interval:    interval,
+		BatchSize:   defaultBatchSize,
+		MaxAttempts: defaultMaxAttempts,
+		MinBackoff:  defaultMinBackoff,
+		MaxBackoff:  defaultMaxBackoff,
+	}
+}
+
+//Run sends due deliveries until the context is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Flush(ctx); err != nil {
+			logrus.Errorf("webhook dispatcher: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//Flush sends one batch of due deliveries and returns how many succeeded
+func (d *Dispatcher) Flush(ctx context.Context) (int, error) {
+	deliveries, err := d.store.DueDeliveries(d.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	succeeded := 0
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if err := d.send(ctx, delivery); err != nil {
+			var retryAt *time.Time
+			if delivery.Attempts+1 < d.MaxAttempts {
+				t := time.Now().Add(d.backoff(delivery.Attempts))
+				retryAt = &t
+			}
+
+			if retryAt == nil {
+				logrus.Warnf("webhook delivery %d moved to the dead letter list: %v", delivery.ID, err)
+			}
+
+			if err := d.store.MarkDeliveryFailed(delivery.ID, err.Error(), retryAt); err != nil {
+				return succeeded, err
+			}
+			continue
+		}
+
+		if err := d.store.MarkDeliverySucceeded(delivery.ID); err != nil {
+			return succeeded, err
+		}
+		succeeded++
+	}
+
+	return succeeded, nil
+}
+
+//posts the signed payload. Any non 2xx response is a failed attempt
+func (d *Dispatcher) send(ctx context.Context, delivery contract.WebhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, ts, delivery.Payload))
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+//exponential backoff bounded by MaxBackoff
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.MinBackoff
+	for i := 0; i < attempts && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+
+	if wait > d.MaxBackoff {
+		wait = d.MaxBackoff
+	}
+
+	return wait
+}
@@ -0,0 +1,192 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+)
+
+//in memory store of subscriptions and deliveries
+type mockStore struct {
+	db.Webhooks
+	subs       []contract.WebhookSubscription
+	deliveries map[int64]*contract.WebhookDelivery
+}
+
+func newMockStore(subs ...contract.WebhookSubscription) *mockStore {
+	return &mockStore{subs: subs, deliveries: map[int64]*contract.WebhookDelivery{}}
+}
+
+func (m *mockStore) Subscriptions() ([]contract.WebhookSubscription, error) {
+	return m.subs, nil
+}
+
+func (m *mockStore) EnqueueDeliveries(event contract.ProductEvent, ids []int64) error {
+	payload, _ := json.Marshal(&event)
+
+	for _, id := range ids {
+		for _, sub := range m.subs {
+			if sub.ID == id {
+				dID := int64(len(m.deliveries) + 1)
+				m.deliveries[dID] = &contract.WebhookDelivery{
+					ID:             dID,
+					SubscriptionID: id,
+					EventID:        event.ID,
+					EventType:      event.Type,
+					Status:         contract.DeliveryPending,
+					Payload:        payload,
+					URL:            sub.URL,
+					Secret:         sub.Secret,
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *mockStore) DueDeliveries(limit int) ([]contract.WebhookDelivery, error) {
+	due := []contract.WebhookDelivery{}
+	for _, d := range m.deliveries {
+		if d.Status == contract.DeliveryPending {
+			due = append(due, *d)
+		}
+	}
+
+	return due, nil
+}
+
+func (m *mockStore) MarkDeliverySucceeded(id int64) error {
+	m.deliveries[id].Status = contract.DeliveryDelivered
+	m.deliveries[id].Attempts++
+	return nil
+}
+
+func (m *mockStore) MarkDeliveryFailed(id int64, reason string, retryAt *time.Time) error {
+	m.deliveries[id].Attempts++
+	m.deliveries[id].LastError = reason
+	if retryAt == nil {
+		m.deliveries[id].Status = contract.DeliveryDead
+	}
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	tests := map[string]struct {
+		status             string
+		deliveriesExpected int
+	}{
+		"#1: active product":       {status: contract.StatusActive, deliveriesExpected: 1},
+		"#2: draft product":        {status: contract.StatusDraft},
+		"#3: pending review":       {status: contract.StatusPendingReview},
+		"#4: discontinued product": {status: contract.StatusDiscontinued},
+		"#5: archived product":     {status: contract.StatusArchived},
+	}
+
+	for desc, tc := range tests {
+		store := newMockStore(contract.WebhookSubscription{ID: 1, URL: "http://partner.example.com"})
+
+		prd := contract.Product{SKU: "FAL-1000000", Status: tc.status}
+		event := contract.ProductEvent{ID: 1, Type: contract.EventProductUpdated, SKU: prd.SKU, Payload: &prd}
+
+		if err := NewSink(store).Publish(context.Background(), event); err != nil {
+			t.Fatalf("%s: error not expected: %v", desc, err)
+		}
+
+		if len(store.deliveries) != tc.deliveriesExpected {
+			t.Errorf("%s:\n deliveries got: %d\n deliveries expected: %d", desc, len(store.deliveries), tc.deliveriesExpected)
+		}
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	const secret = "0123456789abcdef"
+
+	failing := true
+	partner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+
+		if err := Verify(secret, req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderSignature), body, time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}))
+	defer partner.Close()
+
+	store := newMockStore(
+		contract.WebhookSubscription{ID: 1, URL: partner.URL, Secret: secret, Brands: []string{"brand"}},
+		contract.WebhookSubscription{ID: 2, URL: partner.URL, Secret: secret, SKUPrefixes: []string{"ABC-"}},
+	)
+
+	event := contract.ProductEvent{ID: 10, Type: contract.EventProductUpdated, SKU: "FAL-1000000", Payload: &contract.Product{Brand: "Brand", Status: contract.StatusActive}}
+	if err := NewSink(store).Publish(context.Background(), event); err != nil {
+		t.Fatalf("error not expected: %v", err)
+	}
+
+	if len(store.deliveries) != 1 {
+		t.Fatalf("#1: only the matching subscription must get a delivery. Got: %v", len(store.deliveries))
+	}
+
+	dispatcher := NewDispatcher(store, partner.Client(), time.Second)
+	dispatcher.MaxAttempts = 2
+
+	dispatcher.Flush(context.Background())
+	if d := store.deliveries[1]; d.Status != contract.DeliveryPending || d.Attempts != 1 {
+		t.Errorf("#2: failed delivery must be retried: %+v", d)
+	}
+
+	dispatcher.Flush(context.Background())
+	if d := store.deliveries[1]; d.Status != contract.DeliveryDead {
+		t.Errorf("#3: delivery must be dead after the max attempts: %+v", d)
+	}
+
+	//replayed deliveries are sent again
+	failing = false
+	store.deliveries[1].Status = contract.DeliveryPending
+
+	succeeded, err := dispatcher.Flush(context.Background())
+	if err != nil || succeeded != 1 {
+		t.Errorf("#4: signed delivery must succeed. Succeeded: %v, error: %v", succeeded, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now().Unix()
+	sig := Sign("secret", now, body)
+
+	tests := map[string]struct {
+		secret      string
+		timestamp   string
+		signature   string
+		errExpected bool
+	}{
+		"#1: valid case":         {secret: "secret", timestamp: strconv.FormatInt(now, 10), signature: sig},
+		"#2: wrong secret":       {secret: "other", timestamp: strconv.FormatInt(now, 10), signature: sig, errExpected: true},
+		"#3: tampered timestamp": {secret: "secret", timestamp: strconv.FormatInt(now-1, 10), signature: sig, errExpected: true},
+		"#4: expired timestamp":  {secret: "secret", timestamp: strconv.FormatInt(now-600, 10), signature: Sign("secret", now-600, body), errExpected: true},
+		"#5: invalid timestamp":  {secret: "secret", timestamp: "abc", signature: sig, errExpected: true},
+	}
+
+	for desc, tc := range tests {
+		err := Verify(tc.secret, tc.timestamp, tc.signature, body, 5*time.Minute)
+		isErr := err != nil
+
+		if isErr != tc.errExpected {
+			t.Errorf("%s:\n got Error? %v.\n Error expected? %v.\n Error: %v", desc, isErr, tc.errExpected, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/garciacer87/product-api/internal/contract"
+	"github.com/garciacer87/product-api/internal/db"
+)
+
+//Sink receives the product events from the outbox relay and schedules a delivery for each matching subscription
+type Sink struct {
+	store db.Webhooks
+}
+
+//NewSink creates a sink scheduling deliveries in store
+func NewSink(store db.Webhooks) *Sink {
+	return &Sink{store: store}
+}
+
+//Name of the sink
+func (s *Sink) Name() string {
+	return "webhook subscriptions"
+}
+
+//Publish enqueues a delivery of the event for every subscription matching it. Partners read the products like
+//anonymous users, so the events of the products that are not public are not delivered
+func (s *Sink) Publish(_ context.Context, event contract.ProductEvent) error {
+	if event.Payload == nil || !event.Payload.Public() {
+		return nil
+	}
+
+	subs, err := s.store.Subscriptions()
+	if err != nil {
+		return err
+	}
+
+	var ids []int64
+	for _, sub := range subs {
+		if sub.Matches(event) {
+			ids = append(ids, sub.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return s.store.EnqueueDeliveries(event, ids)
+}
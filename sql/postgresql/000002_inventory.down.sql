@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+    DROP TABLE IF EXISTS public.reservation;
+    DROP TABLE IF EXISTS public.stock;
+   
+END TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,25 @@
+BEGIN TRANSACTION;
+
+	CREATE TABLE public.stock (
+		sku VARCHAR(12) NOT NULL REFERENCES public.product(sku) ON DELETE CASCADE,
+		warehouse VARCHAR(50) NOT NULL,
+		on_hand INTEGER NOT NULL DEFAULT 0 CHECK (on_hand >= 0),
+		reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0),
+		PRIMARY KEY (sku, warehouse),
+		CHECK (reserved <= on_hand)
+	);
+
+	CREATE TABLE public.reservation (
+		id BIGSERIAL PRIMARY KEY,
+		sku VARCHAR(12) NOT NULL,
+		warehouse VARCHAR(50) NOT NULL,
+		quantity INTEGER NOT NULL CHECK (quantity > 0),
+		status VARCHAR(10) NOT NULL DEFAULT 'pending',
+		expires_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY (sku, warehouse) REFERENCES public.stock(sku, warehouse) ON DELETE CASCADE
+	);
+
+	CREATE INDEX reservation_pending_idx ON public.reservation (expires_at) WHERE status = 'pending';
+
+END TRANSACTION;
\ No newline at end of file
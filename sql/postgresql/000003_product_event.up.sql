@@ -0,0 +1,19 @@
+BEGIN TRANSACTION;
+
+	CREATE TABLE public.product_event (
+		id BIGSERIAL PRIMARY KEY,
+		sku VARCHAR(12) NOT NULL,
+		type VARCHAR(20) NOT NULL,
+		version BIGINT NOT NULL,
+		payload JSONB NOT NULL,
+		occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		last_error TEXT,
+		delivered_at TIMESTAMPTZ,
+		UNIQUE (sku, version)
+	);
+
+	CREATE INDEX product_event_pending_idx ON public.product_event (sku, id) WHERE delivered_at IS NULL;
+
+END TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+    DROP TABLE IF EXISTS public.webhook_delivery;
+    DROP TABLE IF EXISTS public.webhook_subscription;
+   
+END TRANSACTION;
\ No newline at end of file
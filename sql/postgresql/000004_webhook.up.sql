@@ -0,0 +1,31 @@
+BEGIN TRANSACTION;
+
+	CREATE TABLE public.webhook_subscription (
+		id BIGSERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		event_types TEXT[] NOT NULL DEFAULT '{}',
+		brands TEXT[] NOT NULL DEFAULT '{}',
+		sku_prefixes TEXT[] NOT NULL DEFAULT '{}',
+		secret TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE public.webhook_delivery (
+		id BIGSERIAL PRIMARY KEY,
+		subscription_id BIGINT NOT NULL REFERENCES public.webhook_subscription(id) ON DELETE CASCADE,
+		event_id BIGINT NOT NULL,
+		event_type VARCHAR(20) NOT NULL,
+		sku VARCHAR(12) NOT NULL,
+		payload JSONB NOT NULL,
+		status VARCHAR(10) NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		last_error TEXT,
+		delivered_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (subscription_id, event_id)
+	);
+
+	CREATE INDEX webhook_delivery_due_idx ON public.webhook_delivery (next_attempt_at) WHERE status = 'pending';
+
+END TRANSACTION;
\ No newline at end of file
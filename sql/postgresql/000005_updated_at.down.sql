@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+    DROP INDEX IF EXISTS public.product_event_deleted_idx;
+    DROP TRIGGER IF EXISTS stock_updated_at ON public.stock;
+    DROP TRIGGER IF EXISTS product_updated_at ON public.product;
+    DROP FUNCTION IF EXISTS public.set_updated_at();
+    ALTER TABLE public.stock DROP COLUMN IF EXISTS updated_at;
+    ALTER TABLE public.product DROP COLUMN IF EXISTS updated_at;
+
+END TRANSACTION;
\ No newline at end of file
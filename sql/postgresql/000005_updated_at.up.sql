@@ -0,0 +1,21 @@
+BEGIN TRANSACTION;
+
+	ALTER TABLE public.product ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+	ALTER TABLE public.stock ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+	CREATE FUNCTION public.set_updated_at() RETURNS TRIGGER AS $$
+	BEGIN
+		NEW.updated_at = clock_timestamp();
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER product_updated_at BEFORE UPDATE ON public.product
+		FOR EACH ROW EXECUTE FUNCTION public.set_updated_at();
+
+	CREATE TRIGGER stock_updated_at BEFORE UPDATE ON public.stock
+		FOR EACH ROW EXECUTE FUNCTION public.set_updated_at();
+
+	CREATE INDEX product_event_deleted_idx ON public.product_event (occurred_at) WHERE type = 'product.deleted';
+
+END TRANSACTION;
\ No newline at end of file
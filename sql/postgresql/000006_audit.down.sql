@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+
+    DROP INDEX IF EXISTS public.product_updated_at_idx;
+
+    ALTER TABLE public.product DROP COLUMN IF EXISTS updated_by;
+    ALTER TABLE public.product DROP COLUMN IF EXISTS created_by;
+    ALTER TABLE public.product DROP COLUMN IF EXISTS created_at;
+
+END TRANSACTION;
\ No newline at end of file
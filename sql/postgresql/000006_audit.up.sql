@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+	ALTER TABLE public.product ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+	ALTER TABLE public.product ADD COLUMN created_by VARCHAR(100) NOT NULL DEFAULT '';
+	ALTER TABLE public.product ADD COLUMN updated_by VARCHAR(100) NOT NULL DEFAULT '';
+
+	--the products created before tracking the creation time keep the time of their last update
+	ALTER TABLE public.product DISABLE TRIGGER product_updated_at;
+	UPDATE public.product SET created_at = updated_at;
+	ALTER TABLE public.product ENABLE TRIGGER product_updated_at;
+
+	CREATE INDEX product_updated_at_idx ON public.product (updated_at);
+
+END TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+    --fails while there are SKUs longer than 12 characters
+    ALTER TABLE public.webhook_delivery ALTER COLUMN sku TYPE VARCHAR(12);
+    ALTER TABLE public.product_event ALTER COLUMN sku TYPE VARCHAR(12);
+    ALTER TABLE public.reservation ALTER COLUMN sku TYPE VARCHAR(12);
+    ALTER TABLE public.stock ALTER COLUMN sku TYPE VARCHAR(12);
+    ALTER TABLE public.product ALTER COLUMN sku TYPE VARCHAR(12);
+
+END TRANSACTION;
\ No newline at end of file
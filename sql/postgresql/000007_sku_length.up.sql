@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+	--SKUs of other marketplaces are longer than the FAL- ones
+	ALTER TABLE public.product ALTER COLUMN sku TYPE VARCHAR(64);
+	ALTER TABLE public.stock ALTER COLUMN sku TYPE VARCHAR(64);
+	ALTER TABLE public.reservation ALTER COLUMN sku TYPE VARCHAR(64);
+	ALTER TABLE public.product_event ALTER COLUMN sku TYPE VARCHAR(64);
+	ALTER TABLE public.webhook_delivery ALTER COLUMN sku TYPE VARCHAR(64);
+
+END TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,11 @@
+BEGIN TRANSACTION;
+
+	--last value allocated to the generated SKUs of each rule. The row of a rule is created the first time it
+	--generates SKUs, starting after the greatest SKU of the rule already stored
+	CREATE TABLE public.sku_sequence (
+		rule VARCHAR(50) PRIMARY KEY,
+		last_value BIGINT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+END TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN TRANSACTION;
+
+    DROP TABLE IF EXISTS public.idempotency_key;
+
+END TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,17 @@
+BEGIN TRANSACTION;
+
+	--responses of the requests with an Idempotency-Key, replayed when the requests are retried. The status is 0
+	--while the request is in progress
+	CREATE TABLE public.idempotency_key (
+		key VARCHAR(255) PRIMARY KEY,
+		fingerprint CHAR(64) NOT NULL,
+		status INTEGER NOT NULL DEFAULT 0,
+		headers JSONB,
+		body BYTEA,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		expires_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE INDEX idempotency_key_expires_at_idx ON public.idempotency_key (expires_at);
+
+END TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN TRANSACTION;
+
+    DROP TABLE IF EXISTS public.product_image;
+
+END TRANSACTION;
\ No newline at end of file
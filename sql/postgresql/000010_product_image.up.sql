@@ -0,0 +1,19 @@
+BEGIN TRANSACTION;
+
+	--images uploaded for the products. The images of deleted products are removed from the storage by a
+	--background job, so they do not reference the product table
+	CREATE TABLE public.product_image (
+		id BIGSERIAL PRIMARY KEY,
+		sku VARCHAR(64) NOT NULL,
+		object_key VARCHAR(255) NOT NULL UNIQUE,
+		thumbnail_key VARCHAR(255) NOT NULL,
+		content_type VARCHAR(50) NOT NULL,
+		width INTEGER NOT NULL,
+		height INTEGER NOT NULL,
+		size BIGINT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX product_image_sku_idx ON public.product_image (sku);
+
+END TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN TRANSACTION;
+
+    DROP TABLE IF EXISTS public.image_check;
+
+END TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,17 @@
+BEGIN TRANSACTION;
+
+	--last check of the image URLs referenced by the products. The status is 0 when the request failed
+	CREATE TABLE public.image_check (
+		url TEXT PRIMARY KEY,
+		ok BOOLEAN NOT NULL,
+		status INTEGER NOT NULL DEFAULT 0,
+		error TEXT,
+		failures INTEGER NOT NULL DEFAULT 0,
+		checked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		failing_since TIMESTAMPTZ
+	);
+
+	CREATE INDEX image_check_checked_at_idx ON public.image_check (checked_at);
+	CREATE INDEX image_check_broken_idx ON public.image_check (url) WHERE NOT ok;
+
+END TRANSACTION;
\ No newline at end of file
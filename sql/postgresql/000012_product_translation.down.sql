@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+    ALTER TABLE public.product
+        DROP COLUMN IF EXISTS translations,
+        DROP COLUMN IF EXISTS description;
+
+END TRANSACTION;
\ No newline at end of file
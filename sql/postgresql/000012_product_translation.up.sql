@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+	--description of the product and its names and descriptions by language tag, e.g.: {"es": {"name": "...", "description": "..."}}
+	ALTER TABLE public.product
+		ADD COLUMN description TEXT NOT NULL DEFAULT '',
+		ADD COLUMN translations JSONB NOT NULL DEFAULT '{}';
+
+END TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,12 @@
+BEGIN TRANSACTION;
+
+    DROP TABLE IF EXISTS public.category_schema;
+
+    DROP INDEX IF EXISTS public.product_attributes_idx;
+    DROP INDEX IF EXISTS public.product_category_idx;
+
+    ALTER TABLE public.product
+        DROP COLUMN IF EXISTS attributes,
+        DROP COLUMN IF EXISTS category;
+
+END TRANSACTION;
\ No newline at end of file
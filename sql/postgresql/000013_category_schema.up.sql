@@ -0,0 +1,19 @@
+BEGIN TRANSACTION;
+
+	--custom attributes of the products, validated with the schema of their category
+	ALTER TABLE public.product
+		ADD COLUMN category TEXT NOT NULL DEFAULT '',
+		ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}';
+
+	CREATE INDEX product_category_idx ON public.product (category);
+	CREATE INDEX product_attributes_idx ON public.product USING GIN (attributes jsonb_path_ops);
+
+	--definitions of the custom attributes allowed for the products of each category
+	CREATE TABLE public.category_schema (
+		category TEXT PRIMARY KEY,
+		attributes JSONB NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_by VARCHAR(100) NOT NULL DEFAULT ''
+	);
+
+END TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,11 @@
+BEGIN TRANSACTION;
+
+    DROP INDEX IF EXISTS public.product_status_idx;
+
+    ALTER TABLE public.product
+        DROP CONSTRAINT IF EXISTS product_status_check,
+        DROP COLUMN IF EXISTS status_updated_by,
+        DROP COLUMN IF EXISTS status_updated_at,
+        DROP COLUMN IF EXISTS status;
+
+END TRANSACTION;
\ No newline at end of file
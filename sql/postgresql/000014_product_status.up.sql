@@ -0,0 +1,17 @@
+BEGIN TRANSACTION;
+
+	--lifecycle status of the products. The existing products were public, so they are active, while the new
+	--ones start as drafts
+	ALTER TABLE public.product
+		ADD COLUMN status TEXT NOT NULL DEFAULT 'active',
+		ADD COLUMN status_updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		ADD COLUMN status_updated_by VARCHAR(100) NOT NULL DEFAULT '';
+
+	ALTER TABLE public.product ALTER COLUMN status SET DEFAULT 'draft';
+
+	ALTER TABLE public.product ADD CONSTRAINT product_status_check
+		CHECK (status IN ('draft', 'pending_review', 'active', 'discontinued', 'archived'));
+
+	CREATE INDEX product_status_idx ON public.product (status);
+
+END TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+    DROP TABLE IF EXISTS public.product_revision;
+
+    ALTER TABLE public.product DROP COLUMN IF EXISTS revision;
+
+END TRANSACTION;
\ No newline at end of file
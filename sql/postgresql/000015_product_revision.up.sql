@@ -0,0 +1,45 @@
+BEGIN TRANSACTION;
+
+	--number of the current revision of the products, increased by every write
+	ALTER TABLE public.product ADD COLUMN revision INT NOT NULL DEFAULT 1;
+
+	--immutable versions of the products, with the last version of the deleted ones. They are kept after the
+	--product is deleted, so the revisions of a SKU created again continue its numbering
+	CREATE TABLE public.product_revision (
+		sku VARCHAR(64) NOT NULL,
+		revision INT NOT NULL,
+		product JSONB NOT NULL,
+		deleted BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		created_by VARCHAR(100) NOT NULL DEFAULT '',
+		PRIMARY KEY (sku, revision)
+	);
+
+	CREATE INDEX product_revision_created_at_idx ON public.product_revision (sku, created_at);
+
+	--the existing products start their history with their current version
+	INSERT INTO public.product_revision (sku, revision, product, created_at, created_by)
+	SELECT sku, 1, jsonb_build_object(
+			'sku', sku,
+			'name', name,
+			'description', description,
+			'brand', brand,
+			'size', COALESCE(size, 0),
+			'price', price,
+			'imageURL', image_url,
+			'altImages', to_jsonb(alt_images),
+			'category', category,
+			'attributes', attributes,
+			'translations', translations,
+			'status', status,
+			'statusUpdatedAt', status_updated_at,
+			'statusUpdatedBy', status_updated_by,
+			'revision', 1,
+			'createdAt', created_at,
+			'createdBy', created_by,
+			'updatedAt', updated_at,
+			'updatedBy', updated_by
+		), updated_at, updated_by
+	FROM public.product;
+
+END TRANSACTION;
\ No newline at end of file
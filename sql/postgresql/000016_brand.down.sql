@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+    DROP INDEX IF EXISTS public.product_brand_id_idx;
+
+    ALTER TABLE public.product DROP COLUMN IF EXISTS brand_id;
+
+    DROP TABLE IF EXISTS public.brand_alias;
+    DROP TABLE IF EXISTS public.brand;
+
+END TRANSACTION;
\ No newline at end of file
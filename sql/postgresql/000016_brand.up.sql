@@ -0,0 +1,95 @@
+BEGIN TRANSACTION;
+
+	--registry of the brands, with their canonical name and the other spellings of the name
+	CREATE TABLE public.brand (
+		id BIGSERIAL PRIMARY KEY,
+		name VARCHAR(50) NOT NULL,
+		slug VARCHAR(60) NOT NULL UNIQUE,
+		aliases TEXT[] NOT NULL DEFAULT '{}',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_by VARCHAR(100) NOT NULL DEFAULT ''
+	);
+
+	--lowercase names and aliases of the brands, with their spaces collapsed, resolving the brands of the products
+	CREATE TABLE public.brand_alias (
+		key VARCHAR(50) PRIMARY KEY,
+		brand_id BIGINT NOT NULL REFERENCES public.brand (id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX brand_alias_brand_id_idx ON public.brand_alias (brand_id);
+
+	--the spellings of the stored brands are grouped by slug, so "Nike", "NIKE" and "nike " become a single brand
+	--named after its most used spelling
+	CREATE TEMPORARY TABLE brand_spelling ON COMMIT DROP AS
+	SELECT name, key, COALESCE(NULLIF(btrim(regexp_replace(key, '[^a-z0-9]+', '-', 'g'), '-'), ''),
+			'brand-' || left(md5(key), 8)) AS slug, products
+	FROM (
+		SELECT btrim(regexp_replace(brand, '\s+', ' ', 'g')) AS name, lower(btrim(regexp_replace(brand, '\s+', ' ', 'g'))) AS key,
+			COUNT(*) AS products
+		FROM public.product
+		WHERE btrim(brand) <> ''
+		GROUP BY 1, 2
+	) spellings;
+
+	INSERT INTO public.brand (name, slug)
+	SELECT (array_agg(name ORDER BY products DESC, name))[1], slug
+	FROM brand_spelling
+	GROUP BY slug;
+
+	INSERT INTO public.brand_alias (key, brand_id)
+	SELECT DISTINCT s.key, b.id
+	FROM brand_spelling s JOIN public.brand b ON b.slug = s.slug;
+
+	UPDATE public.brand b SET aliases = ARRAY(
+		SELECT DISTINCT ON (s.key) s.name
+		FROM brand_spelling s
+		WHERE s.slug = b.slug AND s.key <> lower(b.name)
+		ORDER BY s.key, s.products DESC, s.name
+	);
+
+	--the products reference their brand, and keep its canonical name to be read without joins
+	ALTER TABLE public.product ADD COLUMN brand_id BIGINT REFERENCES public.brand (id);
+
+	--the products written with another spelling take the name of their brand, which is a new revision of them
+	WITH renamed AS (
+		UPDATE public.product p SET brand_id = b.id, brand = b.name, revision = p.revision + 1
+		FROM brand_spelling s JOIN public.brand b ON b.slug = s.slug
+		WHERE s.key = lower(btrim(regexp_replace(p.brand, '\s+', ' ', 'g'))) AND p.brand <> b.name
+		RETURNING p.*
+	)
+	INSERT INTO public.product_revision (sku, revision, product, created_at, created_by)
+	SELECT sku, revision, jsonb_build_object(
+			'sku', sku,
+			'name', name,
+			'description', description,
+			'brand', brand,
+			'brandID', brand_id,
+			'size', COALESCE(size, 0),
+			'price', price,
+			'imageURL', image_url,
+			'altImages', to_jsonb(alt_images),
+			'category', category,
+			'attributes', attributes,
+			'translations', translations,
+			'status', status,
+			'statusUpdatedAt', status_updated_at,
+			'statusUpdatedBy', status_updated_by,
+			'revision', revision,
+			'createdAt', created_at,
+			'createdBy', created_by,
+			'updatedAt', updated_at,
+			'updatedBy', updated_by
+		), updated_at, updated_by
+	FROM renamed;
+
+	--linking the products that already spell their brand right does not change them
+	ALTER TABLE public.product DISABLE TRIGGER product_updated_at;
+	UPDATE public.product p SET brand_id = b.id
+	FROM brand_spelling s JOIN public.brand b ON b.slug = s.slug
+	WHERE p.brand_id IS NULL AND s.key = lower(btrim(regexp_replace(p.brand, '\s+', ' ', 'g')));
+	ALTER TABLE public.product ENABLE TRIGGER product_updated_at;
+
+	CREATE INDEX product_brand_id_idx ON public.product (brand_id);
+
+END TRANSACTION;
\ No newline at end of file
@@ -0,0 +1,9 @@
+//Package postgresql embeds the schema migrations of the PostgreSQL database, so the binary can apply them
+package postgresql
+
+import "embed"
+
+//Migrations SQL files of the schema migrations, named as expected by golang-migrate
+//
+//go:embed *.sql
+var Migrations embed.FS